@@ -0,0 +1,44 @@
+/*
+Contains commands that can be run from the command line instead of the server.
+*/
+package main
+
+import (
+	"log"
+
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/models"
+)
+
+const promoteAdminCommand = "promote-admin"
+
+// Runs the command given in args, e.g. "promote-admin <username>" to make the first admin
+func runCommand(args []string) {
+	switch args[0] {
+	case promoteAdminCommand:
+		if len(args) != 2 {
+			log.Fatalf("Usage: %s <username>", promoteAdminCommand)
+		}
+		db := database.ConnectProdDatabase()
+		if err := promoteAdmin(&database.AdminDB{DB: db}, args[1]); err != nil {
+			log.Fatalf("Unable to promote %s to admin: %v", args[1], err)
+		}
+	default:
+		log.Fatalf("Unknown command %q", args[0])
+	}
+}
+
+type RoleSetter interface {
+	SetUserRole(username string, role string, actorID string) (*models.User, error)
+}
+
+// Makes the user an admin. There is no actor, since the command is run with direct
+// access to the server rather than by a user.
+func promoteAdmin(db RoleSetter, username string) error {
+	user, err := db.SetUserRole(username, models.RoleAdmin, "")
+	if err != nil {
+		return err
+	}
+	log.Printf("Promoted %s to admin", user.Username)
+	return nil
+}
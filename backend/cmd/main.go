@@ -6,20 +6,31 @@ package main
 import (
 	"context"
 	"log"
+	"os"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/gin-contrib/sessions/redis"
 	"github.com/gin-gonic/gin"
 	goredis "github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/contentfilter"
+	"github.com/ryanozx/skillnet/controllers"
 	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/oidc"
+	"github.com/ryanozx/skillnet/workers"
 	"google.golang.org/api/option"
 	"gorm.io/gorm"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
 	serverConfig := initialiseProdServer()
 	serverConfig.setupRoutes()
+	serverConfig.startWorkers()
 	serverConfig.runRouter()
 	log.Println("Setup complete!")
 }
@@ -33,7 +44,17 @@ type serverConfig struct {
 	likesRedis    *goredis.Client
 	commentsRedis *goredis.Client
 	notifRedis    *goredis.Client
+	filterRedis   *goredis.Client
+	recsRedis     *goredis.Client
+	rankingRedis  *goredis.Client
+	timelineRedis *goredis.Client
 	GoogleCloud   *storage.Client
+	oidcProvider  *oidc.Provider
+	mailer        helpers.Mailer
+	// The recommender both serves recommendations and refreshes them in the background
+	recommender *workers.Recommender
+	// The ranker both ranks posts as they change and backfills rankings in the background
+	postRanker *controllers.RedisPostRanker
 }
 
 // Returns a server configuration with the production database (as defined
@@ -46,16 +67,29 @@ func initialiseProdServer() *serverConfig {
 	likesRedis := setupRedis(1)
 	commentsRedis := setupRedis(2)
 	notifRedis := setupRedis(3)
+	filterRedis := setupRedis(4)
+	recsRedis := setupRedis(5)
+	rankingRedis := setupRedis(6)
+	timelineRedis := setupRedis(7)
 	googleCloud := setupGoogleCloud()
+	oidcProvider := setupOIDC()
 	server := serverConfig{
 		db:            db,
 		router:        router,
 		store:         store,
 		notifRedis:    notifRedis,
+		filterRedis:   filterRedis,
+		recsRedis:     recsRedis,
+		rankingRedis:  rankingRedis,
+		timelineRedis: timelineRedis,
 		likesRedis:    likesRedis,
 		commentsRedis: commentsRedis,
 		GoogleCloud:   googleCloud,
+		oidcProvider:  oidcProvider,
+		mailer:        helpers.NewMailer(),
 	}
+	server.recommender = server.newRecommender()
+	server.postRanker = controllers.NewRedisPostRanker(rankingRedis, db)
 	return &server
 }
 
@@ -96,6 +130,121 @@ func setupGoogleCloud() *storage.Client {
 	return client
 }
 
+// Discovers the OpenID Connect provider used for single sign-on. Returns nil if single
+// sign-on is not configured.
+func setupOIDC() *oidc.Provider {
+	env := helpers.RetrieveOIDCEnv()
+	if !env.IsEnabled() {
+		log.Println("OIDC issuer not set, single sign-on disabled")
+		return nil
+	}
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		IssuerURL:    env.IssuerURL,
+		ClientID:     env.ClientID,
+		ClientSecret: env.ClientSecret,
+		RedirectURL:  env.RedirectURL,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up OIDC provider: %v", err)
+	}
+	return provider
+}
+
+// Starts the background workers; they run for as long as the server does
+func (server *serverConfig) startWorkers() {
+	const purgeInterval = time.Hour
+	purger := &workers.AccountPurger{
+		DB:            &database.UserDB{DB: server.db},
+		LikesCache:    server.likesRedis,
+		CommentsCache: server.commentsRedis,
+		Notifications: server.notifRedis,
+		Ranker:        server.postRanker,
+		Timelines:     controllers.NewRedisHomeTimeline(server.timelineRedis, server.db),
+		Files:         &workers.GoogleCloudStorage{Client: server.GoogleCloud},
+		Interval:      purgeInterval,
+	}
+	go purger.Run(context.Background())
+	go server.recommender.Run(context.Background())
+	backfiller := &workers.RankingBackfiller{
+		Posts:  &database.PostDB{DB: server.db},
+		Ranker: server.postRanker,
+		Marker: server.rankingRedis,
+	}
+	go backfiller.Run(context.Background())
+	for _, reconciler := range server.counterReconcilers() {
+		go reconciler.Run(context.Background())
+	}
+}
+
+// Returns the reconcilers that correct cached like and comment counts that have drifted
+// from the database
+func (server *serverConfig) counterReconcilers() []*workers.CounterReconciler {
+	const reconcileInterval = 30 * time.Minute
+	posts := &database.PostDB{DB: server.db}
+	return []*workers.CounterReconciler{
+		{
+			Name:     "likes",
+			Posts:    posts,
+			DB:       &database.LikeDB{DB: server.db},
+			Cache:    server.likesRedis,
+			Key:      helpers.LikeCountKey,
+			Interval: reconcileInterval,
+		},
+		{
+			Name:     "comments",
+			Posts:    posts,
+			DB:       &database.CommentDB{DB: server.db},
+			Cache:    server.commentsRedis,
+			Key:      helpers.CommentCountKey,
+			Interval: reconcileInterval,
+		},
+	}
+}
+
+// Returns the recommender that suggests users, communities and projects to each user
+func (server *serverConfig) newRecommender() *workers.Recommender {
+	const recommendationInterval = 6 * time.Hour
+	return &workers.Recommender{
+		DB:       &database.RecommendationDB{DB: server.db},
+		Store:    &workers.RedisRecommendations{Client: server.recsRedis},
+		Interval: recommendationInterval,
+	}
+}
+
+// Returns the exporter that builds archives of user data
+func (server *serverConfig) dataExporter() *workers.DataExporter {
+	return &workers.DataExporter{
+		DB:            &database.ExportDB{DB: server.db},
+		Store:         &workers.GoogleCloudStorage{Client: server.GoogleCloud},
+		Notifications: &workers.RedisNotifications{Client: server.notifRedis},
+	}
+}
+
+// Returns the filters that posts and comments pass through, in the order they are run.
+// Duplicates are checked last so that rejected content is not remembered.
+func (server *serverConfig) contentFilter() contentfilter.Chain {
+	env := helpers.RetrieveContentFilterEnv()
+	chain := contentfilter.Chain{
+		&contentfilter.MaxLength{Max: env.MaxLength},
+	}
+	if len(env.BannedWords) > 0 {
+		bannedWords, err := contentfilter.NewBannedWords(env.BannedWords)
+		if err != nil {
+			log.Fatalf("Failed to set up banned words filter: %v", err)
+		}
+		chain = append(chain, bannedWords)
+	}
+	return append(chain,
+		&contentfilter.LinkLimit{
+			Users:         &database.UserDB{DB: server.db},
+			MaxLinks:      env.MaxLinks,
+			MinAccountAge: env.NewAccountAge,
+			Now:           time.Now,
+		},
+		&contentfilter.Duplicate{Store: server.filterRedis, Window: env.DuplicateWindow},
+	)
+}
+
 func (server *serverConfig) runRouter() {
 	env := helpers.RetrieveWebAppEnv()
 	routerAddress := env.Address()
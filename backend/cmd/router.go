@@ -5,6 +5,7 @@ file to add additional routes where necessary.
 package main
 
 import (
+	"expvar"
 	"log"
 
 	"github.com/gin-contrib/cors"
@@ -12,8 +13,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"github.com/ryanozx/skillnet/controllers"
+	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/middleware"
+	"github.com/ryanozx/skillnet/models"
 )
 
 // Sets up the routes on the server router
@@ -27,6 +30,7 @@ func (s *serverConfig) setupRoutes() {
 		DB:          s.db,
 		GoogleCloud: s.GoogleCloud,
 		NotifRedis:  s.notifRedis,
+		Mailer:      s.mailer,
 	}
 
 	// Sets the ClientAddress and BackendAddress global variables in the models package so that the env file
@@ -34,6 +38,15 @@ func (s *serverConfig) setupRoutes() {
 	helpers.SetModelClientAddress()
 	helpers.SetModelBackendAddress()
 
+	// Posts and comments are checked by the content filter before they are saved
+	apiEnv.InitialiseContentFilter(s.contentFilter())
+	// Posts are ranked for the hot and top feeds whenever their like and comment counts
+	// change, so the ranker is set up before the likes and comments APIs
+	apiEnv.InitialisePostRanker(s.postRanker)
+	// Posts are added to home feeds when they are created, and users' home feeds are
+	// backfilled when they follow a user or join a community
+	apiEnv.InitialiseHomeTimeline(controllers.NewRedisHomeTimeline(s.timelineRedis, s.db))
+
 	// Register routes - routes are grouped by features for greater
 	// modularity
 	setupPostAPI(routerGroup, apiEnv)
@@ -46,6 +59,19 @@ func (s *serverConfig) setupRoutes() {
 	setupCommunityAPI(routerGroup, apiEnv)
 	setupProjectAPI(routerGroup, apiEnv)
 	setupSearchAPI(routerGroup, apiEnv)
+	setupTokenAPI(routerGroup, apiEnv)
+	setupExportAPI(routerGroup, apiEnv, s.dataExporter())
+	setupAdminAPI(routerGroup, apiEnv)
+	setupReportAPI(routerGroup, apiEnv)
+	setupBlockAPI(routerGroup, apiEnv)
+	setupFollowAPI(routerGroup, apiEnv)
+	setupSkillAPI(routerGroup, apiEnv)
+	setupRecruitmentAPI(routerGroup, apiEnv)
+	setupRecommendationAPI(routerGroup, apiEnv, s.recommender)
+	setupBookmarkAPI(routerGroup, apiEnv)
+	if s.oidcProvider != nil {
+		setupOIDCAPI(routerGroup, apiEnv, s.oidcProvider)
+	}
 }
 
 // Sets up CORS to allow the frontend app to access resources
@@ -66,22 +92,39 @@ func (s *serverConfig) RouterGroups() *RouterGroups {
 	// All publicly accessible routes are prefixed with "/",
 	// while all non-publicly accessible routes are prefixed with "/auth"
 	publicGroup := s.router.Group("/")
-	privateGroup := s.router.Group("/auth")
 
 	// add middleware - for instance, middleware to check that the user
-	// has a valid session in order to access non-publicly accessible routes
-	privateGroup.Use(middleware.AuthRequired)
+	// has a valid session or personal access token in order to access
+	// non-publicly accessible routes
+	authRequired := middleware.AuthRequired(&database.TokenDB{DB: s.db}, &database.UserDB{DB: s.db})
+
+	// Non-publicly accessible routes are further grouped by the scope that a
+	// personal access token requires to write to them; sessions are not restricted
+	privateGroup := s.router.Group("/auth", authRequired, middleware.RequireScope(""))
+	postScopedGroup := s.router.Group("/auth", authRequired, middleware.RequireScope(models.ScopeWritePosts))
+	projectScopedGroup := s.router.Group("/auth", authRequired, middleware.RequireScope(models.ScopeWriteProjects))
+	sessionOnlyGroup := s.router.Group("/auth", authRequired, middleware.RequireSession)
+	adminGroup := s.router.Group("/auth"+helpers.AdminPath, authRequired, middleware.RequireSession,
+		middleware.RequireRole(models.RoleAdmin))
 
 	routerGroup := RouterGroups{
-		public:  publicGroup,
-		private: privateGroup,
+		public:        publicGroup,
+		private:       privateGroup,
+		postScoped:    postScopedGroup,
+		projectScoped: projectScopedGroup,
+		sessionOnly:   sessionOnlyGroup,
+		admin:         adminGroup,
 	}
 	return &routerGroup
 }
 
 type RouterGroups struct {
-	public  *gin.RouterGroup
-	private *gin.RouterGroup
+	public        *gin.RouterGroup
+	private       *gin.RouterGroup
+	postScoped    *gin.RouterGroup
+	projectScoped *gin.RouterGroup
+	sessionOnly   *gin.RouterGroup
+	admin         *gin.RouterGroup
 }
 
 func (rg *RouterGroups) Public() *gin.RouterGroup {
@@ -92,13 +135,36 @@ func (rg *RouterGroups) Private() *gin.RouterGroup {
 	return rg.private
 }
 
-// At present we use only two router groups as only the AuthRequired middleware is
-// used, hence there needs to be a distinction between public and private routes.
-// Should any subset of routes require additional middleware, the router groups can
-// be added
+func (rg *RouterGroups) PostScoped() *gin.RouterGroup {
+	return rg.postScoped
+}
+
+func (rg *RouterGroups) ProjectScoped() *gin.RouterGroup {
+	return rg.projectScoped
+}
+
+func (rg *RouterGroups) SessionOnly() *gin.RouterGroup {
+	return rg.sessionOnly
+}
+
+func (rg *RouterGroups) Admin() *gin.RouterGroup {
+	return rg.admin
+}
+
+// Public routes require no authentication, while the remaining router groups require
+// the AuthRequired middleware. Private routes can only be read with a personal access
+// token, PostScoped and ProjectScoped routes can be written to with a token that has
+// the write:posts and write:projects scope respectively, and SessionOnly routes cannot
+// be accessed with a token at all. Admin routes are prefixed with "/auth/admin" and can
+// only be accessed by admins with a session. Should any subset of routes require
+// additional middleware, the router groups can be added
 type RouterGrouper interface {
 	Public() *gin.RouterGroup
 	Private() *gin.RouterGroup
+	PostScoped() *gin.RouterGroup
+	ProjectScoped() *gin.RouterGroup
+	SessionOnly() *gin.RouterGroup
+	Admin() *gin.RouterGroup
 }
 
 // Sets up Post API
@@ -118,17 +184,25 @@ type PostAPIer interface {
 	CreatePost(*gin.Context)
 	UpdatePost(*gin.Context)
 	DeletePost(*gin.Context)
+	PinPost(*gin.Context)
+	UnpinPost(*gin.Context)
+	// Generates the feed of posts by followed users and in joined communities
+	GetHomeFeed(*gin.Context)
 }
 
 func registerPostRoutes(rg RouterGrouper, api PostAPIer) {
 	const postPathWithID = helpers.PostPath + "/:" + helpers.PostIDKey
+	const pinPathWithFeed = postPathWithID + helpers.PinPath + "/:" + helpers.PinFeedKey
 
 	// Private routes
-	rg.Private().GET(helpers.PostPath, api.GetPosts)
-	rg.Private().GET(postPathWithID, api.GetPostByID)
-	rg.Private().POST(helpers.PostPath, api.CreatePost)
-	rg.Private().PATCH(postPathWithID, api.UpdatePost)
-	rg.Private().DELETE(postPathWithID, api.DeletePost)
+	rg.PostScoped().GET(helpers.PostPath, api.GetPosts)
+	rg.PostScoped().GET(helpers.HomeFeedPath, api.GetHomeFeed)
+	rg.PostScoped().GET(postPathWithID, api.GetPostByID)
+	rg.PostScoped().POST(helpers.PostPath, api.CreatePost)
+	rg.PostScoped().PATCH(postPathWithID, api.UpdatePost)
+	rg.PostScoped().DELETE(postPathWithID, api.DeletePost)
+	rg.PostScoped().POST(pinPathWithFeed, api.PinPost)
+	rg.PostScoped().DELETE(pinPathWithFeed, api.UnpinPost)
 }
 
 // Sets up User API
@@ -147,6 +221,11 @@ type UserAPIer interface {
 	GetSelfProfile(*gin.Context)
 	CreateUser(*gin.Context)
 	UpdateUser(*gin.Context)
+	DeleteUser(*gin.Context)
+	UpdatePassword(*gin.Context)
+	UpdateEmail(*gin.Context)
+	VerifyEmail(*gin.Context)
+	UpdateUsername(*gin.Context)
 }
 
 func registerUserRoutes(rg RouterGrouper, api UserAPIer) {
@@ -156,6 +235,12 @@ func registerUserRoutes(rg RouterGrouper, api UserAPIer) {
 	rg.Private().GET("/users/:username", api.GetProfile)
 	rg.Private().GET(userPath, api.GetSelfProfile)
 	rg.Private().PATCH(userPath, api.UpdateUser)
+	// Account settings cannot be changed with personal access tokens
+	rg.SessionOnly().DELETE(userPath, api.DeleteUser)
+	rg.SessionOnly().PATCH(userPath+"/password", api.UpdatePassword)
+	rg.SessionOnly().PATCH(userPath+"/email", api.UpdateEmail)
+	rg.SessionOnly().PATCH(userPath+"/username", api.UpdateUsername)
+	rg.Public().POST(userPath+"/email/verify", api.VerifyEmail)
 }
 
 // Sets up Auth API
@@ -177,7 +262,260 @@ func registerAuthRoutes(rg RouterGrouper, api AuthAPIer) {
 	rg.Public().GET("/login", api.GetLogin)
 	rg.Public().POST("/login", api.PostLogin)
 
-	rg.Private().POST("/logout", api.PostLogout)
+	rg.SessionOnly().POST("/logout", api.PostLogout)
+}
+
+// Sets up single sign-on through an OpenID Connect provider
+func setupOIDCAPI(rg RouterGrouper, api OIDCAPIer, provider controllers.OIDCAuthenticator) {
+	api.InitialiseOIDCHandler(provider)
+	registerOIDCRoutes(rg, api)
+}
+
+// OIDCAPIer is an interface that describes the methods required to implement
+// single sign-on
+type OIDCAPIer interface {
+	InitialiseOIDCHandler(controllers.OIDCAuthenticator)
+	GetOIDCStart(*gin.Context)
+	GetOIDCCallback(*gin.Context)
+	PostOIDCLink(*gin.Context)
+}
+
+func registerOIDCRoutes(rg RouterGrouper, api OIDCAPIer) {
+	rg.Public().GET(helpers.OIDCStartPath, api.GetOIDCStart)
+	rg.Public().GET(helpers.OIDCCallbackPath, api.GetOIDCCallback)
+	rg.Public().POST(helpers.OIDCLinkPath, api.PostOIDCLink)
+}
+
+// Sets up exporting of user data
+func setupExportAPI(rg RouterGrouper, api ExportAPIer, exporter controllers.DataExporter) {
+	api.InitialiseExportHandler(exporter)
+	registerExportRoutes(rg, api)
+}
+
+// ExportAPIer is an interface that describes the methods required to implement
+// exporting of user data
+type ExportAPIer interface {
+	InitialiseExportHandler(controllers.DataExporter)
+	PostExport(*gin.Context)
+	GetExportDownload(*gin.Context)
+}
+
+func registerExportRoutes(rg RouterGrouper, api ExportAPIer) {
+	rg.SessionOnly().POST(helpers.DataExportPath, api.PostExport)
+	rg.Public().GET(helpers.DataExportPath+"/download", api.GetExportDownload)
+}
+
+// Sets up site administration
+func setupAdminAPI(rg RouterGrouper, api AdminAPIer) {
+	api.InitialiseAdminHandler()
+	registerAdminRoutes(rg, api)
+}
+
+// AdminAPIer is an interface that describes the methods required to implement
+// site administration
+type AdminAPIer interface {
+	InitialiseAdminHandler()
+	GetAuditLogs(*gin.Context)
+	GetAdminUsers(*gin.Context)
+	SuspendUser(*gin.Context)
+	UnsuspendUser(*gin.Context)
+	ForceLogoutUser(*gin.Context)
+	ResetEmailVerification(*gin.Context)
+	ImpersonateUser(*gin.Context)
+}
+
+func registerAdminRoutes(rg RouterGrouper, api AdminAPIer) {
+	adminUserPath := helpers.AdminUserPath + "/:" + helpers.UsernameKey
+	rg.Admin().GET(helpers.AuditLogPath, api.GetAuditLogs)
+	rg.Admin().GET(helpers.AdminUserPath, api.GetAdminUsers)
+	rg.Admin().POST(adminUserPath+"/suspension", api.SuspendUser)
+	rg.Admin().DELETE(adminUserPath+"/suspension", api.UnsuspendUser)
+	rg.Admin().POST(adminUserPath+"/logout", api.ForceLogoutUser)
+	rg.Admin().DELETE(adminUserPath+"/email-change", api.ResetEmailVerification)
+	rg.Admin().POST(adminUserPath+"/impersonation", api.ImpersonateUser)
+	// Metrics published by the server and its workers, such as counter drift
+	rg.Admin().GET(helpers.MetricsPath, gin.WrapH(expvar.Handler()))
+}
+
+// Sets up blocking and muting of users
+func setupBlockAPI(rg RouterGrouper, api BlockAPIer) {
+	api.InitialiseBlockHandler()
+	registerBlockRoutes(rg, api)
+}
+
+// BlockAPIer is an interface that describes the methods required to implement
+// blocking and muting of users
+type BlockAPIer interface {
+	InitialiseBlockHandler()
+	BlockUser(*gin.Context)
+	UnblockUser(*gin.Context)
+	GetBlockedUsers(*gin.Context)
+	MuteUser(*gin.Context)
+	UnmuteUser(*gin.Context)
+	GetMutedUsers(*gin.Context)
+}
+
+func registerBlockRoutes(rg RouterGrouper, api BlockAPIer) {
+	const userPathWithUsername = "/users/:" + helpers.UsernameKey
+	rg.Private().GET(helpers.BlockListPath, api.GetBlockedUsers)
+	rg.Private().GET(helpers.MuteListPath, api.GetMutedUsers)
+	rg.Private().POST(userPathWithUsername+helpers.BlockPath, api.BlockUser)
+	rg.Private().DELETE(userPathWithUsername+helpers.BlockPath, api.UnblockUser)
+	rg.Private().POST(userPathWithUsername+helpers.MutePath, api.MuteUser)
+	rg.Private().DELETE(userPathWithUsername+helpers.MutePath, api.UnmuteUser)
+}
+
+// Sets up following of users
+func setupFollowAPI(rg RouterGrouper, api FollowAPIer) {
+	api.InitialiseFollowHandler()
+	registerFollowRoutes(rg, api)
+}
+
+// FollowAPIer is an interface that describes the methods required to implement
+// following of users
+type FollowAPIer interface {
+	InitialiseFollowHandler()
+	FollowUser(*gin.Context)
+	UnfollowUser(*gin.Context)
+	GetFollowedUsers(*gin.Context)
+}
+
+func registerFollowRoutes(rg RouterGrouper, api FollowAPIer) {
+	const userPathWithUsername = "/users/:" + helpers.UsernameKey
+	rg.Private().GET(helpers.FollowingListPath, api.GetFollowedUsers)
+	rg.Private().POST(userPathWithUsername+helpers.FollowPath, api.FollowUser)
+	rg.Private().DELETE(userPathWithUsername+helpers.FollowPath, api.UnfollowUser)
+}
+
+// Sets up the skills listed on user profiles and their endorsements
+func setupSkillAPI(rg RouterGrouper, api SkillAPIer) {
+	api.InitialiseSkillHandler()
+	registerSkillRoutes(rg, api)
+}
+
+// SkillAPIer is an interface that describes the methods required to implement
+// listing skills on user profiles and endorsing them
+type SkillAPIer interface {
+	InitialiseSkillHandler()
+	UpdateUserSkills(*gin.Context)
+	EndorseSkill(*gin.Context)
+	UnendorseSkill(*gin.Context)
+}
+
+func registerSkillRoutes(rg RouterGrouper, api SkillAPIer) {
+	endorsementPath := "/users/:" + helpers.UsernameKey + helpers.SkillsPath + "/:" + helpers.SkillIDKey + helpers.EndorsementPath
+	rg.Private().PUT(helpers.UserSkillsPath, api.UpdateUserSkills)
+	rg.Private().POST(endorsementPath, api.EndorseSkill)
+	rg.Private().DELETE(endorsementPath, api.UnendorseSkill)
+}
+
+// Sets up open roles on projects and applications to them
+func setupRecruitmentAPI(rg RouterGrouper, api RecruitmentAPIer) {
+	api.InitialiseRecruitmentHandler()
+	registerRecruitmentRoutes(rg, api)
+}
+
+// RecruitmentAPIer is an interface that describes the methods required to implement
+// posting open roles on projects, applying to them and reviewing applications
+type RecruitmentAPIer interface {
+	InitialiseRecruitmentHandler()
+	GetOpenRoles(*gin.Context)
+	CreateOpenRole(*gin.Context)
+	UpdateOpenRole(*gin.Context)
+	DeleteOpenRole(*gin.Context)
+	ApplyToRole(*gin.Context)
+	GetProjectApplications(*gin.Context)
+	GetUserApplications(*gin.Context)
+	ReviewApplication(*gin.Context)
+}
+
+func registerRecruitmentRoutes(rg RouterGrouper, api RecruitmentAPIer) {
+	const projectPathWithID = helpers.ProjectPath + "/:" + helpers.ProjectIDKey
+	const openRolePathWithID = projectPathWithID + helpers.OpenRolePath + "/:" + helpers.OpenRoleIDKey
+	const applicationPathWithID = projectPathWithID + helpers.ApplicationPath + "/:" + helpers.ApplicationIDKey
+	rg.ProjectScoped().GET(projectPathWithID+helpers.OpenRolePath, api.GetOpenRoles)
+	rg.ProjectScoped().POST(projectPathWithID+helpers.OpenRolePath, api.CreateOpenRole)
+	rg.ProjectScoped().PUT(openRolePathWithID, api.UpdateOpenRole)
+	rg.ProjectScoped().DELETE(openRolePathWithID, api.DeleteOpenRole)
+	rg.ProjectScoped().POST(openRolePathWithID+helpers.ApplicationPath, api.ApplyToRole)
+	rg.ProjectScoped().GET(projectPathWithID+helpers.ApplicationPath, api.GetProjectApplications)
+	rg.ProjectScoped().POST(applicationPathWithID+helpers.ApplicationReviewPath, api.ReviewApplication)
+	rg.ProjectScoped().GET(helpers.UserApplicationPath, api.GetUserApplications)
+}
+
+// Sets up recommended users, communities and projects
+func setupRecommendationAPI(rg RouterGrouper, api RecommendationAPIer, recommender controllers.Recommender) {
+	api.InitialiseRecommendationHandler(recommender)
+	registerRecommendationRoutes(rg, api)
+}
+
+// RecommendationAPIer is an interface that describes the methods required to implement
+// retrieving and dismissing recommendations
+type RecommendationAPIer interface {
+	InitialiseRecommendationHandler(controllers.Recommender)
+	GetRecommendations(*gin.Context)
+	DismissRecommendation(*gin.Context)
+}
+
+func registerRecommendationRoutes(rg RouterGrouper, api RecommendationAPIer) {
+	rg.Private().GET(helpers.RecommendationPath, api.GetRecommendations)
+	rg.Private().POST(helpers.RecommendationPath+helpers.DismissalPath, api.DismissRecommendation)
+}
+
+// Sets up bookmarks and bookmark collections
+func setupBookmarkAPI(rg RouterGrouper, api BookmarkAPIer) {
+	api.InitialiseBookmarkHandler()
+	registerBookmarkRoutes(rg, api)
+}
+
+// BookmarkAPIer is an interface that describes the methods required to implement
+// bookmarking posts and organising and sharing bookmark collections
+type BookmarkAPIer interface {
+	InitialiseBookmarkHandler()
+	PostBookmark(*gin.Context)
+	DeleteBookmark(*gin.Context)
+	GetBookmarks(*gin.Context)
+	GetBookmarkCollections(*gin.Context)
+	CreateBookmarkCollection(*gin.Context)
+	UpdateBookmarkCollection(*gin.Context)
+	DeleteBookmarkCollection(*gin.Context)
+	GetSharedBookmarkCollection(*gin.Context)
+}
+
+func registerBookmarkRoutes(rg RouterGrouper, api BookmarkAPIer) {
+	bookmarkPathWithID := helpers.BookmarkPath + "/:" + helpers.PostIDKey
+	collectionPathWithID := helpers.BookmarkCollectionPath + "/:" + helpers.BookmarkCollectionIDKey
+
+	rg.Private().GET(helpers.BookmarkPath, api.GetBookmarks)
+	rg.PostScoped().POST(bookmarkPathWithID, api.PostBookmark)
+	rg.PostScoped().DELETE(bookmarkPathWithID, api.DeleteBookmark)
+	rg.Private().GET(helpers.BookmarkCollectionPath, api.GetBookmarkCollections)
+	rg.PostScoped().POST(helpers.BookmarkCollectionPath, api.CreateBookmarkCollection)
+	rg.PostScoped().PATCH(collectionPathWithID, api.UpdateBookmarkCollection)
+	rg.PostScoped().DELETE(collectionPathWithID, api.DeleteBookmarkCollection)
+	rg.Public().GET(helpers.SharedCollectionPath+"/:"+helpers.ShareTokenKey, api.GetSharedBookmarkCollection)
+}
+
+// Sets up reporting of content and the moderation queue
+func setupReportAPI(rg RouterGrouper, api ReportAPIer) {
+	api.InitialiseReportHandler()
+	registerReportRoutes(rg, api)
+}
+
+// ReportAPIer is an interface that describes the methods required to implement
+// reporting of content and moderation of reports
+type ReportAPIer interface {
+	InitialiseReportHandler()
+	PostReport(*gin.Context)
+	GetReports(*gin.Context)
+	ResolveReport(*gin.Context)
+}
+
+func registerReportRoutes(rg RouterGrouper, api ReportAPIer) {
+	moderationReportPath := helpers.ModerationPath + helpers.ReportPath
+	rg.Private().POST(helpers.ReportPath, api.PostReport)
+	rg.SessionOnly().GET(moderationReportPath, api.GetReports)
+	rg.SessionOnly().POST(moderationReportPath+"/:"+helpers.ReportIDKey+"/resolution", api.ResolveReport)
 }
 
 func setupPhotoAPI(rg RouterGrouper, api PhotoAPIer) {
@@ -209,8 +547,8 @@ type LikeAPIer interface {
 func registerLikeRoutes(rg RouterGrouper, api LikeAPIer) {
 	const likePathWithID = "/likes/:" + helpers.PostIDKey
 
-	rg.Private().POST(likePathWithID, api.PostLike)
-	rg.Private().DELETE(likePathWithID, api.DeleteLike)
+	rg.PostScoped().POST(likePathWithID, api.PostLike)
+	rg.PostScoped().DELETE(likePathWithID, api.DeleteLike)
 }
 
 func setupCommentAPI(rg RouterGrouper, api CommentAPIer, client *redis.Client) {
@@ -231,10 +569,10 @@ func registerCommentRoutes(rg RouterGrouper, api CommentAPIer) {
 	const commentRouteWithID = helpers.CommentPath + "/:" + helpers.CommentIDKey
 
 	// Private routes
-	rg.Private().GET(helpers.CommentPath, api.GetComments)
-	rg.Private().POST(helpers.CommentPath, api.CreateComment)
-	rg.Private().PATCH(commentRouteWithID, api.UpdateComment)
-	rg.Private().DELETE(commentRouteWithID, api.DeleteComment)
+	rg.PostScoped().GET(helpers.CommentPath, api.GetComments)
+	rg.PostScoped().POST(helpers.CommentPath, api.CreateComment)
+	rg.PostScoped().PATCH(commentRouteWithID, api.UpdateComment)
+	rg.PostScoped().DELETE(commentRouteWithID, api.DeleteComment)
 }
 
 func setupNotificationAPI(rg RouterGrouper, api NotificationAPIer, client *redis.Client) {
@@ -257,6 +595,8 @@ type CommunityAPIer interface {
 	GetCommunities(*gin.Context)
 	GetCommunityByName(*gin.Context)
 	UpdateCommunity(*gin.Context)
+	JoinCommunity(*gin.Context)
+	LeaveCommunity(*gin.Context)
 }
 
 func setupCommunityAPI(rg RouterGrouper, api CommunityAPIer) {
@@ -270,6 +610,8 @@ func registerCommunityRoutes(rg RouterGrouper, api CommunityAPIer) {
 	rg.Private().GET(communityPathWithName, api.GetCommunityByName)
 	rg.Private().POST(helpers.CommunityPath, api.CreateCommunity)
 	rg.Private().PATCH(communityPathWithName, api.UpdateCommunity)
+	rg.Private().POST(communityPathWithName+helpers.MembershipPath, api.JoinCommunity)
+	rg.Private().DELETE(communityPathWithName+helpers.MembershipPath, api.LeaveCommunity)
 }
 
 type ProjectAPIer interface {
@@ -279,6 +621,8 @@ type ProjectAPIer interface {
 	GetProjects(*gin.Context)
 	GetProjectByID(*gin.Context)
 	UpdateProject(*gin.Context)
+	UpdateProjectSkills(*gin.Context)
+	GetProjectsMatchingSkills(*gin.Context)
 }
 
 func setupProjectAPI(rg RouterGrouper, api ProjectAPIer) {
@@ -288,21 +632,50 @@ func setupProjectAPI(rg RouterGrouper, api ProjectAPIer) {
 
 func registerProjectRoutes(rg RouterGrouper, api ProjectAPIer) {
 	const projectPathWithID = helpers.ProjectPath + "/:" + helpers.ProjectIDKey
-	rg.Private().GET(helpers.ProjectPath, api.GetProjects)
-	rg.Private().GET(projectPathWithID, api.GetProjectByID)
-	rg.Private().POST(helpers.ProjectPath, api.CreateProject)
-	rg.Private().DELETE(projectPathWithID, api.DeleteProject)
-	rg.Private().PATCH(projectPathWithID, api.UpdateProject)
+	rg.ProjectScoped().GET(helpers.ProjectPath, api.GetProjects)
+	rg.ProjectScoped().GET(projectPathWithID, api.GetProjectByID)
+	rg.ProjectScoped().POST(helpers.ProjectPath, api.CreateProject)
+	rg.ProjectScoped().DELETE(projectPathWithID, api.DeleteProject)
+	rg.ProjectScoped().PATCH(projectPathWithID, api.UpdateProject)
+	rg.ProjectScoped().PUT(projectPathWithID+helpers.SkillsPath, api.UpdateProjectSkills)
+	rg.ProjectScoped().GET(helpers.ProjectPath+helpers.ProjectMatchingPath, api.GetProjectsMatchingSkills)
 }
 
 func setupSearchAPI(rg RouterGrouper, api SearchAPIer) {
+	api.InitialiseSearchHandler()
 	registerSearchRoutes(rg, api)
 }
 
 type SearchAPIer interface {
+	InitialiseSearchHandler()
 	GetSearchResults(*gin.Context)
+	GetSearchSuggestions(*gin.Context)
 }
 
 func registerSearchRoutes(rg RouterGrouper, api SearchAPIer) {
-	rg.Private().GET("/search", api.GetSearchResults)
+	rg.Private().GET(helpers.SearchPath, api.GetSearchResults)
+	rg.Private().GET(helpers.SearchPath+helpers.SuggestPath, api.GetSearchSuggestions)
+}
+
+func setupTokenAPI(rg RouterGrouper, api TokenAPIer) {
+	api.InitialiseTokenHandler()
+	registerTokenRoutes(rg, api)
+}
+
+// TokenAPIer is an interface that describes the methods required to manage
+// personal access tokens
+type TokenAPIer interface {
+	InitialiseTokenHandler()
+	CreateToken(*gin.Context)
+	GetTokens(*gin.Context)
+	DeleteToken(*gin.Context)
+}
+
+func registerTokenRoutes(rg RouterGrouper, api TokenAPIer) {
+	const tokenPathWithID = helpers.TokenPath + "/:" + helpers.TokenIDKey
+
+	// Tokens can only be managed from a logged in session
+	rg.SessionOnly().GET(helpers.TokenPath, api.GetTokens)
+	rg.SessionOnly().POST(helpers.TokenPath, api.CreateToken)
+	rg.SessionOnly().DELETE(tokenPathWithID, api.DeleteToken)
 }
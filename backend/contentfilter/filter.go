@@ -0,0 +1,102 @@
+/*
+Contains the filters that user-submitted text passes through before it is saved. Each
+filter decides whether the text is allowed, held for review by a moderator, or
+rejected outright; a Chain runs the filters in order and returns the strictest
+decision.
+*/
+package contentfilter
+
+import (
+	"context"
+	"log"
+)
+
+// Verdict is a filter's decision on a piece of content, ordered from least to most strict
+type Verdict int
+
+const (
+	Allow Verdict = iota
+	Hold
+	Reject
+)
+
+// Kinds of content that are filtered
+const (
+	KindPost    = "post"
+	KindComment = "comment"
+)
+
+// Content is the text being checked along with who wrote it
+type Content struct {
+	Kind     string
+	AuthorID string
+	Text     string
+	IsEdit   bool // Edits are not checked for duplicates, since the text was already posted
+}
+
+// Result is the outcome of filtering content. Reason explains why the content was held
+// or rejected, and is nil if the content is allowed.
+type Result struct {
+	Verdict Verdict
+	Reason  error
+}
+
+var allowed = &Result{Verdict: Allow}
+
+func reject(reason error) *Result {
+	return &Result{Verdict: Reject, Reason: reason}
+}
+
+func hold(reason error) *Result {
+	return &Result{Verdict: Hold, Reason: reason}
+}
+
+type Filter interface {
+	Check(ctx context.Context, content *Content) (*Result, error)
+}
+
+// Releaser is implemented by filters that record content as they check it, so that the
+// record can be removed if the content is not saved after all
+type Releaser interface {
+	Release(ctx context.Context, content *Content) error
+}
+
+// Chain runs each filter in order, stopping at the first rejection
+type Chain []Filter
+
+/*
+Returns the strictest result of the filters in the chain. Filters that fail are
+skipped, so that an outage of a service a filter depends on does not stop users from
+posting.
+*/
+func (chain Chain) Check(ctx context.Context, content *Content) *Result {
+	result := allowed
+	for _, filter := range chain {
+		filterResult, err := filter.Check(ctx, content)
+		if err != nil {
+			log.Printf("Content filter %T failed: %v", filter, err)
+			continue
+		}
+		if filterResult.Verdict > result.Verdict {
+			result = filterResult
+		}
+		if result.Verdict == Reject {
+			break
+		}
+	}
+	return result
+}
+
+// Removes what the filters in the chain recorded when checking content that could not
+// be saved. Filters that fail are logged and skipped.
+func (chain Chain) Release(ctx context.Context, content *Content) {
+	for _, filter := range chain {
+		releaser, ok := filter.(Releaser)
+		if !ok {
+			continue
+		}
+		if err := releaser.Release(ctx, content); err != nil {
+			log.Printf("Content filter %T failed to release content: %v", filter, err)
+		}
+	}
+}
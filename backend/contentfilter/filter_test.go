@@ -0,0 +1,278 @@
+package contentfilter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/models"
+)
+
+const testAuthorID = "author"
+
+var errTest = errors.New("test error")
+
+type stubFilter struct {
+	result *Result
+	err    error
+	called bool
+}
+
+func (f *stubFilter) Check(ctx context.Context, content *Content) (*Result, error) {
+	f.called = true
+	return f.result, f.err
+}
+
+func TestChain_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		filters     []*stubFilter
+		wantVerdict Verdict
+		wantReason  error
+		wantCalled  []bool
+	}{
+		{
+			"Empty chain allows content",
+			nil,
+			Allow, nil, nil,
+		},
+		{
+			"Hold is stricter than allow",
+			[]*stubFilter{{result: allowed}, {result: hold(ErrTooManyLinks)}, {result: allowed}},
+			Hold, ErrTooManyLinks, []bool{true, true, true},
+		},
+		{
+			"Reject stops the chain",
+			[]*stubFilter{{result: hold(ErrTooManyLinks)}, {result: reject(ErrTooLong)}, {result: reject(ErrDuplicate)}},
+			Reject, ErrTooLong, []bool{true, true, false},
+		},
+		{
+			"Failing filters are skipped",
+			[]*stubFilter{{err: errTest}, {result: allowed}},
+			Allow, nil, []bool{true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chain Chain
+			for _, filter := range tt.filters {
+				chain = append(chain, filter)
+			}
+			result := chain.Check(context.Background(), &Content{Text: "text"})
+			if result.Verdict != tt.wantVerdict || !errors.Is(result.Reason, tt.wantReason) {
+				t.Errorf("Check() = %v, %v, want %v, %v", result.Verdict, result.Reason, tt.wantVerdict, tt.wantReason)
+			}
+			for i, filter := range tt.filters {
+				if filter.called != tt.wantCalled[i] {
+					t.Errorf("Filter %d called = %v, want %v", i, filter.called, tt.wantCalled[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewBannedWords_Empty(t *testing.T) {
+	if _, err := NewBannedWords([]string{"", " "}); !errors.Is(err, ErrEmptyWordList) {
+		t.Errorf("NewBannedWords() error = %v, want %v", err, ErrEmptyWordList)
+	}
+}
+
+func TestBannedWords_Check(t *testing.T) {
+	filter, err := NewBannedWords([]string{"spam", " scam ", "c++"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		want Verdict
+	}{
+		{"Nothing to see here", Allow},
+		{"Buy SPAM now", Reject},
+		{"this is a scam!", Reject},
+		{"I write c++ for a living", Reject},
+		// Words are only matched whole
+		{"spammer", Allow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			result, err := filter.Check(context.Background(), &Content{Text: tt.text})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Verdict != tt.want {
+				t.Errorf("Check() = %v, want %v", result.Verdict, tt.want)
+			}
+		})
+	}
+}
+
+type stubUsers struct {
+	user   *models.User
+	err    error
+	called bool
+}
+
+func (u *stubUsers) GetUserByID(id string) (*models.User, error) {
+	u.called = true
+	return u.user, u.err
+}
+
+func TestLinkLimit_Check(t *testing.T) {
+	now := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	newUser := &models.User{ID: testAuthorID, CreatedAt: now.Add(-time.Hour)}
+	oldUser := &models.User{ID: testAuthorID, CreatedAt: now.Add(-30 * 24 * time.Hour)}
+	const twoLinks = "See https://example.com and www.example.org"
+	const threeLinks = "http://a.example https://b.example www.c.example"
+	tests := []struct {
+		name        string
+		text        string
+		user        *models.User
+		userErr     error
+		wantVerdict Verdict
+		wantErr     error
+		wantLookup  bool
+	}{
+		{"Few links", twoLinks, newUser, nil, Allow, nil, false},
+		{"New account with many links", threeLinks, newUser, nil, Hold, nil, true},
+		{"Old account with many links", threeLinks, oldUser, nil, Allow, nil, true},
+		{"Cannot find author", threeLinks, nil, errTest, Allow, errTest, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &stubUsers{user: tt.user, err: tt.userErr}
+			filter := &LinkLimit{
+				Users:         users,
+				MaxLinks:      2,
+				MinAccountAge: 72 * time.Hour,
+				Now:           func() time.Time { return now },
+			}
+			result, err := filter.Check(context.Background(), &Content{AuthorID: testAuthorID, Text: tt.text})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && result.Verdict != tt.wantVerdict {
+				t.Errorf("Check() = %v, want %v", result.Verdict, tt.wantVerdict)
+			}
+			if users.called != tt.wantLookup {
+				t.Errorf("Author looked up = %v, want %v", users.called, tt.wantLookup)
+			}
+		})
+	}
+}
+
+// stubRecorder keeps keys in memory, ignoring expiry
+type stubRecorder struct {
+	keys map[string]time.Duration
+	err  error
+}
+
+func (r *stubRecorder) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	if r.err != nil {
+		return redis.NewBoolResult(false, r.err)
+	}
+	if _, ok := r.keys[key]; ok {
+		return redis.NewBoolResult(false, nil)
+	}
+	r.keys[key] = expiration
+	return redis.NewBoolResult(true, nil)
+}
+
+func (r *stubRecorder) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	cmd := redis.NewIntCmd(ctx)
+	if r.err != nil {
+		cmd.SetErr(r.err)
+		return cmd
+	}
+	for _, key := range keys {
+		delete(r.keys, key)
+	}
+	return cmd
+}
+
+func TestDuplicate_Check(t *testing.T) {
+	store := &stubRecorder{keys: map[string]time.Duration{}}
+	filter := &Duplicate{Store: store, Window: 10 * time.Minute}
+	check := func(content *Content) Verdict {
+		t.Helper()
+		result, err := filter.Check(context.Background(), content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Verdict
+	}
+
+	first := &Content{Kind: KindPost, AuthorID: testAuthorID, Text: "Hello world"}
+	if got := check(first); got != Allow {
+		t.Errorf("First post = %v, want %v", got, Allow)
+	}
+	// Content differing only in case and whitespace is a duplicate
+	if got := check(&Content{Kind: KindPost, AuthorID: testAuthorID, Text: "  hello   WORLD "}); got != Reject {
+		t.Errorf("Repeated post = %v, want %v", got, Reject)
+	}
+	if got := check(&Content{Kind: KindPost, AuthorID: "other", Text: "Hello world"}); got != Allow {
+		t.Errorf("Same post by another user = %v, want %v", got, Allow)
+	}
+	if got := check(&Content{Kind: KindComment, AuthorID: testAuthorID, Text: "Hello world"}); got != Allow {
+		t.Errorf("Comment with same text as post = %v, want %v", got, Allow)
+	}
+	if got := check(&Content{Kind: KindPost, AuthorID: testAuthorID, Text: "Hello world", IsEdit: true}); got != Allow {
+		t.Errorf("Edited post = %v, want %v", got, Allow)
+	}
+	if store.keys[duplicateKey(first)] != filter.Window {
+		t.Errorf("Duplicate key expiry = %v, want %v", store.keys[duplicateKey(first)], filter.Window)
+	}
+
+	// Content that could not be saved can be sent again
+	if err := filter.Release(context.Background(), first); err != nil {
+		t.Fatal(err)
+	}
+	if got := check(first); got != Allow {
+		t.Errorf("Released post = %v, want %v", got, Allow)
+	}
+
+	store.err = errTest
+	if _, err := filter.Check(context.Background(), first); !errors.Is(err, errTest) {
+		t.Errorf("Check() error = %v, want %v", err, errTest)
+	}
+}
+
+// Only filters that record content release it
+func TestChain_Release(t *testing.T) {
+	store := &stubRecorder{keys: map[string]time.Duration{}}
+	chain := Chain{&MaxLength{Max: 100}, &Duplicate{Store: store, Window: time.Minute}}
+	content := &Content{Kind: KindComment, AuthorID: testAuthorID, Text: "Hello world"}
+	if result := chain.Check(context.Background(), content); result.Verdict != Allow {
+		t.Fatalf("Check() = %v, want %v", result.Verdict, Allow)
+	}
+
+	chain.Release(context.Background(), content)
+	if len(store.keys) != 0 {
+		t.Errorf("Recorded content %v not released", store.keys)
+	}
+}
+
+func TestMaxLength_Check(t *testing.T) {
+	filter := &MaxLength{Max: 5}
+	tests := []struct {
+		text string
+		want Verdict
+	}{
+		{"hello", Allow},
+		// Length is counted in characters rather than bytes
+		{"héllo", Allow},
+		{"hello!", Reject},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			result, err := filter.Check(context.Background(), &Content{Text: tt.text})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Verdict != tt.want {
+				t.Errorf("Check() = %v, want %v", result.Verdict, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package contentfilter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/models"
+)
+
+// Errors
+var (
+	ErrBannedWord    = errors.New("content contains a banned word")
+	ErrDuplicate     = errors.New("content is a duplicate of something you posted recently")
+	ErrTooLong       = errors.New("content is too long")
+	ErrTooManyLinks  = errors.New("content from new accounts with many links is reviewed before it is shown")
+	ErrEmptyWordList = errors.New("banned word list is empty")
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// BannedWords rejects content containing any of a list of words, ignoring case
+type BannedWords struct {
+	pattern *regexp.Regexp
+}
+
+// Returns a filter for the given words, which are matched as whole words
+func NewBannedWords(words []string) (*BannedWords, error) {
+	var quoted []string
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil, ErrEmptyWordList
+	}
+	// Word boundaries are matched explicitly, since \b does not match next to words
+	// ending in punctuation such as "c++"
+	pattern, err := regexp.Compile(`(?i)(?:^|[^\pL\pN_])(?:` + strings.Join(quoted, "|") + `)(?:[^\pL\pN_]|$)`)
+	if err != nil {
+		return nil, err
+	}
+	return &BannedWords{pattern: pattern}, nil
+}
+
+func (f *BannedWords) Check(ctx context.Context, content *Content) (*Result, error) {
+	if f.pattern.MatchString(content.Text) {
+		return reject(ErrBannedWord), nil
+	}
+	return allowed, nil
+}
+
+// UserGetter is implemented by database.UserDB
+type UserGetter interface {
+	GetUserByID(string) (*models.User, error)
+}
+
+// LinkLimit holds content with more than MaxLinks links for review if its author's
+// account is younger than MinAccountAge, since new accounts are most often used for spam
+type LinkLimit struct {
+	Users         UserGetter
+	MaxLinks      int
+	MinAccountAge time.Duration
+	Now           func() time.Time
+}
+
+func (f *LinkLimit) Check(ctx context.Context, content *Content) (*Result, error) {
+	// The author is only looked up when the content has too many links
+	if len(linkPattern.FindAllStringIndex(content.Text, f.MaxLinks+1)) <= f.MaxLinks {
+		return allowed, nil
+	}
+	user, err := f.Users.GetUserByID(content.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	if f.Now().Sub(user.CreatedAt) < f.MinAccountAge {
+		return hold(ErrTooManyLinks), nil
+	}
+	return allowed, nil
+}
+
+// Recorder is implemented by redis.Client
+type Recorder interface {
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+}
+
+// Duplicate rejects content that its author already posted within Window. Only a hash
+// of the content is stored, and it expires after Window. The hash is stored as the
+// content is checked, so that two copies sent at once cannot both be allowed; it is
+// removed again if the content cannot be saved.
+type Duplicate struct {
+	Store  Recorder
+	Window time.Duration
+}
+
+func (f *Duplicate) Check(ctx context.Context, content *Content) (*Result, error) {
+	if content.IsEdit {
+		return allowed, nil
+	}
+	isNew, err := f.Store.SetNX(ctx, duplicateKey(content), 1, f.Window).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !isNew {
+		return reject(ErrDuplicate), nil
+	}
+	return allowed, nil
+}
+
+// Forgets the content, so that its author can send it again
+func (f *Duplicate) Release(ctx context.Context, content *Content) error {
+	if content.IsEdit {
+		return nil
+	}
+	return f.Store.Del(ctx, duplicateKey(content)).Err()
+}
+
+// Content that differs only in case or whitespace is treated as a duplicate
+func duplicateKey(content *Content) string {
+	normalised := strings.Join(strings.Fields(strings.ToLower(content.Text)), " ")
+	hash := sha256.Sum256([]byte(normalised))
+	return fmt.Sprintf("duplicate:%s:%s:%s", content.Kind, content.AuthorID, hex.EncodeToString(hash[:]))
+}
+
+// MaxLength rejects content longer than Max characters
+type MaxLength struct {
+	Max int
+}
+
+func (f *MaxLength) Check(ctx context.Context, content *Content) (*Result, error) {
+	if utf8.RuneCountInString(content.Text) > f.Max {
+		return reject(ErrTooLong), nil
+	}
+	return allowed, nil
+}
@@ -0,0 +1,222 @@
+/*
+Contains controllers for site administration.
+*/
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	EmailVerificationResetMsg = "Email verification reset"
+	UserLoggedOutMsg          = "User logged out of all sessions"
+	UserSuspendedMsg          = "User suspended"
+	UserUnsuspendedMsg        = "User unsuspended"
+)
+
+// Errors
+var (
+	ErrBadSuspensionEnd        = errors.New("suspension must end in the future")
+	ErrCannotGetAuditLogs      = errors.New("cannot retrieve audit log")
+	ErrCannotGetUsers          = errors.New("cannot retrieve users")
+	ErrCannotImpersonate       = errors.New("cannot create read-only access token")
+	ErrCannotSuspendAdmin      = errors.New("admins cannot be suspended")
+	ErrCannotUpdateUserAsAdmin = errors.New("cannot update user")
+	ErrMissingSuspensionReason = errors.New("a reason for the suspension is required")
+)
+
+func (a *APIEnv) InitialiseAdminHandler() {
+	a.AdminDBHandler = &database.AdminDB{
+		DB: a.DB,
+	}
+}
+
+func (a *APIEnv) GetAuditLogs(ctx *gin.Context) {
+	// Ensure that cutoff is an unsigned integer or empty
+	cutoff, err := helpers.GetCutoffFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	entries, err := a.AdminDBHandler.GetAuditLogs(cutoff)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotGetAuditLogs)
+		return
+	}
+	var smallestID uint = 0
+	if len(entries) > 0 {
+		smallestID = entries[len(entries)-1].ID
+	}
+	helpers.OutputData(ctx, models.AuditLogArray{
+		AuditLogs:   entries,
+		NextPageURL: helpers.GenerateAuditLogNextPageURL(models.BackendAddress, smallestID),
+	})
+}
+
+// Lists users in order of username, optionally filtered by a search term that matches
+// part of a username or email
+func (a *APIEnv) GetAdminUsers(ctx *gin.Context) {
+	search := strings.TrimSpace(ctx.Query(helpers.AdminUserSearchKey))
+	after := ctx.Query(helpers.AdminUserAfterKey)
+
+	users, err := a.AdminDBHandler.GetUsers(search, after)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotGetUsers)
+		return
+	}
+	now := time.Now()
+	userViews := []models.AdminUserView{}
+	for _, user := range users {
+		userViews = append(userViews, *user.AdminUserView(now))
+	}
+	nextPageURL := ""
+	if len(users) > 0 {
+		nextPageURL = helpers.GenerateAdminUsersNextPageURL(models.BackendAddress, users[len(users)-1].Username, search)
+	}
+	helpers.OutputData(ctx, models.AdminUserViewArray{
+		Users:       userViews,
+		NextPageURL: nextPageURL,
+	})
+}
+
+// Suspends a user, rejecting their existing sessions and tokens until the suspension
+// ends or is lifted
+func (a *APIEnv) SuspendUser(ctx *gin.Context) {
+	var input models.SuspensionInput
+
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrMissingSuspensionReason)
+		return
+	}
+	now := time.Now()
+	if input.Until.Valid && !input.Until.Time.After(now) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadSuspensionEnd)
+		return
+	}
+
+	user, err := a.AdminDBHandler.SuspendUser(helpers.GetUsernameFromContext(ctx), &models.Suspension{
+		SuspendedAt:      null.TimeFrom(now),
+		SuspendedUntil:   input.Until,
+		SuspensionReason: null.StringFrom(input.Reason),
+	}, helpers.GetUserIDFromContext(ctx))
+	// Admins cannot be suspended, so that an admin cannot lock the other admins out
+	if errors.Is(err, database.ErrCannotSuspendAdmin) {
+		helpers.OutputError(ctx, http.StatusForbidden, ErrCannotSuspendAdmin)
+		return
+	}
+	if !a.checkAdminUserUpdate(ctx, err) {
+		return
+	}
+	helpers.OutputData(ctx, user.AdminUserView(now))
+}
+
+func (a *APIEnv) UnsuspendUser(ctx *gin.Context) {
+	user, err := a.AdminDBHandler.UnsuspendUser(helpers.GetUsernameFromContext(ctx), helpers.GetUserIDFromContext(ctx))
+	if !a.checkAdminUserUpdate(ctx, err) {
+		return
+	}
+	helpers.OutputData(ctx, user.AdminUserView(time.Now()))
+}
+
+// Logs a user out of all their sessions
+func (a *APIEnv) ForceLogoutUser(ctx *gin.Context) {
+	_, err := a.AdminDBHandler.RevokeUserSessions(helpers.GetUsernameFromContext(ctx), time.Now(), helpers.GetUserIDFromContext(ctx))
+	if !a.checkAdminUserUpdate(ctx, err) {
+		return
+	}
+	helpers.OutputMessage(ctx, UserLoggedOutMsg)
+}
+
+// Marks a user's email as unverified and cancels their pending email change, e.g. if
+// the verification email was sent to a mistyped address
+func (a *APIEnv) ResetEmailVerification(ctx *gin.Context) {
+	_, err := a.AdminDBHandler.ResetEmailVerification(helpers.GetUsernameFromContext(ctx), helpers.GetUserIDFromContext(ctx))
+	if !a.checkAdminUserUpdate(ctx, err) {
+		return
+	}
+	helpers.OutputMessage(ctx, EmailVerificationResetMsg)
+}
+
+// Creates a short-lived personal access token with only the read scope, so that support
+// staff can see what a user sees without being able to act on their behalf
+func (a *APIEnv) ImpersonateUser(ctx *gin.Context) {
+	token, err := helpers.GenerateAccessToken()
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotImpersonate)
+		return
+	}
+	dbToken := &models.PersonalAccessToken{
+		Name:      helpers.ImpersonationTokenName,
+		TokenHash: helpers.HashAccessToken(token),
+		Scopes:    models.ScopeRead,
+		ExpiresAt: null.TimeFrom(time.Now().Add(helpers.ImpersonationTokenTTL)),
+	}
+	_, err = a.AdminDBHandler.CreateImpersonationToken(helpers.GetUsernameFromContext(ctx), dbToken, helpers.GetUserIDFromContext(ctx))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotImpersonate)
+		return
+	}
+	helpers.OutputData(ctx, models.NewPersonalAccessTokenView{
+		PersonalAccessTokenView: *dbToken.PersonalAccessTokenView(),
+		Token:                   token,
+	})
+}
+
+// Outputs the error, if any, from updating a user. Returns true if there is no error.
+func (a *APIEnv) checkAdminUserUpdate(ctx *gin.Context, err error) bool {
+	// If the user cannot be found, return status code 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return false
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateUserAsAdmin)
+		return false
+	}
+	return true
+}
+
+/*
+Retries an action that failed because the user does not own the resource, if the user
+is an admin. getResource retrieves the resource so that the action can be retried on
+behalf of its owner; the bypass is written to the audit log before the retry, so that
+no bypass goes unrecorded. Returns err unchanged if the user is not an admin.
+*/
+func retryAsAdmin[T helpers.UserIDGetter](a *APIEnv, ctx *gin.Context, err error, entry *models.AuditLog,
+	getResource func() (T, error), action func(ownerID string) error) error {
+	if !errors.Is(err, helpers.ErrNotOwner) || !helpers.UserHasRole(ctx, models.RoleAdmin) {
+		return err
+	}
+	resource, err := getResource()
+	if err != nil {
+		return err
+	}
+	entry.ActorID = helpers.GetUserIDFromContext(ctx)
+	entry.OwnerID = resource.GetUserID()
+	if err := a.AdminDBHandler.CreateAuditLog(entry); err != nil {
+		return err
+	}
+	return action(entry.OwnerID)
+}
@@ -0,0 +1,511 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+const testAdminID = "admin"
+
+var defaultAuditLog = models.AuditLog{
+	Model: gorm.Model{
+		ID: 1,
+	},
+	ActorID:    testAdminID,
+	Action:     models.AuditActionDelete,
+	TargetType: models.AuditTargetPost,
+	TargetID:   "1",
+	OwnerID:    testUserID,
+}
+
+type AdminDBTestHandler struct {
+	CreateAuditLogFunc           func(*models.AuditLog) error
+	GetAuditLogsFunc             func(*helpers.NullableUint) ([]models.AuditLog, error)
+	GetUsersFunc                 func(string, string) ([]models.User, error)
+	UpdateUserFunc               func(string) (*models.User, error)
+	CreateImpersonationTokenFunc func(string, *models.PersonalAccessToken) (*models.User, error)
+	Created                      []models.AuditLog
+	Suspension                   *models.Suspension
+}
+
+func (h *AdminDBTestHandler) CreateAuditLog(entry *models.AuditLog) error {
+	return h.CreateAuditLogFunc(entry)
+}
+
+func (h *AdminDBTestHandler) GetAuditLogs(cutoff *helpers.NullableUint) ([]models.AuditLog, error) {
+	return h.GetAuditLogsFunc(cutoff)
+}
+
+func (h *AdminDBTestHandler) GetUsers(search string, after string) ([]models.User, error) {
+	return h.GetUsersFunc(search, after)
+}
+
+func (h *AdminDBTestHandler) SuspendUser(username string, suspension *models.Suspension, actorID string) (*models.User, error) {
+	h.Suspension = suspension
+	return h.UpdateUserFunc(username)
+}
+
+func (h *AdminDBTestHandler) UnsuspendUser(username string, actorID string) (*models.User, error) {
+	return h.UpdateUserFunc(username)
+}
+
+func (h *AdminDBTestHandler) RevokeUserSessions(username string, now time.Time, actorID string) (*models.User, error) {
+	return h.UpdateUserFunc(username)
+}
+
+func (h *AdminDBTestHandler) ResetEmailVerification(username string, actorID string) (*models.User, error) {
+	return h.UpdateUserFunc(username)
+}
+
+func (h *AdminDBTestHandler) CreateImpersonationToken(username string, token *models.PersonalAccessToken, actorID string) (*models.User, error) {
+	return h.CreateImpersonationTokenFunc(username, token)
+}
+
+func (h *AdminDBTestHandler) SetMockUpdateUserFunc(user *models.User, err error) {
+	h.UpdateUserFunc = func(username string) (*models.User, error) {
+		return user, err
+	}
+}
+
+func (h *AdminDBTestHandler) SetMockCreateAuditLogFunc(err error) {
+	h.CreateAuditLogFunc = func(entry *models.AuditLog) error {
+		if err == nil {
+			h.Created = append(h.Created, *entry)
+		}
+		return err
+	}
+}
+
+func (h *AdminDBTestHandler) SetMockGetAuditLogsFunc(entries []models.AuditLog, err error) {
+	h.GetAuditLogsFunc = func(cutoff *helpers.NullableUint) ([]models.AuditLog, error) {
+		return entries, err
+	}
+}
+
+func TestAPIEnv_InitialiseAdminHandler(t *testing.T) {
+	db := &gorm.DB{}
+	a := &APIEnv{
+		DB: db,
+	}
+	a.InitialiseAdminHandler()
+	if adminDB, ok := a.AdminDBHandler.(*database.AdminDB); !ok || adminDB.DB != db {
+		t.Error("AdminDBHandler not initialised correctly")
+	}
+}
+
+func TestAPIEnv_GetAuditLogs(t *testing.T) {
+	helpers.SetEnvVars(t)
+	tests := []struct {
+		name        string
+		adminDBErr  error
+		expected    helpers.ExpectedJSONOutput[models.AuditLogArray]
+		expectedLen int
+	}{
+		{
+			"Get Audit Logs OK",
+			nil,
+			helpers.ExpectedJSONOutput[models.AuditLogArray]{
+				StatusCode: http.StatusOK,
+			},
+			1,
+		},
+		{
+			"Get Audit Logs cannot retrieve",
+			ErrTest,
+			helpers.ExpectedJSONOutput[models.AuditLogArray]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotGetAuditLogs,
+			},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &AdminDBTestHandler{}
+			a := &APIEnv{
+				AdminDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+
+			dbTestHandler.SetMockGetAuditLogsFunc([]models.AuditLog{defaultAuditLog}, tt.adminDBErr)
+			a.GetAuditLogs(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expected.JSONType == helpers.ExpectedError {
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data, _ := m["data"].(map[string]interface{})
+			entries, _ := data["AuditLogs"].([]interface{})
+			if len(entries) != tt.expectedLen {
+				t.Errorf("Expected %d audit log entries, got %d", tt.expectedLen, len(entries))
+			}
+		})
+	}
+}
+
+// Admins may delete posts they do not own, but only once the bypass has been recorded
+func TestAPIEnv_DeletePost_Admin(t *testing.T) {
+	tests := []struct {
+		name          string
+		role          string
+		auditErr      error
+		expectedCode  int
+		expectedOwner string
+	}{
+		{
+			"Admin deletes post OK",
+			models.RoleAdmin,
+			nil,
+			http.StatusOK,
+			testUserID,
+		},
+		{
+			"Moderator cannot bypass owner check",
+			models.RoleModerator,
+			nil,
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"Admin cannot write audit log",
+			models.RoleAdmin,
+			ErrTest,
+			http.StatusInternalServerError,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			postDBHandler := &PostDBTestHandler{}
+			adminDBHandler := &AdminDBTestHandler{}
+			a := &APIEnv{
+				PostDBHandler:  postDBHandler,
+				AdminDBHandler: adminDBHandler,
+				PostRanker:     &TestPostRanker{},
+				HomeTimeline:   &TestHomeTimeline{},
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testAdminID)
+			helpers.AddParamsToContext(c, helpers.PostIDKey, testPostID)
+			c.Set(helpers.UserRoleKey, tt.role)
+
+			var deletedAs string
+			postDBHandler.DeletePostFunc = func(postID uint, userID string) error {
+				if userID != defaultPost.UserID {
+					return helpers.ErrNotOwner
+				}
+				deletedAs = userID
+				return nil
+			}
+			postDBHandler.SetMockGetPostByIDFunc(&defaultPost, nil)
+			adminDBHandler.SetMockCreateAuditLogFunc(tt.auditErr)
+			a.DeletePost(c)
+
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if deletedAs != tt.expectedOwner {
+				t.Errorf("Post deleted as %q, want %q", deletedAs, tt.expectedOwner)
+			}
+			if tt.expectedOwner != "" {
+				if len(adminDBHandler.Created) != 1 {
+					t.Fatalf("Expected 1 audit log entry, got %d", len(adminDBHandler.Created))
+				}
+				entry := adminDBHandler.Created[0]
+				if entry.ActorID != testAdminID || entry.OwnerID != testUserID || entry.Action != models.AuditActionDelete {
+					t.Errorf("Unexpected audit log entry: %v", entry)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetAdminUsers(t *testing.T) {
+	helpers.SetEnvVars(t)
+	dbTestHandler := &AdminDBTestHandler{}
+	a := &APIEnv{
+		AdminDBHandler: dbTestHandler,
+	}
+	c, w := helpers.CreateTestContextAndRecorder()
+	c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+	helpers.AddParamsToQuery(c.Request, helpers.AdminUserSearchKey, " test ")
+
+	var gotSearch string
+	dbTestHandler.GetUsersFunc = func(search string, after string) ([]models.User, error) {
+		gotSearch = search
+		return []models.User{defaultUser}, nil
+	}
+	a.GetAdminUsers(c)
+
+	if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(http.StatusOK, w.Code); !isEqual {
+		t.Fatal(errStr)
+	}
+	if gotSearch != "test" {
+		t.Errorf("Search term not trimmed: %q", gotSearch)
+	}
+	b, _ := io.ReadAll(w.Body)
+	m, err := helpers.ParseJSONString(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := m["data"].(map[string]interface{})
+	nextPageURL, _ := data["NextPageURL"].(string)
+	if !strings.Contains(nextPageURL, helpers.AdminUserAfterKey+"="+defaultUser.Username) {
+		t.Errorf("Unexpected next page URL: %s", nextPageURL)
+	}
+}
+
+func TestAPIEnv_SuspendUser(t *testing.T) {
+	type args struct {
+		Input      *models.SuspensionInput
+		AdminDBErr error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.AdminUserView]
+	}{
+		{
+			"Suspend User OK",
+			args{
+				Input: &models.SuspensionInput{
+					Reason: "Spam",
+					Until:  null.TimeFrom(time.Now().Add(time.Hour)),
+				},
+			},
+			helpers.ExpectedJSONOutput[models.AdminUserView]{
+				StatusCode: http.StatusOK,
+			},
+		},
+		{
+			"Suspend User bad binding",
+			args{},
+			helpers.ExpectedJSONOutput[models.AdminUserView]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadBinding,
+			},
+		},
+		{
+			"Suspend User missing reason",
+			args{
+				Input: &models.SuspensionInput{
+					Reason: "  ",
+				},
+			},
+			helpers.ExpectedJSONOutput[models.AdminUserView]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrMissingSuspensionReason,
+			},
+		},
+		{
+			"Suspend User end in the past",
+			args{
+				Input: &models.SuspensionInput{
+					Reason: "Spam",
+					Until:  null.TimeFrom(time.Now().Add(-time.Hour)),
+				},
+			},
+			helpers.ExpectedJSONOutput[models.AdminUserView]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadSuspensionEnd,
+			},
+		},
+		{
+			"Suspend User admin",
+			args{
+				Input: &models.SuspensionInput{
+					Reason: "Spam",
+				},
+				AdminDBErr: database.ErrCannotSuspendAdmin,
+			},
+			helpers.ExpectedJSONOutput[models.AdminUserView]{
+				StatusCode: http.StatusForbidden,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotSuspendAdmin,
+			},
+		},
+		{
+			"Suspend User not found",
+			args{
+				Input: &models.SuspensionInput{
+					Reason: "Spam",
+				},
+				AdminDBErr: gorm.ErrRecordNotFound,
+			},
+			helpers.ExpectedJSONOutput[models.AdminUserView]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrUserNotFound,
+			},
+		},
+		{
+			"Suspend User cannot update",
+			args{
+				Input: &models.SuspensionInput{
+					Reason: "Spam",
+				},
+				AdminDBErr: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.AdminUserView]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotUpdateUserAsAdmin,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &AdminDBTestHandler{}
+			a := &APIEnv{
+				AdminDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testAdminID)
+			helpers.AddParamsToContext(c, helpers.UsernameKey, testUsername)
+			if tt.args.Input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.args.Input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			dbTestHandler.SetMockUpdateUserFunc(&defaultUser, tt.args.AdminDBErr)
+			a.SuspendUser(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if tt.expected.JSONType != helpers.ExpectedError {
+				if dbTestHandler.Suspension.SuspensionReason.String != tt.args.Input.Reason ||
+					!dbTestHandler.Suspension.SuspendedUntil.Equal(tt.args.Input.Until) {
+					t.Errorf("Unexpected suspension: %v", dbTestHandler.Suspension)
+				}
+				return
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+// The remaining user management endpoints only differ in the action taken on the user
+func TestAPIEnv_AdminUserActions(t *testing.T) {
+	actions := []struct {
+		name    string
+		handler func(*APIEnv) func(*gin.Context)
+		message string
+	}{
+		{"Unsuspend User", func(a *APIEnv) func(*gin.Context) { return a.UnsuspendUser }, ""},
+		{"Force Logout User", func(a *APIEnv) func(*gin.Context) { return a.ForceLogoutUser }, UserLoggedOutMsg},
+		{"Reset Email Verification", func(a *APIEnv) func(*gin.Context) { return a.ResetEmailVerification }, EmailVerificationResetMsg},
+	}
+	for _, action := range actions {
+		tests := []struct {
+			name         string
+			adminDBErr   error
+			expectedCode int
+		}{
+			{"OK", nil, http.StatusOK},
+			{"not found", gorm.ErrRecordNotFound, http.StatusNotFound},
+			{"cannot update", ErrTest, http.StatusInternalServerError},
+		}
+		for _, tt := range tests {
+			t.Run(action.name+" "+tt.name, func(t *testing.T) {
+				dbTestHandler := &AdminDBTestHandler{}
+				a := &APIEnv{
+					AdminDBHandler: dbTestHandler,
+				}
+				c, w := helpers.CreateTestContextAndRecorder()
+				helpers.AddParamsToContext(c, helpers.UserIDKey, testAdminID)
+				helpers.AddParamsToContext(c, helpers.UsernameKey, testUsername)
+
+				dbTestHandler.SetMockUpdateUserFunc(&defaultUser, tt.adminDBErr)
+				action.handler(a)(c)
+
+				if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+					t.Error(errStr)
+				}
+				if tt.expectedCode != http.StatusOK || action.message == "" {
+					return
+				}
+				b, _ := io.ReadAll(w.Body)
+				m, err := helpers.ParseJSONString(b)
+				if err != nil {
+					t.Error(err)
+				}
+				if errStr, isEqual := helpers.CheckExpectedMessageEqualsActual(m, action.message); !isEqual {
+					t.Error(errStr)
+				}
+			})
+		}
+	}
+}
+
+func TestAPIEnv_ImpersonateUser(t *testing.T) {
+	dbTestHandler := &AdminDBTestHandler{}
+	a := &APIEnv{
+		AdminDBHandler: dbTestHandler,
+	}
+	c, w := helpers.CreateTestContextAndRecorder()
+	helpers.AddParamsToContext(c, helpers.UserIDKey, testAdminID)
+	helpers.AddParamsToContext(c, helpers.UsernameKey, testUsername)
+
+	var storedToken *models.PersonalAccessToken
+	dbTestHandler.CreateImpersonationTokenFunc = func(username string, token *models.PersonalAccessToken) (*models.User, error) {
+		storedToken = token
+		return &defaultUser, nil
+	}
+	a.ImpersonateUser(c)
+
+	if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(http.StatusOK, w.Code); !isEqual {
+		t.Fatal(errStr)
+	}
+	b, _ := io.ReadAll(w.Body)
+	m, err := helpers.ParseJSONString(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := m["data"].(map[string]interface{})
+	token, _ := data["Token"].(string)
+	if storedToken.TokenHash != helpers.HashAccessToken(token) {
+		t.Error("Stored hash does not match returned token")
+	}
+	// Impersonation must not allow the admin to act on the user's behalf
+	if storedToken.Scopes != models.ScopeRead || !storedToken.ExpiresAt.Valid {
+		t.Errorf("Impersonation token is not read-only and short-lived: %v", storedToken)
+	}
+}
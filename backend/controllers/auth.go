@@ -6,6 +6,7 @@ package controllers
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -15,14 +16,16 @@ import (
 
 // Messages
 const (
-	GetLoginOKMsg       = "OK"
-	LoginSuccessfulMsg  = "Logged in"
-	SuccessfulLogoutMsg = "Logged out successfully"
+	GetLoginOKMsg             = "OK"
+	LoginDeletionCancelledMsg = "Logged in, account deletion cancelled"
+	LoginSuccessfulMsg        = "Logged in"
+	SuccessfulLogoutMsg       = "Logged out successfully"
 )
 
 // Errors
 var (
 	ErrAlreadyLoggedIn          = errors.New("already logged in")
+	ErrCannotCancelDeletion     = errors.New("unable to cancel account deletion")
 	ErrIncorrectUserCredentials = errors.New("incorrect username or password")
 	ErrMissingUserCredentials   = errors.New("missing username or password")
 )
@@ -76,6 +79,23 @@ func (a *APIEnv) PostLogin(ctx *gin.Context) {
 		return
 	}
 
+	// If the user is suspended, return with status code 403 Forbidden. This is only revealed
+	// once the password is checked, so that anyone cannot find out which users are suspended
+	if dbUser.IsSuspended(time.Now()) {
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.SuspensionError(&dbUser.Suspension))
+		return
+	}
+
+	// Logging in during the grace period cancels the deletion of the account
+	message := LoginSuccessfulMsg
+	if dbUser.IsPendingDeletion() {
+		if err := a.AuthDBHandler.CancelUserDeletion(dbUser.ID); err != nil {
+			helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotCancelDeletion)
+			return
+		}
+		message = LoginDeletionCancelledMsg
+	}
+
 	// Saves session and sets a session cookie on the client's side; if unsuccessful, return
 	// with status code 500 Internal Server Error
 	if err := helpers.SaveSession(ctx, dbUser); err != nil {
@@ -84,7 +104,7 @@ func (a *APIEnv) PostLogin(ctx *gin.Context) {
 	}
 
 	// Login successful
-	helpers.OutputMessage(ctx, LoginSuccessfulMsg)
+	helpers.OutputMessage(ctx, message)
 }
 
 // Handles user logout
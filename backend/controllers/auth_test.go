@@ -4,11 +4,13 @@ import (
 	"io"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
 	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/guregu/null.v3"
 	"gorm.io/gorm"
 )
 
@@ -20,6 +22,18 @@ var (
 	defaultLoginUserDBEntry = models.User{
 		UserCredentials: defaultCreds,
 	}
+	pendingDeletionLoginUserDBEntry = models.User{
+		UserCredentials: defaultCreds,
+		DeleteAfter:     null.TimeFrom(time.Now().Add(time.Hour)),
+	}
+	suspendedLoginUserDBEntry = models.User{
+		UserCredentials: defaultCreds,
+		Suspension: models.Suspension{
+			SuspendedAt:      null.TimeFrom(time.Now().Add(-time.Hour)),
+			SuspendedUntil:   null.TimeFrom(time.Now().Add(time.Hour)),
+			SuspensionReason: null.StringFrom("Spam"),
+		},
+	}
 	emptyUserCreds = models.UserCredentials{
 		Username: "",
 		Password: "",
@@ -144,6 +158,7 @@ func TestAPIEnv_PostLogin(t *testing.T) {
 		UserCreds    *models.UserCredentials
 		UserDBOutput *models.User
 		UserDBError  error
+		CancelError  error
 		SaveError    error
 	}
 	tests := []struct {
@@ -164,6 +179,55 @@ func TestAPIEnv_PostLogin(t *testing.T) {
 				Message:    LoginSuccessfulMsg,
 			},
 		},
+		{
+			"Post Login cancels account deletion",
+			args{
+				UserCreds:    &defaultCreds,
+				UserDBOutput: &pendingDeletionLoginUserDBEntry,
+			},
+			helpers.ExpectedJSONOutput[models.UserCredentials]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedMessage,
+				Message:    LoginDeletionCancelledMsg,
+			},
+		},
+		{
+			"Post Login cannot cancel account deletion",
+			args{
+				UserCreds:    &defaultCreds,
+				UserDBOutput: &pendingDeletionLoginUserDBEntry,
+				CancelError:  ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.UserCredentials]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotCancelDeletion,
+			},
+		},
+		{
+			"Post Login suspended",
+			args{
+				UserCreds:    &defaultCreds,
+				UserDBOutput: &suspendedLoginUserDBEntry,
+			},
+			helpers.ExpectedJSONOutput[models.UserCredentials]{
+				StatusCode: http.StatusForbidden,
+				JSONType:   helpers.ExpectedError,
+				Error:      helpers.SuspensionError(&suspendedLoginUserDBEntry.Suspension),
+			},
+		},
+		{
+			"Post Login suspended incorrect password",
+			args{
+				UserCreds:    &incorrectPasswordCreds,
+				UserDBOutput: &suspendedLoginUserDBEntry,
+			},
+			helpers.ExpectedJSONOutput[models.UserCredentials]{
+				StatusCode: http.StatusUnauthorized,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrIncorrectUserCredentials,
+			},
+		},
 		{
 			"Post Login already logged in",
 			args{
@@ -259,6 +323,7 @@ func TestAPIEnv_PostLogin(t *testing.T) {
 
 			c.Request = helpers.GenerateHttpFormDataRequest(http.MethodPost, *tt.args.UserCreds)
 			dbTestHandler.SetMockGetUserByUsernameFunc(&expectedUser, tt.args.UserDBError)
+			dbTestHandler.SetMockCancelUserDeletionFunc(tt.args.CancelError)
 			a.PostLogin(c)
 
 			b, _ := io.ReadAll(w.Body)
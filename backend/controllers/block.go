@@ -0,0 +1,136 @@
+/*
+Contains controllers for blocking and muting users.
+*/
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	UserBlockedMsg   = "User blocked"
+	UserMutedMsg     = "User muted"
+	UserUnblockedMsg = "User unblocked"
+	UserUnmutedMsg   = "User unmuted"
+)
+
+// Errors
+var (
+	ErrBlocked                 = errors.New("you cannot interact with this user")
+	ErrCannotBlockUser         = errors.New("cannot block user")
+	ErrCannotMuteUser          = errors.New("cannot mute user")
+	ErrCannotRestrictSelf      = errors.New("you cannot block or mute yourself")
+	ErrCannotRetrieveBlockList = errors.New("cannot retrieve blocked users")
+	ErrCannotRetrieveMuteList  = errors.New("cannot retrieve muted users")
+	ErrCannotUnblockUser       = errors.New("cannot unblock user")
+	ErrCannotUnmuteUser        = errors.New("cannot unmute user")
+	ErrNotBlocked              = errors.New("user is not blocked")
+	ErrNotMuted                = errors.New("user is not muted")
+)
+
+func (a *APIEnv) InitialiseBlockHandler() {
+	a.BlockDBHandler = &database.BlockDB{
+		DB: a.DB,
+	}
+}
+
+// Blocks the user in the URL. Blocked users cannot see, like or comment on the user's
+// posts, the user no longer sees theirs, and neither is notified of the other's activity.
+func (a *APIEnv) BlockUser(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	err := a.BlockDBHandler.BlockUser(userID, helpers.GetUsernameFromContext(ctx))
+	if !outputRestrictionError(ctx, err, ErrCannotBlockUser) {
+		helpers.OutputMessage(ctx, UserBlockedMsg)
+	}
+}
+
+func (a *APIEnv) UnblockUser(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	err := a.BlockDBHandler.UnblockUser(userID, helpers.GetUsernameFromContext(ctx))
+	// If the user is not blocked, return status code 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrNotBlocked)
+		return
+	}
+	if !outputRestrictionError(ctx, err, ErrCannotUnblockUser) {
+		helpers.OutputMessage(ctx, UserUnblockedMsg)
+	}
+}
+
+func (a *APIEnv) GetBlockedUsers(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	blocks, err := a.BlockDBHandler.GetBlocks(userID)
+	// If unable to retrieve blocked users, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveBlockList)
+		return
+	}
+	views := []models.ListedUserView{}
+	for i := range blocks {
+		views = append(views, *blocks[i].Blocked.ListedUserView(blocks[i].CreatedAt))
+	}
+	helpers.OutputData(ctx, views)
+}
+
+// Mutes the user in the URL, hiding their posts from the user's feeds
+func (a *APIEnv) MuteUser(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	err := a.BlockDBHandler.MuteUser(userID, helpers.GetUsernameFromContext(ctx))
+	if !outputRestrictionError(ctx, err, ErrCannotMuteUser) {
+		helpers.OutputMessage(ctx, UserMutedMsg)
+	}
+}
+
+func (a *APIEnv) UnmuteUser(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	err := a.BlockDBHandler.UnmuteUser(userID, helpers.GetUsernameFromContext(ctx))
+	// If the user is not muted, return status code 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrNotMuted)
+		return
+	}
+	if !outputRestrictionError(ctx, err, ErrCannotUnmuteUser) {
+		helpers.OutputMessage(ctx, UserUnmutedMsg)
+	}
+}
+
+func (a *APIEnv) GetMutedUsers(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	mutes, err := a.BlockDBHandler.GetMutes(userID)
+	// If unable to retrieve muted users, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveMuteList)
+		return
+	}
+	views := []models.ListedUserView{}
+	for i := range mutes {
+		views = append(views, *mutes[i].Muted.ListedUserView(mutes[i].CreatedAt))
+	}
+	helpers.OutputData(ctx, views)
+}
+
+// Outputs the error returned when blocking or muting a user, if any, and returns
+// whether there was an error
+func outputRestrictionError(ctx *gin.Context, err error, errFailed error) bool {
+	switch {
+	case err == nil:
+		return false
+	// If the user cannot be found, return status code 404 Not Found
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+	// If users try to block or mute themselves, return status code 400 Bad Request
+	case errors.Is(err, database.ErrRestrictingOneself):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCannotRestrictSelf)
+	default:
+		helpers.OutputError(ctx, http.StatusInternalServerError, errFailed)
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+const testBlockedUsername = "blockeduser"
+
+type BlockDBTestHandler struct {
+	BlockUserFunc   func(string, string) error
+	UnblockUserFunc func(string, string) error
+	GetBlocksFunc   func(string) ([]models.Block, error)
+	MuteUserFunc    func(string, string) error
+	UnmuteUserFunc  func(string, string) error
+	GetMutesFunc    func(string) ([]models.Mute, error)
+	IsBlockedFunc   func(string, string) (bool, error)
+}
+
+func (h *BlockDBTestHandler) BlockUser(userID string, username string) error {
+	return h.BlockUserFunc(userID, username)
+}
+
+func (h *BlockDBTestHandler) UnblockUser(userID string, username string) error {
+	return h.UnblockUserFunc(userID, username)
+}
+
+func (h *BlockDBTestHandler) GetBlocks(userID string) ([]models.Block, error) {
+	return h.GetBlocksFunc(userID)
+}
+
+func (h *BlockDBTestHandler) MuteUser(userID string, username string) error {
+	return h.MuteUserFunc(userID, username)
+}
+
+func (h *BlockDBTestHandler) UnmuteUser(userID string, username string) error {
+	return h.UnmuteUserFunc(userID, username)
+}
+
+func (h *BlockDBTestHandler) GetMutes(userID string) ([]models.Mute, error) {
+	return h.GetMutesFunc(userID)
+}
+
+func (h *BlockDBTestHandler) IsBlocked(userID string, otherID string) (bool, error) {
+	return h.IsBlockedFunc(userID, otherID)
+}
+
+// Sets every function that blocks, unblocks, mutes or unmutes a user to return err
+func (h *BlockDBTestHandler) SetMockUpdateFuncs(err error) {
+	update := func(userID string, username string) error {
+		return err
+	}
+	h.BlockUserFunc = update
+	h.UnblockUserFunc = update
+	h.MuteUserFunc = update
+	h.UnmuteUserFunc = update
+}
+
+func TestAPIEnv_BlockAndMuteUser(t *testing.T) {
+	type handlerFunc func(*APIEnv, *gin.Context)
+	block := func(a *APIEnv, ctx *gin.Context) { a.BlockUser(ctx) }
+	unblock := func(a *APIEnv, ctx *gin.Context) { a.UnblockUser(ctx) }
+	mute := func(a *APIEnv, ctx *gin.Context) { a.MuteUser(ctx) }
+	unmute := func(a *APIEnv, ctx *gin.Context) { a.UnmuteUser(ctx) }
+	tests := []struct {
+		name         string
+		handler      handlerFunc
+		blockDBError error
+		expected     helpers.ExpectedJSONOutput[string]
+	}{
+		{"Block user OK", block, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: UserBlockedMsg}},
+		{"Block user not found", block, gorm.ErrRecordNotFound, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrUserNotFound}},
+		{"Block self", block, database.ErrRestrictingOneself, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrCannotRestrictSelf}},
+		{"Block user cannot block", block, ErrTest, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotBlockUser}},
+		{"Unblock user OK", unblock, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: UserUnblockedMsg}},
+		{"Unblock user not blocked", unblock, gorm.ErrRecordNotFound, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrNotBlocked}},
+		{"Unblock user cannot unblock", unblock, ErrTest, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotUnblockUser}},
+		{"Mute user OK", mute, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: UserMutedMsg}},
+		{"Mute self", mute, database.ErrRestrictingOneself, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrCannotRestrictSelf}},
+		{"Mute user cannot mute", mute, ErrTest, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotMuteUser}},
+		{"Unmute user OK", unmute, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: UserUnmutedMsg}},
+		{"Unmute user not muted", unmute, gorm.ErrRecordNotFound, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrNotMuted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &BlockDBTestHandler{}
+			a := &APIEnv{
+				BlockDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.UsernameKey, testBlockedUsername)
+
+			dbTestHandler.SetMockUpdateFuncs(tt.blockDBError)
+			tt.handler(a, c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetBlockedAndMutedUsers(t *testing.T) {
+	helpers.SetEnvVars(t)
+	since := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	blockedUser := models.User{
+		ID: diffUserID,
+		UserCredentials: models.UserCredentials{
+			Username: testBlockedUsername,
+		},
+	}
+	tests := []struct {
+		name         string
+		muted        bool
+		blockDBError error
+		expectedCode int
+		expectedErr  error
+	}{
+		{"Get blocked users OK", false, nil, http.StatusOK, nil},
+		{"Get blocked users cannot retrieve", false, ErrTest, http.StatusInternalServerError, ErrCannotRetrieveBlockList},
+		{"Get muted users OK", true, nil, http.StatusOK, nil},
+		{"Get muted users cannot retrieve", true, ErrTest, http.StatusInternalServerError, ErrCannotRetrieveMuteList},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &BlockDBTestHandler{}
+			a := &APIEnv{
+				BlockDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+
+			dbTestHandler.GetBlocksFunc = func(userID string) ([]models.Block, error) {
+				return []models.Block{{UserID: userID, BlockedID: blockedUser.ID, Blocked: blockedUser, CreatedAt: since}}, tt.blockDBError
+			}
+			dbTestHandler.GetMutesFunc = func(userID string) ([]models.Mute, error) {
+				return []models.Mute{{UserID: userID, MutedID: blockedUser.ID, Muted: blockedUser, CreatedAt: since}}, tt.blockDBError
+			}
+			if tt.muted {
+				a.GetMutedUsers(c)
+			} else {
+				a.GetBlockedUsers(c)
+			}
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			users, ok := m["data"].([]interface{})
+			if !ok || len(users) != 1 {
+				t.Fatalf("Expected one listed user, got %v", m["data"])
+			}
+			user := users[0].(map[string]interface{})
+			if user["Username"] != testBlockedUsername || user["Since"] != since.Format(time.RFC3339) {
+				t.Errorf("Unexpected listed user %v", user)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+/*
+Contains controllers for bookmarking posts and organising bookmarks into collections.
+*/
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	BookmarkDeletedMsg   = "Bookmark removed"
+	CollectionDeletedMsg = "Collection deleted"
+	PostBookmarkedMsg    = "Post bookmarked"
+)
+
+// Errors
+var (
+	ErrBookmarkNotFound            = errors.New("bookmark not found")
+	ErrCannotBookmarkPost          = errors.New("cannot bookmark post")
+	ErrCannotCreateCollection      = errors.New("cannot create collection")
+	ErrCannotDeleteBookmark        = errors.New("cannot remove bookmark")
+	ErrCannotDeleteCollection      = errors.New("cannot delete collection")
+	ErrCannotRetrieveBookmarks     = errors.New("cannot retrieve bookmarks")
+	ErrCannotRetrieveCollections   = errors.New("cannot retrieve collections")
+	ErrCannotUpdateCollection      = errors.New("cannot update collection")
+	ErrCollectionNameTaken         = errors.New("you already have a collection with this name")
+	ErrCollectionNotFound          = errors.New("collection not found")
+	ErrSharedCollectionUnavailable = errors.New("this collection is no longer shared")
+)
+
+func (a *APIEnv) InitialiseBookmarkHandler() {
+	a.BookmarkDBHandler = &database.BookmarkDB{
+		DB: a.DB,
+	}
+}
+
+// Bookmarks the post, in the collection given in the query if any. Bookmarking a post
+// again moves it to the collection.
+func (a *APIEnv) PostBookmark(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that postID is an unsigned integer
+	postID, err := helpers.GetPostIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrPostNotFound)
+		return
+	}
+
+	// Ensure that collection ID is an unsigned integer or empty
+	collectionID, err := helpers.GetBookmarkCollectionFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	err = a.BookmarkDBHandler.CreateBookmark(userID, postID, collectionID)
+	// If the post cannot be seen by the user or the collection does not exist, return
+	// status code 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if collectionID.IsNull() {
+			helpers.OutputError(ctx, http.StatusNotFound, ErrPostNotFound)
+		} else {
+			helpers.OutputError(ctx, http.StatusNotFound, ErrCollectionNotFound)
+		}
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotBookmarkPost)
+		return
+	}
+	helpers.OutputMessage(ctx, PostBookmarkedMsg)
+}
+
+func (a *APIEnv) DeleteBookmark(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that postID is an unsigned integer
+	postID, err := helpers.GetPostIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrPostNotFound)
+		return
+	}
+
+	err = a.BookmarkDBHandler.DeleteBookmark(userID, postID)
+	// If the user has not bookmarked the post, return status code 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrBookmarkNotFound)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotDeleteBookmark)
+		return
+	}
+	helpers.OutputMessage(ctx, BookmarkDeletedMsg)
+}
+
+// Returns a page of the user's bookmarked posts, most recently bookmarked first, from
+// the collection in the query if any
+func (a *APIEnv) GetBookmarks(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that cutoff is an unsigned integer or empty
+	cutoff, err := helpers.GetCutoffFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	// Ensure that collection ID is an unsigned integer or empty
+	collectionID, err := helpers.GetBookmarkCollectionFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	bookmarks, err := a.BookmarkDBHandler.GetBookmarks(userID, collectionID, cutoff)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveBookmarks)
+		return
+	}
+	posts, smallestID, err := a.bookmarkedPosts(bookmarks, userID)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveBookmarks)
+		return
+	}
+
+	postViewArray := models.PostViewArray{
+		Posts:       a.postViews(ctx, posts, userID),
+		NextPageURL: helpers.GenerateBookmarkNextPageURL(models.BackendAddress, smallestID, collectionID),
+	}
+	helpers.OutputData(ctx, postViewArray)
+}
+
+// Retrieves the bookmarked posts that can be shown to the user, in the order of the
+// bookmarks, along with the smallest bookmark ID to continue the next page from.
+// Bookmarked posts that can no longer be shown are skipped.
+func (a *APIEnv) bookmarkedPosts(bookmarks []models.Bookmark, userID string) ([]models.Post, uint, error) {
+	var smallestID uint = 0
+	postIDs := []uint{}
+	for _, bookmark := range bookmarks {
+		smallestID = bookmark.ID
+		postIDs = append(postIDs, bookmark.PostID)
+	}
+	visiblePosts, err := a.PostDBHandler.GetPostsByIDs(postIDs, time.Time{}, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	postsByID := map[uint]models.Post{}
+	for _, post := range visiblePosts {
+		postsByID[post.ID] = post
+	}
+	posts := []models.Post{}
+	for _, bookmark := range bookmarks {
+		if post, ok := postsByID[bookmark.PostID]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, smallestID, nil
+}
+
+func (a *APIEnv) GetBookmarkCollections(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	collections, err := a.BookmarkDBHandler.GetCollections(userID)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveCollections)
+		return
+	}
+	views := []models.BookmarkCollectionView{}
+	for i := range collections {
+		views = append(views, *collections[i].BookmarkCollectionView())
+	}
+	helpers.OutputData(ctx, views)
+}
+
+func (a *APIEnv) CreateBookmarkCollection(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	var input models.BookmarkCollectionInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	// If the collection has no name or too long a name, return status code 400 Bad Request
+	if err := helpers.ValidateBookmarkCollection(&input, false); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	collection, err := a.BookmarkDBHandler.CreateCollection(userID, &input)
+	// If the user already has a collection with the name, return status code 409 Conflict
+	if errors.Is(err, database.ErrCollectionNameTaken) {
+		helpers.OutputError(ctx, http.StatusConflict, ErrCollectionNameTaken)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotCreateCollection)
+		return
+	}
+	helpers.OutputData(ctx, collection.BookmarkCollectionView())
+}
+
+// Renames the collection, and shares or stops sharing it. Fields left out of the request
+// are unchanged. A collection that is shared again after it stops being shared gets a
+// new link.
+func (a *APIEnv) UpdateBookmarkCollection(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that collectionID is an unsigned integer
+	collectionID, err := helpers.GetBookmarkCollectionIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCollectionNotFound)
+		return
+	}
+
+	var input models.BookmarkCollectionInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	// If the new name is too long, return status code 400 Bad Request
+	if err := helpers.ValidateBookmarkCollection(&input, true); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	collection, err := a.BookmarkDBHandler.UpdateCollection(collectionID, userID, &input)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrCollectionNotFound)
+		return
+	case errors.Is(err, database.ErrCollectionNameTaken):
+		helpers.OutputError(ctx, http.StatusConflict, ErrCollectionNameTaken)
+		return
+	case err != nil:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateCollection)
+		return
+	}
+	helpers.OutputData(ctx, collection.BookmarkCollectionView())
+}
+
+// Deletes the collection; the bookmarks in it are kept outside of any collection
+func (a *APIEnv) DeleteBookmarkCollection(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that collectionID is an unsigned integer
+	collectionID, err := helpers.GetBookmarkCollectionIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCollectionNotFound)
+		return
+	}
+
+	err = a.BookmarkDBHandler.DeleteCollection(collectionID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrCollectionNotFound)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotDeleteCollection)
+		return
+	}
+	helpers.OutputMessage(ctx, CollectionDeletedMsg)
+}
+
+// Returns a page of a shared collection to anyone with its link. Posts are shown as
+// they would be to a visitor who is not logged in.
+func (a *APIEnv) GetSharedBookmarkCollection(ctx *gin.Context) {
+	token := helpers.GetShareTokenFromContext(ctx)
+
+	// Ensure that cutoff is an unsigned integer or empty
+	cutoff, err := helpers.GetCutoffFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	collection, err := a.BookmarkDBHandler.GetSharedCollection(token)
+	// If the collection is not shared with the token, such as when the owner has stopped
+	// sharing it, return status code 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrSharedCollectionUnavailable)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveBookmarks)
+		return
+	}
+
+	collectionID := helpers.NewNullableUint(collection.ID)
+	bookmarks, err := a.BookmarkDBHandler.GetBookmarks(collection.UserID, collectionID, cutoff)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveBookmarks)
+		return
+	}
+	posts, smallestID, err := a.bookmarkedPosts(bookmarks, "")
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveBookmarks)
+		return
+	}
+
+	view := models.SharedBookmarkCollectionView{
+		Name:        collection.Name,
+		Owner:       *collection.User.GetUserMinimal(),
+		Posts:       a.postViews(ctx, posts, ""),
+		NextPageURL: helpers.GenerateSharedCollectionNextPageURL(models.BackendAddress, token, smallestID),
+	}
+	helpers.OutputData(ctx, view)
+}
@@ -0,0 +1,483 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+const testCollectionID = 3
+
+type BookmarkDBTestHandler struct {
+	CreateBookmarkFunc      func(string, uint, *helpers.NullableUint) error
+	DeleteBookmarkFunc      func(string, uint) error
+	GetBookmarksFunc        func(string, *helpers.NullableUint, *helpers.NullableUint) ([]models.Bookmark, error)
+	GetCollectionsFunc      func(string) ([]models.BookmarkCollection, error)
+	CreateCollectionFunc    func(string, *models.BookmarkCollectionInput) (*models.BookmarkCollection, error)
+	UpdateCollectionFunc    func(uint, string, *models.BookmarkCollectionInput) (*models.BookmarkCollection, error)
+	DeleteCollectionFunc    func(uint, string) error
+	GetSharedCollectionFunc func(string) (*models.BookmarkCollection, error)
+}
+
+func (h *BookmarkDBTestHandler) CreateBookmark(userID string, postID uint, collectionID *helpers.NullableUint) error {
+	return h.CreateBookmarkFunc(userID, postID, collectionID)
+}
+
+func (h *BookmarkDBTestHandler) DeleteBookmark(userID string, postID uint) error {
+	return h.DeleteBookmarkFunc(userID, postID)
+}
+
+func (h *BookmarkDBTestHandler) GetBookmarks(userID string, collectionID *helpers.NullableUint, cutoff *helpers.NullableUint) ([]models.Bookmark, error) {
+	return h.GetBookmarksFunc(userID, collectionID, cutoff)
+}
+
+func (h *BookmarkDBTestHandler) GetCollections(userID string) ([]models.BookmarkCollection, error) {
+	return h.GetCollectionsFunc(userID)
+}
+
+func (h *BookmarkDBTestHandler) CreateCollection(userID string, input *models.BookmarkCollectionInput) (*models.BookmarkCollection, error) {
+	return h.CreateCollectionFunc(userID, input)
+}
+
+func (h *BookmarkDBTestHandler) UpdateCollection(collectionID uint, userID string, input *models.BookmarkCollectionInput) (*models.BookmarkCollection, error) {
+	return h.UpdateCollectionFunc(collectionID, userID, input)
+}
+
+func (h *BookmarkDBTestHandler) DeleteCollection(collectionID uint, userID string) error {
+	return h.DeleteCollectionFunc(collectionID, userID)
+}
+
+func (h *BookmarkDBTestHandler) GetSharedCollection(token string) (*models.BookmarkCollection, error) {
+	return h.GetSharedCollectionFunc(token)
+}
+
+func TestAPIEnv_PostBookmark(t *testing.T) {
+	tests := []struct {
+		name                 string
+		postID               interface{}
+		collectionID         interface{}
+		dbError              error
+		expectedCollectionID *helpers.NullableUint
+		expected             helpers.ExpectedJSONOutput[string]
+	}{
+		{"Bookmark OK", testPostID, nil, nil, &helpers.NullableUint{}, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: PostBookmarkedMsg}},
+		{"Bookmark in collection OK", testPostID, testCollectionID, nil, helpers.NewNullableUint(testCollectionID),
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: PostBookmarkedMsg}},
+		{"Bookmark invalid post ID", "abc", nil, nil, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrPostNotFound}},
+		{"Bookmark invalid collection ID", testPostID, "abc", nil, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrBadBinding}},
+		{"Bookmark post not found", testPostID, nil, gorm.ErrRecordNotFound, &helpers.NullableUint{}, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrPostNotFound}},
+		{"Bookmark collection not found", testPostID, testCollectionID, gorm.ErrRecordNotFound, helpers.NewNullableUint(testCollectionID),
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrCollectionNotFound}},
+		{"Bookmark cannot bookmark", testPostID, nil, ErrTest, &helpers.NullableUint{}, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotBookmarkPost}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &BookmarkDBTestHandler{}
+			a := &APIEnv{
+				BookmarkDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.PostIDKey, tt.postID)
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.collectionID != nil {
+				helpers.AddParamsToQuery(req, helpers.BookmarkCollectionKey, tt.collectionID)
+			}
+			c.Request = req
+
+			var receivedCollectionID *helpers.NullableUint
+			dbTestHandler.CreateBookmarkFunc = func(userID string, postID uint, collectionID *helpers.NullableUint) error {
+				receivedCollectionID = collectionID
+				return tt.dbError
+			}
+			a.PostBookmark(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if tt.expectedCollectionID != nil && *receivedCollectionID != *tt.expectedCollectionID {
+				t.Errorf("CreateBookmark received collection %+v, want %+v", receivedCollectionID, tt.expectedCollectionID)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_DeleteBookmark(t *testing.T) {
+	tests := []struct {
+		name     string
+		postID   interface{}
+		dbError  error
+		expected helpers.ExpectedJSONOutput[string]
+	}{
+		{"Delete bookmark OK", testPostID, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: BookmarkDeletedMsg}},
+		{"Delete bookmark invalid post ID", "abc", nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrPostNotFound}},
+		{"Delete bookmark not bookmarked", testPostID, gorm.ErrRecordNotFound, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrBookmarkNotFound}},
+		{"Delete bookmark cannot delete", testPostID, ErrTest, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotDeleteBookmark}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &BookmarkDBTestHandler{}
+			a := &APIEnv{
+				BookmarkDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.PostIDKey, tt.postID)
+
+			dbTestHandler.DeleteBookmarkFunc = func(userID string, postID uint) error {
+				return tt.dbError
+			}
+			a.DeleteBookmark(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetBookmarks(t *testing.T) {
+	helpers.SetEnvVars(t)
+	bookmarks := []models.Bookmark{
+		{ID: 9, PostID: 4},
+		{ID: 7, PostID: 2},
+		{ID: 5, PostID: 6},
+	}
+	// The post of the last bookmark can no longer be shown to the user
+	visiblePosts := []models.Post{
+		{Model: gorm.Model{ID: 2}, Bookmarks: []models.Bookmark{{UserID: testUserID, PostID: 2}}},
+		{Model: gorm.Model{ID: 4}, Bookmarks: []models.Bookmark{{UserID: testUserID, PostID: 4}}},
+	}
+	tests := []struct {
+		name            string
+		collectionID    interface{}
+		bookmarkDBError error
+		postDBError     error
+		expectedCode    int
+		expectedErr     error
+		expectedPostIDs []float64
+		expectedNextURL string
+	}{
+		{"Get bookmarks OK", nil, nil, nil, http.StatusOK, nil, []float64{4, 2},
+			helpers.GenerateBookmarkNextPageURL(models.BackendAddress, 5, &helpers.NullableUint{})},
+		{"Get bookmarks in collection OK", testCollectionID, nil, nil, http.StatusOK, nil, []float64{4, 2},
+			helpers.GenerateBookmarkNextPageURL(models.BackendAddress, 5, helpers.NewNullableUint(testCollectionID))},
+		{"Get bookmarks invalid collection ID", "abc", nil, nil, http.StatusBadRequest, ErrBadBinding, nil, ""},
+		{"Get bookmarks cannot retrieve bookmarks", nil, ErrTest, nil, http.StatusInternalServerError, ErrCannotRetrieveBookmarks, nil, ""},
+		{"Get bookmarks cannot retrieve posts", nil, nil, ErrTest, http.StatusInternalServerError, ErrCannotRetrieveBookmarks, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bookmarkDBTestHandler := &BookmarkDBTestHandler{}
+			postDBTestHandler := &PostDBTestHandler{}
+			cacheTestHandler := &helpers.TestCache{}
+			a := &APIEnv{
+				BookmarkDBHandler:    bookmarkDBTestHandler,
+				PostDBHandler:        postDBTestHandler,
+				LikesCacheHandler:    cacheTestHandler,
+				CommentsCacheHandler: cacheTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodGet, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.collectionID != nil {
+				helpers.AddParamsToQuery(req, helpers.BookmarkCollectionKey, tt.collectionID)
+			}
+			c.Request = req
+
+			bookmarkDBTestHandler.GetBookmarksFunc = func(userID string, collectionID *helpers.NullableUint, cutoff *helpers.NullableUint) ([]models.Bookmark, error) {
+				return bookmarks, tt.bookmarkDBError
+			}
+			postDBTestHandler.GetPostsByIDsFunc = func(postIDs []uint, since time.Time, userID string) ([]models.Post, error) {
+				return visiblePosts, tt.postDBError
+			}
+			cacheTestHandler.SetMockGetCacheValFunc(0, nil)
+			a.GetBookmarks(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			if data["NextPageURL"] != tt.expectedNextURL {
+				t.Errorf("NextPageURL = %v, want %v", data["NextPageURL"], tt.expectedNextURL)
+			}
+			posts := data["Posts"].([]interface{})
+			if len(posts) != len(tt.expectedPostIDs) {
+				t.Fatalf("Got %d posts, want %d", len(posts), len(tt.expectedPostIDs))
+			}
+			for i, post := range posts {
+				postView := post.(map[string]interface{})
+				postID := postView["Post"].(map[string]interface{})["ID"]
+				if postID != tt.expectedPostIDs[i] || postView["Bookmarked"] != true {
+					t.Errorf("Post %d = %v (bookmarked %v), want %v", i, postID, postView["Bookmarked"], tt.expectedPostIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAPIEnv_CreateBookmarkCollection(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        *models.BookmarkCollectionInput
+		dbError      error
+		expectedName string
+		expectedCode int
+		expectedErr  error
+	}{
+		{"Create collection OK", &models.BookmarkCollectionInput{Name: " Reading list "}, nil, "Reading list", http.StatusOK, nil},
+		{"Create collection bad request", nil, nil, "", http.StatusBadRequest, ErrBadBinding},
+		{"Create collection no name", &models.BookmarkCollectionInput{Name: " "}, nil, "", http.StatusBadRequest, helpers.ErrNoCollectionName},
+		{"Create collection name too long", &models.BookmarkCollectionInput{Name: strings.Repeat("a", helpers.MaxCollectionNameLength+1)},
+			nil, "", http.StatusBadRequest, helpers.ErrCollectionNameTooLong},
+		{"Create collection name taken", &models.BookmarkCollectionInput{Name: "Reading list"}, database.ErrCollectionNameTaken,
+			"Reading list", http.StatusConflict, ErrCollectionNameTaken},
+		{"Create collection cannot create", &models.BookmarkCollectionInput{Name: "Reading list"}, ErrTest,
+			"Reading list", http.StatusInternalServerError, ErrCannotCreateCollection},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &BookmarkDBTestHandler{}
+			a := &APIEnv{
+				BookmarkDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			if tt.input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			receivedName := ""
+			dbTestHandler.CreateCollectionFunc = func(userID string, input *models.BookmarkCollectionInput) (*models.BookmarkCollection, error) {
+				receivedName = input.Name
+				return &models.BookmarkCollection{ID: testCollectionID, UserID: userID, Name: input.Name}, tt.dbError
+			}
+			a.CreateBookmarkCollection(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if receivedName != tt.expectedName {
+				t.Errorf("CreateCollection received name %q, want %q", receivedName, tt.expectedName)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			if data["Name"] != tt.expectedName || data["Shared"] != false || data["ShareURL"] != nil {
+				t.Errorf("Unexpected collection %v", data)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_UpdateBookmarkCollection(t *testing.T) {
+	helpers.SetEnvVars(t)
+	tests := []struct {
+		name         string
+		collectionID interface{}
+		dbError      error
+		expectedCode int
+		expectedErr  error
+	}{
+		{"Update collection OK", testCollectionID, nil, http.StatusOK, nil},
+		{"Update collection invalid ID", "abc", nil, http.StatusBadRequest, ErrCollectionNotFound},
+		{"Update collection not found", testCollectionID, gorm.ErrRecordNotFound, http.StatusNotFound, ErrCollectionNotFound},
+		{"Update collection name taken", testCollectionID, database.ErrCollectionNameTaken, http.StatusConflict, ErrCollectionNameTaken},
+		{"Update collection cannot update", testCollectionID, ErrTest, http.StatusInternalServerError, ErrCannotUpdateCollection},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &BookmarkDBTestHandler{}
+			a := &APIEnv{
+				BookmarkDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.BookmarkCollectionIDKey, tt.collectionID)
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodPatch, &models.BookmarkCollectionInput{Shared: null.BoolFrom(true)})
+			if err != nil {
+				t.Error(err)
+			}
+			c.Request = req
+
+			dbTestHandler.UpdateCollectionFunc = func(collectionID uint, userID string, input *models.BookmarkCollectionInput) (*models.BookmarkCollection, error) {
+				return &models.BookmarkCollection{
+					ID:         collectionID,
+					Name:       "Reading list",
+					ShareToken: null.StringFrom("token"),
+				}, tt.dbError
+			}
+			a.UpdateBookmarkCollection(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			if data["Shared"] != true || data["ShareURL"] != models.ClientAddress+"/bookmarks/shared/token" {
+				t.Errorf("Unexpected collection %v", data)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetSharedBookmarkCollection(t *testing.T) {
+	helpers.SetEnvVars(t)
+	collection := &models.BookmarkCollection{
+		ID:         testCollectionID,
+		UserID:     testUserID,
+		Name:       "Reading list",
+		ShareToken: null.StringFrom("token"),
+		User: models.User{
+			ID:              testUserID,
+			UserCredentials: models.UserCredentials{Username: testUsername},
+		},
+	}
+	tests := []struct {
+		name         string
+		dbError      error
+		expectedCode int
+		expectedErr  error
+	}{
+		{"Get shared collection OK", nil, http.StatusOK, nil},
+		{"Get shared collection not shared", gorm.ErrRecordNotFound, http.StatusNotFound, ErrSharedCollectionUnavailable},
+		{"Get shared collection cannot retrieve", ErrTest, http.StatusInternalServerError, ErrCannotRetrieveBookmarks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bookmarkDBTestHandler := &BookmarkDBTestHandler{}
+			postDBTestHandler := &PostDBTestHandler{}
+			cacheTestHandler := &helpers.TestCache{}
+			a := &APIEnv{
+				BookmarkDBHandler:    bookmarkDBTestHandler,
+				PostDBHandler:        postDBTestHandler,
+				LikesCacheHandler:    cacheTestHandler,
+				CommentsCacheHandler: cacheTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.ShareTokenKey, "token")
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodGet, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			c.Request = req
+
+			bookmarkDBTestHandler.GetSharedCollectionFunc = func(token string) (*models.BookmarkCollection, error) {
+				return collection, tt.dbError
+			}
+			var receivedUserID string
+			var receivedCollectionID *helpers.NullableUint
+			bookmarkDBTestHandler.GetBookmarksFunc = func(userID string, collectionID *helpers.NullableUint, cutoff *helpers.NullableUint) ([]models.Bookmark, error) {
+				receivedUserID, receivedCollectionID = userID, collectionID
+				return []models.Bookmark{{ID: 4, PostID: testPostID}}, nil
+			}
+			var receivedViewerID string
+			postDBTestHandler.GetPostsByIDsFunc = func(postIDs []uint, since time.Time, userID string) ([]models.Post, error) {
+				receivedViewerID = userID
+				return []models.Post{{Model: gorm.Model{ID: testPostID}}}, nil
+			}
+			cacheTestHandler.SetMockGetCacheValFunc(0, nil)
+			a.GetSharedBookmarkCollection(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			if receivedUserID != testUserID || *receivedCollectionID != *helpers.NewNullableUint(testCollectionID) || receivedViewerID != "" {
+				t.Errorf("Retrieved bookmarks of %q in %+v for viewer %q", receivedUserID, receivedCollectionID, receivedViewerID)
+			}
+			data := m["data"].(map[string]interface{})
+			expectedNextURL := helpers.GenerateSharedCollectionNextPageURL(models.BackendAddress, "token", 4)
+			if data["Name"] != "Reading list" || len(data["Posts"].([]interface{})) != 1 || data["NextPageURL"] != expectedNextURL {
+				t.Errorf("Unexpected collection %v", data)
+			}
+		})
+	}
+}
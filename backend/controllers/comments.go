@@ -9,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/contentfilter"
 	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
@@ -33,8 +34,11 @@ func (a *APIEnv) InitialiseCommentHandler(client *redis.Client) {
 		DB: a.DB,
 	}
 	a.CommentsCacheHandler = &Cache{
-		redisDB:   client,
-		DBHandler: a.CommentDBHandler,
+		redisDB:      client,
+		Key:          helpers.CommentCountKey,
+		DBHandler:    a.CommentDBHandler,
+		Ranker:       a.PostRanker,
+		RankingCount: rankingCountComments,
 	}
 }
 
@@ -63,31 +67,62 @@ func (a *APIEnv) CreateComment(ctx *gin.Context) {
 	}
 	newComment.PostID = postID
 
+	// If the content filter rejects the comment, return status code 422 Unprocessable Entity
+	content := &contentfilter.Content{
+		Kind:     contentfilter.KindComment,
+		AuthorID: userID,
+		Text:     newComment.Text,
+	}
+	filterResult := a.ContentFilter.Check(ctx, content)
+	if filterResult.Verdict == contentfilter.Reject {
+		helpers.OutputError(ctx, http.StatusUnprocessableEntity, filterResult.Reason)
+		return
+	}
+	newComment.HeldForReview = filterResult.Verdict == contentfilter.Hold
+
 	comment, err := a.CommentDBHandler.CreateComment(&newComment)
 
+	// The comment was not saved, so the user may send it again
+	if err != nil {
+		a.ContentFilter.Release(ctx, content)
+	}
+	// If the user and the owner of the post have blocked each other, return status code
+	// 403 Forbidden
+	if errors.Is(err, database.ErrBlocked) {
+		helpers.OutputError(ctx, http.StatusForbidden, ErrBlocked)
+		return
+	}
 	// If comment cannot be created, return status code 500 Internal Service Error
 	if err != nil {
 		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotCreateComment)
 		return
 	}
 
-	newCommentCount, err := a.CommentsCacheHandler.SetCacheVal(ctx, uint(newComment.PostID))
+	newCommentCount, err := a.CommentsCacheHandler.IncrCacheVal(ctx, uint(newComment.PostID), 1)
 	if err != nil {
 		helpers.OutputError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
+	output := models.CommentUpdate{
+		Comment:      *comment.CommentView(userID),
+		CommentCount: newCommentCount,
+	}
+
+	// If the comment is held for review, return status code 202 Accepted. The owner of
+	// the post is not notified until the comment is shown.
+	if newComment.HeldForReview {
+		a.queueHeldContent(models.ReportTargetComment, comment.ID, filterResult)
+		helpers.OutputAcceptedData(ctx, output)
+		return
+	}
+
 	notif := helpers.GenerateCommentNotification(&comment.User, comment.Post.UserID)
 
 	// Even if there is an error in creating the notification server-side,
 	// this should not throw an error client-side
 	a.NotificationPoster.PostNotificationFromEvent(ctx, notif)
 
-	output := models.CommentUpdate{
-		Comment:      *comment.CommentView(userID),
-		CommentCount: newCommentCount,
-	}
-
 	helpers.OutputData(ctx, output)
 }
 
@@ -102,6 +137,13 @@ func (a *APIEnv) DeleteComment(ctx *gin.Context) {
 	}
 
 	postID, err := a.CommentDBHandler.DeleteComment(commentID, userID)
+	// Admins may delete comments that they do not own
+	err = retryAsAdmin(a, ctx, err, models.NewAuditLog(models.AuditActionDelete, models.AuditTargetComment, commentID),
+		func() (*models.Comment, error) { return a.CommentDBHandler.GetCommentByID(commentID) },
+		func(ownerID string) (err error) {
+			postID, err = a.CommentDBHandler.DeleteComment(commentID, ownerID)
+			return err
+		})
 	// If comment cannot be found in the database, return status code 404 Status Not Found
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		helpers.OutputError(ctx, http.StatusNotFound, ErrCommentNotFound)
@@ -118,7 +160,7 @@ func (a *APIEnv) DeleteComment(ctx *gin.Context) {
 		return
 	}
 
-	newCommentCount, err := a.CommentsCacheHandler.SetCacheVal(ctx, postID)
+	newCommentCount, err := a.CommentsCacheHandler.IncrCacheVal(ctx, postID, -1)
 	if err != nil {
 		helpers.OutputError(ctx, http.StatusInternalServerError, err)
 		return
@@ -147,7 +189,7 @@ func (a *APIEnv) GetComments(ctx *gin.Context) {
 		return
 	}
 
-	comments, err := a.CommentDBHandler.GetComments(postID, cutoff)
+	comments, err := a.CommentDBHandler.GetComments(postID, cutoff, userID)
 	// If unable to retrieve comments, return status code 404 Not Found
 	if err != nil {
 		helpers.OutputError(ctx, http.StatusNotFound, ErrCommentNotFound)
@@ -191,7 +233,28 @@ func (a *APIEnv) UpdateComment(ctx *gin.Context) {
 		return
 	}
 
+	// If the content filter rejects the update, return status code 422 Unprocessable Entity
+	filterResult := a.ContentFilter.Check(ctx, &contentfilter.Content{
+		Kind:     contentfilter.KindComment,
+		AuthorID: userID,
+		Text:     inputUpdate.Text,
+		IsEdit:   true,
+	})
+	if filterResult.Verdict == contentfilter.Reject {
+		helpers.OutputError(ctx, http.StatusUnprocessableEntity, filterResult.Reason)
+		return
+	}
+	// Editing a held comment does not release it, since false is not written by the update
+	inputUpdate.HeldForReview = filterResult.Verdict == contentfilter.Hold
+
 	comment, err := a.CommentDBHandler.UpdateComment(&inputUpdate, commentID, userID)
+	// Admins may update comments that they do not own
+	err = retryAsAdmin(a, ctx, err, models.NewAuditLog(models.AuditActionUpdate, models.AuditTargetComment, commentID),
+		func() (*models.Comment, error) { return a.CommentDBHandler.GetCommentByID(commentID) },
+		func(ownerID string) (err error) {
+			comment, err = a.CommentDBHandler.UpdateComment(&inputUpdate, commentID, ownerID)
+			return err
+		})
 
 	// If comment cannot be found in the database, return status code 404 Status Not Found
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -208,5 +271,11 @@ func (a *APIEnv) UpdateComment(ctx *gin.Context) {
 		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateComment)
 		return
 	}
+	// If the comment is held for review, return status code 202 Accepted
+	if inputUpdate.HeldForReview {
+		a.queueHeldContent(models.ReportTargetComment, commentID, filterResult)
+		helpers.OutputAcceptedData(ctx, comment.CommentView(userID))
+		return
+	}
 	helpers.OutputData(ctx, comment.CommentView(userID))
 }
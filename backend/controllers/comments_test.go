@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/contentfilter"
 	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
@@ -48,10 +50,11 @@ var (
 type CommentsDBTestHandler struct {
 	CreateCommentFunc  func(*models.Comment) (*models.Comment, error)
 	DeleteCommentFunc  func(uint, string) (uint, error)
-	GetCommentsFunc    func(uint, *helpers.NullableUint) ([]models.Comment, error)
+	GetCommentsFunc    func(uint, *helpers.NullableUint, string) ([]models.Comment, error)
 	GetCommentByIDFunc func(uint) (*models.Comment, error)
 	UpdateCommentFunc  func(*models.Comment, uint, string) (*models.Comment, error)
 	GetValueFunc       func(uint) (uint64, error)
+	GetValuesFunc      func([]uint) (map[uint]uint64, error)
 }
 
 func (h *CommentsDBTestHandler) CreateComment(comment *models.Comment) (*models.Comment, error) {
@@ -62,8 +65,8 @@ func (h *CommentsDBTestHandler) DeleteComment(commentID uint, userID string) (ui
 	return h.DeleteCommentFunc(commentID, userID)
 }
 
-func (h *CommentsDBTestHandler) GetComments(postID uint, cutoff *helpers.NullableUint) ([]models.Comment, error) {
-	return h.GetCommentsFunc(postID, cutoff)
+func (h *CommentsDBTestHandler) GetComments(postID uint, cutoff *helpers.NullableUint, userID string) ([]models.Comment, error) {
+	return h.GetCommentsFunc(postID, cutoff, userID)
 }
 
 func (h *CommentsDBTestHandler) GetCommentByID(commentID uint) (*models.Comment, error) {
@@ -78,6 +81,10 @@ func (h *CommentsDBTestHandler) GetValue(postID uint) (uint64, error) {
 	return h.GetValueFunc(postID)
 }
 
+func (h *CommentsDBTestHandler) GetValues(postIDs []uint) (map[uint]uint64, error) {
+	return h.GetValuesFunc(postIDs)
+}
+
 func (h *CommentsDBTestHandler) SetMockCreateCommentFunc(newComment *models.Comment, err error) {
 	h.CreateCommentFunc = func(comment *models.Comment) (*models.Comment, error) {
 		return newComment, err
@@ -91,7 +98,7 @@ func (h *CommentsDBTestHandler) SetMockDeleteCommentFunc(postID uint, err error)
 }
 
 func (h *CommentsDBTestHandler) SetMockGetCommentsFunc(comments []models.Comment, err error) {
-	h.GetCommentsFunc = func(postID uint, cutoff *helpers.NullableUint) ([]models.Comment, error) {
+	h.GetCommentsFunc = func(postID uint, cutoff *helpers.NullableUint, userID string) ([]models.Comment, error) {
 		return comments, err
 	}
 }
@@ -198,6 +205,8 @@ func TestAPIEnv_CreateComment(t *testing.T) {
 		CommentDBError     error
 		CommentCacheOutput uint64
 		CommentCacheError  error
+		FilterVerdict      contentfilter.Verdict
+		FilterReason       error
 	}
 	tests := []struct {
 		name     string
@@ -285,6 +294,68 @@ func TestAPIEnv_CreateComment(t *testing.T) {
 				Error:      ErrPostNotFound,
 			},
 		},
+		{
+			"Create comment rejected by content filter",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				QueryParams: map[string]interface{}{
+					helpers.PostIDQueryKey: testPostID,
+				},
+				CommentData:   &newTestComment,
+				FilterVerdict: contentfilter.Reject,
+				FilterReason:  contentfilter.ErrDuplicate,
+			},
+			helpers.ExpectedJSONOutput[models.CommentUpdate]{
+				StatusCode: http.StatusUnprocessableEntity,
+				JSONType:   helpers.ExpectedError,
+				Error:      contentfilter.ErrDuplicate,
+			},
+		},
+		{
+			"Create comment held by content filter",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				QueryParams: map[string]interface{}{
+					helpers.PostIDQueryKey: testPostID,
+				},
+				CommentData:        &newTestComment,
+				CommentDBOutput:    &defaultComment,
+				CommentCacheOutput: 1,
+				FilterVerdict:      contentfilter.Hold,
+				FilterReason:       contentfilter.ErrTooManyLinks,
+			},
+			helpers.ExpectedJSONOutput[models.CommentUpdate]{
+				StatusCode: http.StatusAccepted,
+				JSONType:   helpers.ExpectedData,
+				Data: &models.CommentUpdate{
+					Comment:      *defaultComment.CommentView(testUserID),
+					CommentCount: 1,
+				},
+			},
+		},
+		{
+			"Create comment blocked",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				QueryParams: map[string]interface{}{
+					helpers.PostIDQueryKey: testPostID,
+				},
+				CommentData:     &newTestComment,
+				CommentDBOutput: &defaultComment,
+				CommentDBError:  database.ErrBlocked,
+			},
+			helpers.ExpectedJSONOutput[models.CommentUpdate]{
+				StatusCode: http.StatusForbidden,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBlocked,
+			},
+		},
 		{
 			"Create comment DB throws error",
 			args{
@@ -331,10 +402,14 @@ func TestAPIEnv_CreateComment(t *testing.T) {
 			dbTestHandler := &CommentsDBTestHandler{}
 			cacheTestHandler := &helpers.TestCache{}
 			notifPoster := &helpers.TestNotificationCreator{}
+			contentFilter := &helpers.TestContentFilter{}
+			reportDBTestHandler := &ReportDBTestHandler{}
 			a := &APIEnv{
 				CommentDBHandler:     dbTestHandler,
 				CommentsCacheHandler: cacheTestHandler,
 				NotificationPoster:   notifPoster,
+				ContentFilter:        contentFilter,
+				ReportDBHandler:      reportDBTestHandler,
 			}
 
 			c, w := helpers.CreateTestContextAndRecorder()
@@ -356,14 +431,35 @@ func TestAPIEnv_CreateComment(t *testing.T) {
 				c.Request = req
 			}
 			dbTestHandler.SetMockCreateCommentFunc(tt.args.CommentDBOutput, tt.args.CommentDBError)
-			cacheTestHandler.SetMockSetCacheValFunc(tt.args.CommentCacheOutput, tt.args.CommentCacheError)
-			notifPoster.SetMockPostNotificationFromEventFunc(nil)
+			cacheTestHandler.SetMockIncrCacheValFunc(tt.args.CommentCacheOutput, tt.args.CommentCacheError)
+			notifSent := false
+			notifPoster.PostNotificationFromEventFunc = func(ctx *gin.Context, notif *models.Notification) error {
+				notifSent = true
+				return nil
+			}
+			contentFilter.SetMockCheckFunc(tt.args.FilterVerdict, tt.args.FilterReason)
+			reportQueued := false
+			reportDBTestHandler.CreateReportFunc = func(report *models.Report) (*models.Report, error) {
+				reportQueued = report.ReporterID == models.ContentFilterReporterID && report.TargetType == models.ReportTargetComment
+				return report, nil
+			}
 			a.CreateComment(c)
 
 			b, _ := io.ReadAll(w.Body)
 			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
 				t.Error(errStr)
 			}
+			// Held comments are queued for review instead of notifying the owner of the post
+			if reportQueued != (tt.expected.StatusCode == http.StatusAccepted) {
+				t.Errorf("Held comment queued for review = %v", reportQueued)
+			}
+			if notifSent != (tt.expected.StatusCode == http.StatusOK) {
+				t.Errorf("Comment notification sent = %v", notifSent)
+			}
+			// Comments that are not saved can be sent again
+			if released := len(contentFilter.Released) > 0; released != (tt.args.CommentDBError != nil) {
+				t.Errorf("Comment released by content filter = %v", released)
+			}
 
 			m, err := helpers.ParseJSONString(b)
 			if err != nil {
@@ -509,7 +605,7 @@ func TestAPIEnv_DeleteComment(t *testing.T) {
 			}
 
 			dbTestHandler.SetMockDeleteCommentFunc(tt.args.CommentDBOutput, tt.args.CommentDBError)
-			cacheTestHandler.SetMockSetCacheValFunc(tt.args.CommentCacheOutput, tt.args.CommentCacheError)
+			cacheTestHandler.SetMockIncrCacheValFunc(tt.args.CommentCacheOutput, tt.args.CommentCacheError)
 			a.DeleteComment(c)
 
 			b, _ := io.ReadAll(w.Body)
@@ -775,6 +871,8 @@ func TestAPIEnv_UpdateComment(t *testing.T) {
 		CommentUpdate   *models.Comment
 		CommentDBOutput *models.Comment
 		CommentDBError  error
+		FilterVerdict   contentfilter.Verdict
+		FilterReason    error
 	}
 	tests := []struct {
 		name     string
@@ -899,14 +997,33 @@ func TestAPIEnv_UpdateComment(t *testing.T) {
 				Error:      ErrCannotUpdateComment,
 			},
 		},
+		{
+			"Update comment rejected by content filter",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:    testUserID,
+					helpers.CommentIDKey: testCommentID,
+				},
+				CommentUpdate: &newTestComment,
+				FilterVerdict: contentfilter.Reject,
+				FilterReason:  contentfilter.ErrBannedWord,
+			},
+			helpers.ExpectedJSONOutput[models.CommentView]{
+				StatusCode: http.StatusUnprocessableEntity,
+				JSONType:   helpers.ExpectedError,
+				Error:      contentfilter.ErrBannedWord,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			dbTestHandler := &CommentsDBTestHandler{}
 			cacheTestHandler := &helpers.TestCache{}
+			contentFilter := &helpers.TestContentFilter{}
 			a := &APIEnv{
 				CommentDBHandler:     dbTestHandler,
 				CommentsCacheHandler: cacheTestHandler,
+				ContentFilter:        contentFilter,
 			}
 
 			c, w := helpers.CreateTestContextAndRecorder()
@@ -923,6 +1040,7 @@ func TestAPIEnv_UpdateComment(t *testing.T) {
 				c.Request = req
 			}
 			dbTestHandler.SetMockUpdateCommentFunc(tt.args.CommentDBOutput, tt.args.CommentDBError)
+			contentFilter.SetMockCheckFunc(tt.args.FilterVerdict, tt.args.FilterReason)
 			a.UpdateComment(c)
 
 			b, _ := io.ReadAll(w.Body)
@@ -5,6 +5,7 @@ package controllers
 
 import (
 	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -14,11 +15,20 @@ import (
 	"gorm.io/gorm"
 )
 
+// Messages
+const (
+	CommunityJoinedMsg = "Community joined"
+	CommunityLeftMsg   = "Community left"
+)
+
 // Errors
 var (
 	ErrCannotCreateCommunity = errors.New("cannot create community")
+	ErrCannotJoinCommunity   = errors.New("cannot join community")
+	ErrCannotLeaveCommunity  = errors.New("cannot leave community")
 	ErrCannotUpdateCommunity = errors.New("cannot update community")
 	ErrCommunityNotFound     = errors.New("community not found")
+	ErrNotCommunityMember    = errors.New("you are not a member of this community")
 )
 
 func (a *APIEnv) InitialiseCommunityHandler() {
@@ -117,6 +127,13 @@ func (a *APIEnv) UpdateCommunity(ctx *gin.Context) {
 	}
 
 	community, err := a.CommunityDBHandler.UpdateCommunity(&inputUpdate, communityName, userID)
+	// Admins may update communities that they do not own
+	err = retryAsAdmin(a, ctx, err, models.NewAuditLog(models.AuditActionUpdate, models.AuditTargetCommunity, communityName),
+		func() (*models.Community, error) { return a.CommunityDBHandler.GetCommunityByName(communityName) },
+		func(ownerID string) (err error) {
+			community, err = a.CommunityDBHandler.UpdateCommunity(&inputUpdate, communityName, ownerID)
+			return err
+		})
 
 	// If community cannot be found in the database, return status code 404 Status Not Found
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -135,3 +152,44 @@ func (a *APIEnv) UpdateCommunity(ctx *gin.Context) {
 	}
 	helpers.OutputData(ctx, community.CommunityView(userID))
 }
+
+// Adds the user to the members of the community in the URL, adding its posts to the
+// user's home feed
+func (a *APIEnv) JoinCommunity(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	community, err := a.CommunityDBHandler.JoinCommunity(helpers.GetCommunityNameFromContext(ctx), userID)
+	// If community cannot be found in the database, return status code 404 Status Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrCommunityNotFound)
+		return
+	}
+	// If community cannot be joined for any other reason, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotJoinCommunity)
+		return
+	}
+	// Even if the community's recent posts cannot be added to the home feed, its new
+	// posts are
+	if err := a.HomeTimeline.BackfillCommunity(ctx, userID, community.ID); err != nil {
+		log.Printf("Unable to add posts in community %d to home feed: %v", community.ID, err)
+	}
+	helpers.OutputMessage(ctx, CommunityJoinedMsg)
+}
+
+// Removes the user from the members of the community in the URL. Its posts are no longer
+// shown in the user's home feed, so they do not need to be removed from it.
+func (a *APIEnv) LeaveCommunity(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	err := a.CommunityDBHandler.LeaveCommunity(helpers.GetCommunityNameFromContext(ctx), userID)
+	// If the community cannot be found or the user is not a member, return status code 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrNotCommunityMember)
+		return
+	}
+	// If community cannot be left for any other reason, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotLeaveCommunity)
+		return
+	}
+	helpers.OutputMessage(ctx, CommunityLeftMsg)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
@@ -54,6 +55,8 @@ type CommunityDBTestHandler struct {
 	GetCommunityByNameFunc func(string) (*models.Community, error)
 	GetCommunitiesFunc     func(*helpers.NullableUint) ([]models.Community, error)
 	UpdateCommunityFunc    func(*models.Community, string, string) (*models.Community, error)
+	JoinCommunityFunc      func(string, string) (*models.Community, error)
+	LeaveCommunityFunc     func(string, string) error
 }
 
 func (h *CommunityDBTestHandler) CreateCommunity(newCommunity *models.Community) (*models.Community, error) {
@@ -76,8 +79,12 @@ func (h *CommunityDBTestHandler) UpdateCommunity(update *models.Community, commu
 	return h.UpdateCommunityFunc(update, communityName, userID)
 }
 
-func (h *CommunityDBTestHandler) QueryCommunity(queryString string, cutoff int) ([]models.SearchResult, error) {
-	return nil, nil
+func (h *CommunityDBTestHandler) JoinCommunity(communityName string, userID string) (*models.Community, error) {
+	return h.JoinCommunityFunc(communityName, userID)
+}
+
+func (h *CommunityDBTestHandler) LeaveCommunity(communityName string, userID string) error {
+	return h.LeaveCommunityFunc(communityName, userID)
 }
 
 func (h *CommunityDBTestHandler) SetMockCreateCommunityFunc(community *models.Community, err error) {
@@ -617,3 +624,69 @@ func TestAPIEnv_UpdateCommunity(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIEnv_JoinAndLeaveCommunity(t *testing.T) {
+	type handlerFunc func(*APIEnv, *gin.Context)
+	join := func(a *APIEnv, ctx *gin.Context) { a.JoinCommunity(ctx) }
+	leave := func(a *APIEnv, ctx *gin.Context) { a.LeaveCommunity(ctx) }
+	tests := []struct {
+		name             string
+		handler          handlerFunc
+		communityDBError error
+		backfillError    error
+		wantBackfill     bool
+		expected         helpers.ExpectedJSONOutput[string]
+	}{
+		{"Join community OK", join, nil, nil, true, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: CommunityJoinedMsg}},
+		// The community is still joined if its recent posts cannot be added to the home feed
+		{"Join community cannot backfill", join, nil, ErrTest, true, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: CommunityJoinedMsg}},
+		{"Join community not found", join, gorm.ErrRecordNotFound, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrCommunityNotFound}},
+		{"Join community cannot join", join, ErrTest, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotJoinCommunity}},
+		{"Leave community OK", leave, nil, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: CommunityLeftMsg}},
+		{"Leave community not member", leave, gorm.ErrRecordNotFound, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrNotCommunityMember}},
+		{"Leave community cannot leave", leave, ErrTest, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotLeaveCommunity}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &CommunityDBTestHandler{}
+			timeline := &TestHomeTimeline{BackfillError: tt.backfillError}
+			a := &APIEnv{
+				CommunityDBHandler: dbTestHandler,
+				HomeTimeline:       timeline,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.CommunityNameKey, testCommunityName)
+
+			dbTestHandler.JoinCommunityFunc = func(communityName string, userID string) (*models.Community, error) {
+				return &testCommunity, tt.communityDBError
+			}
+			dbTestHandler.LeaveCommunityFunc = func(communityName string, userID string) error {
+				return tt.communityDBError
+			}
+			tt.handler(a, c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if backfilled := len(timeline.BackfilledCommunity) == 1 && timeline.BackfilledCommunity[0] == testCommunityID; backfilled != tt.wantBackfill {
+				t.Errorf("Community posts added to home feed = %v, want %v", backfilled, tt.wantBackfill)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
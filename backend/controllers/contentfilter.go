@@ -0,0 +1,44 @@
+/*
+Contains functions for passing posts and comments through the content filter.
+*/
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/ryanozx/skillnet/contentfilter"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/models"
+)
+
+// ContentFilter is implemented by contentfilter.Chain
+type ContentFilter interface {
+	Check(ctx context.Context, content *contentfilter.Content) *contentfilter.Result
+	// Removes what the filters recorded when checking content that could not be saved
+	Release(ctx context.Context, content *contentfilter.Content)
+}
+
+func (a *APIEnv) InitialiseContentFilter(filter ContentFilter) {
+	a.ContentFilter = filter
+}
+
+// Adds content held by the content filter to the moderation queue. As the content has
+// already been saved, failing to do so is logged rather than returned to the user.
+func (a *APIEnv) queueHeldContent(targetType string, targetID uint, result *contentfilter.Result) {
+	report := &models.Report{
+		ReporterID: models.ContentFilterReporterID,
+		TargetType: targetType,
+		TargetID:   strconv.FormatUint(uint64(targetID), 10),
+		Reason:     models.ReportReasonFiltered,
+		Note:       result.Reason.Error(),
+		Status:     models.ReportOpen,
+	}
+	// Edited content may already be waiting for review
+	_, err := a.ReportDBHandler.CreateReport(report)
+	if err != nil && !errors.Is(err, database.ErrAlreadyReported) {
+		log.Printf("Unable to queue held %s %d for review: %v", targetType, targetID, err)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Contains controllers for exporting a user's data.
+*/
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	ExportStartedMsg = "Your data export has started, you will be notified when it is ready to download"
+)
+
+// Errors
+var (
+	ErrCannotStartExport  = errors.New("cannot start data export")
+	ErrExportInProgress   = errors.New("a data export is already in progress")
+	ErrExportLinkExpired  = errors.New("download link is invalid or has expired")
+	ErrExportUnavailable  = errors.New("data export is unavailable")
+	ErrMissingExportToken = errors.New("missing download token")
+)
+
+// DataExporter is implemented by workers.DataExporter
+type DataExporter interface {
+	StartExport(*models.DataExport)
+	OpenExport(context.Context, *models.DataExport) (io.ReadCloser, error)
+}
+
+func (a *APIEnv) InitialiseExportHandler(exporter DataExporter) {
+	a.DataExporter = exporter
+	a.ExportDBHandler = &database.ExportDB{
+		DB: a.DB,
+	}
+}
+
+// Starts building an archive of the user's data in the background
+func (a *APIEnv) PostExport(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	export, err := a.ExportDBHandler.CreateExport(&models.DataExport{
+		UserID: userID,
+	})
+	// If the user already has an export running, return status code 409 Conflict
+	if errors.Is(err, database.ErrExportInProgress) {
+		helpers.OutputError(ctx, http.StatusConflict, ErrExportInProgress)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotStartExport)
+		return
+	}
+	a.DataExporter.StartExport(export)
+	helpers.OutputMessage(ctx, ExportStartedMsg)
+}
+
+// Downloads a ready export. The token in the download link identifies the export, so
+// no session is required.
+func (a *APIEnv) GetExportDownload(ctx *gin.Context) {
+	token := ctx.Query(helpers.DataExportTokenKey)
+	if token == "" {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrMissingExportToken)
+		return
+	}
+
+	export, err := a.ExportDBHandler.GetReadyExport(helpers.HashAccessToken(token), time.Now())
+	// If the token does not match an export or the link has expired, return status code
+	// 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrExportLinkExpired)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrExportUnavailable)
+		return
+	}
+
+	reader, err := a.DataExporter.OpenExport(ctx.Request.Context(), export)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrExportUnavailable)
+		return
+	}
+	defer reader.Close()
+	ctx.DataFromReader(http.StatusOK, -1, "application/zip", reader, map[string]string{
+		"Content-Disposition": "attachment; filename=\"" + helpers.DataExportFileName + "\"",
+	})
+}
@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+const (
+	testExportID      = 1
+	testExportToken   = "exporttoken"
+	testExportContent = "zip contents"
+)
+
+var defaultExport = models.DataExport{
+	Model: gorm.Model{
+		ID: testExportID,
+	},
+	UserID: testUserID,
+	Status: models.ExportReady,
+}
+
+type ExportDBTestHandler struct {
+	CreateExportFunc   func(*models.DataExport) (*models.DataExport, error)
+	GetReadyExportFunc func(string, time.Time) (*models.DataExport, error)
+}
+
+func (h *ExportDBTestHandler) CreateExport(export *models.DataExport) (*models.DataExport, error) {
+	return h.CreateExportFunc(export)
+}
+
+func (h *ExportDBTestHandler) GetReadyExport(tokenHash string, now time.Time) (*models.DataExport, error) {
+	return h.GetReadyExportFunc(tokenHash, now)
+}
+
+func (h *ExportDBTestHandler) SetMockCreateExportFunc(err error) {
+	h.CreateExportFunc = func(export *models.DataExport) (*models.DataExport, error) {
+		return export, err
+	}
+}
+
+func (h *ExportDBTestHandler) SetMockGetReadyExportFunc(export *models.DataExport, err error) {
+	h.GetReadyExportFunc = func(tokenHash string, now time.Time) (*models.DataExport, error) {
+		if tokenHash != helpers.HashAccessToken(testExportToken) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return export, err
+	}
+}
+
+type TestDataExporter struct {
+	Started   []*models.DataExport
+	OpenError error
+}
+
+func (e *TestDataExporter) StartExport(export *models.DataExport) {
+	e.Started = append(e.Started, export)
+}
+
+func (e *TestDataExporter) OpenExport(ctx context.Context, export *models.DataExport) (io.ReadCloser, error) {
+	if e.OpenError != nil {
+		return nil, e.OpenError
+	}
+	return io.NopCloser(strings.NewReader(testExportContent)), nil
+}
+
+func TestAPIEnv_InitialiseExportHandler(t *testing.T) {
+	db := &gorm.DB{}
+	exporter := &TestDataExporter{}
+	a := &APIEnv{
+		DB: db,
+	}
+	a.InitialiseExportHandler(exporter)
+	if exportDB, ok := a.ExportDBHandler.(*database.ExportDB); !ok || exportDB.DB != db {
+		t.Error("ExportDBHandler not initialised correctly")
+	}
+	if a.DataExporter != exporter {
+		t.Error("DataExporter not initialised correctly")
+	}
+}
+
+func TestAPIEnv_PostExport(t *testing.T) {
+	type args struct {
+		ExportDBError error
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantStarted bool
+		expected    helpers.ExpectedJSONOutput[string]
+	}{
+		{
+			"Post Export OK",
+			args{},
+			true,
+			helpers.ExpectedJSONOutput[string]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedMessage,
+				Message:    ExportStartedMsg,
+			},
+		},
+		{
+			"Post Export in progress",
+			args{
+				ExportDBError: database.ErrExportInProgress,
+			},
+			false,
+			helpers.ExpectedJSONOutput[string]{
+				StatusCode: http.StatusConflict,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrExportInProgress,
+			},
+		},
+		{
+			"Post Export user not found",
+			args{
+				ExportDBError: gorm.ErrRecordNotFound,
+			},
+			false,
+			helpers.ExpectedJSONOutput[string]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrUserNotFound,
+			},
+		},
+		{
+			"Post Export cannot create",
+			args{
+				ExportDBError: ErrTest,
+			},
+			false,
+			helpers.ExpectedJSONOutput[string]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotStartExport,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := ExportDBTestHandler{}
+			exporter := TestDataExporter{}
+			a := &APIEnv{
+				ExportDBHandler: &dbTestHandler,
+				DataExporter:    &exporter,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+
+			dbTestHandler.SetMockCreateExportFunc(tt.args.ExportDBError)
+			a.PostExport(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+
+			if started := len(exporter.Started) == 1; started != tt.wantStarted {
+				t.Errorf("Export started = %v, want %v", started, tt.wantStarted)
+			}
+			if tt.wantStarted && exporter.Started[0].UserID != testUserID {
+				t.Errorf("Export started for wrong user: %s", exporter.Started[0].UserID)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetExportDownload(t *testing.T) {
+	type args struct {
+		Token         string
+		ExportDBError error
+		OpenError     error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[string]
+	}{
+		{
+			"Download Export missing token",
+			args{},
+			helpers.ExpectedJSONOutput[string]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrMissingExportToken,
+			},
+		},
+		{
+			"Download Export invalid token",
+			args{
+				Token: "badtoken",
+			},
+			helpers.ExpectedJSONOutput[string]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrExportLinkExpired,
+			},
+		},
+		{
+			"Download Export cannot get export",
+			args{
+				Token:         testExportToken,
+				ExportDBError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[string]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrExportUnavailable,
+			},
+		},
+		{
+			"Download Export cannot open export",
+			args{
+				Token:     testExportToken,
+				OpenError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[string]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrExportUnavailable,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := ExportDBTestHandler{}
+			a := &APIEnv{
+				ExportDBHandler: &dbTestHandler,
+				DataExporter: &TestDataExporter{
+					OpenError: tt.args.OpenError,
+				},
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+			if tt.args.Token != "" {
+				helpers.AddParamsToQuery(c.Request, helpers.DataExportTokenKey, tt.args.Token)
+			}
+
+			dbTestHandler.SetMockGetReadyExportFunc(&defaultExport, tt.args.ExportDBError)
+			a.GetExportDownload(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetExportDownload_OK(t *testing.T) {
+	dbTestHandler := ExportDBTestHandler{}
+	a := &APIEnv{
+		ExportDBHandler: &dbTestHandler,
+		DataExporter:    &TestDataExporter{},
+	}
+	c, w := helpers.CreateTestContextAndRecorder()
+	c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+	helpers.AddParamsToQuery(c.Request, helpers.DataExportTokenKey, testExportToken)
+
+	dbTestHandler.SetMockGetReadyExportFunc(&defaultExport, nil)
+	a.GetExportDownload(c)
+
+	if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(http.StatusOK, w.Code); !isEqual {
+		t.Fatal(errStr)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/zip" {
+		t.Errorf("Unexpected content type: %s", contentType)
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), helpers.DataExportFileName) {
+		t.Errorf("Unexpected content disposition: %s", w.Header().Get("Content-Disposition"))
+	}
+	if body := w.Body.String(); body != testExportContent {
+		t.Errorf("Unexpected body: %s", body)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Contains controllers for following users.
+*/
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	UserFollowedMsg   = "User followed"
+	UserUnfollowedMsg = "User unfollowed"
+)
+
+// Errors
+var (
+	ErrCannotFollowSelf            = errors.New("you cannot follow yourself")
+	ErrCannotFollowUser            = errors.New("cannot follow user")
+	ErrCannotRetrieveFollowingList = errors.New("cannot retrieve followed users")
+	ErrCannotUnfollowUser          = errors.New("cannot unfollow user")
+	ErrNotFollowing                = errors.New("user is not followed")
+)
+
+func (a *APIEnv) InitialiseFollowHandler() {
+	a.FollowDBHandler = &database.FollowDB{
+		DB: a.DB,
+	}
+}
+
+// Follows the user in the URL, adding their posts to the user's home feed
+func (a *APIEnv) FollowUser(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	followee, err := a.FollowDBHandler.FollowUser(userID, helpers.GetUsernameFromContext(ctx))
+	switch {
+	case err == nil:
+	// If the user cannot be found, return status code 404 Not Found
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return
+	// If users try to follow themselves, return status code 400 Bad Request
+	case errors.Is(err, database.ErrFollowingOneself):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCannotFollowSelf)
+		return
+	// If either user has blocked the other, return status code 403 Forbidden
+	case errors.Is(err, database.ErrBlocked):
+		helpers.OutputError(ctx, http.StatusForbidden, ErrBlocked)
+		return
+	default:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotFollowUser)
+		return
+	}
+	// Even if the followee's recent posts cannot be added to the home feed, their new
+	// posts are
+	if err := a.HomeTimeline.BackfillFollowee(ctx, userID, followee.ID); err != nil {
+		log.Printf("Unable to add posts by user %s to home feed: %v", followee.ID, err)
+	}
+	helpers.OutputMessage(ctx, UserFollowedMsg)
+}
+
+// Unfollows the user in the URL. Their posts are no longer shown in the user's home
+// feed, so they do not need to be removed from it.
+func (a *APIEnv) UnfollowUser(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	err := a.FollowDBHandler.UnfollowUser(userID, helpers.GetUsernameFromContext(ctx))
+	switch {
+	case err == nil:
+		helpers.OutputMessage(ctx, UserUnfollowedMsg)
+	// If the user cannot be found or is not followed, return status code 404 Not Found
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrNotFollowing)
+	// If users try to unfollow themselves, return status code 400 Bad Request
+	case errors.Is(err, database.ErrFollowingOneself):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCannotFollowSelf)
+	default:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUnfollowUser)
+	}
+}
+
+func (a *APIEnv) GetFollowedUsers(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	follows, err := a.FollowDBHandler.GetFollowing(userID)
+	// If unable to retrieve followed users, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveFollowingList)
+		return
+	}
+	views := []models.ListedUserView{}
+	for i := range follows {
+		views = append(views, *follows[i].Followee.ListedUserView(follows[i].CreatedAt))
+	}
+	helpers.OutputData(ctx, views)
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+const testFollowedUsername = "followeduser"
+
+type FollowDBTestHandler struct {
+	FollowUserFunc   func(string, string) (*models.User, error)
+	UnfollowUserFunc func(string, string) error
+	GetFollowingFunc func(string) ([]models.Follow, error)
+}
+
+func (h *FollowDBTestHandler) FollowUser(userID string, username string) (*models.User, error) {
+	return h.FollowUserFunc(userID, username)
+}
+
+func (h *FollowDBTestHandler) UnfollowUser(userID string, username string) error {
+	return h.UnfollowUserFunc(userID, username)
+}
+
+func (h *FollowDBTestHandler) GetFollowing(userID string) ([]models.Follow, error) {
+	return h.GetFollowingFunc(userID)
+}
+
+func TestAPIEnv_FollowAndUnfollowUser(t *testing.T) {
+	type handlerFunc func(*APIEnv, *gin.Context)
+	follow := func(a *APIEnv, ctx *gin.Context) { a.FollowUser(ctx) }
+	unfollow := func(a *APIEnv, ctx *gin.Context) { a.UnfollowUser(ctx) }
+	tests := []struct {
+		name          string
+		handler       handlerFunc
+		followDBError error
+		backfillError error
+		wantBackfill  bool
+		expected      helpers.ExpectedJSONOutput[string]
+	}{
+		{"Follow user OK", follow, nil, nil, true, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: UserFollowedMsg}},
+		// The user is still followed if their recent posts cannot be added to the home feed
+		{"Follow user cannot backfill", follow, nil, ErrTest, true, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: UserFollowedMsg}},
+		{"Follow user not found", follow, gorm.ErrRecordNotFound, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrUserNotFound}},
+		{"Follow self", follow, database.ErrFollowingOneself, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrCannotFollowSelf}},
+		{"Follow user blocked", follow, database.ErrBlocked, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusForbidden, JSONType: helpers.ExpectedError, Error: ErrBlocked}},
+		{"Follow user cannot follow", follow, ErrTest, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotFollowUser}},
+		{"Unfollow user OK", unfollow, nil, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: UserUnfollowedMsg}},
+		{"Unfollow user not followed", unfollow, gorm.ErrRecordNotFound, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrNotFollowing}},
+		{"Unfollow user cannot unfollow", unfollow, ErrTest, nil, false, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotUnfollowUser}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &FollowDBTestHandler{}
+			timeline := &TestHomeTimeline{BackfillError: tt.backfillError}
+			a := &APIEnv{
+				FollowDBHandler: dbTestHandler,
+				HomeTimeline:    timeline,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.UsernameKey, testFollowedUsername)
+
+			dbTestHandler.FollowUserFunc = func(userID string, username string) (*models.User, error) {
+				if tt.followDBError != nil {
+					return nil, tt.followDBError
+				}
+				return &models.User{ID: diffUserID}, nil
+			}
+			dbTestHandler.UnfollowUserFunc = func(userID string, username string) error {
+				return tt.followDBError
+			}
+			tt.handler(a, c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if backfilled := len(timeline.BackfilledFollowees) == 1 && timeline.BackfilledFollowees[0] == diffUserID; backfilled != tt.wantBackfill {
+				t.Errorf("Followee posts added to home feed = %v, want %v", backfilled, tt.wantBackfill)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetFollowedUsers(t *testing.T) {
+	helpers.SetEnvVars(t)
+	since := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	followedUser := models.User{
+		ID: diffUserID,
+		UserCredentials: models.UserCredentials{
+			Username: testFollowedUsername,
+		},
+	}
+	tests := []struct {
+		name          string
+		followDBError error
+		expectedCode  int
+		expectedErr   error
+	}{
+		{"Get followed users OK", nil, http.StatusOK, nil},
+		{"Get followed users cannot retrieve", ErrTest, http.StatusInternalServerError, ErrCannotRetrieveFollowingList},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &FollowDBTestHandler{}
+			a := &APIEnv{
+				FollowDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+
+			dbTestHandler.GetFollowingFunc = func(userID string) ([]models.Follow, error) {
+				return []models.Follow{{FollowerID: userID, FolloweeID: followedUser.ID, Followee: followedUser, CreatedAt: since}}, tt.followDBError
+			}
+			a.GetFollowedUsers(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			users, ok := m["data"].([]interface{})
+			if !ok || len(users) != 1 {
+				t.Fatalf("Expected one listed user, got %v", m["data"])
+			}
+			user := users[0].(map[string]interface{})
+			if user["Username"] != testFollowedUsername || user["Since"] != since.Format(time.RFC3339) {
+				t.Errorf("Unexpected listed user %v", user)
+			}
+		})
+	}
+}
@@ -32,8 +32,11 @@ func (a *APIEnv) InitialiseLikeHandler(client *redis.Client) {
 		DB: a.DB,
 	}
 	a.LikesCacheHandler = &Cache{
-		redisDB:   client,
-		DBHandler: a.LikeDBHandler,
+		redisDB:      client,
+		Key:          helpers.LikeCountKey,
+		DBHandler:    a.LikeDBHandler,
+		Ranker:       a.PostRanker,
+		RankingCount: rankingCountLikes,
 	}
 }
 
@@ -59,12 +62,17 @@ func (a *APIEnv) PostLike(ctx *gin.Context) {
 	if err == gorm.ErrDuplicatedKey {
 		helpers.OutputError(ctx, http.StatusBadRequest, ErrAlreadyLiked)
 		return
+	} else if errors.Is(err, database.ErrBlocked) {
+		// If the user and the owner of the post have blocked each other, return status
+		// code 403 Forbidden
+		helpers.OutputError(ctx, http.StatusForbidden, ErrBlocked)
+		return
 	} else if err != nil {
 		helpers.OutputError(ctx, http.StatusInternalServerError, ErrLikeNotRegistered)
 		return
 	}
 
-	newLikeCount, err := a.LikesCacheHandler.SetCacheVal(ctx, postID)
+	newLikeCount, err := a.LikesCacheHandler.IncrCacheVal(ctx, postID, 1)
 	if err != nil {
 		helpers.OutputError(ctx, http.StatusInternalServerError, ErrUpdateLikeCountFailed)
 		return
@@ -118,7 +126,7 @@ func (a *APIEnv) DeleteLike(ctx *gin.Context) {
 		return
 	}
 
-	newLikeCount, err := a.LikesCacheHandler.SetCacheVal(ctx, postID)
+	newLikeCount, err := a.LikesCacheHandler.IncrCacheVal(ctx, postID, -1)
 	if err != nil {
 		helpers.OutputError(ctx, http.StatusInternalServerError, ErrUpdateLikeCountFailed)
 		return
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"testing"
@@ -38,6 +39,7 @@ type LikeDBTestHandler struct {
 	CreateLikeFunc  func(*models.Like) (*models.Like, error)
 	DeleteLikeFunc  func(string, uint) error
 	GetCountFunc    func(uint) (uint64, error)
+	GetCountsFunc   func([]uint) (map[uint]uint64, error)
 	GetLikeByIDFunc func(string) (*models.Like, error)
 }
 
@@ -53,6 +55,10 @@ func (h *LikeDBTestHandler) GetValue(postID uint) (uint64, error) {
 	return h.GetCountFunc(postID)
 }
 
+func (h *LikeDBTestHandler) GetValues(postIDs []uint) (map[uint]uint64, error) {
+	return h.GetCountsFunc(postIDs)
+}
+
 func (h *LikeDBTestHandler) GetLikeByID(likeID string) (*models.Like, error) {
 	return h.GetLikeByIDFunc(likeID)
 }
@@ -231,6 +237,22 @@ func TestAPIEnv_PostLike(t *testing.T) {
 				Error:      ErrAlreadyLiked,
 			},
 		},
+		{
+			"Create Like blocked",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+					helpers.PostIDKey: testPostID,
+				},
+				LikeDBOutput: &defaultLike,
+				LikeDBError:  database.ErrBlocked,
+			},
+			helpers.ExpectedJSONOutput[models.LikeUpdate]{
+				StatusCode: http.StatusForbidden,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBlocked,
+			},
+		},
 		{
 			"Create Like cannot create like",
 			args{
@@ -310,7 +332,7 @@ func TestAPIEnv_PostLike(t *testing.T) {
 			c.Request = req
 
 			dbTestHandler.SetMockCreateLikeFunc(tt.args.LikeDBOutput, tt.args.LikeDBError)
-			cacheTestHandler.SetMockSetCacheValFunc(tt.args.LikeCacheOutput, tt.args.LikeCacheError)
+			cacheTestHandler.SetMockIncrCacheValFunc(tt.args.LikeCacheOutput, tt.args.LikeCacheError)
 			notifPoster.SetMockPostNotificationFromEventFunc(tt.args.NotificationError)
 			a.PostLike(c)
 
@@ -458,7 +480,7 @@ func TestAPIEnv_DeleteLike(t *testing.T) {
 			c.Request = req
 
 			dbTestHandler.SetMockDeleteLikeFunc(tt.args.LikeDBError)
-			cacheTestHandler.SetMockSetCacheValFunc(tt.args.LikeCacheOutput, tt.args.LikeCacheError)
+			cacheTestHandler.SetMockIncrCacheValFunc(tt.args.LikeCacheOutput, tt.args.LikeCacheError)
 			a.DeleteLike(c)
 
 			b, _ := io.ReadAll(w.Body)
@@ -477,3 +499,31 @@ func TestAPIEnv_DeleteLike(t *testing.T) {
 		})
 	}
 }
+
+// Unliking a post that was never liked must not decrement its like count
+func TestAPIEnv_DeleteLikeNeverLiked(t *testing.T) {
+	dbTestHandler := &LikeDBTestHandler{}
+	cacheTestHandler := &helpers.TestCache{}
+	a := &APIEnv{
+		LikeDBHandler:     dbTestHandler,
+		LikesCacheHandler: cacheTestHandler,
+	}
+	c, w := helpers.CreateTestContextAndRecorder()
+	helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+	helpers.AddParamsToContext(c, helpers.PostIDKey, testPostID)
+
+	dbTestHandler.SetMockDeleteLikeFunc(gorm.ErrRecordNotFound)
+	decremented := false
+	cacheTestHandler.IncrCacheValFunc = func(ctx context.Context, postID uint, delta int64) (uint64, error) {
+		decremented = true
+		return 0, nil
+	}
+	a.DeleteLike(c)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status code = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if decremented {
+		t.Error("Like count was decremented for a post that was never liked")
+	}
+}
@@ -11,30 +11,50 @@ package controllers
 import (
 	"context"
 	"errors"
-	"fmt"
+	"log"
 	"strconv"
 
 	"cloud.google.com/go/storage"
 	"github.com/redis/go-redis/v9"
 	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
 	"gorm.io/gorm"
 )
 
 // APIEnv is a wrapper for the shared database instance
 type APIEnv struct {
-	DB                   *gorm.DB
-	NotifRedis           *redis.Client
-	PostDBHandler        database.PostDBHandler
-	UserDBHandler        database.UserDBHandler
-	AuthDBHandler        database.AuthDBHandler
-	LikeDBHandler        database.LikeAPIHandler
-	CommentDBHandler     database.CommentsDBHandler
-	CommunityDBHandler   database.CommunityDBHandler
-	ProjectDBHandler     database.ProjectDBHandler
-	GoogleCloud          *storage.Client
-	LikesCacheHandler    CacheHandler
-	CommentsCacheHandler CacheHandler
-	NotificationPoster   NotificationPoster
+	DB                      *gorm.DB
+	NotifRedis              *redis.Client
+	PostDBHandler           database.PostDBHandler
+	UserDBHandler           database.UserDBHandler
+	AuthDBHandler           database.AuthDBHandler
+	LikeDBHandler           database.LikeAPIHandler
+	CommentDBHandler        database.CommentsDBHandler
+	CommunityDBHandler      database.CommunityDBHandler
+	ProjectDBHandler        database.ProjectDBHandler
+	TokenDBHandler          database.TokenDBHandler
+	IdentityDBHandler       database.IdentityDBHandler
+	ExportDBHandler         database.ExportDBHandler
+	AdminDBHandler          database.AdminDBHandler
+	ReportDBHandler         database.ReportDBHandler
+	BlockDBHandler          database.BlockDBHandler
+	FollowDBHandler         database.FollowDBHandler
+	SearchDBHandler         database.SearchDBHandler
+	SkillDBHandler          database.SkillDBHandler
+	RecruitmentDBHandler    database.RecruitmentDBHandler
+	RecommendationDBHandler database.RecommendationDBHandler
+	BookmarkDBHandler       database.BookmarkDBHandler
+	GoogleCloud             *storage.Client
+	LikesCacheHandler       CacheHandler
+	CommentsCacheHandler    CacheHandler
+	NotificationPoster      NotificationPoster
+	OIDCAuthenticator       OIDCAuthenticator
+	Mailer                  helpers.Mailer
+	DataExporter            DataExporter
+	ContentFilter           ContentFilter
+	Recommender             Recommender
+	PostRanker              PostRanker
+	HomeTimeline            HomeTimeline
 }
 
 // General
@@ -51,30 +71,131 @@ var (
 
 type CacheHandler interface {
 	GetCacheVal(context.Context, uint) (uint64, error)
+	GetCacheVals(context.Context, []uint) (map[uint]uint64, error)
+	// Recounts the value in the database and caches it
 	SetCacheVal(context.Context, uint) (uint64, error)
+	// Adds delta to the cached value, after the change has been written to the database
+	IncrCacheVal(context.Context, uint, int64) (uint64, error)
 }
 
 type Cache struct {
 	redisDB   *redis.Client
 	DBHandler database.DBValueGetter
+	// Key returns the Redis key of the cached value of a post
+	Key func(uint) string
+	// Ranker, if set, re-ranks the post whenever its cached value changes
+	Ranker PostRanker
+	// RankingCount is the count in the post's ranking stats that the value is
+	RankingCount string
 }
 
+// Adds to a cached value only if it is cached, so that a value that was never cached
+// is counted rather than started from zero. Values never drop below zero.
+var incrIfCached = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+local val = redis.call("INCRBY", KEYS[1], ARGV[1])
+if val < 0 then
+	redis.call("SET", KEYS[1], 0)
+	return 0
+end
+return val
+`)
+
 func (c *Cache) GetCacheVal(ctx context.Context, key uint) (uint64, error) {
-	val, err := c.redisDB.Get(ctx, fmt.Sprintf("%v", key)).Result()
+	val, err := c.redisDB.Get(ctx, c.Key(key)).Result()
 	if err == redis.Nil {
 		return c.SetCacheVal(ctx, key)
 	}
 	return strconv.ParseUint(val, 10, 32)
 }
 
+// Retrieves the values of many keys in a constant number of round trips. Values missing
+// from the cache, or all values if the cache cannot be read, are counted in the database
+// and cached. Returns the values that could be retrieved along with any error.
+func (c *Cache) GetCacheVals(ctx context.Context, keys []uint) (map[uint]uint64, error) {
+	vals := make(map[uint]uint64, len(keys))
+	if len(keys) == 0 {
+		return vals, nil
+	}
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = c.Key(key)
+	}
+	cached, err := c.redisDB.MGet(ctx, redisKeys...).Result()
+	misses := []uint{}
+	for i, key := range keys {
+		if err != nil {
+			misses = append(misses, key)
+			continue
+		}
+		valStr, ok := cached[i].(string)
+		val, parseErr := strconv.ParseUint(valStr, 10, 32)
+		if !ok || parseErr != nil {
+			misses = append(misses, key)
+			continue
+		}
+		vals[key] = val
+	}
+	if len(misses) == 0 {
+		return vals, nil
+	}
+
+	counts, err := c.DBHandler.GetValues(misses)
+	if err != nil {
+		return vals, ErrDBValueFailed
+	}
+	// The counts are returned even if they cannot be cached; they are cached the next
+	// time they are retrieved. A count cached since the lookup has already been
+	// incremented past the recount, so it is not overwritten.
+	_, _ = c.redisDB.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, count := range counts {
+			pipe.SetNX(ctx, c.Key(key), count, 0)
+		}
+		return nil
+	})
+	for key, count := range counts {
+		vals[key] = count
+	}
+	return vals, nil
+}
+
 func (c *Cache) SetCacheVal(ctx context.Context, id uint) (uint64, error) {
 	newVal, err := c.DBHandler.GetValue(id)
 	if err != nil {
 		return newVal, ErrDBValueFailed
 	}
-	err = c.redisDB.Set(ctx, fmt.Sprintf("%v", id), newVal, 0).Err()
+	err = c.redisDB.Set(ctx, c.Key(id), newVal, 0).Err()
 	if err != nil {
 		return newVal, ErrUpdateCacheValueFailed
 	}
+	c.rankPost(ctx, id, newVal)
 	return newVal, nil
 }
+
+// Atomically adds delta to the cached value instead of recounting it. A value that is
+// not cached is recounted, which already includes the change. If the cache cannot be
+// updated, the value drifts from the database until it is reconciled.
+func (c *Cache) IncrCacheVal(ctx context.Context, id uint, delta int64) (uint64, error) {
+	newVal, err := incrIfCached.Run(ctx, c.redisDB, []string{c.Key(id)}, delta).Uint64()
+	if err == redis.Nil {
+		return c.SetCacheVal(ctx, id)
+	}
+	if err != nil {
+		return newVal, ErrUpdateCacheValueFailed
+	}
+	c.rankPost(ctx, id, newVal)
+	return newVal, nil
+}
+
+// Passes the new value to the ranker, so that the post is scored without being counted
+// again. The value has been updated even if the post cannot be re-ranked; its score is
+// corrected the next time the post is ranked.
+func (c *Cache) rankPost(ctx context.Context, id uint, val uint64) {
+	if c.Ranker != nil {
+		if err := c.Ranker.UpdatePostCount(ctx, id, c.RankingCount, val); err != nil {
+			log.Printf("Unable to rank post %d: %v", id, err)
+		}
+	}
+}
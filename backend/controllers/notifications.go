@@ -9,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
 )
@@ -17,13 +18,20 @@ type NotificationPoster interface {
 	PostNotificationFromEvent(*gin.Context, *models.Notification) error
 }
 
+// BlockChecker is implemented by database.BlockDB
+type BlockChecker interface {
+	IsBlocked(userID string, otherID string) (bool, error)
+}
+
 type NotificationCreator struct {
 	client *redis.Client
+	blocks BlockChecker
 }
 
 func (a *APIEnv) InitialiseNotificationHandler(client *redis.Client) {
 	a.NotificationPoster = &NotificationCreator{
 		client: client,
+		blocks: &database.BlockDB{DB: a.DB},
 	}
 }
 
@@ -169,30 +177,22 @@ func (a *APIEnv) PatchNotification(context *gin.Context) {
 // 	context.JSON(http.StatusOK, gin.H{"status": "success"})
 // }
 
+// Publishes a notification of another user's activity. Notifications between users who
+// have blocked each other are dropped.
 func (a *NotificationCreator) PostNotificationFromEvent(context *gin.Context, notif *models.Notification) error {
-	// Marshalling the notification to JSON
-	notifJson, err := json.Marshal(notif)
-	if err != nil {
-		return err
-	}
-
-	// Adding the notification to the receiver's sorted set in Redis
-	score := float64(time.Now().Unix())
-	receiverKey := fmt.Sprintf("notifications:%s", notif.ReceiverId)
-	err = a.client.ZAdd(context.Request.Context(), receiverKey, redis.Z{Score: score, Member: notifJson}).Err()
-	if err != nil {
-		return err
+	if notif.SenderId != "" {
+		blocked, err := a.blocks.IsBlocked(notif.SenderId, notif.ReceiverId)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return nil
+		}
 	}
-
-	// Publish the notification to the receiver's channel
-	formattedMessage := "data: " + string(notifJson) + "\n\n"
-	err = a.client.Publish(context.Request.Context(), receiverKey, formattedMessage).Err()
-	if err != nil {
+	if err := helpers.PublishNotification(context.Request.Context(), a.client, notif); err != nil {
 		return err
 	}
 	log.Println("Notification sent")
-	log.Println(formattedMessage)
-	log.Println(receiverKey)
+	log.Println(helpers.NotificationKey(notif.ReceiverId))
 	return nil
-	// context.JSON(http.StatusOK, gin.H{"status": "success"})
 }
@@ -0,0 +1,286 @@
+/*
+Contains controllers for single sign-on through an OpenID Connect provider.
+*/
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"github.com/ryanozx/skillnet/oidc"
+	"gorm.io/gorm"
+)
+
+// Errors
+var (
+	ErrOIDCDenied           = errors.New("sign in was cancelled at the identity provider")
+	ErrOIDCEmailNotVerified = errors.New("identity provider has not verified this email address")
+	ErrOIDCLinkFailed       = errors.New("unable to link identity to account")
+	ErrOIDCLinkNotPending   = errors.New("no sign in is waiting to be linked, please sign in with the identity provider again")
+	ErrOIDCLinkRequired     = errors.New("sign in with the account's password to link the identity to it")
+	ErrOIDCLoginFailed      = errors.New("unable to sign in with identity provider")
+	ErrOIDCStartFailed      = errors.New("unable to start sign in with identity provider")
+	ErrOIDCStateMismatch    = errors.New("sign in request has expired or is invalid, please try again")
+)
+
+// OIDCAuthenticator is implemented by oidc.Provider
+type OIDCAuthenticator interface {
+	AuthCodeURL(state string, nonce string, codeVerifier string) string
+	Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*oidc.Claims, error)
+}
+
+func (a *APIEnv) InitialiseOIDCHandler(provider OIDCAuthenticator) {
+	a.OIDCAuthenticator = provider
+	a.IdentityDBHandler = &database.IdentityDB{
+		DB: a.DB,
+	}
+}
+
+// Redirects the user to the identity provider's login page. The state, nonce and PKCE
+// code verifier are kept in the session so that the callback can be checked against them
+func (a *APIEnv) GetOIDCStart(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	if helpers.IsValidSession(session) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrAlreadyLoggedIn)
+		return
+	}
+
+	values := make(map[string]string)
+	for _, key := range []string{helpers.OIDCStateKey, helpers.OIDCNonceKey, helpers.OIDCVerifierKey} {
+		val, err := oidc.GenerateRandomString()
+		if err != nil {
+			helpers.OutputError(ctx, http.StatusInternalServerError, ErrOIDCStartFailed)
+			return
+		}
+		values[key] = val
+		session.Set(key, val)
+	}
+	if err := session.Save(); err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCookieSaveFail)
+		return
+	}
+
+	authURL := a.OIDCAuthenticator.AuthCodeURL(values[helpers.OIDCStateKey], values[helpers.OIDCNonceKey], values[helpers.OIDCVerifierKey])
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Handles the identity provider redirecting the user back to SkillNet. On success,
+// the user is logged in and redirected to the client.
+func (a *APIEnv) GetOIDCCallback(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	if helpers.IsValidSession(session) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrAlreadyLoggedIn)
+		return
+	}
+
+	// The values stored by GetOIDCStart can only be used once
+	state := helpers.GetSessionString(session, helpers.OIDCStateKey)
+	nonce := helpers.GetSessionString(session, helpers.OIDCNonceKey)
+	codeVerifier := helpers.GetSessionString(session, helpers.OIDCVerifierKey)
+	session.Delete(helpers.OIDCStateKey)
+	session.Delete(helpers.OIDCNonceKey)
+	session.Delete(helpers.OIDCVerifierKey)
+	if err := session.Save(); err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCookieSaveFail)
+		return
+	}
+
+	if ctx.Query("error") != "" {
+		helpers.OutputError(ctx, http.StatusUnauthorized, ErrOIDCDenied)
+		return
+	}
+	// If the state does not match the one issued to this session, the callback may have
+	// been forged, so return with status code 400 Bad Request
+	if state == "" || ctx.Query("state") != state {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrOIDCStateMismatch)
+		return
+	}
+
+	claims, err := a.OIDCAuthenticator.Exchange(ctx.Request.Context(), ctx.Query("code"), codeVerifier, nonce)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusUnauthorized, ErrOIDCLoginFailed)
+		return
+	}
+
+	user, err := a.getOrLinkOIDCUser(claims)
+	// Accounts are only linked by email if the provider vouches for the email address,
+	// otherwise anyone could take over an account by claiming its email
+	if errors.Is(err, ErrOIDCEmailNotVerified) {
+		helpers.OutputError(ctx, http.StatusForbidden, ErrOIDCEmailNotVerified)
+		return
+	}
+	// The account's email was never verified, so it may have been registered by someone
+	// else; the identity is only linked once the user enters the account's password
+	if errors.Is(err, ErrOIDCLinkRequired) {
+		session.Set(helpers.OIDCLinkUserIDKey, user.ID)
+		session.Set(helpers.OIDCLinkIssuerKey, claims.Issuer)
+		session.Set(helpers.OIDCLinkSubjectKey, claims.Subject)
+		session.Set(helpers.OIDCLinkEmailKey, claims.Email)
+		session.Set(helpers.OIDCLinkTimeKey, time.Now().Unix())
+		if err := session.Save(); err != nil {
+			helpers.OutputError(ctx, http.StatusInternalServerError, ErrCookieSaveFail)
+			return
+		}
+		ctx.Redirect(http.StatusFound, models.ClientAddress+helpers.OIDCLinkClientPath)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrOIDCLinkFailed)
+		return
+	}
+	if !a.saveOIDCSession(ctx, user) {
+		return
+	}
+	ctx.Redirect(http.StatusFound, models.ClientAddress+helpers.RouteIfSuccessful)
+}
+
+// Links the identity that the user signed in with to the account with the same email
+// once the user enters the account's password, then logs the user in
+func (a *APIEnv) PostOIDCLink(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	if helpers.IsValidSession(session) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrAlreadyLoggedIn)
+		return
+	}
+	var input models.IdentityLinkInput
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	userID := helpers.GetSessionString(session, helpers.OIDCLinkUserIDKey)
+	linkTime, _ := session.Get(helpers.OIDCLinkTimeKey).(int64)
+	if userID == "" || time.Since(time.Unix(linkTime, 0)) > helpers.OIDCLinkTTL {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrOIDCLinkNotPending)
+		return
+	}
+	user, err := a.UserDBHandler.GetUserByID(userID)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return
+	}
+	// If password does not match, return status code 401 Unauthorised; the link stays
+	// pending so that the user can try again
+	if err := helpers.CheckHashEqualsPassword(user.Password, input.Password); err != nil {
+		helpers.OutputError(ctx, http.StatusUnauthorized, ErrIncorrectPassword)
+		return
+	}
+
+	identity := &models.UserIdentity{
+		Issuer:  helpers.GetSessionString(session, helpers.OIDCLinkIssuerKey),
+		Subject: helpers.GetSessionString(session, helpers.OIDCLinkSubjectKey),
+		UserID:  user.ID,
+		Email:   helpers.GetSessionString(session, helpers.OIDCLinkEmailKey),
+	}
+	for _, key := range []string{helpers.OIDCLinkUserIDKey, helpers.OIDCLinkIssuerKey, helpers.OIDCLinkSubjectKey,
+		helpers.OIDCLinkEmailKey, helpers.OIDCLinkTimeKey} {
+		session.Delete(key)
+	}
+	if _, err := a.IdentityDBHandler.CreateIdentity(identity); err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrOIDCLinkFailed)
+		return
+	}
+	if !a.saveOIDCSession(ctx, user) {
+		return
+	}
+	helpers.OutputMessage(ctx, LoginSuccessfulMsg)
+}
+
+// Logs in a user who signed in through the identity provider. If the user cannot be
+// logged in, an error is written to the response and false is returned.
+func (a *APIEnv) saveOIDCSession(ctx *gin.Context, user *models.User) bool {
+	if user.IsSuspended(time.Now()) {
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.SuspensionError(&user.Suspension))
+		return false
+	}
+	// As with logging in with a password, this cancels the deletion of the account
+	if user.IsPendingDeletion() {
+		if err := a.UserDBHandler.CancelUserDeletion(user.ID); err != nil {
+			helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotCancelDeletion)
+			return false
+		}
+	}
+	if err := helpers.SaveProviderSession(ctx, user); err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCookieSaveFail)
+		return false
+	}
+	return true
+}
+
+// Returns the user linked to the identity in the claims. If no user is linked yet, the
+// identity is linked to the user with the same verified email, or to a new user if
+// there is no such user. If the user with the same email has not verified it, the user
+// is returned with ErrOIDCLinkRequired instead of linking the identity.
+func (a *APIEnv) getOrLinkOIDCUser(claims *oidc.Claims) (*models.User, error) {
+	identity, err := a.IdentityDBHandler.GetIdentity(claims.Issuer, claims.Subject)
+	if err == nil {
+		return &identity.User, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	user, err := a.UserDBHandler.GetUserByEmail(claims.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user, err = a.createOIDCUser(claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified {
+		return user, ErrOIDCLinkRequired
+	}
+
+	_, err = a.IdentityDBHandler.CreateIdentity(&models.UserIdentity{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		UserID:  user.ID,
+		Email:   claims.Email,
+	})
+	return user, err
+}
+
+// Creates a user for an identity that is not linked to any account. The account is
+// given a random password so that it can only be accessed through the identity provider,
+// until the user sets a password after logging in through the provider.
+func (a *APIEnv) createOIDCUser(claims *oidc.Claims) (*models.User, error) {
+	password, err := oidc.GenerateRandomString()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := helpers.GenerateHashFromPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	// The provider has verified the email
+	userCredentials := &models.VerifiedSignupUserCredentials{
+		SignupUserCredentials: models.SignupUserCredentials{
+			UserCredentials: models.UserCredentials{
+				Username: helpers.GenerateOIDCUsername(claims.PreferredUsername, claims.Email),
+				Password: string(hashedPassword),
+			},
+			Email: claims.Email,
+		},
+	}
+	user, err := a.UserDBHandler.CreateUser(userCredentials)
+	if err == nil {
+		return user, nil
+	}
+
+	// The username may already be taken, so retry once with a random suffix
+	userCredentials.Username, err = helpers.AddRandomUsernameSuffix(userCredentials.Username)
+	if err != nil {
+		return nil, err
+	}
+	return a.UserDBHandler.CreateUser(userCredentials)
+}
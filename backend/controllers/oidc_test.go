@@ -0,0 +1,581 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"github.com/ryanozx/skillnet/oidc"
+	"github.com/ryanozx/skillnet/oidc/oidctest"
+	"gorm.io/gorm"
+)
+
+const (
+	testOIDCClientID     = "skillnet"
+	testOIDCClientSecret = "secret"
+	testOIDCState        = "test-state"
+	testOIDCNonce        = "test-nonce"
+	testOIDCVerifier     = "test-verifier"
+	testOIDCCode         = "test-code"
+	testOIDCSubject      = "provider-user-1"
+)
+
+type IdentityDBTestHandler struct {
+	CreateIdentityFunc func(*models.UserIdentity) (*models.UserIdentity, error)
+	GetIdentityFunc    func(string, string) (*models.UserIdentity, error)
+}
+
+func (h *IdentityDBTestHandler) CreateIdentity(identity *models.UserIdentity) (*models.UserIdentity, error) {
+	return h.CreateIdentityFunc(identity)
+}
+
+func (h *IdentityDBTestHandler) GetIdentity(issuer string, subject string) (*models.UserIdentity, error) {
+	return h.GetIdentityFunc(issuer, subject)
+}
+
+func (h *IdentityDBTestHandler) SetMockCreateIdentityFunc(err error) {
+	h.CreateIdentityFunc = func(identity *models.UserIdentity) (*models.UserIdentity, error) {
+		return identity, err
+	}
+}
+
+func (h *IdentityDBTestHandler) SetMockGetIdentityFunc(identity *models.UserIdentity, err error) {
+	h.GetIdentityFunc = func(issuer string, subject string) (*models.UserIdentity, error) {
+		return identity, err
+	}
+}
+
+func setupOIDCStub(t *testing.T) (*oidctest.StubProvider, *oidc.Provider) {
+	stub := oidctest.NewStubProvider(testOIDCClientID, testOIDCClientSecret)
+	t.Cleanup(stub.Close)
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		IssuerURL:    stub.URL(),
+		ClientID:     stub.ClientID,
+		ClientSecret: stub.ClientSecret,
+		RedirectURL:  oidctest.RedirectURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stub, provider
+}
+
+func TestAPIEnv_InitialiseOIDCHandler(t *testing.T) {
+	_, provider := setupOIDCStub(t)
+	db := &gorm.DB{}
+	a := &APIEnv{
+		DB: db,
+	}
+	a.InitialiseOIDCHandler(provider)
+	if a.OIDCAuthenticator != provider {
+		t.Error("OIDCAuthenticator not initialised correctly")
+	}
+	if identityDB, ok := a.IdentityDBHandler.(*database.IdentityDB); !ok || identityDB.DB != db {
+		t.Error("IdentityDBHandler not initialised correctly")
+	}
+}
+
+func TestAPIEnv_GetOIDCStart(t *testing.T) {
+	_, provider := setupOIDCStub(t)
+	a := &APIEnv{
+		OIDCAuthenticator: provider,
+	}
+	c, w := helpers.CreateTestContextAndRecorder()
+	store := helpers.MakeMockStore()
+	helpers.AddStoreToContext(c, store)
+	c.Request, _ = http.NewRequest(http.MethodGet, helpers.OIDCStartPath, nil)
+
+	a.GetOIDCStart(c)
+
+	if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(http.StatusFound, w.Code); !isEqual {
+		t.Fatal(errStr)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := location.Query()
+	if query.Get("state") == "" || query.Get("state") != store.Get(helpers.OIDCStateKey) {
+		t.Error("State in redirect does not match state in session")
+	}
+	if query.Get("nonce") == "" || query.Get("nonce") != store.Get(helpers.OIDCNonceKey) {
+		t.Error("Nonce in redirect does not match nonce in session")
+	}
+	verifier, _ := store.Get(helpers.OIDCVerifierKey).(string)
+	if verifier == "" || query.Get("code_challenge") != oidc.CodeChallenge(verifier) {
+		t.Error("Code challenge in redirect does not match code verifier in session")
+	}
+}
+
+func TestAPIEnv_GetOIDCStart_AlreadyLoggedIn(t *testing.T) {
+	a := &APIEnv{}
+	c, w := helpers.CreateTestContextAndRecorder()
+	store := helpers.MakeMockStore()
+	store.Set(helpers.UserIDKey, testUserID)
+	helpers.AddStoreToContext(c, store)
+
+	a.GetOIDCStart(c)
+
+	b, _ := io.ReadAll(w.Body)
+	if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(http.StatusBadRequest, w.Code); !isEqual {
+		t.Error(errStr)
+	}
+	m, err := helpers.ParseJSONString(b)
+	if err != nil {
+		t.Error(err)
+	}
+	if errStr, isEqual := helpers.CheckExpectedErrorEqualsActual(m, ErrAlreadyLoggedIn); !isEqual {
+		t.Error(errStr)
+	}
+}
+
+func TestAPIEnv_GetOIDCCallback(t *testing.T) {
+	helpers.SetEnvVars(t)
+	verifiedClaims := map[string]any{
+		"sub":            testOIDCSubject,
+		"nonce":          testOIDCNonce,
+		"email":          testEmail,
+		"email_verified": true,
+	}
+	unverifiedClaims := map[string]any{
+		"sub":            testOIDCSubject,
+		"nonce":          testOIDCNonce,
+		"email":          testEmail,
+		"email_verified": false,
+	}
+	type args struct {
+		QueryParams        map[string]string
+		StoreParams        map[string]interface{}
+		ProviderClaims     map[string]any
+		IdentityDBOutput   *models.UserIdentity
+		IdentityDBError    error
+		UserByEmailOutput  *models.User
+		UserByEmailError   error
+		CreateUserError    error
+		CreateIdentityErr  error
+		ExpectedIdentity   bool
+		ExpectedCreateUser bool
+		// The identity waits for the user to confirm the link with their password
+		ExpectedLinkPending bool
+	}
+	verifiedUser := defaultUser
+	verifiedUser.EmailVerified = true
+	validStore := map[string]interface{}{
+		helpers.OIDCStateKey:    testOIDCState,
+		helpers.OIDCNonceKey:    testOIDCNonce,
+		helpers.OIDCVerifierKey: testOIDCVerifier,
+	}
+	validQuery := map[string]string{
+		"state": testOIDCState,
+		"code":  testOIDCCode,
+	}
+	tests := []struct {
+		name         string
+		args         args
+		expectedCode int
+		expectedErr  error
+	}{
+		{
+			"Callback existing identity OK",
+			args{
+				QueryParams:      validQuery,
+				StoreParams:      validStore,
+				ProviderClaims:   verifiedClaims,
+				IdentityDBOutput: &models.UserIdentity{User: defaultUser},
+			},
+			http.StatusFound,
+			nil,
+		},
+		{
+			"Callback link existing account by verified email OK",
+			args{
+				QueryParams:       validQuery,
+				StoreParams:       validStore,
+				ProviderClaims:    verifiedClaims,
+				IdentityDBError:   gorm.ErrRecordNotFound,
+				UserByEmailOutput: &verifiedUser,
+				ExpectedIdentity:  true,
+			},
+			http.StatusFound,
+			nil,
+		},
+		{
+			"Callback existing account with unverified email requires password",
+			args{
+				QueryParams:         validQuery,
+				StoreParams:         validStore,
+				ProviderClaims:      verifiedClaims,
+				IdentityDBError:     gorm.ErrRecordNotFound,
+				UserByEmailOutput:   &defaultUser,
+				ExpectedLinkPending: true,
+			},
+			http.StatusFound,
+			nil,
+		},
+		{
+			"Callback create new account OK",
+			args{
+				QueryParams:        validQuery,
+				StoreParams:        validStore,
+				ProviderClaims:     verifiedClaims,
+				IdentityDBError:    gorm.ErrRecordNotFound,
+				UserByEmailError:   gorm.ErrRecordNotFound,
+				ExpectedIdentity:   true,
+				ExpectedCreateUser: true,
+			},
+			http.StatusFound,
+			nil,
+		},
+		{
+			"Callback unverified email",
+			args{
+				QueryParams:     validQuery,
+				StoreParams:     validStore,
+				ProviderClaims:  unverifiedClaims,
+				IdentityDBError: gorm.ErrRecordNotFound,
+			},
+			http.StatusForbidden,
+			ErrOIDCEmailNotVerified,
+		},
+		{
+			"Callback state mismatch",
+			args{
+				QueryParams: map[string]string{
+					"state": "forged-state",
+					"code":  testOIDCCode,
+				},
+				StoreParams:    validStore,
+				ProviderClaims: verifiedClaims,
+			},
+			http.StatusBadRequest,
+			ErrOIDCStateMismatch,
+		},
+		{
+			"Callback without started flow",
+			args{
+				QueryParams:    validQuery,
+				ProviderClaims: verifiedClaims,
+			},
+			http.StatusBadRequest,
+			ErrOIDCStateMismatch,
+		},
+		{
+			"Callback provider error",
+			args{
+				QueryParams: map[string]string{
+					"state": testOIDCState,
+					"error": "access_denied",
+				},
+				StoreParams: validStore,
+			},
+			http.StatusUnauthorized,
+			ErrOIDCDenied,
+		},
+		{
+			"Callback nonce mismatch",
+			args{
+				QueryParams: validQuery,
+				StoreParams: validStore,
+				ProviderClaims: map[string]any{
+					"sub":   testOIDCSubject,
+					"nonce": "replayed-nonce",
+				},
+			},
+			http.StatusUnauthorized,
+			ErrOIDCLoginFailed,
+		},
+		{
+			"Callback unknown code",
+			args{
+				QueryParams: map[string]string{
+					"state": testOIDCState,
+					"code":  "unknown-code",
+				},
+				StoreParams:    validStore,
+				ProviderClaims: verifiedClaims,
+			},
+			http.StatusUnauthorized,
+			ErrOIDCLoginFailed,
+		},
+		{
+			"Callback cannot link identity",
+			args{
+				QueryParams:       validQuery,
+				StoreParams:       validStore,
+				ProviderClaims:    verifiedClaims,
+				IdentityDBError:   gorm.ErrRecordNotFound,
+				UserByEmailOutput: &verifiedUser,
+				CreateIdentityErr: ErrTest,
+			},
+			http.StatusInternalServerError,
+			ErrOIDCLinkFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, provider := setupOIDCStub(t)
+			if tt.args.ProviderClaims != nil {
+				stub.AddCode(testOIDCCode, oidc.CodeChallenge(testOIDCVerifier), tt.args.ProviderClaims)
+			}
+
+			identityDBHandler := &IdentityDBTestHandler{}
+			userDBHandler := &UserDBTestHandler{}
+			a := &APIEnv{
+				OIDCAuthenticator: provider,
+				IdentityDBHandler: identityDBHandler,
+				UserDBHandler:     userDBHandler,
+			}
+
+			var createdIdentity *models.UserIdentity
+			identityDBHandler.SetMockGetIdentityFunc(tt.args.IdentityDBOutput, tt.args.IdentityDBError)
+			identityDBHandler.CreateIdentityFunc = func(identity *models.UserIdentity) (*models.UserIdentity, error) {
+				createdIdentity = identity
+				return identity, tt.args.CreateIdentityErr
+			}
+			userDBHandler.SetMockGetUserByEmailFunc(tt.args.UserByEmailOutput, tt.args.UserByEmailError)
+			var createdUser database.NewUser
+			userDBHandler.CreateUserFunc = func(newUser database.NewUser) (*models.User, error) {
+				createdUser = newUser
+				user := newUser.NewUser()
+				user.ID = testUserID
+				return user, tt.args.CreateUserError
+			}
+
+			c, w := helpers.CreateTestContextAndRecorder()
+			store := helpers.MakeMockStore()
+			helpers.AddStoreToContext(c, store)
+			for paramKey, paramVal := range tt.args.StoreParams {
+				store.Set(paramKey, paramVal)
+			}
+			query := url.Values{}
+			for paramKey, paramVal := range tt.args.QueryParams {
+				query.Set(paramKey, paramVal)
+			}
+			c.Request, _ = http.NewRequest(http.MethodGet, helpers.OIDCCallbackPath+"?"+query.Encode(), nil)
+
+			a.GetOIDCCallback(c)
+
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if store.Get(helpers.OIDCStateKey) != nil {
+				t.Error("State was not removed from session")
+			}
+
+			if tt.expectedErr != nil {
+				b, _ := io.ReadAll(w.Body)
+				m, err := helpers.ParseJSONString(b)
+				if err != nil {
+					t.Error(err)
+				}
+				if errStr, isEqual := helpers.CheckExpectedErrorEqualsActual(m, tt.expectedErr); !isEqual {
+					t.Error(errStr)
+				}
+				if store.Get(helpers.UserIDKey) != nil {
+					t.Error("User logged in despite error")
+				}
+				return
+			}
+
+			if tt.args.ExpectedLinkPending {
+				if store.Get(helpers.UserIDKey) != nil {
+					t.Error("User logged in before confirming the link")
+				}
+				if store.Get(helpers.OIDCLinkUserIDKey) != testUserID || store.Get(helpers.OIDCLinkSubjectKey) != testOIDCSubject {
+					t.Error("Pending link not saved in session")
+				}
+				if w.Header().Get("Location") != models.ClientAddress+helpers.OIDCLinkClientPath {
+					t.Errorf("Unexpected redirect to %s", w.Header().Get("Location"))
+				}
+				if createdIdentity != nil {
+					t.Error("Identity linked without confirmation")
+				}
+				return
+			}
+			if store.Get(helpers.UserIDKey) != testUserID || store.Get(helpers.LoginMethodKey) != helpers.LoginMethodProvider {
+				t.Error("User not logged in through the provider")
+			}
+			if w.Header().Get("Location") != models.ClientAddress+helpers.RouteIfSuccessful {
+				t.Errorf("Unexpected redirect to %s", w.Header().Get("Location"))
+			}
+			if tt.args.ExpectedIdentity && (createdIdentity == nil || createdIdentity.UserID != testUserID ||
+				createdIdentity.Subject != testOIDCSubject || createdIdentity.Issuer != stub.URL()) {
+				t.Errorf("Identity not linked correctly: %v", createdIdentity)
+			}
+			if !tt.args.ExpectedIdentity && createdIdentity != nil {
+				t.Error("Unexpected identity created")
+			}
+			if tt.args.ExpectedCreateUser != (createdUser != nil) {
+				t.Errorf("Expected user created: %v", tt.args.ExpectedCreateUser)
+			}
+			if createdUser != nil && !createdUser.NewUser().EmailVerified {
+				t.Error("Email verified by the provider not marked as verified")
+			}
+		})
+	}
+}
+
+func TestAPIEnv_PostOIDCLink(t *testing.T) {
+	helpers.SetEnvVars(t)
+	user := userWithPassword(t)
+	pendingStore := map[string]interface{}{
+		helpers.OIDCLinkUserIDKey:  testUserID,
+		helpers.OIDCLinkIssuerKey:  "https://issuer.example.com",
+		helpers.OIDCLinkSubjectKey: testOIDCSubject,
+		helpers.OIDCLinkEmailKey:   testEmail,
+		helpers.OIDCLinkTimeKey:    time.Now().Unix(),
+	}
+	expiredStore := map[string]interface{}{
+		helpers.OIDCLinkUserIDKey:  testUserID,
+		helpers.OIDCLinkIssuerKey:  "https://issuer.example.com",
+		helpers.OIDCLinkSubjectKey: testOIDCSubject,
+		helpers.OIDCLinkTimeKey:    time.Now().Add(-helpers.OIDCLinkTTL - time.Minute).Unix(),
+	}
+	loggedInStore := map[string]interface{}{
+		helpers.UserIDKey: testUserID,
+	}
+	type args struct {
+		Input             *models.IdentityLinkInput
+		StoreParams       map[string]interface{}
+		CreateIdentityErr error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.User]
+	}{
+		{
+			"Link identity OK",
+			args{
+				Input:       &models.IdentityLinkInput{Password: testPassword},
+				StoreParams: pendingStore,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedMessage,
+				Message:    LoginSuccessfulMsg,
+			},
+		},
+		{
+			"Link identity wrong password",
+			args{
+				Input:       &models.IdentityLinkInput{Password: "wrongPassword123!"},
+				StoreParams: pendingStore,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusUnauthorized,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrIncorrectPassword,
+			},
+		},
+		{
+			"Link identity not pending",
+			args{
+				Input: &models.IdentityLinkInput{Password: testPassword},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrOIDCLinkNotPending,
+			},
+		},
+		{
+			"Link identity expired",
+			args{
+				Input:       &models.IdentityLinkInput{Password: testPassword},
+				StoreParams: expiredStore,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrOIDCLinkNotPending,
+			},
+		},
+		{
+			"Link identity already logged in",
+			args{
+				Input:       &models.IdentityLinkInput{Password: testPassword},
+				StoreParams: loggedInStore,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrAlreadyLoggedIn,
+			},
+		},
+		{
+			"Link identity cannot link",
+			args{
+				Input:             &models.IdentityLinkInput{Password: testPassword},
+				StoreParams:       pendingStore,
+				CreateIdentityErr: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrOIDCLinkFailed,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identityDBHandler := &IdentityDBTestHandler{}
+			userDBHandler := &UserDBTestHandler{}
+			a := &APIEnv{
+				IdentityDBHandler: identityDBHandler,
+				UserDBHandler:     userDBHandler,
+			}
+
+			var createdIdentity *models.UserIdentity
+			identityDBHandler.CreateIdentityFunc = func(identity *models.UserIdentity) (*models.UserIdentity, error) {
+				createdIdentity = identity
+				return identity, tt.args.CreateIdentityErr
+			}
+			userDBHandler.SetMockGetUserByIDFunc(user, nil)
+
+			c, w := helpers.CreateTestContextAndRecorder()
+			store := helpers.MakeMockStore()
+			helpers.AddStoreToContext(c, store)
+			for paramKey, paramVal := range tt.args.StoreParams {
+				store.Set(paramKey, paramVal)
+			}
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.args.Input)
+			if err != nil {
+				t.Error(err)
+			}
+			c.Request = req
+
+			a.PostOIDCLink(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+			if tt.expected.StatusCode != http.StatusOK {
+				return
+			}
+			if store.Get(helpers.UserIDKey) != testUserID || store.Get(helpers.LoginMethodKey) != helpers.LoginMethodProvider {
+				t.Error("User not logged in through the provider")
+			}
+			if store.Get(helpers.OIDCLinkUserIDKey) != nil {
+				t.Error("Pending link not removed from session")
+			}
+			if createdIdentity == nil || createdIdentity.UserID != testUserID || createdIdentity.Subject != testOIDCSubject {
+				t.Errorf("Identity not linked correctly: %v", createdIdentity)
+			}
+		})
+	}
+}
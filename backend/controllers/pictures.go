@@ -24,9 +24,9 @@ func (a *APIEnv) PostUserPicture(context *gin.Context) {
 	}
 	defer openedFile.Close()
 
-	bucket := a.GoogleCloud.Bucket("skillnet-profile-pictures")
+	bucket := a.GoogleCloud.Bucket(helpers.ProfilePictureBucket)
 	ctx := context.Request.Context()
-	fileName := userID + "-pfp.jpeg"
+	fileName := helpers.ProfilePictureObjectName(userID)
 	writer := bucket.Object(fileName).NewWriter(ctx)
 
 	_, err = io.Copy(writer, openedFile)
@@ -0,0 +1,118 @@
+/*
+Contains controllers for pinning posts to the top of community and project feeds.
+*/
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	PostPinnedMsg   = "Post pinned"
+	PostUnpinnedMsg = "Post unpinned"
+)
+
+// Errors
+var (
+	ErrBadPinExpiry       = errors.New("pin expiry must be in the future")
+	ErrCannotPinPost      = errors.New("cannot pin post")
+	ErrCannotUnpinPost    = errors.New("cannot unpin post")
+	ErrPostNotInCommunity = errors.New("post does not belong to a community")
+	ErrPostNotInProject   = errors.New("post does not belong to a project")
+	ErrPostNotPinned      = errors.New("post is not pinned")
+	ErrTooManyPinnedPosts = fmt.Errorf("feeds can have at most %d pinned posts", helpers.MaxPinnedPosts)
+)
+
+// Pins the post to the top of its community or project feed, as given by the feed in
+// the path, until the expiry in the request body if any. Pinning a pinned post again
+// updates its expiry.
+func (a *APIEnv) PinPost(ctx *gin.Context) {
+	// Ensure that postID is an unsigned integer
+	postID, err := helpers.GetPostIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrPostNotFound)
+		return
+	}
+	feed, err := helpers.GetPinFeedFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var input models.PinInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	if input.ExpiresAt.Valid && !input.ExpiresAt.Time.After(time.Now()) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadPinExpiry)
+		return
+	}
+
+	userID := helpers.GetUserIDFromContext(ctx)
+	siteModerator := helpers.UserHasRole(ctx, models.RoleModerator)
+	err = a.PostDBHandler.PinPost(postID, feed, userID, siteModerator, input.ExpiresAt)
+	if err != nil {
+		outputPinError(ctx, err, ErrCannotPinPost)
+		return
+	}
+	helpers.OutputMessage(ctx, PostPinnedMsg)
+}
+
+func (a *APIEnv) UnpinPost(ctx *gin.Context) {
+	// Ensure that postID is an unsigned integer
+	postID, err := helpers.GetPostIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrPostNotFound)
+		return
+	}
+	feed, err := helpers.GetPinFeedFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID := helpers.GetUserIDFromContext(ctx)
+	siteModerator := helpers.UserHasRole(ctx, models.RoleModerator)
+	err = a.PostDBHandler.UnpinPost(postID, feed, userID, siteModerator)
+	if errors.Is(err, database.ErrPostNotPinned) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrPostNotPinned)
+		return
+	}
+	if err != nil {
+		outputPinError(ctx, err, ErrCannotUnpinPost)
+		return
+	}
+	helpers.OutputMessage(ctx, PostUnpinnedMsg)
+}
+
+// Outputs the error from pinning or unpinning a post, or fallback if it is unexpected
+func outputPinError(ctx *gin.Context, err error, fallback error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrPostNotFound)
+	// Only community owners, site moderators and project owners may pin posts to their
+	// feeds; return status code 403 Forbidden
+	case errors.Is(err, helpers.ErrNotOwner):
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.ErrNotOwner)
+	case errors.Is(err, database.ErrPostNotInCommunity):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrPostNotInCommunity)
+	case errors.Is(err, database.ErrPostNotInProject):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrPostNotInProject)
+	case errors.Is(err, database.ErrTooManyPins):
+		helpers.OutputError(ctx, http.StatusConflict, ErrTooManyPinnedPosts)
+	default:
+		helpers.OutputError(ctx, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+func pinnedPostView(post *models.Post, params *models.PostViewParams) models.PostView {
+	view := post.PostView(params)
+	view.Pinned = true
+	return *view
+}
+
+func TestAPIEnv_PinPost(t *testing.T) {
+	tests := []struct {
+		name                  string
+		postID                interface{}
+		feed                  string
+		role                  string
+		input                 models.PinInput
+		dbError               error
+		expectedSiteModerator bool
+		expected              helpers.ExpectedJSONOutput[string]
+	}{
+		{"Pin OK", testPostID, models.PinFeedCommunity, models.RoleUser, models.PinInput{}, nil, false,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: PostPinnedMsg}},
+		{"Pin with expiry OK", testPostID, models.PinFeedProject, models.RoleUser, models.PinInput{ExpiresAt: null.TimeFrom(time.Now().Add(time.Hour))},
+			nil, false, helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: PostPinnedMsg}},
+		{"Pin as site moderator OK", testPostID, models.PinFeedCommunity, models.RoleModerator, models.PinInput{}, nil, true,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: PostPinnedMsg}},
+		{"Pin invalid post ID", "abc", models.PinFeedCommunity, models.RoleUser, models.PinInput{}, nil, false,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrPostNotFound}},
+		{"Pin invalid feed", testPostID, "global", models.RoleUser, models.PinInput{}, nil, false,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: helpers.ErrInvalidPinFeed}},
+		{"Pin expiry in the past", testPostID, models.PinFeedCommunity, models.RoleUser, models.PinInput{ExpiresAt: null.TimeFrom(time.Now().Add(-time.Hour))},
+			nil, false, helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrBadPinExpiry}},
+		{"Pin post not found", testPostID, models.PinFeedCommunity, models.RoleUser, models.PinInput{}, gorm.ErrRecordNotFound, false,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrPostNotFound}},
+		{"Pin not owner", testPostID, models.PinFeedCommunity, models.RoleUser, models.PinInput{}, helpers.ErrNotOwner, false,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusForbidden, JSONType: helpers.ExpectedError, Error: helpers.ErrNotOwner}},
+		{"Pin post not in community", testPostID, models.PinFeedCommunity, models.RoleModerator, models.PinInput{}, database.ErrPostNotInCommunity, true,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrPostNotInCommunity}},
+		{"Pin post not in project", testPostID, models.PinFeedProject, models.RoleUser, models.PinInput{}, database.ErrPostNotInProject, false,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrPostNotInProject}},
+		{"Pin too many pins", testPostID, models.PinFeedCommunity, models.RoleUser, models.PinInput{}, database.ErrTooManyPins, false,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusConflict, JSONType: helpers.ExpectedError, Error: ErrTooManyPinnedPosts}},
+		{"Pin cannot pin", testPostID, models.PinFeedCommunity, models.RoleUser, models.PinInput{}, ErrTest, false,
+			helpers.ExpectedJSONOutput[string]{StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotPinPost}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &PostDBTestHandler{}
+			a := &APIEnv{
+				PostDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.PostIDKey, tt.postID)
+			helpers.AddParamsToContext(c, helpers.PinFeedKey, tt.feed)
+			c.Set(helpers.UserRoleKey, tt.role)
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.input)
+			if err != nil {
+				t.Error(err)
+			}
+			c.Request = req
+
+			var receivedSiteModerator bool
+			dbTestHandler.PinPostFunc = func(postID uint, feed string, userID string, siteModerator bool, expiresAt null.Time) error {
+				receivedSiteModerator = siteModerator
+				return tt.dbError
+			}
+			a.PinPost(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if receivedSiteModerator != tt.expectedSiteModerator {
+				t.Errorf("PinPost received siteModerator %v, want %v", receivedSiteModerator, tt.expectedSiteModerator)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_UnpinPost(t *testing.T) {
+	tests := []struct {
+		name     string
+		postID   interface{}
+		feed     string
+		dbError  error
+		expected helpers.ExpectedJSONOutput[string]
+	}{
+		{"Unpin OK", testPostID, models.PinFeedCommunity, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: PostUnpinnedMsg}},
+		{"Unpin invalid post ID", "abc", models.PinFeedCommunity, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrPostNotFound}},
+		{"Unpin invalid feed", testPostID, "global", nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: helpers.ErrInvalidPinFeed}},
+		{"Unpin post not found", testPostID, models.PinFeedProject, gorm.ErrRecordNotFound, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrPostNotFound}},
+		{"Unpin post not pinned", testPostID, models.PinFeedCommunity, database.ErrPostNotPinned, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrPostNotPinned}},
+		{"Unpin not owner", testPostID, models.PinFeedProject, helpers.ErrNotOwner, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusForbidden, JSONType: helpers.ExpectedError, Error: helpers.ErrNotOwner}},
+		{"Unpin cannot unpin", testPostID, models.PinFeedCommunity, ErrTest, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotUnpinPost}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &PostDBTestHandler{}
+			a := &APIEnv{
+				PostDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.PostIDKey, tt.postID)
+			helpers.AddParamsToContext(c, helpers.PinFeedKey, tt.feed)
+
+			dbTestHandler.SetMockUnpinPostFunc(tt.dbError)
+			a.UnpinPost(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetRankedPostsWithPinnedPosts(t *testing.T) {
+	testCursor := &models.PostCursor{Score: 10, PostID: 4}
+	tests := []struct {
+		name                  string
+		cursor                *models.PostCursor
+		expectedPinnedFetched bool
+		expectedPostIDs       []uint
+		expectedPinned        []bool
+	}{
+		// The pinned post is moved from its place in the ranking to the top of the first page
+		{"First page OK", nil, true, []uint{2, 3, 1}, []bool{true, false, false}},
+		// The pinned post is not shown again on later pages, and pins are not looked up
+		{"Later page OK", testCursor, false, []uint{3, 1}, []bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranker := &TestPostRanker{
+				GetRankedPostsFunc: func(feed *RankedFeed, cursor *models.PostCursor, count int) ([]models.PostCursor, error) {
+					return []models.PostCursor{{Score: 9, PostID: 3}, {Score: 8, PostID: 2}, {Score: 7, PostID: 1}}, nil
+				},
+			}
+			dbTestHandler := &PostDBTestHandler{
+				// Post 2 is pinned to the community feed, so it is left out of the ranked posts
+				GetFeedPostsByIDsFunc: func(postIDs []uint, since time.Time, communityID *helpers.NullableUint,
+					projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
+					posts := []models.Post{}
+					for _, postID := range postIDs {
+						if postID != 2 || communityID.IsNull() {
+							posts = append(posts, models.Post{Model: gorm.Model{ID: postID}, UserID: testUserID})
+						}
+					}
+					return posts, nil
+				},
+			}
+			pinnedFetched := false
+			dbTestHandler.GetPinnedPostsFunc = func(communityID *helpers.NullableUint, projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
+				pinnedFetched = true
+				return []models.Post{{Model: gorm.Model{ID: 2}, UserID: testUserID}}, nil
+			}
+			cacheTestHandler := &helpers.TestCache{}
+			cacheTestHandler.SetMockGetCacheValFunc(0, nil)
+			a := &APIEnv{
+				PostDBHandler:        dbTestHandler,
+				LikesCacheHandler:    cacheTestHandler,
+				CommentsCacheHandler: cacheTestHandler,
+				PostRanker:           ranker,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			req, _ := http.NewRequest(http.MethodGet, "", nil)
+			helpers.AddParamsToQuery(req, helpers.PostSortKey, models.PostSortHot)
+			helpers.AddParamsToQuery(req, helpers.CommunityIDQueryKey, testCommunityID)
+			if tt.cursor != nil {
+				helpers.AddParamsToQuery(req, helpers.PostCursorKey, helpers.EncodePostCursor(tt.cursor))
+			}
+			c.Request = req
+
+			a.GetPosts(c)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Status code = %d, want %d", w.Code, http.StatusOK)
+			}
+			b, _ := io.ReadAll(w.Body)
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			postIDs := []uint{}
+			pinned := []bool{}
+			for _, post := range m["data"].(map[string]interface{})["Posts"].([]interface{}) {
+				view := post.(map[string]interface{})
+				postIDs = append(postIDs, uint(view["Post"].(map[string]interface{})["ID"].(float64)))
+				pinned = append(pinned, view["Pinned"].(bool))
+			}
+			if pinnedFetched != tt.expectedPinnedFetched {
+				t.Errorf("Pinned posts fetched = %v, want %v", pinnedFetched, tt.expectedPinnedFetched)
+			}
+			if !reflect.DeepEqual(postIDs, tt.expectedPostIDs) || !reflect.DeepEqual(pinned, tt.expectedPinned) {
+				t.Errorf("Returned posts %v pinned %v, want %v pinned %v", postIDs, pinned, tt.expectedPostIDs, tt.expectedPinned)
+			}
+		})
+	}
+}
@@ -5,9 +5,12 @@ package controllers
 
 import (
 	"errors"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/contentfilter"
 	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
@@ -27,6 +30,16 @@ var (
 	ErrPostNotFound     = errors.New("post not found")
 )
 
+const (
+	// Number of posts in each page of a hot or top feed
+	rankedPostsToReturn = 10
+	// Number of ranked posts read at a time while filling a page
+	rankedPostBatchSize = 20
+	// Most batches read while filling a page, so that a feed of mostly hidden posts does
+	// not hold up the request; the next page continues from where this one stopped
+	maxRankedPostBatches = 5
+)
+
 func (a *APIEnv) InitialisePostHandler() {
 	a.PostDBHandler = &database.PostDB{
 		DB: a.DB,
@@ -49,14 +62,40 @@ func (a *APIEnv) CreatePost(ctx *gin.Context) {
 	userID := helpers.GetUserIDFromContext(ctx)
 	newPost.UserID = userID
 
+	// If the content filter rejects the post, return status code 422 Unprocessable Entity
+	content := &contentfilter.Content{
+		Kind:     contentfilter.KindPost,
+		AuthorID: userID,
+		Text:     newPost.Content,
+	}
+	filterResult := a.ContentFilter.Check(ctx, content)
+	if filterResult.Verdict == contentfilter.Reject {
+		helpers.OutputError(ctx, http.StatusUnprocessableEntity, filterResult.Reason)
+		return
+	}
+	newPost.HeldForReview = filterResult.Verdict == contentfilter.Hold
+
 	post, err := a.PostDBHandler.CreatePost(&newPost)
 
-	// If post cannot be created, return status code 500 Internal Service Error
+	// If post cannot be created, return status code 500 Internal Service Error. The post
+	// was not saved, so the user may send it again.
 	if err != nil {
+		a.ContentFilter.Release(ctx, content)
 		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotCreatePost)
 		return
 	}
-	helpers.OutputData(ctx, post.PostView(&models.PostViewParams{UserID: userID}))
+	// Even if the post cannot be ranked, it is still shown in the newest posts and is
+	// ranked once it is liked or commented on
+	a.rankPost(ctx, post.ID)
+	a.addToTimelines(ctx, post.ID)
+	postView := post.PostView(&models.PostViewParams{UserID: userID})
+	// If the post is held for review, return status code 202 Accepted
+	if newPost.HeldForReview {
+		a.queueHeldContent(models.ReportTargetPost, post.ID, filterResult)
+		helpers.OutputAcceptedData(ctx, postView)
+		return
+	}
+	helpers.OutputData(ctx, postView)
 }
 
 func (a *APIEnv) DeletePost(ctx *gin.Context) {
@@ -70,6 +109,10 @@ func (a *APIEnv) DeletePost(ctx *gin.Context) {
 	}
 
 	err = a.PostDBHandler.DeletePost(postID, userID)
+	// Admins may delete posts that they do not own
+	err = retryAsAdmin(a, ctx, err, models.NewAuditLog(models.AuditActionDelete, models.AuditTargetPost, postID),
+		func() (*models.Post, error) { return a.PostDBHandler.GetPostByID(postID, "") },
+		func(ownerID string) error { return a.PostDBHandler.DeletePost(postID, ownerID) })
 	// If post cannot be found in the database return status code 404 Status Not Found
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		helpers.OutputError(ctx, http.StatusNotFound, ErrPostNotFound)
@@ -85,11 +128,27 @@ func (a *APIEnv) DeletePost(ctx *gin.Context) {
 		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotDeletePost)
 		return
 	}
+	// Even if the post cannot be removed from the rankings and home feeds, it is no
+	// longer shown in feeds
+	a.rankPost(ctx, postID)
+	a.removeFromTimelines(ctx, postID)
 	helpers.OutputMessage(ctx, PostDeletedMsg)
 }
 
 func (a *APIEnv) GetPosts(ctx *gin.Context) {
 	userID := helpers.GetUserIDFromContext(ctx)
+	// Ensure that sort and window are valid, defaulting to the newest posts
+	sort, err := helpers.GetPostSortFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	window, err := helpers.GetPostWindowFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
 	// Ensure that cutoff is an unsigned integer or empty
 	cutoff, err := helpers.GetCutoffFromQuery(ctx)
 	if err != nil {
@@ -111,6 +170,25 @@ func (a *APIEnv) GetPosts(ctx *gin.Context) {
 		return
 	}
 
+	additionalURLParams := map[string]interface{}{}
+	if !communityID.IsNull() {
+		val, _ := communityID.GetValue()
+		additionalURLParams[helpers.CommunityIDQueryKey] = val
+	} else if !projectID.IsNull() {
+		val, _ := projectID.GetValue()
+		additionalURLParams[helpers.ProjectIDQueryKey] = val
+	}
+
+	if sort != models.PostSortNew {
+		a.getRankedPosts(ctx, userID, &RankedFeed{
+			Sort:        sort,
+			Window:      window,
+			CommunityID: communityID,
+			ProjectID:   projectID,
+		}, additionalURLParams)
+		return
+	}
+
 	posts, err := a.PostDBHandler.GetPosts(cutoff, communityID, projectID, userID)
 	// If unable to retrieve posts, return status code 404 Not Found
 	if err != nil {
@@ -118,44 +196,202 @@ func (a *APIEnv) GetPosts(ctx *gin.Context) {
 		return
 	}
 	var smallestID uint = 0
-	var postViews []models.PostView
-	// Fill in user details for each post using userID of post creator
+	// Set next cutoff value; pinned posts are left out of GetPosts, so they do not
+	// affect the cutoff
 	for _, post := range posts {
 		smallestID = post.ID
-		likeCount, err := a.LikesCacheHandler.GetCacheVal(ctx, post.ID)
+	}
+
+	nextPageURL := helpers.GeneratePostNextPageURL(models.BackendAddress, smallestID, additionalURLParams)
+
+	// Pinned posts are only shown above the first page of the feed
+	var pinnedPosts []models.Post
+	if cutoff.IsNull() {
+		pinnedPosts = a.getPinnedPosts(communityID, projectID, userID)
+	}
+	postViewArray := models.PostViewArray{
+		Posts:       a.feedViews(ctx, pinnedPosts, posts, userID),
+		NextPageURL: nextPageURL,
+	}
+	helpers.OutputData(ctx, postViewArray)
+}
+
+// Returns a page of the hot or top feed, continuing from the cursor in the query. Posts
+// are read from the ranking in batches and those that cannot be shown to the user are
+// skipped, so the next page continues from the last post read rather than the last
+// post returned.
+func (a *APIEnv) getRankedPosts(ctx *gin.Context, userID string, feed *RankedFeed, additionalURLParams map[string]interface{}) {
+	// Ensure that cursor is one given in a previous page
+	cursor, err := helpers.GetPostCursorFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	// Pinned posts are shown above the first page rather than at their place in the
+	// ranking, so GetFeedPostsByIDs leaves them out of every page
+	var pinnedPosts []models.Post
+	if cursor == nil {
+		pinnedPosts = a.getPinnedPosts(feed.CommunityID, feed.ProjectID, userID)
+	}
+
+	// Top posts are only ranked among those created within the window
+	var since time.Time
+	if duration, ok := models.RankingWindowDurations[feed.Window]; ok && feed.Sort == models.PostSortTop {
+		since = time.Now().Add(-duration)
+	}
+
+	posts := []models.Post{}
+	for batch := 0; batch < maxRankedPostBatches && len(posts) < rankedPostsToReturn; batch++ {
+		positions, err := a.PostRanker.GetRankedPosts(ctx, feed, cursor, rankedPostBatchSize)
+		// If unable to retrieve posts, return status code 404 Not Found
 		if err != nil {
-			continue
+			helpers.OutputError(ctx, http.StatusNotFound, ErrPostNotFound)
+			return
+		}
+		postIDs := []uint{}
+		for _, position := range positions {
+			postIDs = append(postIDs, position.PostID)
 		}
-		commentCount, err := a.CommentsCacheHandler.GetCacheVal(ctx, post.ID)
+		visiblePosts, err := a.PostDBHandler.GetFeedPostsByIDs(postIDs, since, feed.CommunityID, feed.ProjectID, userID)
 		if err != nil {
-			continue
+			helpers.OutputError(ctx, http.StatusNotFound, ErrPostNotFound)
+			return
+		}
+		postsByID := map[uint]models.Post{}
+		for _, post := range visiblePosts {
+			postsByID[post.ID] = post
+		}
+		for i := range positions {
+			cursor = &positions[i]
+			if post, ok := postsByID[positions[i].PostID]; ok {
+				posts = append(posts, post)
+				if len(posts) == rankedPostsToReturn {
+					break
+				}
+			}
+		}
+		// The ranking has no more posts
+		if len(positions) < rankedPostBatchSize {
+			break
 		}
-		postView := post.PostView(&models.PostViewParams{
-			UserID:       userID,
-			LikeCount:    likeCount,
-			CommentCount: commentCount,
-		})
-		postViews = append(postViews, *postView)
 	}
 
-	additionalURLParams := map[string]interface{}{}
-	if !communityID.IsNull() {
-		val, _ := communityID.GetValue()
-		additionalURLParams[helpers.CommunityIDQueryKey] = val
-	} else if !projectID.IsNull() {
-		val, _ := projectID.GetValue()
-		additionalURLParams[helpers.ProjectIDQueryKey] = val
+	nextPageURL := ""
+	if cursor != nil {
+		nextPageURL = helpers.GenerateRankedPostNextPageURL(models.BackendAddress, feed.Sort, feed.Window, cursor, additionalURLParams)
+	}
+	postViewArray := models.PostViewArray{
+		Posts:       a.feedViews(ctx, pinnedPosts, posts, userID),
+		NextPageURL: nextPageURL,
 	}
+	helpers.OutputData(ctx, postViewArray)
+}
 
-	nextPageURL := helpers.GeneratePostNextPageURL(models.BackendAddress, smallestID, additionalURLParams)
+// Returns a page of the user's home feed, continuing from the cutoff in the query. Like
+// the hot and top feeds, posts that cannot be shown to the user are skipped, so the next
+// page continues from the last post read rather than the last post returned.
+func (a *APIEnv) GetHomeFeed(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	// Ensure that cutoff is an unsigned integer or empty
+	cutoff, err := helpers.GetCutoffFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	posts := []models.Post{}
+	var smallestID uint = 0
+	for batch := 0; batch < maxRankedPostBatches && len(posts) < rankedPostsToReturn; batch++ {
+		postIDs, err := a.HomeTimeline.GetTimeline(ctx, userID, cutoff, rankedPostBatchSize)
+		// If unable to retrieve posts, return status code 404 Not Found
+		if err != nil {
+			helpers.OutputError(ctx, http.StatusNotFound, ErrPostNotFound)
+			return
+		}
+		visiblePosts, err := a.PostDBHandler.GetHomeFeedPostsByIDs(postIDs, userID)
+		if err != nil {
+			helpers.OutputError(ctx, http.StatusNotFound, ErrPostNotFound)
+			return
+		}
+		postsByID := map[uint]models.Post{}
+		for _, post := range visiblePosts {
+			postsByID[post.ID] = post
+		}
+		for _, postID := range postIDs {
+			smallestID = postID
+			cutoff = helpers.NewNullableUint(postID)
+			if post, ok := postsByID[postID]; ok {
+				posts = append(posts, post)
+				if len(posts) == rankedPostsToReturn {
+					break
+				}
+			}
+		}
+		// The timeline has no more posts
+		if len(postIDs) < rankedPostBatchSize {
+			break
+		}
+	}
 
 	postViewArray := models.PostViewArray{
-		Posts:       postViews,
-		NextPageURL: nextPageURL,
+		Posts:       a.postViews(ctx, posts, userID),
+		NextPageURL: helpers.GenerateHomeFeedNextPageURL(models.BackendAddress, smallestID),
 	}
 	helpers.OutputData(ctx, postViewArray)
 }
 
+// Retrieves the posts pinned to the community or project feed being viewed. Feeds are
+// still shown without their pinned posts if these cannot be retrieved.
+func (a *APIEnv) getPinnedPosts(communityID *helpers.NullableUint, projectID *helpers.NullableUint, userID string) []models.Post {
+	if communityID.IsNull() && projectID.IsNull() {
+		return nil
+	}
+	pinnedPosts, err := a.PostDBHandler.GetPinnedPosts(communityID, projectID, userID)
+	if err != nil {
+		log.Printf("Unable to retrieve pinned posts: %v", err)
+		return nil
+	}
+	return pinnedPosts
+}
+
+// Returns the views of a page of a feed, with the pinned posts first
+func (a *APIEnv) feedViews(ctx *gin.Context, pinnedPosts []models.Post, posts []models.Post, userID string) []models.PostView {
+	postViews := a.postViews(ctx, append(pinnedPosts, posts...), userID)
+	for i := range pinnedPosts {
+		postViews[i].Pinned = true
+	}
+	return postViews
+}
+
+// Fills in the like and comment counts of each post, looking up the counts of all the
+// posts at once. Posts whose counts cannot be retrieved are still shown, with the counts
+// that could not be retrieved left as zero.
+func (a *APIEnv) postViews(ctx *gin.Context, posts []models.Post, userID string) []models.PostView {
+	postIDs := make([]uint, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	likeCounts, err := a.LikesCacheHandler.GetCacheVals(ctx, postIDs)
+	if err != nil {
+		log.Printf("Unable to retrieve like counts: %v", err)
+	}
+	commentCounts, err := a.CommentsCacheHandler.GetCacheVals(ctx, postIDs)
+	if err != nil {
+		log.Printf("Unable to retrieve comment counts: %v", err)
+	}
+
+	var postViews []models.PostView
+	for _, post := range posts {
+		postView := post.PostView(&models.PostViewParams{
+			UserID:       userID,
+			LikeCount:    likeCounts[post.ID],
+			CommentCount: commentCounts[post.ID],
+		})
+		postViews = append(postViews, *postView)
+	}
+	return postViews
+}
+
 func (a *APIEnv) GetPostByID(ctx *gin.Context) {
 	userID := helpers.GetUserIDFromContext(ctx)
 
@@ -212,7 +448,28 @@ func (a *APIEnv) UpdatePost(ctx *gin.Context) {
 		return
 	}
 
+	// If the content filter rejects the update, return status code 422 Unprocessable Entity
+	filterResult := a.ContentFilter.Check(ctx, &contentfilter.Content{
+		Kind:     contentfilter.KindPost,
+		AuthorID: userID,
+		Text:     inputUpdate.Content,
+		IsEdit:   true,
+	})
+	if filterResult.Verdict == contentfilter.Reject {
+		helpers.OutputError(ctx, http.StatusUnprocessableEntity, filterResult.Reason)
+		return
+	}
+	// Editing a held post does not release it, since false is not written by the update
+	inputUpdate.HeldForReview = filterResult.Verdict == contentfilter.Hold
+
 	post, err := a.PostDBHandler.UpdatePost(&inputUpdate, postID, userID)
+	// Admins may update posts that they do not own
+	err = retryAsAdmin(a, ctx, err, models.NewAuditLog(models.AuditActionUpdate, models.AuditTargetPost, postID),
+		func() (*models.Post, error) { return a.PostDBHandler.GetPostByID(postID, "") },
+		func(ownerID string) (err error) {
+			post, err = a.PostDBHandler.UpdatePost(&inputUpdate, postID, ownerID)
+			return err
+		})
 
 	// If post cannot be found in the database, return status code 404 Status Not Found
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -242,9 +499,16 @@ func (a *APIEnv) UpdatePost(ctx *gin.Context) {
 		return
 	}
 
-	helpers.OutputData(ctx, post.PostView(&models.PostViewParams{
+	postView := post.PostView(&models.PostViewParams{
 		UserID:       userID,
 		LikeCount:    likeCount,
 		CommentCount: commentCount,
-	}))
+	})
+	// If the post is held for review, return status code 202 Accepted
+	if inputUpdate.HeldForReview {
+		a.queueHeldContent(models.ReportTargetPost, postID, filterResult)
+		helpers.OutputAcceptedData(ctx, postView)
+		return
+	}
+	helpers.OutputData(ctx, postView)
 }
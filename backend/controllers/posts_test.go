@@ -1,13 +1,18 @@
 package controllers
 
 import (
+	"context"
 	"io"
 	"net/http"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/ryanozx/skillnet/contentfilter"
 	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
 	"gorm.io/gorm"
 )
 
@@ -37,17 +42,30 @@ var (
 		UserID:  testUserID,
 		User:    defaultUser,
 	}
+	bookmarkedPost = models.Post{
+		Model:     gorm.Model{ID: testPostID},
+		Content:   "Hello world!",
+		UserID:    testUserID,
+		User:      defaultUser,
+		Bookmarks: []models.Bookmark{{UserID: testUserID, PostID: testPostID}},
+	}
 	newTestPost = models.Post{
 		Content: "Hello world!",
 	}
 )
 
 type PostDBTestHandler struct {
-	CreatePostFunc  func(*models.Post) (*models.Post, error)
-	DeletePostFunc  func(uint, string) error
-	GetPostsFunc    func(*helpers.NullableUint, *helpers.NullableUint, *helpers.NullableUint, string) ([]models.Post, error)
-	GetPostByIDFunc func(uint, string) (*models.Post, error)
-	UpdatePostFunc  func(*models.Post, uint, string) (*models.Post, error)
+	CreatePostFunc        func(*models.Post) (*models.Post, error)
+	DeletePostFunc        func(uint, string) error
+	GetPostsFunc          func(*helpers.NullableUint, *helpers.NullableUint, *helpers.NullableUint, string) ([]models.Post, error)
+	GetPostByIDFunc       func(uint, string) (*models.Post, error)
+	GetPostsByIDsFunc     func([]uint, time.Time, string) ([]models.Post, error)
+	GetFeedPostsByIDsFunc func([]uint, time.Time, *helpers.NullableUint, *helpers.NullableUint, string) ([]models.Post, error)
+	GetHomeFeedPostsFunc  func([]uint, string) ([]models.Post, error)
+	GetPinnedPostsFunc    func(*helpers.NullableUint, *helpers.NullableUint, string) ([]models.Post, error)
+	PinPostFunc           func(uint, string, string, bool, null.Time) error
+	UnpinPostFunc         func(uint, string, string, bool) error
+	UpdatePostFunc        func(*models.Post, uint, string) (*models.Post, error)
 }
 
 func (h *PostDBTestHandler) CreatePost(newPost *models.Post) (*models.Post, error) {
@@ -67,10 +85,87 @@ func (h *PostDBTestHandler) GetPostByID(postID uint, userID string) (*models.Pos
 	return h.GetPostByIDFunc(postID, userID)
 }
 
+func (h *PostDBTestHandler) GetPostsByIDs(postIDs []uint, since time.Time, userID string) ([]models.Post, error) {
+	return h.GetPostsByIDsFunc(postIDs, since, userID)
+}
+
+func (h *PostDBTestHandler) GetFeedPostsByIDs(postIDs []uint, since time.Time, communityID *helpers.NullableUint,
+	projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
+	return h.GetFeedPostsByIDsFunc(postIDs, since, communityID, projectID, userID)
+}
+
+func (h *PostDBTestHandler) GetHomeFeedPostsByIDs(postIDs []uint, userID string) ([]models.Post, error) {
+	return h.GetHomeFeedPostsFunc(postIDs, userID)
+}
+
+func (h *PostDBTestHandler) GetPinnedPosts(communityID *helpers.NullableUint, projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
+	return h.GetPinnedPostsFunc(communityID, projectID, userID)
+}
+
+func (h *PostDBTestHandler) PinPost(postID uint, feed string, userID string, siteModerator bool, expiresAt null.Time) error {
+	return h.PinPostFunc(postID, feed, userID, siteModerator, expiresAt)
+}
+
+func (h *PostDBTestHandler) UnpinPost(postID uint, feed string, userID string, siteModerator bool) error {
+	return h.UnpinPostFunc(postID, feed, userID, siteModerator)
+}
+
 func (h *PostDBTestHandler) UpdatePost(post *models.Post, postID uint, userID string) (*models.Post, error) {
 	return h.UpdatePostFunc(post, postID, userID)
 }
 
+type TestPostRanker struct {
+	Ranked             []uint
+	GetRankedPostsFunc func(*RankedFeed, *models.PostCursor, int) ([]models.PostCursor, error)
+}
+
+func (r *TestPostRanker) RankPost(ctx context.Context, postID uint) error {
+	r.Ranked = append(r.Ranked, postID)
+	return nil
+}
+
+func (r *TestPostRanker) UpdatePostCount(ctx context.Context, postID uint, count string, val uint64) error {
+	r.Ranked = append(r.Ranked, postID)
+	return nil
+}
+
+func (r *TestPostRanker) GetRankedPosts(ctx context.Context, feed *RankedFeed, cursor *models.PostCursor, count int) ([]models.PostCursor, error) {
+	return r.GetRankedPostsFunc(feed, cursor, count)
+}
+
+type TestHomeTimeline struct {
+	Added               []uint
+	Removed             []uint
+	BackfilledFollowees []string
+	BackfilledCommunity []uint
+	BackfillError       error
+	GetTimelineFunc     func(string, *helpers.NullableUint, int) ([]uint, error)
+}
+
+func (t *TestHomeTimeline) AddPost(ctx context.Context, postID uint) error {
+	t.Added = append(t.Added, postID)
+	return nil
+}
+
+func (t *TestHomeTimeline) RemovePost(ctx context.Context, postID uint) error {
+	t.Removed = append(t.Removed, postID)
+	return nil
+}
+
+func (t *TestHomeTimeline) BackfillFollowee(ctx context.Context, userID string, followeeID string) error {
+	t.BackfilledFollowees = append(t.BackfilledFollowees, followeeID)
+	return t.BackfillError
+}
+
+func (t *TestHomeTimeline) BackfillCommunity(ctx context.Context, userID string, communityID uint) error {
+	t.BackfilledCommunity = append(t.BackfilledCommunity, communityID)
+	return t.BackfillError
+}
+
+func (t *TestHomeTimeline) GetTimeline(ctx context.Context, userID string, cutoff *helpers.NullableUint, count int) ([]uint, error) {
+	return t.GetTimelineFunc(userID, cutoff, count)
+}
+
 func (h *PostDBTestHandler) SetMockCreatePostFunc(post *models.Post, err error) {
 	h.CreatePostFunc = func(newPost *models.Post) (*models.Post, error) {
 		return post, err
@@ -90,6 +185,18 @@ func (h *PostDBTestHandler) SetMockGetPostsFunc(posts []models.Post, err error)
 	}
 }
 
+func (h *PostDBTestHandler) SetMockGetPinnedPostsFunc(posts []models.Post, err error) {
+	h.GetPinnedPostsFunc = func(communityID *helpers.NullableUint, projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
+		return posts, err
+	}
+}
+
+func (h *PostDBTestHandler) SetMockUnpinPostFunc(err error) {
+	h.UnpinPostFunc = func(postID uint, feed string, userID string, siteModerator bool) error {
+		return err
+	}
+}
+
 func (h *PostDBTestHandler) SetMockGetPostByIDFunc(post *models.Post, err error) {
 	h.GetPostByIDFunc = func(postID uint, userID string) (*models.Post, error) {
 		return post, err
@@ -151,6 +258,8 @@ func TestAPIEnv_CreatePost(t *testing.T) {
 		PostData      *models.Post
 		PostDBOutput  *models.Post
 		PostDBError   error
+		FilterVerdict contentfilter.Verdict
+		FilterReason  error
 	}
 	tests := []struct {
 		name     string
@@ -188,6 +297,41 @@ func TestAPIEnv_CreatePost(t *testing.T) {
 				Error:      ErrBadBinding,
 			},
 		},
+		{
+			"Create Post - Rejected by Content Filter",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				PostData:      &newTestPost,
+				FilterVerdict: contentfilter.Reject,
+				FilterReason:  contentfilter.ErrBannedWord,
+			},
+			helpers.ExpectedJSONOutput[models.PostView]{
+				StatusCode: http.StatusUnprocessableEntity,
+				JSONType:   helpers.ExpectedError,
+				Error:      contentfilter.ErrBannedWord,
+			},
+		},
+		{
+			"Create Post - Held by Content Filter",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				PostData:      &newTestPost,
+				PostDBOutput:  &defaultPost,
+				FilterVerdict: contentfilter.Hold,
+				FilterReason:  contentfilter.ErrTooManyLinks,
+			},
+			helpers.ExpectedJSONOutput[models.PostView]{
+				StatusCode: http.StatusAccepted,
+				JSONType:   helpers.ExpectedData,
+				Data: defaultPost.PostView(&models.PostViewParams{
+					UserID: testUserID,
+				}),
+			},
+		},
 		{
 			"Create Post - Cannot Create",
 			args{
@@ -210,10 +354,18 @@ func TestAPIEnv_CreatePost(t *testing.T) {
 			dbTestHandler := &PostDBTestHandler{}
 			likesCacheTestHandler := &helpers.TestCache{}
 			commentsCacheTestHandler := &helpers.TestCache{}
+			contentFilter := &helpers.TestContentFilter{}
+			reportDBTestHandler := &ReportDBTestHandler{}
+			ranker := &TestPostRanker{}
+			timeline := &TestHomeTimeline{}
 			a := &APIEnv{
 				PostDBHandler:        dbTestHandler,
 				LikesCacheHandler:    likesCacheTestHandler,
 				CommentsCacheHandler: commentsCacheTestHandler,
+				ContentFilter:        contentFilter,
+				ReportDBHandler:      reportDBTestHandler,
+				PostRanker:           ranker,
+				HomeTimeline:         timeline,
 			}
 
 			c, w := helpers.CreateTestContextAndRecorder()
@@ -236,12 +388,31 @@ func TestAPIEnv_CreatePost(t *testing.T) {
 			}
 
 			dbTestHandler.SetMockCreatePostFunc(tt.args.PostDBOutput, tt.args.PostDBError)
+			contentFilter.SetMockCheckFunc(tt.args.FilterVerdict, tt.args.FilterReason)
+			reportQueued := false
+			reportDBTestHandler.CreateReportFunc = func(report *models.Report) (*models.Report, error) {
+				reportQueued = report.ReporterID == models.ContentFilterReporterID && report.TargetType == models.ReportTargetPost
+				return report, nil
+			}
 			a.CreatePost(c)
 
 			b, _ := io.ReadAll(w.Body)
 			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
 				t.Error(errStr)
 			}
+			if reportQueued != (tt.expected.StatusCode == http.StatusAccepted) {
+				t.Errorf("Held post queued for review = %v", reportQueued)
+			}
+			// Posts that are not saved can be sent again
+			if released := len(contentFilter.Released) > 0; released != (tt.args.PostDBError != nil) {
+				t.Errorf("Post released by content filter = %v", released)
+			}
+			if ranked := len(ranker.Ranked) == 1; ranked != (tt.expected.JSONType == helpers.ExpectedData) {
+				t.Errorf("Created post ranked = %v", ranked)
+			}
+			if added := len(timeline.Added) == 1; added != (tt.expected.JSONType == helpers.ExpectedData) {
+				t.Errorf("Created post added to home feeds = %v", added)
+			}
 
 			m, err := helpers.ParseJSONString(b)
 			if err != nil {
@@ -329,8 +500,12 @@ func TestAPIEnv_DeletePost(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			dbTestHandler := &PostDBTestHandler{}
+			ranker := &TestPostRanker{}
+			timeline := &TestHomeTimeline{}
 			a := &APIEnv{
 				PostDBHandler: dbTestHandler,
+				PostRanker:    ranker,
+				HomeTimeline:  timeline,
 			}
 
 			c, w := helpers.CreateTestContextAndRecorder()
@@ -353,6 +528,13 @@ func TestAPIEnv_DeletePost(t *testing.T) {
 			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
 				t.Error(errStr)
 			}
+			// Deleted posts are re-ranked so that they are removed from the rankings
+			if ranked := len(ranker.Ranked) == 1; ranked != (tt.expected.StatusCode == http.StatusOK) {
+				t.Errorf("Deleted post ranked = %v", ranked)
+			}
+			if removed := len(timeline.Removed) == 1; removed != (tt.expected.StatusCode == http.StatusOK) {
+				t.Errorf("Deleted post removed from home feeds = %v", removed)
+			}
 
 			m, err := helpers.ParseJSONString(b)
 			if err != nil {
@@ -374,6 +556,8 @@ func TestAPIEnv_GetPosts(t *testing.T) {
 		QueryParams        map[string]interface{}
 		PostDBOutput       []models.Post
 		PostDBError        error
+		PinnedPostDBOutput []models.Post
+		PinnedPostDBError  error
 		LikesCacheVal      uint64
 		LikesCacheError    error
 		CommentsCacheVal   uint64
@@ -498,6 +682,112 @@ func TestAPIEnv_GetPosts(t *testing.T) {
 				},
 			},
 		},
+		{
+			"Get posts OK - pinned posts first",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				QueryParams: map[string]interface{}{
+					helpers.CommunityIDQueryKey: testCommunityID,
+				},
+				PostDBOutput:       []models.Post{defaultPost},
+				PostDBError:        nil,
+				PinnedPostDBOutput: []models.Post{diffCutoffPost},
+				LikesCacheVal:      1,
+				LikesCacheError:    nil,
+				CommentsCacheVal:   2,
+				CommentsCacheError: nil,
+			},
+			// Pinned posts do not affect the cutoff of the next page
+			helpers.ExpectedJSONOutput[models.PostViewArray]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedData,
+				Data: &models.PostViewArray{
+					Posts: []models.PostView{
+						pinnedPostView(&diffCutoffPost, &models.PostViewParams{
+							UserID:       testUserID,
+							LikeCount:    1,
+							CommentCount: 2,
+						}),
+						*defaultPost.PostView(&models.PostViewParams{
+							UserID:       testUserID,
+							LikeCount:    1,
+							CommentCount: 2,
+						}),
+					},
+					NextPageURL: helpers.GeneratePostNextPageURL(models.BackendAddress, testPostID, map[string]interface{}{
+						helpers.CommunityIDQueryKey: testCommunityID,
+					}),
+				},
+			},
+		},
+		{
+			"Get posts OK - pinned posts only on first page",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				QueryParams: map[string]interface{}{
+					helpers.ProjectIDQueryKey: testProjectID,
+					helpers.CutoffKey:         testDiffCutoffPostID,
+				},
+				PostDBOutput:       []models.Post{defaultPost},
+				PostDBError:        nil,
+				PinnedPostDBOutput: []models.Post{diffCutoffPost},
+				LikesCacheVal:      1,
+				LikesCacheError:    nil,
+				CommentsCacheVal:   2,
+				CommentsCacheError: nil,
+			},
+			helpers.ExpectedJSONOutput[models.PostViewArray]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedData,
+				Data: &models.PostViewArray{
+					Posts: []models.PostView{*defaultPost.PostView(&models.PostViewParams{
+						UserID:       testUserID,
+						LikeCount:    1,
+						CommentCount: 2,
+					})},
+					NextPageURL: helpers.GeneratePostNextPageURL(models.BackendAddress, testPostID, map[string]interface{}{
+						helpers.ProjectIDQueryKey: testProjectID,
+					}),
+				},
+			},
+		},
+		{
+			"Get posts pinned posts error OK",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				QueryParams: map[string]interface{}{
+					helpers.CommunityIDQueryKey: testCommunityID,
+				},
+				PostDBOutput:       []models.Post{defaultPost},
+				PostDBError:        nil,
+				PinnedPostDBError:  ErrTest,
+				LikesCacheVal:      1,
+				LikesCacheError:    nil,
+				CommentsCacheVal:   2,
+				CommentsCacheError: nil,
+			},
+			// The rest of the feed is still shown when its pinned posts cannot be retrieved
+			helpers.ExpectedJSONOutput[models.PostViewArray]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedData,
+				Data: &models.PostViewArray{
+					Posts: []models.PostView{*defaultPost.PostView(&models.PostViewParams{
+						UserID:       testUserID,
+						LikeCount:    1,
+						CommentCount: 2,
+					})},
+					NextPageURL: helpers.GeneratePostNextPageURL(models.BackendAddress, testPostID, map[string]interface{}{
+						helpers.CommunityIDQueryKey: testCommunityID,
+					}),
+				},
+			},
+		},
 		{
 			"Get posts OK - multiple posts",
 			args{
@@ -605,11 +895,14 @@ func TestAPIEnv_GetPosts(t *testing.T) {
 				LikesCacheVal:   1,
 				LikesCacheError: ErrTest,
 			},
+			// The post is still shown when its like count cannot be retrieved
 			helpers.ExpectedJSONOutput[models.PostViewArray]{
 				StatusCode: http.StatusOK,
 				JSONType:   helpers.ExpectedData,
 				Data: &models.PostViewArray{
-					Posts:       []models.PostView{},
+					Posts: []models.PostView{*defaultPost.PostView(&models.PostViewParams{
+						UserID: testUserID,
+					})},
 					NextPageURL: helpers.GeneratePostNextPageURL(models.BackendAddress, testPostID, map[string]interface{}{}),
 				},
 			},
@@ -627,11 +920,15 @@ func TestAPIEnv_GetPosts(t *testing.T) {
 				CommentsCacheVal:   2,
 				CommentsCacheError: ErrTest,
 			},
+			// The post is still shown when its comment count cannot be retrieved
 			helpers.ExpectedJSONOutput[models.PostViewArray]{
 				StatusCode: http.StatusOK,
 				JSONType:   helpers.ExpectedData,
 				Data: &models.PostViewArray{
-					Posts:       []models.PostView{},
+					Posts: []models.PostView{*defaultPost.PostView(&models.PostViewParams{
+						UserID:    testUserID,
+						LikeCount: 1,
+					})},
 					NextPageURL: helpers.GeneratePostNextPageURL(models.BackendAddress, testPostID, map[string]interface{}{}),
 				},
 			},
@@ -666,6 +963,7 @@ func TestAPIEnv_GetPosts(t *testing.T) {
 			c.Request = req
 
 			dbTestHandler.SetMockGetPostsFunc(tt.args.PostDBOutput, tt.args.PostDBError)
+			dbTestHandler.SetMockGetPinnedPostsFunc(tt.args.PinnedPostDBOutput, tt.args.PinnedPostDBError)
 			likesCacheTestHandler.SetMockGetCacheValFunc(tt.args.LikesCacheVal, tt.args.LikesCacheError)
 			commentsCacheTestHandler.SetMockGetCacheValFunc(tt.args.CommentsCacheVal, tt.args.CommentsCacheError)
 			a.GetPosts(c)
@@ -726,6 +1024,33 @@ func TestAPIEnv_GetPostByID(t *testing.T) {
 				}),
 			},
 		},
+		{
+			"Get Post By ID - bookmarked OK",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+					helpers.PostIDKey: testPostID,
+				},
+				PostDBOutput:       &bookmarkedPost,
+				PostDBError:        nil,
+				LikesCacheVal:      1,
+				LikesCacheError:    nil,
+				CommentsCacheVal:   2,
+				CommentsCacheError: nil,
+			},
+			helpers.ExpectedJSONOutput[models.PostView]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedData,
+				Data: &models.PostView{
+					Post:         defaultPost,
+					UserMinimal:  *defaultUser.GetUserMinimal(),
+					IsEditable:   true,
+					Bookmarked:   true,
+					LikeCount:    1,
+					CommentCount: 2,
+				},
+			},
+		},
 		{
 			"Get Post By ID - Invalid Post ID",
 			args{
@@ -851,6 +1176,8 @@ func TestAPIEnv_UpdatePost(t *testing.T) {
 		LikesCacheError    error
 		CommentsCacheVal   uint64
 		CommentsCacheError error
+		FilterVerdict      contentfilter.Verdict
+		FilterReason       error
 	}
 	tests := []struct {
 		name     string
@@ -910,6 +1237,23 @@ func TestAPIEnv_UpdatePost(t *testing.T) {
 				Error:      ErrBadBinding,
 			},
 		},
+		{
+			"Update Post - Rejected by Content Filter",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+					helpers.PostIDKey: testPostID,
+				},
+				PostData:      &newTestPost,
+				FilterVerdict: contentfilter.Reject,
+				FilterReason:  contentfilter.ErrTooLong,
+			},
+			helpers.ExpectedJSONOutput[models.PostView]{
+				StatusCode: http.StatusUnprocessableEntity,
+				JSONType:   helpers.ExpectedError,
+				Error:      contentfilter.ErrTooLong,
+			},
+		},
 		{
 			"Update Post - Post Not Found",
 			args{
@@ -1007,10 +1351,12 @@ func TestAPIEnv_UpdatePost(t *testing.T) {
 			dbTestHandler := &PostDBTestHandler{}
 			likesCacheTestHandler := &helpers.TestCache{}
 			commentsCacheTestHandler := &helpers.TestCache{}
+			contentFilter := &helpers.TestContentFilter{}
 			a := &APIEnv{
 				PostDBHandler:        dbTestHandler,
 				LikesCacheHandler:    likesCacheTestHandler,
 				CommentsCacheHandler: commentsCacheTestHandler,
+				ContentFilter:        contentFilter,
 			}
 
 			c, w := helpers.CreateTestContextAndRecorder()
@@ -1029,6 +1375,7 @@ func TestAPIEnv_UpdatePost(t *testing.T) {
 			}
 
 			dbTestHandler.SetMockUpdatePostFunc(tt.args.PostDBOutput, tt.args.PostDBError)
+			contentFilter.SetMockCheckFunc(tt.args.FilterVerdict, tt.args.FilterReason)
 			likesCacheTestHandler.SetMockGetCacheValFunc(tt.args.LikesCacheVal, tt.args.LikesCacheError)
 			commentsCacheTestHandler.SetMockGetCacheValFunc(tt.args.CommentsCacheVal, tt.args.CommentsCacheError)
 			a.UpdatePost(c)
@@ -1049,3 +1396,276 @@ func TestAPIEnv_UpdatePost(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIEnv_GetRankedPosts(t *testing.T) {
+	testCursor := &models.PostCursor{Score: 4, PostID: 9}
+	// Posts 2 and 5 cannot be shown to the user
+	hidden := map[uint]bool{2: true, 5: true}
+	tests := []struct {
+		name            string
+		query           map[string]string
+		positions       []models.PostCursor
+		rankerError     error
+		expectedFeed    *RankedFeed
+		expectedCursor  *models.PostCursor
+		expectedSince   bool
+		expectedPostIDs []uint
+		expectedNext    *models.PostCursor
+		expectedCode    int
+		expectedErr     error
+	}{
+		{"Get hot posts OK", map[string]string{helpers.PostSortKey: models.PostSortHot},
+			[]models.PostCursor{{Score: 9, PostID: 3}, {Score: 8, PostID: 2}, {Score: 7, PostID: 1}}, nil,
+			&RankedFeed{Sort: models.PostSortHot, Window: models.RankingWindowAll}, nil, false,
+			[]uint{3, 1}, &models.PostCursor{Score: 7, PostID: 1}, http.StatusOK, nil},
+		{"Get top posts of week after cursor OK", map[string]string{
+			helpers.PostSortKey:   models.PostSortTop,
+			helpers.PostWindowKey: models.RankingWindowWeek,
+			helpers.PostCursorKey: helpers.EncodePostCursor(testCursor),
+		}, []models.PostCursor{{Score: 4, PostID: 5}}, nil,
+			&RankedFeed{Sort: models.PostSortTop, Window: models.RankingWindowWeek}, testCursor, true,
+			[]uint{}, &models.PostCursor{Score: 4, PostID: 5}, http.StatusOK, nil},
+		{"Get top posts empty feed", map[string]string{helpers.PostSortKey: models.PostSortTop}, []models.PostCursor{}, nil,
+			&RankedFeed{Sort: models.PostSortTop, Window: models.RankingWindowAll}, nil, false,
+			[]uint{}, nil, http.StatusOK, nil},
+		{"Get posts invalid sort", map[string]string{helpers.PostSortKey: "best"}, nil, nil, nil, nil, false, nil, nil,
+			http.StatusBadRequest, helpers.ErrInvalidPostSort},
+		{"Get posts invalid window", map[string]string{helpers.PostSortKey: models.PostSortTop, helpers.PostWindowKey: "month"},
+			nil, nil, nil, nil, false, nil, nil, http.StatusBadRequest, helpers.ErrInvalidPostWindow},
+		{"Get posts invalid cursor", map[string]string{helpers.PostSortKey: models.PostSortHot, helpers.PostCursorKey: "!!"},
+			nil, nil, nil, nil, false, nil, nil, http.StatusBadRequest, helpers.ErrInvalidPostCursor},
+		{"Get hot posts cannot rank", map[string]string{helpers.PostSortKey: models.PostSortHot}, nil, ErrTest,
+			&RankedFeed{Sort: models.PostSortHot, Window: models.RankingWindowAll}, nil, false, nil, nil,
+			http.StatusNotFound, ErrPostNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &PostDBTestHandler{}
+			likesCacheTestHandler := &helpers.TestCache{}
+			commentsCacheTestHandler := &helpers.TestCache{}
+			var receivedFeed *RankedFeed
+			var receivedCursor *models.PostCursor
+			ranker := &TestPostRanker{
+				GetRankedPostsFunc: func(feed *RankedFeed, cursor *models.PostCursor, count int) ([]models.PostCursor, error) {
+					receivedFeed, receivedCursor = feed, cursor
+					return tt.positions, tt.rankerError
+				},
+			}
+			a := &APIEnv{
+				PostDBHandler:        dbTestHandler,
+				LikesCacheHandler:    likesCacheTestHandler,
+				CommentsCacheHandler: commentsCacheTestHandler,
+				PostRanker:           ranker,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			req, _ := http.NewRequest(http.MethodGet, "", nil)
+			for key, val := range tt.query {
+				helpers.AddParamsToQuery(req, key, val)
+			}
+			c.Request = req
+
+			var receivedSince time.Time
+			dbTestHandler.GetFeedPostsByIDsFunc = func(postIDs []uint, since time.Time, communityID *helpers.NullableUint,
+				projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
+				receivedSince = since
+				posts := []models.Post{}
+				for _, postID := range postIDs {
+					if !hidden[postID] {
+						posts = append(posts, models.Post{Model: gorm.Model{ID: postID}, UserID: testUserID})
+					}
+				}
+				return posts, nil
+			}
+			likesCacheTestHandler.SetMockGetCacheValFunc(0, nil)
+			commentsCacheTestHandler.SetMockGetCacheValFunc(0, nil)
+			a.GetPosts(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if tt.expectedFeed != nil && (receivedFeed == nil || receivedFeed.Sort != tt.expectedFeed.Sort ||
+				receivedFeed.Window != tt.expectedFeed.Window) {
+				t.Errorf("GetRankedPosts received feed %+v, want %+v", receivedFeed, tt.expectedFeed)
+			}
+			if !reflect.DeepEqual(receivedCursor, tt.expectedCursor) {
+				t.Errorf("GetRankedPosts received cursor %+v, want %+v", receivedCursor, tt.expectedCursor)
+			}
+			if receivedSince.IsZero() == tt.expectedSince {
+				t.Errorf("GetPostsByIDs received since %v", receivedSince)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			postIDs := []uint{}
+			if posts, ok := data["Posts"].([]interface{}); ok {
+				for _, post := range posts {
+					postIDs = append(postIDs, uint(post.(map[string]interface{})["Post"].(map[string]interface{})["ID"].(float64)))
+				}
+			}
+			if !reflect.DeepEqual(postIDs, tt.expectedPostIDs) {
+				t.Errorf("Returned posts %v, want %v", postIDs, tt.expectedPostIDs)
+			}
+			expectedNext := ""
+			if tt.expectedNext != nil {
+				expectedNext = helpers.GenerateRankedPostNextPageURL(models.BackendAddress, tt.expectedFeed.Sort,
+					tt.expectedFeed.Window, tt.expectedNext, map[string]interface{}{})
+			}
+			if data["NextPageURL"] != expectedNext {
+				t.Errorf("NextPageURL = %v, want %v", data["NextPageURL"], expectedNext)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetRankedPostsStopsAfterMaxBatches(t *testing.T) {
+	calls := 0
+	ranker := &TestPostRanker{
+		GetRankedPostsFunc: func(feed *RankedFeed, cursor *models.PostCursor, count int) ([]models.PostCursor, error) {
+			calls++
+			positions := []models.PostCursor{}
+			for i := 0; i < count; i++ {
+				positions = append(positions, models.PostCursor{Score: float64(calls), PostID: uint(i + 1)})
+			}
+			return positions, nil
+		},
+	}
+	dbTestHandler := &PostDBTestHandler{
+		// None of the ranked posts can be shown to the user
+		GetFeedPostsByIDsFunc: func(postIDs []uint, since time.Time, communityID *helpers.NullableUint,
+			projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
+			return []models.Post{}, nil
+		},
+	}
+	cacheTestHandler := &helpers.TestCache{}
+	cacheTestHandler.SetMockGetCacheValFunc(0, nil)
+	a := &APIEnv{
+		PostDBHandler:        dbTestHandler,
+		LikesCacheHandler:    cacheTestHandler,
+		CommentsCacheHandler: cacheTestHandler,
+		PostRanker:           ranker,
+	}
+	c, w := helpers.CreateTestContextAndRecorder()
+	helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+	req, _ := http.NewRequest(http.MethodGet, "", nil)
+	helpers.AddParamsToQuery(req, helpers.PostSortKey, models.PostSortHot)
+	c.Request = req
+
+	a.GetPosts(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if calls != maxRankedPostBatches {
+		t.Errorf("Read %d batches, want %d", calls, maxRankedPostBatches)
+	}
+	b, _ := io.ReadAll(w.Body)
+	m, _ := helpers.ParseJSONString(b)
+	// The next page continues after the last post read
+	expectedNext := helpers.GenerateRankedPostNextPageURL(models.BackendAddress, models.PostSortHot, models.RankingWindowAll,
+		&models.PostCursor{Score: maxRankedPostBatches, PostID: rankedPostBatchSize}, map[string]interface{}{})
+	if next := m["data"].(map[string]interface{})["NextPageURL"]; next != expectedNext {
+		t.Errorf("NextPageURL = %v, want %v", next, expectedNext)
+	}
+}
+
+func TestAPIEnv_GetHomeFeed(t *testing.T) {
+	// Post 8 cannot be shown to the user
+	hidden := map[uint]bool{8: true}
+	tests := []struct {
+		name            string
+		cutoff          string
+		timeline        []uint
+		timelineError   error
+		expectedCutoff  *helpers.NullableUint
+		expectedPostIDs []uint
+		expectedNext    uint
+		expectedCode    int
+		expectedErr     error
+	}{
+		{"Get home feed OK", "", []uint{9, 8, 7}, nil, &helpers.NullableUint{}, []uint{9, 7}, 7, http.StatusOK, nil},
+		{"Get home feed after cutoff OK", "7", []uint{8}, nil, helpers.NewNullableUint(7), []uint{}, 8, http.StatusOK, nil},
+		{"Get home feed empty", "", []uint{}, nil, &helpers.NullableUint{}, []uint{}, 0, http.StatusOK, nil},
+		{"Get home feed invalid cutoff", "badcutoff", nil, nil, nil, nil, 0, http.StatusBadRequest, ErrBadBinding},
+		{"Get home feed cannot read timeline", "", nil, ErrTest, &helpers.NullableUint{}, nil, 0, http.StatusNotFound, ErrPostNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &PostDBTestHandler{}
+			cacheTestHandler := &helpers.TestCache{}
+			var receivedCutoff *helpers.NullableUint
+			timeline := &TestHomeTimeline{
+				GetTimelineFunc: func(userID string, cutoff *helpers.NullableUint, count int) ([]uint, error) {
+					receivedCutoff = cutoff
+					return tt.timeline, tt.timelineError
+				},
+			}
+			a := &APIEnv{
+				PostDBHandler:        dbTestHandler,
+				LikesCacheHandler:    cacheTestHandler,
+				CommentsCacheHandler: cacheTestHandler,
+				HomeTimeline:         timeline,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			req, _ := http.NewRequest(http.MethodGet, "", nil)
+			helpers.AddParamsToQuery(req, helpers.CutoffKey, tt.cutoff)
+			c.Request = req
+
+			dbTestHandler.GetHomeFeedPostsFunc = func(postIDs []uint, userID string) ([]models.Post, error) {
+				posts := []models.Post{}
+				for _, postID := range postIDs {
+					if !hidden[postID] {
+						posts = append(posts, models.Post{Model: gorm.Model{ID: postID}, UserID: testUserID})
+					}
+				}
+				return posts, nil
+			}
+			cacheTestHandler.SetMockGetCacheValFunc(0, nil)
+			a.GetHomeFeed(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if !reflect.DeepEqual(receivedCutoff, tt.expectedCutoff) {
+				t.Errorf("GetTimeline received cutoff %+v, want %+v", receivedCutoff, tt.expectedCutoff)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			postIDs := []uint{}
+			if posts, ok := data["Posts"].([]interface{}); ok {
+				for _, post := range posts {
+					postIDs = append(postIDs, uint(post.(map[string]interface{})["Post"].(map[string]interface{})["ID"].(float64)))
+				}
+			}
+			if !reflect.DeepEqual(postIDs, tt.expectedPostIDs) {
+				t.Errorf("Returned posts %v, want %v", postIDs, tt.expectedPostIDs)
+			}
+			// The next page continues after the last post read
+			if expectedNext := helpers.GenerateHomeFeedNextPageURL(models.BackendAddress, tt.expectedNext); data["NextPageURL"] != expectedNext {
+				t.Errorf("NextPageURL = %v, want %v", data["NextPageURL"], expectedNext)
+			}
+		})
+	}
+}
@@ -22,10 +22,12 @@ const (
 
 // Errors
 var (
-	ErrCannotCreateProject = errors.New("cannot create project")
-	ErrCannotDeleteProject = errors.New("cannot delete project")
-	ErrCannotUpdateProject = errors.New("cannot update project")
-	ErrProjectNotFound     = errors.New("project not found")
+	ErrCannotCreateProject           = errors.New("cannot create project")
+	ErrCannotDeleteProject           = errors.New("cannot delete project")
+	ErrCannotRetrieveMatchedProjects = errors.New("cannot retrieve projects matching your skills")
+	ErrCannotUpdateProject           = errors.New("cannot update project")
+	ErrCannotUpdateProjectSkills     = errors.New("cannot update project skills")
+	ErrProjectNotFound               = errors.New("project not found")
 )
 
 func (a *APIEnv) InitialiseProjectHandler() {
@@ -72,6 +74,10 @@ func (a *APIEnv) DeleteProject(ctx *gin.Context) {
 	}
 
 	err = a.ProjectDBHandler.DeleteProject(projectID, userID)
+	// Admins may delete projects that they do not own
+	err = retryAsAdmin(a, ctx, err, models.NewAuditLog(models.AuditActionDelete, models.AuditTargetProject, projectID),
+		func() (*models.Project, error) { return a.ProjectDBHandler.GetProjectByID(projectID) },
+		func(ownerID string) error { return a.ProjectDBHandler.DeleteProject(projectID, ownerID) })
 	// If project cannot be found in the database return status code 404 Status Not Found
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		helpers.OutputError(ctx, http.StatusNotFound, ErrProjectNotFound)
@@ -108,7 +114,14 @@ func (a *APIEnv) GetProjects(ctx *gin.Context) {
 
 	username := helpers.GetUsernameFromQuery(ctx)
 
-	projects, err := a.ProjectDBHandler.GetProjects(cutoff, communityID, username)
+	skills := helpers.NormaliseSkillNames(ctx.QueryArray(helpers.SkillQueryKey))
+	// If there are too many skills or a name is too long, return status code 400 Bad Request
+	if err := helpers.ValidateSkillNames(skills); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	projects, err := a.ProjectDBHandler.GetProjects(cutoff, communityID, username, skills)
 	// If unable to retrieve projects, return status code 404 Not Found
 	if err != nil {
 		helpers.OutputError(ctx, http.StatusNotFound, ErrProjectNotFound)
@@ -126,7 +139,7 @@ func (a *APIEnv) GetProjects(ctx *gin.Context) {
 
 	projectsArray := models.ProjectsArray{
 		Projects:    projectMinimals,
-		NextPageURL: helpers.GenerateProjectsNextPageURL(models.BackendAddress, smallestID, communityID, username),
+		NextPageURL: helpers.GenerateProjectsNextPageURL(models.BackendAddress, smallestID, communityID, username, skills),
 	}
 	helpers.OutputData(ctx, projectsArray)
 }
@@ -173,6 +186,13 @@ func (a *APIEnv) UpdateProject(ctx *gin.Context) {
 	}
 
 	project, err := a.ProjectDBHandler.UpdateProject(&inputUpdate, projectID, userID)
+	// Admins may update projects that they do not own
+	err = retryAsAdmin(a, ctx, err, models.NewAuditLog(models.AuditActionUpdate, models.AuditTargetProject, projectID),
+		func() (*models.Project, error) { return a.ProjectDBHandler.GetProjectByID(projectID) },
+		func(ownerID string) (err error) {
+			project, err = a.ProjectDBHandler.UpdateProject(&inputUpdate, projectID, ownerID)
+			return err
+		})
 
 	// If project cannot be found in the database, return status code 404 Status Not Found
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -191,3 +211,61 @@ func (a *APIEnv) UpdateProject(ctx *gin.Context) {
 	}
 	helpers.OutputData(ctx, project.ProjectView(userID))
 }
+
+// Replaces the required and used skills that the project is tagged with
+func (a *APIEnv) UpdateProjectSkills(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that projectID is an unsigned integer
+	projectID, err := helpers.GetProjectIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrProjectNotFound)
+		return
+	}
+
+	var input models.ProjectSkillsInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	input.Required = helpers.NormaliseSkillNames(input.Required)
+	input.Used = helpers.NormaliseSkillNames(input.Used)
+	// If there are too many skills or a name is too long, return status code 400 Bad Request
+	if err := helpers.ValidateSkillNames(append(input.Required, input.Used...)); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	project, err := a.ProjectDBHandler.SetProjectSkills(projectID, userID, &input)
+	// If project cannot be found in the database, return status code 404 Status Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrProjectNotFound)
+		return
+	}
+	// If user is not the owner of the project, return status code 403 Forbidden
+	if errors.Is(err, helpers.ErrNotOwner) {
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.ErrNotOwner)
+		return
+	}
+	// If skills cannot be updated for any other reason, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateProjectSkills)
+		return
+	}
+	helpers.OutputData(ctx, project.ProjectView(userID))
+}
+
+// Returns the projects whose required skills or open roles match the most skills listed
+// on the user's profile
+func (a *APIEnv) GetProjectsMatchingSkills(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	matches, err := a.ProjectDBHandler.GetProjectsMatchingSkills(userID)
+	// If unable to retrieve projects, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveMatchedProjects)
+		return
+	}
+	helpers.OutputData(ctx, matches)
+}
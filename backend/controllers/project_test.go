@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+type ProjectDBTestHandler struct {
+	CreateProjectFunc             func(*models.Project) (*models.Project, error)
+	DeleteProjectFunc             func(uint, string) error
+	GetProjectByIDFunc            func(uint) (*models.Project, error)
+	GetProjectsFunc               func(*helpers.NullableUint, *helpers.NullableUint, string, []string) ([]models.Project, error)
+	UpdateProjectFunc             func(*models.Project, uint, string) (*models.Project, error)
+	SetProjectSkillsFunc          func(uint, string, *models.ProjectSkillsInput) (*models.Project, error)
+	GetProjectsMatchingSkillsFunc func(string) ([]models.ProjectMatch, error)
+}
+
+func (h *ProjectDBTestHandler) CreateProject(project *models.Project) (*models.Project, error) {
+	return h.CreateProjectFunc(project)
+}
+
+func (h *ProjectDBTestHandler) DeleteProject(projectID uint, userID string) error {
+	return h.DeleteProjectFunc(projectID, userID)
+}
+
+func (h *ProjectDBTestHandler) GetProjectByID(projectID uint) (*models.Project, error) {
+	return h.GetProjectByIDFunc(projectID)
+}
+
+func (h *ProjectDBTestHandler) GetProjects(cutoff *helpers.NullableUint, communityID *helpers.NullableUint, username string, skills []string) ([]models.Project, error) {
+	return h.GetProjectsFunc(cutoff, communityID, username, skills)
+}
+
+func (h *ProjectDBTestHandler) UpdateProject(project *models.Project, projectID uint, userID string) (*models.Project, error) {
+	return h.UpdateProjectFunc(project, projectID, userID)
+}
+
+func (h *ProjectDBTestHandler) SetProjectSkills(projectID uint, userID string, input *models.ProjectSkillsInput) (*models.Project, error) {
+	return h.SetProjectSkillsFunc(projectID, userID, input)
+}
+
+func (h *ProjectDBTestHandler) GetProjectsMatchingSkills(userID string) ([]models.ProjectMatch, error) {
+	return h.GetProjectsMatchingSkillsFunc(userID)
+}
+
+func TestAPIEnv_GetProjectsWithSkills(t *testing.T) {
+	helpers.SetEnvVars(t)
+	tests := []struct {
+		name           string
+		skills         []string
+		expectedSkills []string
+		expectedCode   int
+		expectedErr    error
+	}{
+		{"No skills OK", nil, []string{}, http.StatusOK, nil},
+		{"Skills OK", []string{" Go ", "react", "go"}, []string{"Go", "react"}, http.StatusOK, nil},
+		{"Skill name too long", []string{strings.Repeat("a", helpers.MaxSkillNameLength+1)}, nil, http.StatusBadRequest, helpers.ErrSkillNameTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &ProjectDBTestHandler{}
+			a := &APIEnv{
+				ProjectDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "", nil)
+			for _, skill := range tt.skills {
+				helpers.AddParamsToQuery(req, helpers.SkillQueryKey, skill)
+			}
+			c.Request = req
+
+			var receivedSkills []string
+			dbTestHandler.GetProjectsFunc = func(cutoff *helpers.NullableUint, communityID *helpers.NullableUint, username string, skills []string) ([]models.Project, error) {
+				receivedSkills = skills
+				return []models.Project{{ProjectMinimal: models.ProjectMinimal{ID: testProjectID}}}, nil
+			}
+			a.GetProjects(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if !reflect.DeepEqual(receivedSkills, tt.expectedSkills) {
+				t.Errorf("GetProjects received skills %q, want %q", receivedSkills, tt.expectedSkills)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			// Skills must be kept when fetching the next page
+			nextPageURL := m["data"].(map[string]interface{})["NextPageURL"].(string)
+			for _, skill := range tt.expectedSkills {
+				if !strings.Contains(nextPageURL, helpers.SkillQueryKey+"="+skill) {
+					t.Errorf("Next page URL %s does not filter by skill %s", nextPageURL, skill)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIEnv_UpdateProjectSkills(t *testing.T) {
+	helpers.SetEnvVars(t)
+	project := models.Project{
+		ProjectMinimal: models.ProjectMinimal{ID: testProjectID, Name: "SkillNet"},
+		OwnerID:        testUserID,
+		Skills: []models.ProjectSkill{
+			{SkillID: 2, Skill: models.Skill{ID: 2, Name: "React"}, Kind: models.ProjectSkillUsed},
+			{SkillID: 1, Skill: models.Skill{ID: 1, Name: "Go"}, Kind: models.ProjectSkillRequired},
+		},
+	}
+	tests := []struct {
+		name          string
+		projectID     interface{}
+		input         *models.ProjectSkillsInput
+		dbError       error
+		expectedInput *models.ProjectSkillsInput
+		expectedCode  int
+		expectedErr   error
+	}{
+		{
+			"Update project skills OK",
+			testProjectID,
+			&models.ProjectSkillsInput{Required: []string{" golang "}, Used: []string{"React", "react"}},
+			nil,
+			&models.ProjectSkillsInput{Required: []string{"golang"}, Used: []string{"React"}},
+			http.StatusOK, nil,
+		},
+		{"Update project skills invalid ID", "abc", &models.ProjectSkillsInput{}, nil, nil, http.StatusBadRequest, ErrProjectNotFound},
+		{"Update project skills bad request", testProjectID, nil, nil, nil, http.StatusBadRequest, ErrBadBinding},
+		{
+			"Update project skills name too long",
+			testProjectID,
+			&models.ProjectSkillsInput{Used: []string{strings.Repeat("a", helpers.MaxSkillNameLength+1)}},
+			nil, nil, http.StatusBadRequest, helpers.ErrSkillNameTooLong,
+		},
+		{
+			"Update project skills not found",
+			testProjectID,
+			&models.ProjectSkillsInput{Required: []string{"Go"}},
+			gorm.ErrRecordNotFound,
+			&models.ProjectSkillsInput{Required: []string{"Go"}, Used: []string{}},
+			http.StatusNotFound, ErrProjectNotFound,
+		},
+		{
+			"Update project skills not owner",
+			testProjectID,
+			&models.ProjectSkillsInput{Required: []string{"Go"}},
+			helpers.ErrNotOwner,
+			&models.ProjectSkillsInput{Required: []string{"Go"}, Used: []string{}},
+			http.StatusForbidden, helpers.ErrNotOwner,
+		},
+		{
+			"Update project skills cannot update",
+			testProjectID,
+			&models.ProjectSkillsInput{Required: []string{"Go"}},
+			ErrTest,
+			&models.ProjectSkillsInput{Required: []string{"Go"}, Used: []string{}},
+			http.StatusInternalServerError, ErrCannotUpdateProjectSkills,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &ProjectDBTestHandler{}
+			a := &APIEnv{
+				ProjectDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.ProjectIDKey, tt.projectID)
+			if tt.input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPut, tt.input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			var receivedInput *models.ProjectSkillsInput
+			dbTestHandler.SetProjectSkillsFunc = func(projectID uint, userID string, input *models.ProjectSkillsInput) (*models.Project, error) {
+				receivedInput = input
+				return &project, tt.dbError
+			}
+			a.UpdateProjectSkills(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if !reflect.DeepEqual(receivedInput, tt.expectedInput) {
+				t.Errorf("SetProjectSkills received %+v, want %+v", receivedInput, tt.expectedInput)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			required, _ := data["RequiredSkills"].([]interface{})
+			used, _ := data["UsedSkills"].([]interface{})
+			if len(required) != 1 || len(used) != 1 {
+				t.Errorf("Expected one required and one used skill, got %v and %v", data["RequiredSkills"], data["UsedSkills"])
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetProjectsMatchingSkills(t *testing.T) {
+	tests := []struct {
+		name         string
+		dbOutput     []models.ProjectMatch
+		dbError      error
+		expectedCode int
+		expectedErr  error
+	}{
+		{"Get matching projects OK", []models.ProjectMatch{
+			{ProjectMinimal: models.ProjectMinimal{ID: testProjectID}, MatchedSkills: []models.Skill{{ID: 1, Name: "Go"}}},
+		}, nil, http.StatusOK, nil},
+		{"Get matching projects none", []models.ProjectMatch{}, nil, http.StatusOK, nil},
+		{"Get matching projects cannot retrieve", nil, ErrTest, http.StatusInternalServerError, ErrCannotRetrieveMatchedProjects},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &ProjectDBTestHandler{}
+			a := &APIEnv{
+				ProjectDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+
+			var receivedUserID string
+			dbTestHandler.GetProjectsMatchingSkillsFunc = func(userID string) ([]models.ProjectMatch, error) {
+				receivedUserID = userID
+				return tt.dbOutput, tt.dbError
+			}
+			a.GetProjectsMatchingSkills(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if receivedUserID != testUserID {
+				t.Errorf("Matched skills of user %s, want %s", receivedUserID, testUserID)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			if matches, ok := m["data"].([]interface{}); !ok || len(matches) != len(tt.dbOutput) {
+				t.Errorf("Expected %d matches, got %v", len(tt.dbOutput), m["data"])
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+/*
+Contains the rankings behind the hot and top post feeds.
+*/
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// PostRanker keeps the scores of posts in each ranked feed
+type PostRanker interface {
+	// Updates the scores of the post in the feeds that it belongs to, or removes it from
+	// them if it has been deleted
+	RankPost(ctx context.Context, postID uint) error
+	// Sets one of the counts that the post is scored by, either rankingCountLikes or
+	// rankingCountComments, and updates its scores without recounting the post in the
+	// database
+	UpdatePostCount(ctx context.Context, postID uint, count string, val uint64) error
+	// Returns the positions of up to count posts ranked after the cursor in the feed. If
+	// cursor is nil, the positions start from the top of the feed.
+	GetRankedPosts(ctx context.Context, feed *RankedFeed, cursor *models.PostCursor, count int) ([]models.PostCursor, error)
+}
+
+// RankedFeed identifies a hot or top feed: the whole site, or a single community or
+// project
+type RankedFeed struct {
+	Sort        string
+	Window      string
+	CommunityID *helpers.NullableUint
+	ProjectID   *helpers.NullableUint
+}
+
+func (a *APIEnv) InitialisePostRanker(ranker PostRanker) {
+	a.PostRanker = ranker
+}
+
+func NewRedisPostRanker(client *redis.Client, db *gorm.DB) *RedisPostRanker {
+	return &RedisPostRanker{
+		redisDB:   client,
+		DBHandler: &database.PostDB{DB: db},
+		Now:       time.Now,
+	}
+}
+
+// RedisPostRanker ranks posts in Redis sorted sets. Every feed has a hot ranking and a
+// top ranking per window. Posts are also kept in a set by creation time, so that posts
+// leaving the day and week windows can be removed from them. What each post is ranked
+// by is kept in a hash, so that a post can be scored again when one of its counts
+// changes without recounting it in the database.
+type RedisPostRanker struct {
+	redisDB   *redis.Client
+	DBHandler database.PostRankingDBHandler
+	Now       func() time.Time
+}
+
+// Counts that posts are scored by, which are fields of their ranking stats hash
+const (
+	rankingCountLikes    = "likes"
+	rankingCountComments = "comments"
+)
+
+// Fields of a post's ranking stats hash, in the order that updateRankingCount returns them
+var rankingStatsFields = []string{"created_at", "community_id", "project_id", rankingCountLikes, rankingCountComments}
+
+// Sets a count in a post's ranking stats only if its stats are stored, returning all of
+// its stats; posts whose stats are not stored need to be counted in the database
+var updateRankingCount = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return false
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+return redis.call("HMGET", KEYS[1], "created_at", "community_id", "project_id", "likes", "comments")
+`)
+
+// Scopes of the feeds that a post belongs to
+func postRankingScopes(stats *models.PostRankingStats) []string {
+	scopes := []string{"all", fmt.Sprintf("community:%d", stats.CommunityID)}
+	if stats.ProjectID != 0 {
+		scopes = append(scopes, fmt.Sprintf("project:%d", stats.ProjectID))
+	}
+	return scopes
+}
+
+// Scope of the feed; project feeds take precedence over community feeds, as they do for
+// the newest posts
+func (feed *RankedFeed) scope() string {
+	if !feed.ProjectID.IsNull() {
+		projectID, _ := feed.ProjectID.GetValue()
+		return fmt.Sprintf("project:%d", projectID)
+	}
+	if !feed.CommunityID.IsNull() {
+		communityID, _ := feed.CommunityID.GetValue()
+		return fmt.Sprintf("community:%d", communityID)
+	}
+	return "all"
+}
+
+func hotRankingKey(scope string) string {
+	return "posts:hot:" + scope
+}
+
+func topRankingKey(window string, scope string) string {
+	return "posts:top:" + window + ":" + scope
+}
+
+func createdRankingKey(scope string) string {
+	return "posts:created:" + scope
+}
+
+func rankingStatsKey(postID uint) string {
+	return fmt.Sprintf("posts:stats:%d", postID)
+}
+
+func rankingStatsValues(stats *models.PostRankingStats) map[string]interface{} {
+	return map[string]interface{}{
+		"created_at":         stats.CreatedAt.Unix(),
+		"community_id":       stats.CommunityID,
+		"project_id":         stats.ProjectID,
+		rankingCountLikes:    stats.LikeCount,
+		rankingCountComments: stats.CommentCount,
+	}
+}
+
+// Parses the ranking stats of a post returned by updateRankingCount
+func parseRankingStats(postID uint, vals []interface{}) (*models.PostRankingStats, error) {
+	if len(vals) != len(rankingStatsFields) {
+		return nil, fmt.Errorf("expected %d ranking stats, got %d", len(rankingStatsFields), len(vals))
+	}
+	nums := make([]int64, len(vals))
+	for i, val := range vals {
+		str, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("ranking stat %s is missing", rankingStatsFields[i])
+		}
+		num, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = num
+	}
+	return &models.PostRankingStats{
+		ID:           postID,
+		CreatedAt:    time.Unix(nums[0], 0),
+		CommunityID:  uint(nums[1]),
+		ProjectID:    uint(nums[2]),
+		LikeCount:    nums[3],
+		CommentCount: nums[4],
+	}, nil
+}
+
+func (feed *RankedFeed) key() string {
+	if feed.Sort == models.PostSortHot {
+		return hotRankingKey(feed.scope())
+	}
+	return topRankingKey(feed.Window, feed.scope())
+}
+
+// Members are zero-padded so that posts with the same score are ordered by ID
+func postRankingMember(postID uint) string {
+	return fmt.Sprintf("%020d", postID)
+}
+
+func (r *RedisPostRanker) RankPost(ctx context.Context, postID uint) error {
+	stats, err := r.DBHandler.GetPostRankingStats(postID)
+	// Posts that no longer exist at all are removed from the rankings when they are
+	// purged, with RemovePost
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.rank(ctx, stats)
+}
+
+// Removes a post that has been deleted from the database from the feeds that it was
+// ranked in, given what it was ranked by before it was deleted
+func (r *RedisPostRanker) RemovePost(ctx context.Context, stats *models.PostRankingStats) error {
+	removed := *stats
+	removed.Deleted = true
+	return r.rank(ctx, &removed)
+}
+
+// Posts whose ranking stats are not stored, or cannot be read, are ranked from the
+// database instead
+func (r *RedisPostRanker) UpdatePostCount(ctx context.Context, postID uint, count string, val uint64) error {
+	vals, err := updateRankingCount.Run(ctx, r.redisDB, []string{rankingStatsKey(postID)}, count, val).Slice()
+	if err == redis.Nil {
+		return r.RankPost(ctx, postID)
+	}
+	if err != nil {
+		return err
+	}
+	stats, err := parseRankingStats(postID, vals)
+	if err != nil {
+		return r.RankPost(ctx, postID)
+	}
+	return r.rank(ctx, stats)
+}
+
+// Scores the post in the feeds that it belongs to and stores its ranking stats, or
+// removes it from them if it has been deleted
+func (r *RedisPostRanker) rank(ctx context.Context, stats *models.PostRankingStats) error {
+	now := r.Now()
+	member := postRankingMember(stats.ID)
+	scopes := postRankingScopes(stats)
+	_, err := r.redisDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if stats.Deleted {
+			pipe.Del(ctx, rankingStatsKey(stats.ID))
+		} else {
+			pipe.HSet(ctx, rankingStatsKey(stats.ID), rankingStatsValues(stats))
+		}
+		for _, scope := range scopes {
+			if stats.Deleted {
+				pipe.ZRem(ctx, hotRankingKey(scope), member)
+				pipe.ZRem(ctx, createdRankingKey(scope), member)
+				for _, window := range models.RankingWindows {
+					pipe.ZRem(ctx, topRankingKey(window, scope), member)
+				}
+				continue
+			}
+			pipe.ZAdd(ctx, hotRankingKey(scope), redis.Z{Score: stats.HotScore(), Member: member})
+			for _, window := range models.RankingWindows {
+				if stats.IsWithinWindow(window, now) {
+					pipe.ZAdd(ctx, topRankingKey(window, scope), redis.Z{Score: stats.TopScore(), Member: member})
+				}
+			}
+			if stats.IsWithinWindow(models.RankingWindowWeek, now) {
+				pipe.ZAdd(ctx, createdRankingKey(scope), redis.Z{Score: float64(stats.CreatedAt.Unix()), Member: member})
+			}
+		}
+		return nil
+	})
+	if err != nil || stats.Deleted {
+		return err
+	}
+	return r.removeStalePosts(ctx, scopes, now)
+}
+
+// Removes the posts in the feeds that have left the day and week windows. Posts are only
+// kept in the set by creation time while they are in a window.
+func (r *RedisPostRanker) removeStalePosts(ctx context.Context, scopes []string, now time.Time) error {
+	staleByKey := map[string]*redis.StringSliceCmd{}
+	_, err := r.redisDB.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, scope := range scopes {
+			for window, duration := range models.RankingWindowDurations {
+				staleByKey[topRankingKey(window, scope)] = pipe.ZRangeByScore(ctx, createdRankingKey(scope), &redis.ZRangeBy{
+					Min: "-inf",
+					Max: "(" + strconv.FormatInt(now.Add(-duration).Unix(), 10),
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.redisDB.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, stale := range staleByKey {
+			if members := stale.Val(); len(members) > 0 {
+				pipe.ZRem(ctx, key, stringsToInterfaces(members)...)
+			}
+		}
+		for _, scope := range scopes {
+			pipe.ZRemRangeByScore(ctx, createdRankingKey(scope), "-inf",
+				"("+strconv.FormatInt(now.Add(-models.RankingWindowDurations[models.RankingWindowWeek]).Unix(), 10))
+		}
+		return nil
+	})
+	return err
+}
+
+// Returns up to ARGV[3] members ranked after the cursor, which has score ARGV[1] and
+// member ARGV[2], with their scores. Members with the same score are ordered by
+// descending member, so the first member after the cursor is found by a binary search
+// among the members tied with it instead of by stepping through them.
+var rankedAfterCursor = redis.NewScript(`
+local first = redis.call("ZCOUNT", KEYS[1], "(" .. ARGV[1], "+inf")
+local last = redis.call("ZCOUNT", KEYS[1], ARGV[1], "+inf")
+while first < last do
+	local mid = math.floor((first + last) / 2)
+	local member = redis.call("ZREVRANGE", KEYS[1], mid, mid)[1]
+	if member >= ARGV[2] then
+		first = mid + 1
+	else
+		last = mid
+	end
+end
+return redis.call("ZREVRANGE", KEYS[1], first, first + tonumber(ARGV[3]) - 1, "WITHSCORES")
+`)
+
+// Top feeds with a window are cleared of posts that have left it first, since the feed
+// may not have had a post ranked since then
+func (r *RedisPostRanker) GetRankedPosts(ctx context.Context, feed *RankedFeed, cursor *models.PostCursor, count int) ([]models.PostCursor, error) {
+	if _, windowed := models.RankingWindowDurations[feed.Window]; feed.Sort == models.PostSortTop && windowed {
+		if err := r.removeStalePosts(ctx, []string{feed.scope()}, r.Now()); err != nil {
+			return nil, err
+		}
+	}
+	var entries []redis.Z
+	var err error
+	if cursor == nil {
+		entries, err = r.redisDB.ZRevRangeWithScores(ctx, feed.key(), 0, int64(count-1)).Result()
+	} else {
+		var vals []interface{}
+		vals, err = rankedAfterCursor.Run(ctx, r.redisDB, []string{feed.key()},
+			strconv.FormatFloat(cursor.Score, 'g', -1, 64), postRankingMember(cursor.PostID), count).Slice()
+		entries = parseRankedEntries(vals)
+	}
+	if err != nil {
+		return nil, err
+	}
+	positions := []models.PostCursor{}
+	for _, entry := range entries {
+		member, _ := entry.Member.(string)
+		postID, err := strconv.ParseUint(member, 10, 32)
+		if err != nil {
+			continue
+		}
+		positions = append(positions, models.PostCursor{Score: entry.Score, PostID: uint(postID)})
+	}
+	return positions, nil
+}
+
+// Parses the alternating members and scores returned by rankedAfterCursor, skipping any
+// that cannot be parsed
+func parseRankedEntries(vals []interface{}) []redis.Z {
+	entries := []redis.Z{}
+	for i := 0; i+1 < len(vals); i += 2 {
+		member, _ := vals[i].(string)
+		scoreStr, _ := vals[i+1].(string)
+		score, err := strconv.ParseFloat(scoreStr, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, redis.Z{Score: score, Member: member})
+	}
+	return entries
+}
+
+func stringsToInterfaces(strs []string) []interface{} {
+	output := make([]interface{}, len(strs))
+	for i, str := range strs {
+		output[i] = str
+	}
+	return output
+}
+
+// Ranks the post, logging rather than returning any error
+func (a *APIEnv) rankPost(ctx context.Context, postID uint) {
+	if err := a.PostRanker.RankPost(ctx, postID); err != nil {
+		log.Printf("Unable to rank post %d: %v", postID, err)
+	}
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/models"
+)
+
+// Stats written by rank are read back by UpdatePostCount when a count changes
+func TestParseRankingStats(t *testing.T) {
+	stats := &models.PostRankingStats{
+		ID:           4,
+		CreatedAt:    time.Unix(1690000000, 0),
+		CommunityID:  2,
+		ProjectID:    3,
+		LikeCount:    5,
+		CommentCount: 6,
+	}
+	values := rankingStatsValues(stats)
+	vals := make([]interface{}, len(rankingStatsFields))
+	for i, field := range rankingStatsFields {
+		val, ok := values[field]
+		if !ok {
+			t.Fatalf("Ranking stat %s is not stored", field)
+		}
+		// Redis returns hash values as strings
+		vals[i] = fmt.Sprint(val)
+	}
+
+	parsed, err := parseRankingStats(stats.ID, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *parsed != *stats {
+		t.Errorf("parseRankingStats() = %+v, want %+v", parsed, stats)
+	}
+
+	// Stats with a missing field are not parsed, so that the post is counted instead
+	vals[3] = nil
+	if _, err := parseRankingStats(stats.ID, vals); err == nil {
+		t.Error("parseRankingStats() with a missing stat returned no error")
+	}
+}
+
+// Scores are returned by rankedAfterCursor as strings, alternating with their members
+func TestParseRankedEntries(t *testing.T) {
+	vals := []interface{}{
+		postRankingMember(7), "12.5",
+		postRankingMember(3), "not a score",
+		postRankingMember(2), "4",
+		postRankingMember(1),
+	}
+	want := []redis.Z{
+		{Score: 12.5, Member: postRankingMember(7)},
+		{Score: 4, Member: postRankingMember(2)},
+	}
+	if entries := parseRankedEntries(vals); !reflect.DeepEqual(entries, want) {
+		t.Errorf("parseRankedEntries() = %v, want %v", entries, want)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Contains controllers for recommended users, communities and projects.
+*/
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	RecommendationDismissedMsg = "Recommendation dismissed"
+)
+
+// Errors
+var (
+	ErrCannotDismissRecommendation   = errors.New("cannot dismiss recommendation")
+	ErrCannotRetrieveRecommendations = errors.New("cannot retrieve recommendations")
+	ErrRecommendationTargetNotFound  = errors.New("recommended user, community or project not found")
+)
+
+// Recommender is implemented by workers.Recommender
+type Recommender interface {
+	GetRecommendations(ctx context.Context, userID string) ([]models.Recommendation, error)
+	Dismiss(ctx context.Context, dismissal *models.RecommendationDismissal) error
+}
+
+func (a *APIEnv) InitialiseRecommendationHandler(recommender Recommender) {
+	a.Recommender = recommender
+	a.RecommendationDBHandler = &database.RecommendationDB{
+		DB: a.DB,
+	}
+}
+
+// Returns the users, communities and projects recommended to the user, best first.
+// Recommendations whose targets have since been deleted or blocked are left out.
+func (a *APIEnv) GetRecommendations(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	recommendations, err := a.Recommender.GetRecommendations(ctx.Request.Context(), userID)
+	// If unable to retrieve recommendations, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveRecommendations)
+		return
+	}
+
+	view, err := a.recommendationsView(userID, recommendations)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveRecommendations)
+		return
+	}
+	helpers.OutputData(ctx, view)
+}
+
+// Fills in the user, community or project of each recommendation, keeping the order
+// of the recommendations
+func (a *APIEnv) recommendationsView(userID string, recommendations []models.Recommendation) (*models.RecommendationsView, error) {
+	userIDs := []string{}
+	communityIDs := []uint{}
+	projectIDs := []uint{}
+	for _, recommendation := range recommendations {
+		switch recommendation.Kind {
+		case models.RecommendationUser:
+			userIDs = append(userIDs, recommendation.TargetID)
+		case models.RecommendationCommunity:
+			if id, err := strconv.ParseUint(recommendation.TargetID, 10, 0); err == nil {
+				communityIDs = append(communityIDs, uint(id))
+			}
+		case models.RecommendationProject:
+			if id, err := strconv.ParseUint(recommendation.TargetID, 10, 0); err == nil {
+				projectIDs = append(projectIDs, uint(id))
+			}
+		}
+	}
+
+	users, err := a.RecommendationDBHandler.GetRecommendedUsers(userID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	communities, err := a.RecommendationDBHandler.GetRecommendedCommunities(communityIDs)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := a.RecommendationDBHandler.GetRecommendedProjects(userID, projectIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	usersByID := map[string]*models.User{}
+	for i := range users {
+		usersByID[users[i].ID] = &users[i]
+	}
+	communitiesByID := map[string]*models.Community{}
+	for i := range communities {
+		communitiesByID[strconv.FormatUint(uint64(communities[i].ID), 10)] = &communities[i]
+	}
+	projectsByID := map[string]*models.Project{}
+	for i := range projects {
+		projectsByID[strconv.FormatUint(uint64(projects[i].ID), 10)] = &projects[i]
+	}
+
+	view := models.RecommendationsView{
+		Users:       []models.RecommendationView{},
+		Communities: []models.RecommendationView{},
+		Projects:    []models.RecommendationView{},
+	}
+	for _, recommendation := range recommendations {
+		recommendationView := models.RecommendationView{
+			Kind:     recommendation.Kind,
+			TargetID: recommendation.TargetID,
+			Reason:   recommendation.Reason,
+		}
+		switch recommendation.Kind {
+		case models.RecommendationUser:
+			if user, ok := usersByID[recommendation.TargetID]; ok {
+				// Users are identified to clients by their username
+				recommendationView.TargetID = user.Username
+				recommendationView.User = user.GetUserMinimal()
+				view.Users = append(view.Users, recommendationView)
+			}
+		case models.RecommendationCommunity:
+			if community, ok := communitiesByID[recommendation.TargetID]; ok {
+				recommendationView.Community = community
+				view.Communities = append(view.Communities, recommendationView)
+			}
+		case models.RecommendationProject:
+			if project, ok := projectsByID[recommendation.TargetID]; ok {
+				recommendationView.Project = project.GetProjectMinimal()
+				view.Projects = append(view.Projects, recommendationView)
+			}
+		}
+	}
+	return &view, nil
+}
+
+// Dismisses a recommendation so that it is no longer shown to the user, and is not
+// recommended again
+func (a *APIEnv) DismissRecommendation(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	var input models.RecommendationDismissalInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	// If kind is not valid, return status code 400 Bad Request
+	if !helpers.IsValidRecommendationKind(input.Kind) {
+		helpers.OutputError(ctx, http.StatusBadRequest, helpers.ErrInvalidRecommendationKind)
+		return
+	}
+
+	targetID := input.TargetID
+	if input.Kind == models.RecommendationUser {
+		// Recommended users are given by their username, but stored by their ID
+		id, err := a.RecommendationDBHandler.GetUserIDByUsername(input.TargetID)
+		// If user cannot be found in the database, return status code 404 Not Found
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helpers.OutputError(ctx, http.StatusNotFound, ErrRecommendationTargetNotFound)
+			return
+		}
+		if err != nil {
+			helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotDismissRecommendation)
+			return
+		}
+		targetID = id
+	} else if _, err := strconv.ParseUint(input.TargetID, 10, 0); err != nil {
+		// Ensure that community and project IDs are unsigned integers
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrRecommendationTargetNotFound)
+		return
+	}
+
+	err := a.Recommender.Dismiss(ctx.Request.Context(), &models.RecommendationDismissal{
+		UserID:   userID,
+		Kind:     input.Kind,
+		TargetID: targetID,
+	})
+	// If recommendation cannot be dismissed, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotDismissRecommendation)
+		return
+	}
+	helpers.OutputMessage(ctx, RecommendationDismissedMsg)
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+type RecommendationDBTestHandler struct {
+	GetUserIDByUsernameFunc       func(string) (string, error)
+	GetRecommendedUsersFunc       func(string, []string) ([]models.User, error)
+	GetRecommendedCommunitiesFunc func([]uint) ([]models.Community, error)
+	GetRecommendedProjectsFunc    func(string, []uint) ([]models.Project, error)
+}
+
+func (h *RecommendationDBTestHandler) GetRecommendationCandidates(userID string, kind string, limit int) ([]models.RecommendationCandidate, error) {
+	return nil, nil
+}
+
+func (h *RecommendationDBTestHandler) GetActiveUserIDs(after string, limit int) ([]string, error) {
+	return nil, nil
+}
+
+func (h *RecommendationDBTestHandler) DismissRecommendation(dismissal *models.RecommendationDismissal) error {
+	return nil
+}
+
+func (h *RecommendationDBTestHandler) GetUserIDByUsername(username string) (string, error) {
+	return h.GetUserIDByUsernameFunc(username)
+}
+
+func (h *RecommendationDBTestHandler) GetRecommendedUsers(viewerID string, userIDs []string) ([]models.User, error) {
+	return h.GetRecommendedUsersFunc(viewerID, userIDs)
+}
+
+func (h *RecommendationDBTestHandler) GetRecommendedCommunities(communityIDs []uint) ([]models.Community, error) {
+	return h.GetRecommendedCommunitiesFunc(communityIDs)
+}
+
+func (h *RecommendationDBTestHandler) GetRecommendedProjects(viewerID string, projectIDs []uint) ([]models.Project, error) {
+	return h.GetRecommendedProjectsFunc(viewerID, projectIDs)
+}
+
+type TestRecommender struct {
+	Recommendations []models.Recommendation
+	GetError        error
+	DismissError    error
+	Dismissed       []*models.RecommendationDismissal
+}
+
+func (r *TestRecommender) GetRecommendations(ctx context.Context, userID string) ([]models.Recommendation, error) {
+	return r.Recommendations, r.GetError
+}
+
+func (r *TestRecommender) Dismiss(ctx context.Context, dismissal *models.RecommendationDismissal) error {
+	r.Dismissed = append(r.Dismissed, dismissal)
+	return r.DismissError
+}
+
+func TestAPIEnv_GetRecommendations(t *testing.T) {
+	recommendations := []models.Recommendation{
+		{Kind: models.RecommendationUser, TargetID: diffUserID, Score: 6, Reason: models.ReasonSharedSkills},
+		// Deleted since it was recommended
+		{Kind: models.RecommendationUser, TargetID: "deleteduser", Score: 4, Reason: models.ReasonSharedLikes},
+		{Kind: models.RecommendationCommunity, TargetID: "2", Score: 5, Reason: models.ReasonSharedCommunities},
+		{Kind: models.RecommendationCommunity, TargetID: "1", Score: 3, Reason: models.ReasonPopular},
+		{Kind: models.RecommendationProject, TargetID: "3", Score: 3, Reason: models.ReasonSharedSkills},
+	}
+	tests := []struct {
+		name                string
+		recommenderError    error
+		dbError             error
+		expectedUsers       []string
+		expectedCommunities []string
+		expectedProjects    []string
+		expectedCode        int
+		expectedErr         error
+	}{
+		{"Get recommendations OK", nil, nil, []string{testUsername}, []string{"2", "1"}, []string{"3"}, http.StatusOK, nil},
+		{"Get recommendations cannot retrieve", ErrTest, nil, nil, nil, nil, http.StatusInternalServerError, ErrCannotRetrieveRecommendations},
+		{"Get recommendations cannot hydrate", nil, ErrTest, nil, nil, nil, http.StatusInternalServerError, ErrCannotRetrieveRecommendations},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &RecommendationDBTestHandler{
+				GetRecommendedUsersFunc: func(viewerID string, userIDs []string) ([]models.User, error) {
+					return []models.User{{ID: diffUserID, UserCredentials: models.UserCredentials{Username: testUsername}}}, tt.dbError
+				},
+				GetRecommendedCommunitiesFunc: func(communityIDs []uint) ([]models.Community, error) {
+					if !reflect.DeepEqual(communityIDs, []uint{2, 1}) {
+						t.Errorf("GetRecommendedCommunities received %v", communityIDs)
+					}
+					return []models.Community{{Model: gorm.Model{ID: 1}, Name: "one"}, {Model: gorm.Model{ID: 2}, Name: "two"}}, nil
+				},
+				GetRecommendedProjectsFunc: func(viewerID string, projectIDs []uint) ([]models.Project, error) {
+					return []models.Project{{ProjectMinimal: models.ProjectMinimal{ID: 3, Name: "three"}}}, nil
+				},
+			}
+			a := &APIEnv{
+				RecommendationDBHandler: dbTestHandler,
+				Recommender:             &TestRecommender{Recommendations: recommendations, GetError: tt.recommenderError},
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			c.Request, _ = http.NewRequest(http.MethodGet, "", nil)
+
+			a.GetRecommendations(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			for key, expected := range map[string][]string{
+				"Users":       tt.expectedUsers,
+				"Communities": tt.expectedCommunities,
+				"Projects":    tt.expectedProjects,
+			} {
+				targetIDs := []string{}
+				for _, view := range data[key].([]interface{}) {
+					targetIDs = append(targetIDs, view.(map[string]interface{})["TargetID"].(string))
+				}
+				if !reflect.DeepEqual(targetIDs, expected) {
+					t.Errorf("%s recommended %v, want %v", key, targetIDs, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIEnv_DismissRecommendation(t *testing.T) {
+	tests := []struct {
+		name              string
+		input             *models.RecommendationDismissalInput
+		lookupError       error
+		dismissError      error
+		expectedDismissal *models.RecommendationDismissal
+		expectedCode      int
+		expectedErr       error
+	}{
+		{"Dismiss user OK", &models.RecommendationDismissalInput{Kind: models.RecommendationUser, TargetID: testUsername},
+			nil, nil, &models.RecommendationDismissal{UserID: testUserID, Kind: models.RecommendationUser, TargetID: diffUserID},
+			http.StatusOK, nil},
+		{"Dismiss project OK", &models.RecommendationDismissalInput{Kind: models.RecommendationProject, TargetID: "3"},
+			nil, nil, &models.RecommendationDismissal{UserID: testUserID, Kind: models.RecommendationProject, TargetID: "3"},
+			http.StatusOK, nil},
+		{"Dismiss bad request", nil, nil, nil, nil, http.StatusBadRequest, ErrBadBinding},
+		{"Dismiss invalid kind", &models.RecommendationDismissalInput{Kind: "post", TargetID: "1"},
+			nil, nil, nil, http.StatusBadRequest, helpers.ErrInvalidRecommendationKind},
+		{"Dismiss invalid community ID", &models.RecommendationDismissalInput{Kind: models.RecommendationCommunity, TargetID: "abc"},
+			nil, nil, nil, http.StatusBadRequest, ErrRecommendationTargetNotFound},
+		{"Dismiss user not found", &models.RecommendationDismissalInput{Kind: models.RecommendationUser, TargetID: "nobody"},
+			gorm.ErrRecordNotFound, nil, nil, http.StatusNotFound, ErrRecommendationTargetNotFound},
+		{"Dismiss cannot dismiss", &models.RecommendationDismissalInput{Kind: models.RecommendationCommunity, TargetID: "1"},
+			nil, ErrTest, &models.RecommendationDismissal{UserID: testUserID, Kind: models.RecommendationCommunity, TargetID: "1"},
+			http.StatusInternalServerError, ErrCannotDismissRecommendation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recommender := &TestRecommender{DismissError: tt.dismissError}
+			a := &APIEnv{
+				RecommendationDBHandler: &RecommendationDBTestHandler{
+					GetUserIDByUsernameFunc: func(username string) (string, error) {
+						return diffUserID, tt.lookupError
+					},
+				},
+				Recommender: recommender,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			if tt.input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			a.DismissRecommendation(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			var dismissal *models.RecommendationDismissal
+			if len(recommender.Dismissed) == 1 {
+				dismissal = recommender.Dismissed[0]
+			}
+			if !reflect.DeepEqual(dismissal, tt.expectedDismissal) {
+				t.Errorf("Dismissed %+v, want %+v", dismissal, tt.expectedDismissal)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedMessage, Message: RecommendationDismissedMsg}
+			if tt.expectedErr != nil {
+				expected = helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+/*
+Contains controllers for open roles on projects and applications to them.
+*/
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	OpenRoleDeletedMsg = "Role successfully deleted"
+)
+
+// Errors
+var (
+	ErrAlreadyApplied             = errors.New("you have already applied to this role")
+	ErrAlreadyMember              = errors.New("you are already a member of this project")
+	ErrApplicationNotFound        = errors.New("application not found")
+	ErrApplicationReviewed        = errors.New("application has already been reviewed")
+	ErrCannotApplyToOwnProject    = errors.New("you cannot apply to roles on your own project")
+	ErrCannotApplyToRole          = errors.New("cannot apply to role")
+	ErrCannotCreateOpenRole       = errors.New("cannot create role")
+	ErrCannotDeleteOpenRole       = errors.New("cannot delete role")
+	ErrCannotRetrieveApplications = errors.New("cannot retrieve applications")
+	ErrCannotRetrieveOpenRoles    = errors.New("cannot retrieve roles")
+	ErrCannotReviewApplication    = errors.New("cannot review application")
+	ErrCannotUpdateOpenRole       = errors.New("cannot update role")
+	ErrOpenRoleNotFound           = errors.New("role not found")
+	ErrRoleNotOpen                = errors.New("role is no longer accepting applications")
+)
+
+func (a *APIEnv) InitialiseRecruitmentHandler() {
+	a.RecruitmentDBHandler = &database.RecruitmentDB{
+		DB: a.DB,
+	}
+}
+
+// Returns the roles posted on the project, open roles first
+func (a *APIEnv) GetOpenRoles(ctx *gin.Context) {
+	// Ensure that projectID is an unsigned integer
+	projectID, err := helpers.GetProjectIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrProjectNotFound)
+		return
+	}
+
+	roles, err := a.RecruitmentDBHandler.GetOpenRoles(projectID)
+	// If project cannot be found in the database, return status code 404 Status Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrProjectNotFound)
+		return
+	}
+	// If roles cannot be retrieved for any other reason, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveOpenRoles)
+		return
+	}
+
+	views := []models.OpenRoleView{}
+	for _, role := range roles {
+		views = append(views, *role.OpenRoleView())
+	}
+	helpers.OutputData(ctx, views)
+}
+
+// Posts an open role on the project, which users can then apply to
+func (a *APIEnv) CreateOpenRole(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that projectID is an unsigned integer
+	projectID, err := helpers.GetProjectIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrProjectNotFound)
+		return
+	}
+
+	var input models.OpenRoleInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	// If the role is missing details or has invalid ones, return status code 400 Bad Request
+	if err := helpers.ValidateOpenRole(&input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	role, err := a.RecruitmentDBHandler.CreateOpenRole(projectID, userID, &input)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrProjectNotFound)
+		return
+	case errors.Is(err, helpers.ErrNotOwner):
+		// If user is not the owner of the project, return status code 403 Forbidden
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.ErrNotOwner)
+		return
+	case err != nil:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotCreateOpenRole)
+		return
+	}
+	helpers.OutputData(ctx, role.OpenRoleView())
+}
+
+// Replaces the details and skills of the role. The owner fills or closes a role by
+// setting its status.
+func (a *APIEnv) UpdateOpenRole(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that projectID and roleID are unsigned integers
+	projectID, err := helpers.GetProjectIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrProjectNotFound)
+		return
+	}
+	roleID, err := helpers.GetOpenRoleIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrOpenRoleNotFound)
+		return
+	}
+
+	var input models.OpenRoleInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	// If the role is missing details or has invalid ones, return status code 400 Bad Request
+	if err := helpers.ValidateOpenRole(&input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	role, err := a.RecruitmentDBHandler.UpdateOpenRole(projectID, roleID, userID, &input)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrOpenRoleNotFound)
+		return
+	case errors.Is(err, helpers.ErrNotOwner):
+		// If user is not the owner of the project, return status code 403 Forbidden
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.ErrNotOwner)
+		return
+	case err != nil:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateOpenRole)
+		return
+	}
+	helpers.OutputData(ctx, role.OpenRoleView())
+}
+
+// Deletes the role and its applications
+func (a *APIEnv) DeleteOpenRole(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that projectID and roleID are unsigned integers
+	projectID, err := helpers.GetProjectIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrProjectNotFound)
+		return
+	}
+	roleID, err := helpers.GetOpenRoleIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrOpenRoleNotFound)
+		return
+	}
+
+	err = a.RecruitmentDBHandler.DeleteOpenRole(projectID, roleID, userID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrOpenRoleNotFound)
+		return
+	case errors.Is(err, helpers.ErrNotOwner):
+		// If user is not the owner of the project, return status code 403 Forbidden
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.ErrNotOwner)
+		return
+	case err != nil:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotDeleteOpenRole)
+		return
+	}
+	helpers.OutputMessage(ctx, OpenRoleDeletedMsg)
+}
+
+// Applies to an open role on the project, and notifies the project owner
+func (a *APIEnv) ApplyToRole(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that projectID and roleID are unsigned integers
+	projectID, err := helpers.GetProjectIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrProjectNotFound)
+		return
+	}
+	roleID, err := helpers.GetOpenRoleIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrOpenRoleNotFound)
+		return
+	}
+
+	var input models.RoleApplicationInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	if len([]rune(input.Message)) > helpers.MaxApplicationMessageLength {
+		helpers.OutputError(ctx, http.StatusBadRequest, helpers.ErrApplicationTooLong)
+		return
+	}
+
+	application, err := a.RecruitmentDBHandler.ApplyToRole(projectID, roleID, userID, input.Message)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrOpenRoleNotFound)
+		return
+	case errors.Is(err, database.ErrRoleNotOpen):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrRoleNotOpen)
+		return
+	case errors.Is(err, database.ErrApplyingToOwnProject):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCannotApplyToOwnProject)
+		return
+	case errors.Is(err, database.ErrAlreadyMember):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrAlreadyMember)
+		return
+	case errors.Is(err, database.ErrAlreadyApplied):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrAlreadyApplied)
+		return
+	case errors.Is(err, database.ErrBlocked):
+		// If the users have blocked each other, return status code 403 Forbidden
+		helpers.OutputError(ctx, http.StatusForbidden, ErrBlocked)
+		return
+	case err != nil:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotApplyToRole)
+		return
+	}
+
+	notif := helpers.GenerateApplicationNotification(application)
+	// Even if there is an error in creating the notification server-side,
+	// this should not throw an error client-side
+	a.NotificationPoster.PostNotificationFromEvent(ctx, notif)
+
+	helpers.OutputData(ctx, application.RoleApplicationView())
+}
+
+// Returns the applications to roles on the project for its owner to review, optionally
+// filtered by status
+func (a *APIEnv) GetProjectApplications(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that projectID is an unsigned integer
+	projectID, err := helpers.GetProjectIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrProjectNotFound)
+		return
+	}
+	status := ctx.Query(helpers.ApplicationStatusKey)
+	if status != "" && !helpers.IsValidApplicationStatus(status) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	applications, err := a.RecruitmentDBHandler.GetProjectApplications(projectID, userID, status)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrProjectNotFound)
+		return
+	case errors.Is(err, helpers.ErrNotOwner):
+		// Only the owner of the project may see its applications
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.ErrNotOwner)
+		return
+	case err != nil:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveApplications)
+		return
+	}
+	outputApplications(ctx, applications)
+}
+
+// Returns the applications that the user has made
+func (a *APIEnv) GetUserApplications(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	applications, err := a.RecruitmentDBHandler.GetUserApplications(userID)
+	// If applications cannot be retrieved, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveApplications)
+		return
+	}
+	outputApplications(ctx, applications)
+}
+
+// Accepts or rejects an application to a role on the project, and notifies the
+// applicant. Accepted applicants become members of the project.
+func (a *APIEnv) ReviewApplication(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that projectID and applicationID are unsigned integers
+	projectID, err := helpers.GetProjectIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrProjectNotFound)
+		return
+	}
+	applicationID, err := helpers.GetApplicationIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrApplicationNotFound)
+		return
+	}
+
+	var input models.ApplicationReviewInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	if !helpers.IsValidApplicationReview(input.Status) {
+		helpers.OutputError(ctx, http.StatusBadRequest, helpers.ErrInvalidApplicationStatus)
+		return
+	}
+
+	application, err := a.RecruitmentDBHandler.ReviewApplication(projectID, applicationID, userID, input.Status)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrApplicationNotFound)
+		return
+	case errors.Is(err, helpers.ErrNotOwner):
+		// If user is not the owner of the project, return status code 403 Forbidden
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.ErrNotOwner)
+		return
+	case errors.Is(err, database.ErrApplicationReviewed):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrApplicationReviewed)
+		return
+	case err != nil:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotReviewApplication)
+		return
+	}
+
+	notif := helpers.GenerateApplicationReviewNotification(application)
+	// Even if there is an error in creating the notification server-side,
+	// this should not throw an error client-side
+	a.NotificationPoster.PostNotificationFromEvent(ctx, notif)
+
+	helpers.OutputData(ctx, application.RoleApplicationView())
+}
+
+func outputApplications(ctx *gin.Context, applications []models.RoleApplication) {
+	views := []models.RoleApplicationView{}
+	for _, application := range applications {
+		views = append(views, *application.RoleApplicationView())
+	}
+	helpers.OutputData(ctx, views)
+}
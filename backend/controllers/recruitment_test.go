@@ -0,0 +1,421 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+const (
+	testOpenRoleID    = 4
+	testApplicationID = 5
+)
+
+type RecruitmentDBTestHandler struct {
+	GetOpenRolesFunc           func(uint) ([]models.OpenRole, error)
+	CreateOpenRoleFunc         func(uint, string, *models.OpenRoleInput) (*models.OpenRole, error)
+	UpdateOpenRoleFunc         func(uint, uint, string, *models.OpenRoleInput) (*models.OpenRole, error)
+	DeleteOpenRoleFunc         func(uint, uint, string) error
+	ApplyToRoleFunc            func(uint, uint, string, string) (*models.RoleApplication, error)
+	GetProjectApplicationsFunc func(uint, string, string) ([]models.RoleApplication, error)
+	GetUserApplicationsFunc    func(string) ([]models.RoleApplication, error)
+	ReviewApplicationFunc      func(uint, uint, string, string) (*models.RoleApplication, error)
+}
+
+func (h *RecruitmentDBTestHandler) GetOpenRoles(projectID uint) ([]models.OpenRole, error) {
+	return h.GetOpenRolesFunc(projectID)
+}
+
+func (h *RecruitmentDBTestHandler) CreateOpenRole(projectID uint, userID string, input *models.OpenRoleInput) (*models.OpenRole, error) {
+	return h.CreateOpenRoleFunc(projectID, userID, input)
+}
+
+func (h *RecruitmentDBTestHandler) UpdateOpenRole(projectID uint, roleID uint, userID string, input *models.OpenRoleInput) (*models.OpenRole, error) {
+	return h.UpdateOpenRoleFunc(projectID, roleID, userID, input)
+}
+
+func (h *RecruitmentDBTestHandler) DeleteOpenRole(projectID uint, roleID uint, userID string) error {
+	return h.DeleteOpenRoleFunc(projectID, roleID, userID)
+}
+
+func (h *RecruitmentDBTestHandler) ApplyToRole(projectID uint, roleID uint, applicantID string, message string) (*models.RoleApplication, error) {
+	return h.ApplyToRoleFunc(projectID, roleID, applicantID, message)
+}
+
+func (h *RecruitmentDBTestHandler) GetProjectApplications(projectID uint, userID string, status string) ([]models.RoleApplication, error) {
+	return h.GetProjectApplicationsFunc(projectID, userID, status)
+}
+
+func (h *RecruitmentDBTestHandler) GetUserApplications(userID string) ([]models.RoleApplication, error) {
+	return h.GetUserApplicationsFunc(userID)
+}
+
+func (h *RecruitmentDBTestHandler) ReviewApplication(projectID uint, applicationID uint, userID string, status string) (*models.RoleApplication, error) {
+	return h.ReviewApplicationFunc(projectID, applicationID, userID, status)
+}
+
+// Returns an application by diffUserID to a role on a project owned by testUserID
+func testRoleApplication(status string) *models.RoleApplication {
+	return &models.RoleApplication{
+		ID:          testApplicationID,
+		OpenRoleID:  testOpenRoleID,
+		ApplicantID: diffUserID,
+		Applicant:   models.User{ID: diffUserID, UserCredentials: models.UserCredentials{Username: "applicant"}},
+		Status:      status,
+		OpenRole: models.OpenRole{
+			ID:        testOpenRoleID,
+			ProjectID: testProjectID,
+			Title:     "Backend developer",
+			Project:   models.Project{ProjectMinimal: models.ProjectMinimal{ID: testProjectID, Name: "SkillNet"}, OwnerID: testUserID},
+		},
+	}
+}
+
+func TestAPIEnv_CreateOpenRole(t *testing.T) {
+	validInput := models.OpenRoleInput{
+		Title:      " Backend developer ",
+		Commitment: models.CommitmentPartTime,
+		Skills:     []string{"Go", " go ", "PostgreSQL"},
+	}
+	tests := []struct {
+		name          string
+		projectID     interface{}
+		input         *models.OpenRoleInput
+		dbError       error
+		expectedInput *models.OpenRoleInput
+		expectedCode  int
+		expectedErr   error
+	}{
+		{"Create role OK", testProjectID, &validInput, nil, &models.OpenRoleInput{
+			Title: "Backend developer", Commitment: models.CommitmentPartTime, Skills: []string{"Go", "PostgreSQL"},
+		}, http.StatusOK, nil},
+		{"Create role invalid project ID", "abc", &validInput, nil, nil, http.StatusBadRequest, ErrProjectNotFound},
+		{"Create role bad request", testProjectID, nil, nil, nil, http.StatusBadRequest, ErrBadBinding},
+		{"Create role no title", testProjectID, &models.OpenRoleInput{Title: " ", Commitment: models.CommitmentCasual},
+			nil, nil, http.StatusBadRequest, helpers.ErrNoOpenRoleTitle},
+		{"Create role title too long", testProjectID, &models.OpenRoleInput{
+			Title: strings.Repeat("a", helpers.MaxOpenRoleTitleLength+1), Commitment: models.CommitmentCasual,
+		}, nil, nil, http.StatusBadRequest, helpers.ErrOpenRoleTitleTooLong},
+		{"Create role invalid commitment", testProjectID, &models.OpenRoleInput{Title: "Designer", Commitment: "weekends"},
+			nil, nil, http.StatusBadRequest, helpers.ErrInvalidCommitment},
+		{"Create role invalid status", testProjectID, &models.OpenRoleInput{Title: "Designer", Commitment: models.CommitmentCasual, Status: "paused"},
+			nil, nil, http.StatusBadRequest, helpers.ErrInvalidOpenRoleStatus},
+		{"Create role project not found", testProjectID, &models.OpenRoleInput{Title: "Designer", Commitment: models.CommitmentCasual},
+			gorm.ErrRecordNotFound, &models.OpenRoleInput{Title: "Designer", Commitment: models.CommitmentCasual, Skills: []string{}},
+			http.StatusNotFound, ErrProjectNotFound},
+		{"Create role not owner", testProjectID, &models.OpenRoleInput{Title: "Designer", Commitment: models.CommitmentCasual},
+			helpers.ErrNotOwner, &models.OpenRoleInput{Title: "Designer", Commitment: models.CommitmentCasual, Skills: []string{}},
+			http.StatusForbidden, helpers.ErrNotOwner},
+		{"Create role cannot create", testProjectID, &models.OpenRoleInput{Title: "Designer", Commitment: models.CommitmentCasual},
+			ErrTest, &models.OpenRoleInput{Title: "Designer", Commitment: models.CommitmentCasual, Skills: []string{}},
+			http.StatusInternalServerError, ErrCannotCreateOpenRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &RecruitmentDBTestHandler{}
+			a := &APIEnv{
+				RecruitmentDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.ProjectIDKey, tt.projectID)
+			if tt.input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			var receivedInput *models.OpenRoleInput
+			dbTestHandler.CreateOpenRoleFunc = func(projectID uint, userID string, input *models.OpenRoleInput) (*models.OpenRole, error) {
+				receivedInput = input
+				if tt.dbError != nil {
+					return nil, tt.dbError
+				}
+				return &models.OpenRole{
+					ID:         testOpenRoleID,
+					ProjectID:  projectID,
+					Title:      input.Title,
+					Commitment: input.Commitment,
+					Status:     models.OpenRoleOpen,
+				}, nil
+			}
+			a.CreateOpenRole(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if !reflect.DeepEqual(receivedInput, tt.expectedInput) {
+				t.Errorf("CreateOpenRole received %+v, want %+v", receivedInput, tt.expectedInput)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			if data["Status"] != models.OpenRoleOpen || data["Title"] != "Backend developer" {
+				t.Errorf("Unexpected role %v", data)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_ApplyToRole(t *testing.T) {
+	tests := []struct {
+		name         string
+		roleID       interface{}
+		input        *models.RoleApplicationInput
+		dbError      error
+		expectedCode int
+		expectedErr  error
+		expectNotify bool
+	}{
+		{"Apply OK", testOpenRoleID, &models.RoleApplicationInput{Message: "I have built APIs in Go"}, nil, http.StatusOK, nil, true},
+		{"Apply invalid role ID", "abc", &models.RoleApplicationInput{}, nil, http.StatusBadRequest, ErrOpenRoleNotFound, false},
+		{"Apply bad request", testOpenRoleID, nil, nil, http.StatusBadRequest, ErrBadBinding, false},
+		{"Apply message too long", testOpenRoleID, &models.RoleApplicationInput{Message: strings.Repeat("a", helpers.MaxApplicationMessageLength+1)},
+			nil, http.StatusBadRequest, helpers.ErrApplicationTooLong, false},
+		{"Apply role not found", testOpenRoleID, &models.RoleApplicationInput{}, gorm.ErrRecordNotFound, http.StatusNotFound, ErrOpenRoleNotFound, false},
+		{"Apply role not open", testOpenRoleID, &models.RoleApplicationInput{}, database.ErrRoleNotOpen, http.StatusBadRequest, ErrRoleNotOpen, false},
+		{"Apply own project", testOpenRoleID, &models.RoleApplicationInput{}, database.ErrApplyingToOwnProject,
+			http.StatusBadRequest, ErrCannotApplyToOwnProject, false},
+		{"Apply already member", testOpenRoleID, &models.RoleApplicationInput{}, database.ErrAlreadyMember, http.StatusBadRequest, ErrAlreadyMember, false},
+		{"Apply twice", testOpenRoleID, &models.RoleApplicationInput{}, database.ErrAlreadyApplied, http.StatusBadRequest, ErrAlreadyApplied, false},
+		{"Apply blocked", testOpenRoleID, &models.RoleApplicationInput{}, database.ErrBlocked, http.StatusForbidden, ErrBlocked, false},
+		{"Apply cannot apply", testOpenRoleID, &models.RoleApplicationInput{}, ErrTest, http.StatusInternalServerError, ErrCannotApplyToRole, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &RecruitmentDBTestHandler{}
+			notifPoster := &helpers.TestNotificationCreator{}
+			a := &APIEnv{
+				RecruitmentDBHandler: dbTestHandler,
+				NotificationPoster:   notifPoster,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, diffUserID)
+			helpers.AddParamsToContext(c, helpers.ProjectIDKey, testProjectID)
+			helpers.AddParamsToContext(c, helpers.OpenRoleIDKey, tt.roleID)
+			if tt.input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			dbTestHandler.ApplyToRoleFunc = func(projectID uint, roleID uint, applicantID string, message string) (*models.RoleApplication, error) {
+				if tt.dbError != nil {
+					return nil, tt.dbError
+				}
+				application := testRoleApplication(models.ApplicationPending)
+				application.Message = message
+				return application, nil
+			}
+			var notified *models.Notification
+			notifPoster.PostNotificationFromEventFunc = func(ctx *gin.Context, notif *models.Notification) error {
+				notified = notif
+				return nil
+			}
+			a.ApplyToRole(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if (notified != nil) != tt.expectNotify {
+				t.Errorf("Notification sent = %v, want %v", notified != nil, tt.expectNotify)
+			}
+			// The project owner is notified of the application
+			if notified != nil && (notified.ReceiverId != testUserID || notified.SenderId != diffUserID) {
+				t.Errorf("Notification %+v sent to the wrong user", notified)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			if data["Message"] != tt.input.Message || data["RoleTitle"] != "Backend developer" {
+				t.Errorf("Unexpected application %v", data)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_ReviewApplication(t *testing.T) {
+	tests := []struct {
+		name          string
+		applicationID interface{}
+		input         *models.ApplicationReviewInput
+		dbError       error
+		expectedCode  int
+		expectedErr   error
+		expectNotify  bool
+	}{
+		{"Accept application OK", testApplicationID, &models.ApplicationReviewInput{Status: models.ApplicationAccepted},
+			nil, http.StatusOK, nil, true},
+		{"Reject application OK", testApplicationID, &models.ApplicationReviewInput{Status: models.ApplicationRejected},
+			nil, http.StatusOK, nil, true},
+		{"Review application invalid ID", "abc", &models.ApplicationReviewInput{Status: models.ApplicationAccepted},
+			nil, http.StatusBadRequest, ErrApplicationNotFound, false},
+		{"Review application bad request", testApplicationID, nil, nil, http.StatusBadRequest, ErrBadBinding, false},
+		{"Review application as pending", testApplicationID, &models.ApplicationReviewInput{Status: models.ApplicationPending},
+			nil, http.StatusBadRequest, helpers.ErrInvalidApplicationStatus, false},
+		{"Review application not found", testApplicationID, &models.ApplicationReviewInput{Status: models.ApplicationAccepted},
+			gorm.ErrRecordNotFound, http.StatusNotFound, ErrApplicationNotFound, false},
+		{"Review application not owner", testApplicationID, &models.ApplicationReviewInput{Status: models.ApplicationAccepted},
+			helpers.ErrNotOwner, http.StatusForbidden, helpers.ErrNotOwner, false},
+		{"Review application twice", testApplicationID, &models.ApplicationReviewInput{Status: models.ApplicationRejected},
+			database.ErrApplicationReviewed, http.StatusBadRequest, ErrApplicationReviewed, false},
+		{"Review application cannot review", testApplicationID, &models.ApplicationReviewInput{Status: models.ApplicationAccepted},
+			ErrTest, http.StatusInternalServerError, ErrCannotReviewApplication, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &RecruitmentDBTestHandler{}
+			notifPoster := &helpers.TestNotificationCreator{}
+			a := &APIEnv{
+				RecruitmentDBHandler: dbTestHandler,
+				NotificationPoster:   notifPoster,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.ProjectIDKey, testProjectID)
+			helpers.AddParamsToContext(c, helpers.ApplicationIDKey, tt.applicationID)
+			if tt.input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			dbTestHandler.ReviewApplicationFunc = func(projectID uint, applicationID uint, userID string, status string) (*models.RoleApplication, error) {
+				if tt.dbError != nil {
+					return nil, tt.dbError
+				}
+				return testRoleApplication(status), nil
+			}
+			var notified *models.Notification
+			notifPoster.PostNotificationFromEventFunc = func(ctx *gin.Context, notif *models.Notification) error {
+				notified = notif
+				return nil
+			}
+			a.ReviewApplication(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if (notified != nil) != tt.expectNotify {
+				t.Errorf("Notification sent = %v, want %v", notified != nil, tt.expectNotify)
+			}
+			// The applicant is notified of the decision
+			if notified != nil && (notified.ReceiverId != diffUserID || notified.SenderId != testUserID) {
+				t.Errorf("Notification %+v sent to the wrong user", notified)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			if status := m["data"].(map[string]interface{})["Status"]; status != tt.input.Status {
+				t.Errorf("Application status %v, want %s", status, tt.input.Status)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetProjectApplications(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         string
+		dbOutput       []models.RoleApplication
+		dbError        error
+		expectedStatus string
+		expectedCode   int
+		expectedErr    error
+	}{
+		{"Get applications OK", "", []models.RoleApplication{*testRoleApplication(models.ApplicationPending)}, nil, "", http.StatusOK, nil},
+		{"Get pending applications OK", models.ApplicationPending, []models.RoleApplication{}, nil, models.ApplicationPending, http.StatusOK, nil},
+		{"Get applications invalid status", "withdrawn", nil, nil, "", http.StatusBadRequest, ErrBadBinding},
+		{"Get applications not owner", "", nil, helpers.ErrNotOwner, "", http.StatusForbidden, helpers.ErrNotOwner},
+		{"Get applications project not found", "", nil, gorm.ErrRecordNotFound, "", http.StatusNotFound, ErrProjectNotFound},
+		{"Get applications cannot retrieve", "", nil, ErrTest, "", http.StatusInternalServerError, ErrCannotRetrieveApplications},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &RecruitmentDBTestHandler{}
+			a := &APIEnv{
+				RecruitmentDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.ProjectIDKey, testProjectID)
+			req, _ := http.NewRequest(http.MethodGet, "", nil)
+			if tt.status != "" {
+				helpers.AddParamsToQuery(req, helpers.ApplicationStatusKey, tt.status)
+			}
+			c.Request = req
+
+			var receivedStatus string
+			dbTestHandler.GetProjectApplicationsFunc = func(projectID uint, userID string, status string) ([]models.RoleApplication, error) {
+				receivedStatus = status
+				return tt.dbOutput, tt.dbError
+			}
+			a.GetProjectApplications(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if receivedStatus != tt.expectedStatus {
+				t.Errorf("GetProjectApplications received status %q, want %q", receivedStatus, tt.expectedStatus)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			if applications, ok := m["data"].([]interface{}); !ok || len(applications) != len(tt.dbOutput) {
+				t.Errorf("Expected %d applications, got %v", len(tt.dbOutput), m["data"])
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Contains controllers for reporting content and moderating reports.
+*/
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Errors
+var (
+	ErrAlreadyReported      = errors.New("you have already reported this")
+	ErrBadReportAction      = errors.New("invalid moderation action")
+	ErrBadReportReason      = errors.New("invalid report reason")
+	ErrBadReportStatus      = errors.New("invalid report status")
+	ErrBadReportTarget      = errors.New("invalid report target")
+	ErrCannotCreateReport   = errors.New("cannot submit report")
+	ErrCannotGetReports     = errors.New("cannot retrieve reports")
+	ErrCannotRemoveTarget   = errors.New("only posts, comments and projects can be removed")
+	ErrCannotReportSelf     = errors.New("you cannot report yourself or your own content")
+	ErrCannotResolveReport  = errors.New("cannot resolve report")
+	ErrReportNotFound       = errors.New("report not found")
+	ErrReportResolved       = errors.New("report has already been resolved")
+	ErrReportTargetNotFound = errors.New("reported content not found")
+)
+
+func (a *APIEnv) InitialiseReportHandler() {
+	a.ReportDBHandler = &database.ReportDB{
+		DB: a.DB,
+	}
+}
+
+// Reports a post, comment, project, community or user to the moderators
+func (a *APIEnv) PostReport(ctx *gin.Context) {
+	var input models.ReportInput
+
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	input.TargetID = strings.TrimSpace(input.TargetID)
+	input.Note = strings.TrimSpace(input.Note)
+	if !helpers.IsValidReportTarget(input.TargetType) || !helpers.IsValidReportTargetID(input.TargetType, input.TargetID) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadReportTarget)
+		return
+	}
+	if !helpers.IsValidReportReason(input.Reason) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadReportReason)
+		return
+	}
+
+	userID := helpers.GetUserIDFromContext(ctx)
+	report, err := a.ReportDBHandler.CreateReport(input.Report(userID))
+	// If the reported content does not exist, return status code 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrReportTargetNotFound)
+		return
+	}
+	if errors.Is(err, database.ErrReportingOneself) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCannotReportSelf)
+		return
+	}
+	// If the user has an open report on the same content, return status code 409 Conflict
+	if errors.Is(err, database.ErrAlreadyReported) {
+		helpers.OutputError(ctx, http.StatusConflict, ErrAlreadyReported)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotCreateReport)
+		return
+	}
+	helpers.OutputData(ctx, report)
+}
+
+// Retrieves the moderation queue. Site moderators see every report, while community
+// owners see the reports in their communities. Open reports are returned by default.
+func (a *APIEnv) GetReports(ctx *gin.Context) {
+	status := ctx.DefaultQuery(helpers.ReportStatusKey, models.ReportOpen)
+	if !helpers.IsValidReportStatus(status) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadReportStatus)
+		return
+	}
+	// Ensure that cutoff is an unsigned integer or empty
+	cutoff, err := helpers.GetCutoffFromQuery(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	userID := helpers.GetUserIDFromContext(ctx)
+	siteModerator := helpers.UserHasRole(ctx, models.RoleModerator)
+	reports, err := a.ReportDBHandler.GetReports(status, userID, siteModerator, cutoff)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotGetReports)
+		return
+	}
+	var smallestID uint = 0
+	if len(reports) > 0 {
+		smallestID = reports[len(reports)-1].ID
+	}
+	helpers.OutputData(ctx, models.ReportArray{
+		Reports:     reports,
+		NextPageURL: helpers.GenerateReportsNextPageURL(models.BackendAddress, smallestID, status),
+	})
+}
+
+// Resolves a report by removing the content, warning or suspending its owner, or
+// dismissing the report. Reporters are notified of the outcome.
+func (a *APIEnv) ResolveReport(ctx *gin.Context) {
+	// Ensure that reportID is an unsigned integer
+	reportID, err := helpers.GetReportIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrReportNotFound)
+		return
+	}
+
+	var input models.ReportResolutionInput
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	if !helpers.IsValidReportAction(input.Action) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadReportAction)
+		return
+	}
+	now := time.Now()
+	if input.SuspendUntil.Valid && !input.SuspendUntil.Time.After(now) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadSuspensionEnd)
+		return
+	}
+
+	resolution := &models.ReportResolution{
+		Action:       input.Action,
+		Note:         strings.TrimSpace(input.Note),
+		ModeratorID:  helpers.GetUserIDFromContext(ctx),
+		SuspendUntil: input.SuspendUntil,
+		ResolvedAt:   now,
+	}
+	siteModerator := helpers.UserHasRole(ctx, models.RoleModerator)
+	result, err := a.ReportDBHandler.ResolveReport(reportID, resolution, siteModerator)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrReportNotFound)
+		return
+	}
+	if errors.Is(err, database.ErrReportResolved) {
+		helpers.OutputError(ctx, http.StatusConflict, ErrReportResolved)
+		return
+	}
+	// Community owners can only resolve reports in their communities, and cannot suspend
+	// users; return status code 403 Forbidden
+	if errors.Is(err, helpers.ErrNotOwner) {
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.ErrNotOwner)
+		return
+	}
+	if errors.Is(err, database.ErrCannotSuspendAdmin) {
+		helpers.OutputError(ctx, http.StatusForbidden, ErrCannotSuspendAdmin)
+		return
+	}
+	if errors.Is(err, database.ErrCannotRemove) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCannotRemoveTarget)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotResolveReport)
+		return
+	}
+
+	// The decision has been made at this point, so failing to update the comment count or
+	// ranking, or to notify users, does not fail the request
+	if result.RemovedPostID.Valid {
+		// Removed posts are dropped from the hot and top feeds and from home feeds
+		a.rankPost(ctx, uint(result.RemovedPostID.Int64))
+		a.removeFromTimelines(ctx, uint(result.RemovedPostID.Int64))
+	}
+	if result.CommentPostID.Valid {
+		postID := uint(result.CommentPostID.Int64)
+		// Updating the comment count also re-ranks the post, so it is only re-ranked here
+		// if the count cannot be updated
+		if _, err := a.CommentsCacheHandler.SetCacheVal(ctx, postID); err != nil {
+			log.Printf("Unable to update comment count of post %d: %v", postID, err)
+			a.rankPost(ctx, postID)
+		}
+	}
+	if input.Action == models.ReportActionWarnUser && len(result.Reports) > 0 {
+		a.NotificationPoster.PostNotificationFromEvent(ctx, helpers.GenerateWarningNotification(&result.Reports[0], resolution.Note))
+	}
+	for i := range result.Reports {
+		// Content held by the content filter has no reporter to notify
+		if result.Reports[i].ReporterID == models.ContentFilterReporterID {
+			continue
+		}
+		a.NotificationPoster.PostNotificationFromEvent(ctx, helpers.GenerateReportOutcomeNotification(&result.Reports[i]))
+	}
+	helpers.OutputData(ctx, models.ReportArray{
+		Reports: result.Reports,
+	})
+}
@@ -0,0 +1,521 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+const (
+	testReportID   = 1
+	testReporterID = "reporter"
+)
+
+var (
+	defaultReportInput = models.ReportInput{
+		TargetType: models.ReportTargetPost,
+		TargetID:   "1",
+		Reason:     models.ReportReasonSpam,
+		Note:       "Buy now!",
+	}
+	defaultReport = models.Report{
+		Model: gorm.Model{
+			ID: testReportID,
+		},
+		ReporterID:   testReporterID,
+		TargetType:   models.ReportTargetComment,
+		TargetID:     "1",
+		TargetUserID: testUserID,
+		Reason:       models.ReportReasonSpam,
+		Status:       models.ReportOpen,
+	}
+)
+
+type ReportDBTestHandler struct {
+	CreateReportFunc  func(*models.Report) (*models.Report, error)
+	GetReportsFunc    func(string, string, bool, *helpers.NullableUint) ([]models.Report, error)
+	ResolveReportFunc func(uint, *models.ReportResolution, bool) (*models.ModerationResult, error)
+}
+
+func (h *ReportDBTestHandler) CreateReport(report *models.Report) (*models.Report, error) {
+	return h.CreateReportFunc(report)
+}
+
+func (h *ReportDBTestHandler) GetReports(status string, moderatorID string, siteModerator bool, cutoff *helpers.NullableUint) ([]models.Report, error) {
+	return h.GetReportsFunc(status, moderatorID, siteModerator, cutoff)
+}
+
+func (h *ReportDBTestHandler) ResolveReport(reportID uint, resolution *models.ReportResolution, siteModerator bool) (*models.ModerationResult, error) {
+	return h.ResolveReportFunc(reportID, resolution, siteModerator)
+}
+
+func (h *ReportDBTestHandler) SetMockCreateReportFunc(err error) {
+	h.CreateReportFunc = func(report *models.Report) (*models.Report, error) {
+		return report, err
+	}
+}
+
+func TestAPIEnv_PostReport(t *testing.T) {
+	type args struct {
+		Input         *models.ReportInput
+		ReportDBError error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.Report]
+	}{
+		{
+			"Post Report OK",
+			args{
+				Input: &defaultReportInput,
+			},
+			helpers.ExpectedJSONOutput[models.Report]{
+				StatusCode: http.StatusOK,
+			},
+		},
+		{
+			"Post Report bad binding",
+			args{},
+			helpers.ExpectedJSONOutput[models.Report]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadBinding,
+			},
+		},
+		{
+			"Post Report unknown target type",
+			args{
+				Input: &models.ReportInput{
+					TargetType: "message",
+					TargetID:   "1",
+					Reason:     models.ReportReasonSpam,
+				},
+			},
+			helpers.ExpectedJSONOutput[models.Report]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadReportTarget,
+			},
+		},
+		{
+			"Post Report non-numeric post ID",
+			args{
+				Input: &models.ReportInput{
+					TargetType: models.ReportTargetPost,
+					TargetID:   "abc",
+					Reason:     models.ReportReasonSpam,
+				},
+			},
+			helpers.ExpectedJSONOutput[models.Report]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadReportTarget,
+			},
+		},
+		{
+			"Post Report unknown reason",
+			args{
+				Input: &models.ReportInput{
+					TargetType: models.ReportTargetUser,
+					TargetID:   testUsername,
+					Reason:     "boring",
+				},
+			},
+			helpers.ExpectedJSONOutput[models.Report]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadReportReason,
+			},
+		},
+		{
+			"Post Report target not found",
+			args{
+				Input:         &defaultReportInput,
+				ReportDBError: gorm.ErrRecordNotFound,
+			},
+			helpers.ExpectedJSONOutput[models.Report]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrReportTargetNotFound,
+			},
+		},
+		{
+			"Post Report own content",
+			args{
+				Input:         &defaultReportInput,
+				ReportDBError: database.ErrReportingOneself,
+			},
+			helpers.ExpectedJSONOutput[models.Report]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotReportSelf,
+			},
+		},
+		{
+			"Post Report already reported",
+			args{
+				Input:         &defaultReportInput,
+				ReportDBError: database.ErrAlreadyReported,
+			},
+			helpers.ExpectedJSONOutput[models.Report]{
+				StatusCode: http.StatusConflict,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrAlreadyReported,
+			},
+		},
+		{
+			"Post Report cannot create",
+			args{
+				Input:         &defaultReportInput,
+				ReportDBError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.Report]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotCreateReport,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &ReportDBTestHandler{}
+			a := &APIEnv{
+				ReportDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testReporterID)
+			if tt.args.Input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.args.Input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			dbTestHandler.SetMockCreateReportFunc(tt.args.ReportDBError)
+			a.PostReport(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if tt.expected.JSONType != helpers.ExpectedError {
+				return
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetReports(t *testing.T) {
+	helpers.SetEnvVars(t)
+	tests := []struct {
+		name              string
+		status            string
+		role              string
+		reportDBError     error
+		expectedCode      int
+		wantStatus        string
+		wantSiteModerator bool
+	}{
+		{"Get Reports defaults to open", "", models.RoleUser, nil, http.StatusOK, models.ReportOpen, false},
+		{"Get Reports as site moderator", models.ReportDismissed, models.RoleModerator, nil, http.StatusOK, models.ReportDismissed, true},
+		{"Get Reports as admin", models.ReportActioned, models.RoleAdmin, nil, http.StatusOK, models.ReportActioned, true},
+		{"Get Reports bad status", "closed", models.RoleAdmin, nil, http.StatusBadRequest, "", false},
+		{"Get Reports cannot retrieve", "", models.RoleAdmin, ErrTest, http.StatusInternalServerError, models.ReportOpen, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &ReportDBTestHandler{}
+			a := &APIEnv{
+				ReportDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			c.Request, _ = http.NewRequest(http.MethodGet, "/", nil)
+			if tt.status != "" {
+				helpers.AddParamsToQuery(c.Request, helpers.ReportStatusKey, tt.status)
+			}
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			c.Set(helpers.UserRoleKey, tt.role)
+
+			var gotStatus string
+			var gotSiteModerator bool
+			dbTestHandler.GetReportsFunc = func(status string, moderatorID string, siteModerator bool, cutoff *helpers.NullableUint) ([]models.Report, error) {
+				gotStatus, gotSiteModerator = status, siteModerator
+				return []models.Report{defaultReport}, tt.reportDBError
+			}
+			a.GetReports(c)
+
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if gotStatus != tt.wantStatus || gotSiteModerator != tt.wantSiteModerator {
+				t.Errorf("GetReports called with status %q, site moderator %v", gotStatus, gotSiteModerator)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_ResolveReport(t *testing.T) {
+	type args struct {
+		Input         *models.ReportResolutionInput
+		ReportDBError error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.ReportArray]
+	}{
+		{
+			"Resolve Report bad binding",
+			args{},
+			helpers.ExpectedJSONOutput[models.ReportArray]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadBinding,
+			},
+		},
+		{
+			"Resolve Report unknown action",
+			args{
+				Input: &models.ReportResolutionInput{Action: "ban"},
+			},
+			helpers.ExpectedJSONOutput[models.ReportArray]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadReportAction,
+			},
+		},
+		{
+			"Resolve Report suspension ends in the past",
+			args{
+				Input: &models.ReportResolutionInput{
+					Action:       models.ReportActionSuspendUser,
+					SuspendUntil: null.TimeFrom(time.Now().Add(-time.Hour)),
+				},
+			},
+			helpers.ExpectedJSONOutput[models.ReportArray]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadSuspensionEnd,
+			},
+		},
+		{
+			"Resolve Report not found",
+			args{
+				Input:         &models.ReportResolutionInput{Action: models.ReportActionDismiss},
+				ReportDBError: gorm.ErrRecordNotFound,
+			},
+			helpers.ExpectedJSONOutput[models.ReportArray]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrReportNotFound,
+			},
+		},
+		{
+			"Resolve Report already resolved",
+			args{
+				Input:         &models.ReportResolutionInput{Action: models.ReportActionDismiss},
+				ReportDBError: database.ErrReportResolved,
+			},
+			helpers.ExpectedJSONOutput[models.ReportArray]{
+				StatusCode: http.StatusConflict,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrReportResolved,
+			},
+		},
+		{
+			"Resolve Report not a moderator of the community",
+			args{
+				Input:         &models.ReportResolutionInput{Action: models.ReportActionRemoveContent},
+				ReportDBError: helpers.ErrNotOwner,
+			},
+			helpers.ExpectedJSONOutput[models.ReportArray]{
+				StatusCode: http.StatusForbidden,
+				JSONType:   helpers.ExpectedError,
+				Error:      helpers.ErrNotOwner,
+			},
+		},
+		{
+			"Resolve Report cannot remove user",
+			args{
+				Input:         &models.ReportResolutionInput{Action: models.ReportActionRemoveContent},
+				ReportDBError: database.ErrCannotRemove,
+			},
+			helpers.ExpectedJSONOutput[models.ReportArray]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotRemoveTarget,
+			},
+		},
+		{
+			"Resolve Report cannot suspend admin",
+			args{
+				Input:         &models.ReportResolutionInput{Action: models.ReportActionSuspendUser},
+				ReportDBError: database.ErrCannotSuspendAdmin,
+			},
+			helpers.ExpectedJSONOutput[models.ReportArray]{
+				StatusCode: http.StatusForbidden,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotSuspendAdmin,
+			},
+		},
+		{
+			"Resolve Report cannot resolve",
+			args{
+				Input:         &models.ReportResolutionInput{Action: models.ReportActionDismiss},
+				ReportDBError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.ReportArray]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotResolveReport,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &ReportDBTestHandler{}
+			a := &APIEnv{
+				ReportDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testAdminID)
+			helpers.AddParamsToContext(c, helpers.ReportIDKey, testReportID)
+			if tt.args.Input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.args.Input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			dbTestHandler.ResolveReportFunc = func(reportID uint, resolution *models.ReportResolution, siteModerator bool) (*models.ModerationResult, error) {
+				return nil, tt.args.ReportDBError
+			}
+			a.ResolveReport(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+// Removing a reported comment updates the post's comment count, and each reporter is
+// notified of the outcome; warnings are sent to the owner of the content
+func TestAPIEnv_ResolveReport_OK(t *testing.T) {
+	tests := []struct {
+		name            string
+		action          string
+		removedPostID   null.Int
+		commentPostID   null.Int
+		cacheError      error
+		wantCacheUpdate bool
+		wantRanked      []uint
+		wantReceivers   []string
+	}{
+		{"Remove post", models.ReportActionRemoveContent, null.IntFrom(testPostID), null.Int{}, nil, false, []uint{testPostID},
+			[]string{testReporterID, "reporter2"}},
+		// The post is re-ranked by the comments cache when its count is updated
+		{"Remove comment", models.ReportActionRemoveContent, null.Int{}, null.IntFrom(testPostID), nil, true, nil,
+			[]string{testReporterID, "reporter2"}},
+		{"Remove comment cannot update count", models.ReportActionRemoveContent, null.Int{}, null.IntFrom(testPostID), ErrTest, true,
+			[]uint{testPostID}, []string{testReporterID, "reporter2"}},
+		{"Warn user", models.ReportActionWarnUser, null.Int{}, null.Int{}, nil, false, nil, []string{testUserID, testReporterID, "reporter2"}},
+		{"Dismiss", models.ReportActionDismiss, null.Int{}, null.Int{}, nil, false, nil, []string{testReporterID, "reporter2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &ReportDBTestHandler{}
+			cache := &helpers.TestCache{}
+			notifier := &helpers.TestNotificationCreator{}
+			ranker := &TestPostRanker{}
+			timeline := &TestHomeTimeline{}
+			a := &APIEnv{
+				ReportDBHandler:      dbTestHandler,
+				CommentsCacheHandler: cache,
+				NotificationPoster:   notifier,
+				PostRanker:           ranker,
+				HomeTimeline:         timeline,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.ReportIDKey, testReportID)
+			c.Set(helpers.UserRoleKey, models.RoleModerator)
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, models.ReportResolutionInput{Action: tt.action})
+			if err != nil {
+				t.Error(err)
+			}
+			c.Request = req
+
+			otherReport := defaultReport
+			otherReport.ID = 2
+			otherReport.ReporterID = "reporter2"
+			dbTestHandler.ResolveReportFunc = func(reportID uint, resolution *models.ReportResolution, siteModerator bool) (*models.ModerationResult, error) {
+				if !siteModerator || resolution.ModeratorID != testUserID {
+					t.Errorf("Resolved by %s, site moderator %v", resolution.ModeratorID, siteModerator)
+				}
+				return &models.ModerationResult{
+					Reports:       []models.Report{defaultReport, otherReport},
+					RemovedPostID: tt.removedPostID,
+					CommentPostID: tt.commentPostID,
+				}, nil
+			}
+			cacheUpdated := false
+			cache.SetCacheValFunc = func(ctx context.Context, postID uint) (uint64, error) {
+				cacheUpdated = postID == testPostID
+				return 0, tt.cacheError
+			}
+			var receivers []string
+			notifier.PostNotificationFromEventFunc = func(ctx *gin.Context, notif *models.Notification) error {
+				receivers = append(receivers, notif.ReceiverId)
+				return nil
+			}
+			a.ResolveReport(c)
+
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(http.StatusOK, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if cacheUpdated != tt.wantCacheUpdate {
+				t.Errorf("Comment count updated = %v, want %v", cacheUpdated, tt.wantCacheUpdate)
+			}
+			if !reflect.DeepEqual(ranker.Ranked, tt.wantRanked) {
+				t.Errorf("Ranked posts %v, want %v", ranker.Ranked, tt.wantRanked)
+			}
+			// Only removed posts are taken out of home feeds
+			if removed := len(timeline.Removed) == 1; removed != tt.removedPostID.Valid {
+				t.Errorf("Removed post taken out of home feeds = %v", removed)
+			}
+			if strings.Join(receivers, ",") != strings.Join(tt.wantReceivers, ",") {
+				t.Errorf("Notified %v, want %v", receivers, tt.wantReceivers)
+			}
+		})
+	}
+}
@@ -1,54 +1,142 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"sort"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
 )
 
+// Errors
+var (
+	ErrCannotSearch         = errors.New("cannot retrieve search results")
+	ErrInvalidSearchLimit   = fmt.Errorf("limit must be between 1 and %d", helpers.MaxSearchLimit)
+	ErrSearchTermTooLong    = errors.New("search term is too long")
+	ErrSearchTermNotPresent = errors.New("search term not present")
+)
+
+func (a *APIEnv) InitialiseSearchHandler() {
+	a.SearchDBHandler = &database.SearchDB{
+		DB: a.DB,
+	}
+}
+
 func (a *APIEnv) GetSearchResults(ctx *gin.Context) {
-	searchTerm := ctx.Query("q")
-	limit := ctx.DefaultQuery("limit", "10")
-	limitInt, err := strconv.Atoi(limit)
+	userID := helpers.GetUserIDFromContext(ctx)
+	searchTerm, limit, err := getSearchTermAndLimitFromQuery(ctx, helpers.DefaultSearchLimit)
+	// If the search term or limit is invalid, return status code 400 Bad Request
 	if err != nil {
 		helpers.OutputError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	userResults, err := a.UserDBHandler.QueryUser(searchTerm, limitInt)
+	params, err := getSearchFiltersFromQuery(ctx)
+	// If any filter is invalid, return status code 400 Bad Request
 	if err != nil {
-		helpers.OutputError(ctx, http.StatusInternalServerError, err)
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
 		return
 	}
+	params.Term = searchTerm
+	// Retrieve one more result than needed to find out if there is a next page
+	params.Limit = limit + 1
 
-	results := userResults
-
-	projectResults, err := a.ProjectDBHandler.QueryProject(searchTerm, limitInt)
+	results, err := a.SearchDBHandler.Search(params, userID)
+	// If unable to search, return status code 500 Internal Server Error
 	if err != nil {
-		helpers.OutputError(ctx, http.StatusInternalServerError, err)
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotSearch)
 		return
 	}
 
-	results = append(results, projectResults...)
-	communityResults, err := a.CommunityDBHandler.QueryCommunity(searchTerm, limitInt)
+	output := models.SearchResultsPage{
+		Results: results,
+	}
+	if len(results) > limit {
+		output.Results = results[:limit]
+		output.NextCursor = helpers.EncodeSearchCursor(results[limit-1].Cursor())
+	}
+
+	// Facets do not change between pages, so they are only counted for the first page
+	if params.Cursor == nil {
+		output.Facets, err = a.SearchDBHandler.CountSearchResults(params, userID)
+		if err != nil {
+			helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotSearch)
+			return
+		}
+	}
+	helpers.OutputData(ctx, output)
+}
+
+func (a *APIEnv) GetSearchSuggestions(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	searchTerm, limit, err := getSearchTermAndLimitFromQuery(ctx, helpers.DefaultSuggestLimit)
+	// If the search term or limit is invalid, return status code 400 Bad Request
 	if err != nil {
-		helpers.OutputError(ctx, http.StatusInternalServerError, err)
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(searchTerm)) < helpers.MinSuggestTermLength {
+		helpers.OutputData(ctx, []models.SearchResult{})
 		return
 	}
-	results = append(results, communityResults...)
 
-	// Sort the results by score
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
+	suggestions, err := a.SearchDBHandler.Suggest(strings.TrimSpace(searchTerm), limit, userID)
+	// If unable to retrieve suggestions, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotSearch)
+		return
+	}
+	helpers.OutputData(ctx, suggestions)
+}
 
-	// Return the top 10 results
-	if len(results) > 10 {
-		results = results[:10]
+// Retrieves the search term and the number of results to return from the query
+func getSearchTermAndLimitFromQuery(ctx *gin.Context, defaultLimit int) (string, int, error) {
+	searchTerm, ok := ctx.GetQuery(helpers.SearchTermKey)
+	if !ok {
+		return "", 0, ErrSearchTermNotPresent
+	}
+	if utf8.RuneCountInString(searchTerm) > helpers.MaxSearchTermLength {
+		return "", 0, ErrSearchTermTooLong
+	}
+	// Ensure that limit is an integer within range or empty
+	limit := defaultLimit
+	if limitStr := ctx.Query(helpers.SearchLimitKey); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > helpers.MaxSearchLimit {
+			return "", 0, ErrInvalidSearchLimit
+		}
 	}
+	return searchTerm, limit, nil
+}
 
-	helpers.OutputData(ctx, results)
+// Retrieves the filters and cursor of a search from the query
+func getSearchFiltersFromQuery(ctx *gin.Context) (*database.SearchParams, error) {
+	var err error
+	params := database.SearchParams{
+		Community: ctx.Query(helpers.SearchCommunityKey),
+		Skills:    helpers.NormaliseSkillNames(ctx.QueryArray(helpers.SearchSkillKey)),
+	}
+	if err = helpers.ValidateSkillNames(params.Skills); err != nil {
+		return nil, err
+	}
+	if params.Types, err = helpers.GetSearchTypesFromQuery(ctx); err != nil {
+		return nil, err
+	}
+	if params.CreatedAfter, err = helpers.GetSearchDateFromQuery(ctx, helpers.SearchCreatedAfterKey); err != nil {
+		return nil, err
+	}
+	if params.CreatedBefore, err = helpers.GetSearchDateFromQuery(ctx, helpers.SearchCreatedBeforeKey); err != nil {
+		return nil, err
+	}
+	if params.Cursor, err = helpers.GetSearchCursorFromQuery(ctx); err != nil {
+		return nil, err
+	}
+	return &params, nil
 }
@@ -0,0 +1,426 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+)
+
+type SearchDBTestHandler struct {
+	SearchFunc             func(*database.SearchParams, string) ([]models.SearchResult, error)
+	CountSearchResultsFunc func(*database.SearchParams, string) (map[string]int64, error)
+	SuggestFunc            func(string, int, string) ([]models.SearchResult, error)
+}
+
+func (h *SearchDBTestHandler) Search(params *database.SearchParams, userID string) ([]models.SearchResult, error) {
+	return h.SearchFunc(params, userID)
+}
+
+func (h *SearchDBTestHandler) CountSearchResults(params *database.SearchParams, userID string) (map[string]int64, error) {
+	return h.CountSearchResultsFunc(params, userID)
+}
+
+func (h *SearchDBTestHandler) Suggest(term string, limit int, userID string) ([]models.SearchResult, error) {
+	return h.SuggestFunc(term, limit, userID)
+}
+
+// Returns n results with decreasing scores
+func generateSearchResults(n int) []models.SearchResult {
+	results := []models.SearchResult{}
+	for i := 0; i < n; i++ {
+		results = append(results, models.SearchResult{
+			ID:         strings.Repeat("a", i+1),
+			Name:       testUsername,
+			ResultType: models.SearchResultUser,
+			Score:      1 / float64(i+2),
+		})
+	}
+	return results
+}
+
+func TestAPIEnv_GetSearchResults(t *testing.T) {
+	testCursor := &models.SearchCursor{Score: 0.25, ResultType: models.SearchResultPost, ID: "12"}
+	testDate := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	testFacets := map[string]int64{models.SearchResultUser: 3, models.SearchResultPost: 1}
+	tooManySkills := []string{}
+	for i := 0; i <= helpers.MaxUserSkills; i++ {
+		tooManySkills = append(tooManySkills, strings.Repeat("a", i+1))
+	}
+	type args struct {
+		QueryParams map[string]interface{}
+		SearchError error
+		CountError  error
+	}
+	tests := []struct {
+		name           string
+		args           args
+		expectedCode   int
+		expectedErr    error
+		expectedParams *database.SearchParams
+	}{
+		{
+			"Search OK",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go web dev"}},
+			http.StatusOK, nil,
+			&database.SearchParams{Term: "go web dev", Types: []string{}, Skills: []string{}, Limit: helpers.DefaultSearchLimit + 1},
+		},
+		{
+			"Search with filters OK",
+			args{QueryParams: map[string]interface{}{
+				helpers.SearchTermKey:          "go",
+				helpers.SearchLimitKey:         2,
+				helpers.SearchTypeKey:          "project,post",
+				helpers.SearchCommunityKey:     "Golang",
+				helpers.SearchCreatedAfterKey:  "2023-07-01",
+				helpers.SearchCreatedBeforeKey: testDate.Format(time.RFC3339),
+			}},
+			http.StatusOK, nil,
+			&database.SearchParams{
+				Term:          "go",
+				Types:         []string{models.SearchResultProject, models.SearchResultPost},
+				Community:     "Golang",
+				Skills:        []string{},
+				CreatedAfter:  null.TimeFrom(testDate),
+				CreatedBefore: null.TimeFrom(testDate),
+				Limit:         3,
+			},
+		},
+		{
+			"Search with skills OK",
+			args{QueryParams: map[string]interface{}{
+				helpers.SearchTermKey:  "developer",
+				helpers.SearchSkillKey: []string{"Go", " postgres ", "go", ""},
+			}},
+			http.StatusOK, nil,
+			&database.SearchParams{
+				Term:   "developer",
+				Types:  []string{},
+				Skills: []string{"Go", "postgres"},
+				Limit:  helpers.DefaultSearchLimit + 1,
+			},
+		},
+		{
+			"Search with cursor OK",
+			args{QueryParams: map[string]interface{}{
+				helpers.SearchTermKey:   "go",
+				helpers.SearchCursorKey: helpers.EncodeSearchCursor(testCursor),
+			}},
+			http.StatusOK, nil,
+			&database.SearchParams{Term: "go", Types: []string{}, Skills: []string{}, Cursor: testCursor, Limit: helpers.DefaultSearchLimit + 1},
+		},
+		{
+			"Search with quote injection",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "'; DROP TABLE users; --"}},
+			http.StatusOK, nil,
+			&database.SearchParams{Term: "'; DROP TABLE users; --", Types: []string{}, Skills: []string{}, Limit: helpers.DefaultSearchLimit + 1},
+		},
+		{
+			"Search with tsquery syntax",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: `a:* & !(b <-> "c") | \`}},
+			http.StatusOK, nil,
+			&database.SearchParams{Term: `a:* & !(b <-> "c") | \`, Types: []string{}, Skills: []string{}, Limit: helpers.DefaultSearchLimit + 1},
+		},
+		{
+			"Search with placeholders",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "$1 ? %s %v"}},
+			http.StatusOK, nil,
+			&database.SearchParams{Term: "$1 ? %s %v", Types: []string{}, Skills: []string{}, Limit: helpers.DefaultSearchLimit + 1},
+		},
+		{
+			"Search with quote injection in community",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go", helpers.SearchCommunityKey: "x' OR '1'='1"}},
+			http.StatusOK, nil,
+			&database.SearchParams{Term: "go", Types: []string{}, Skills: []string{}, Community: "x' OR '1'='1", Limit: helpers.DefaultSearchLimit + 1},
+		},
+		{
+			"Search without term",
+			args{QueryParams: map[string]interface{}{}},
+			http.StatusBadRequest, ErrSearchTermNotPresent, nil,
+		},
+		{
+			"Search term too long",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: strings.Repeat("a", helpers.MaxSearchTermLength+1)}},
+			http.StatusBadRequest, ErrSearchTermTooLong, nil,
+		},
+		{
+			"Search with non-numeric limit",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go", helpers.SearchLimitKey: "1; DROP TABLE users"}},
+			http.StatusBadRequest, ErrInvalidSearchLimit, nil,
+		},
+		{
+			"Search with negative limit",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go", helpers.SearchLimitKey: -1}},
+			http.StatusBadRequest, ErrInvalidSearchLimit, nil,
+		},
+		{
+			"Search with limit too large",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go", helpers.SearchLimitKey: helpers.MaxSearchLimit + 1}},
+			http.StatusBadRequest, ErrInvalidSearchLimit, nil,
+		},
+		{
+			"Search with invalid type",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go", helpers.SearchTypeKey: "user,users"}},
+			http.StatusBadRequest, helpers.ErrInvalidSearchType, nil,
+		},
+		{
+			"Search with invalid date",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go", helpers.SearchCreatedAfterKey: "yesterday"}},
+			http.StatusBadRequest, helpers.ErrInvalidSearchDate, nil,
+		},
+		{
+			"Search with too many skills",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go", helpers.SearchSkillKey: tooManySkills}},
+			http.StatusBadRequest, helpers.ErrTooManySkills, nil,
+		},
+		{
+			"Search with invalid cursor",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go", helpers.SearchCursorKey: "' OR 1=1 --"}},
+			http.StatusBadRequest, helpers.ErrInvalidSearchCursor, nil,
+		},
+		{
+			"Search cannot retrieve results",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go"}, SearchError: ErrTest},
+			http.StatusInternalServerError, ErrCannotSearch,
+			&database.SearchParams{Term: "go", Types: []string{}, Skills: []string{}, Limit: helpers.DefaultSearchLimit + 1},
+		},
+		{
+			"Search cannot count results",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "go"}, CountError: ErrTest},
+			http.StatusInternalServerError, ErrCannotSearch,
+			&database.SearchParams{Term: "go", Types: []string{}, Skills: []string{}, Limit: helpers.DefaultSearchLimit + 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var receivedParams *database.SearchParams
+			dbTestHandler := &SearchDBTestHandler{
+				SearchFunc: func(params *database.SearchParams, userID string) ([]models.SearchResult, error) {
+					receivedParams = params
+					if userID != testUserID {
+						t.Errorf("Search received userID %s", userID)
+					}
+					return generateSearchResults(params.Limit - 1), tt.args.SearchError
+				},
+				CountSearchResultsFunc: func(params *database.SearchParams, userID string) (map[string]int64, error) {
+					return testFacets, tt.args.CountError
+				},
+			}
+			a := &APIEnv{
+				SearchDBHandler: dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			req, _ := http.NewRequest(http.MethodGet, "", nil)
+			for paramKey, paramVal := range tt.args.QueryParams {
+				// Parameters that can be repeated are given as a slice
+				if paramVals, ok := paramVal.([]string); ok {
+					for _, val := range paramVals {
+						helpers.AddParamsToQuery(req, paramKey, val)
+					}
+					continue
+				}
+				helpers.AddParamsToQuery(req, paramKey, paramVal)
+			}
+			c.Request = req
+
+			a.GetSearchResults(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			// The search term and filters must reach the database handler exactly as entered
+			if !reflect.DeepEqual(receivedParams, tt.expectedParams) {
+				t.Errorf("Search received params %+v, want %+v", receivedParams, tt.expectedParams)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			data := m["data"].(map[string]interface{})
+			results, ok := data["results"].([]interface{})
+			if !ok || len(results) != tt.expectedParams.Limit-1 {
+				t.Errorf("Expected %d results, got %v", tt.expectedParams.Limit-1, data["results"])
+			}
+			_, hasFacets := data["facets"]
+			if hasFacets != (tt.expectedParams.Cursor == nil) {
+				t.Errorf("Facets returned = %v with cursor %v", hasFacets, tt.expectedParams.Cursor)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetSearchResults_Pagination(t *testing.T) {
+	const limit = 3
+	tests := []struct {
+		name           string
+		resultCount    int
+		expectedCursor string
+	}{
+		{"Last page", limit, ""},
+		{"More pages", limit + 1, helpers.EncodeSearchCursor(generateSearchResults(limit)[limit-1].Cursor())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &APIEnv{
+				SearchDBHandler: &SearchDBTestHandler{
+					SearchFunc: func(params *database.SearchParams, userID string) ([]models.SearchResult, error) {
+						return generateSearchResults(tt.resultCount), nil
+					},
+					CountSearchResultsFunc: func(params *database.SearchParams, userID string) (map[string]int64, error) {
+						return map[string]int64{}, nil
+					},
+				},
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			req, _ := http.NewRequest(http.MethodGet, "", nil)
+			helpers.AddParamsToQuery(req, helpers.SearchTermKey, "go")
+			helpers.AddParamsToQuery(req, helpers.SearchLimitKey, limit)
+			c.Request = req
+
+			a.GetSearchResults(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(http.StatusOK, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			data := m["data"].(map[string]interface{})
+			if results := data["results"].([]interface{}); len(results) != limit {
+				t.Errorf("Expected %d results, got %d", limit, len(results))
+			}
+			nextCursor, _ := data["next_cursor"].(string)
+			if nextCursor != tt.expectedCursor {
+				t.Errorf("Expected next cursor %q, got %q", tt.expectedCursor, nextCursor)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_GetSearchSuggestions(t *testing.T) {
+	type args struct {
+		QueryParams  map[string]interface{}
+		SuggestError error
+	}
+	tests := []struct {
+		name          string
+		args          args
+		expectedCode  int
+		expectedErr   error
+		expectedTerm  string
+		expectedLimit int
+	}{
+		{
+			"Suggest OK",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "jdoe_4"}},
+			http.StatusOK, nil, "jdoe_4", helpers.DefaultSuggestLimit,
+		},
+		{
+			"Suggest with limit OK",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "jdoe", helpers.SearchLimitKey: 3}},
+			http.StatusOK, nil, "jdoe", 3,
+		},
+		{
+			"Suggest trims whitespace",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: " jdoe "}},
+			http.StatusOK, nil, "jdoe", helpers.DefaultSuggestLimit,
+		},
+		{
+			"Suggest with LIKE wildcards",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: `%_\`}},
+			http.StatusOK, nil, `%_\`, helpers.DefaultSuggestLimit,
+		},
+		{
+			"Suggest with quote injection",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "'; DROP TABLE users; --"}},
+			http.StatusOK, nil, "'; DROP TABLE users; --", helpers.DefaultSuggestLimit,
+		},
+		{
+			"Suggest term too short",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: " j "}},
+			http.StatusOK, nil, "", 0,
+		},
+		{
+			"Suggest without term",
+			args{QueryParams: map[string]interface{}{}},
+			http.StatusBadRequest, ErrSearchTermNotPresent, "", 0,
+		},
+		{
+			"Suggest with invalid limit",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "jdoe", helpers.SearchLimitKey: 0}},
+			http.StatusBadRequest, ErrInvalidSearchLimit, "", 0,
+		},
+		{
+			"Suggest cannot retrieve suggestions",
+			args{QueryParams: map[string]interface{}{helpers.SearchTermKey: "jdoe"}, SuggestError: ErrTest},
+			http.StatusInternalServerError, ErrCannotSearch, "jdoe", helpers.DefaultSuggestLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var receivedTerm string
+			var receivedLimit int
+			a := &APIEnv{
+				SearchDBHandler: &SearchDBTestHandler{
+					SuggestFunc: func(term string, limit int, userID string) ([]models.SearchResult, error) {
+						receivedTerm, receivedLimit = term, limit
+						if userID != testUserID {
+							t.Errorf("Suggest received userID %s", userID)
+						}
+						return generateSearchResults(limit), tt.args.SuggestError
+					},
+				},
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			req, _ := http.NewRequest(http.MethodGet, "", nil)
+			for paramKey, paramVal := range tt.args.QueryParams {
+				helpers.AddParamsToQuery(req, paramKey, paramVal)
+			}
+			c.Request = req
+
+			a.GetSearchSuggestions(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			if receivedTerm != tt.expectedTerm || receivedLimit != tt.expectedLimit {
+				t.Errorf("Suggest received term %q and limit %d, want %q and %d", receivedTerm, receivedLimit, tt.expectedTerm, tt.expectedLimit)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			if suggestions, ok := m["data"].([]interface{}); !ok || len(suggestions) != tt.expectedLimit {
+				t.Errorf("Expected %d suggestions, got %v", tt.expectedLimit, m["data"])
+			}
+		})
+	}
+}
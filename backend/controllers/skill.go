@@ -0,0 +1,130 @@
+/*
+Contains controllers for the skills listed on user profiles and their endorsements.
+*/
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	SkillEndorsedMsg   = "Skill endorsed"
+	SkillUnendorsedMsg = "Skill endorsement removed"
+)
+
+// Errors
+var (
+	ErrAlreadyEndorsed      = errors.New("already endorsed")
+	ErrCannotEndorseSelf    = errors.New("you cannot endorse your own skills")
+	ErrCannotEndorseSkill   = errors.New("cannot endorse skill")
+	ErrCannotRetrieveSkills = errors.New("cannot retrieve skills")
+	ErrCannotUnendorseSkill = errors.New("cannot remove endorsement")
+	ErrCannotUpdateSkills   = errors.New("cannot update skills")
+	ErrEndorsementNotFound  = errors.New("endorsement not found")
+	ErrSkillNotFound        = errors.New("skill not found")
+)
+
+func (a *APIEnv) InitialiseSkillHandler() {
+	a.SkillDBHandler = &database.SkillDB{
+		DB: a.DB,
+	}
+}
+
+// Replaces the skills listed on the user's profile. Skills are saved under their
+// canonical name, so "golang" is listed as Go.
+func (a *APIEnv) UpdateUserSkills(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	var input models.UserSkillsInput
+
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	// If a skill is invalid or there are too many, return status code 400 Bad Request
+	if err := helpers.ValidateUserSkills(input.Skills); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	skills, err := a.SkillDBHandler.SetUserSkills(userID, input.Skills)
+	// If skills cannot be updated, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateSkills)
+		return
+	}
+	helpers.OutputData(ctx, skills)
+}
+
+// Endorses a skill listed on the profile of the user in the URL, and notifies the user
+func (a *APIEnv) EndorseSkill(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	skillID, err := helpers.GetSkillIDFromContext(ctx)
+	// If skillID is not an unsigned integer, return status code 400 Bad Request
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrSkillNotFound)
+		return
+	}
+
+	endorsement, err := a.SkillDBHandler.EndorseSkill(userID, helpers.GetUsernameFromContext(ctx), skillID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return
+	case errors.Is(err, database.ErrSkillNotListed):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrSkillNotFound)
+		return
+	case errors.Is(err, database.ErrEndorsingOneself):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCannotEndorseSelf)
+		return
+	case errors.Is(err, database.ErrAlreadyEndorsed):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrAlreadyEndorsed)
+		return
+	case errors.Is(err, database.ErrBlocked):
+		// If the users have blocked each other, return status code 403 Forbidden
+		helpers.OutputError(ctx, http.StatusForbidden, ErrBlocked)
+		return
+	case err != nil:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotEndorseSkill)
+		return
+	}
+
+	notif := helpers.GenerateEndorsementNotification(&endorsement.Endorser, endorsement.UserID, endorsement.Skill.Name)
+	// Even if there is an error in creating the notification server-side,
+	// this should not throw an error client-side
+	a.NotificationPoster.PostNotificationFromEvent(ctx, notif)
+
+	helpers.OutputMessage(ctx, SkillEndorsedMsg)
+}
+
+func (a *APIEnv) UnendorseSkill(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	skillID, err := helpers.GetSkillIDFromContext(ctx)
+	// If skillID is not an unsigned integer, return status code 400 Bad Request
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrSkillNotFound)
+		return
+	}
+
+	err = a.SkillDBHandler.UnendorseSkill(userID, helpers.GetUsernameFromContext(ctx), skillID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		helpers.OutputError(ctx, http.StatusNotFound, ErrEndorsementNotFound)
+		return
+	case errors.Is(err, database.ErrEndorsingOneself):
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrCannotEndorseSelf)
+		return
+	case err != nil:
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUnendorseSkill)
+		return
+	}
+	helpers.OutputMessage(ctx, SkillUnendorsedMsg)
+}
@@ -0,0 +1,304 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+const testSkillID = 3
+
+type SkillDBTestHandler struct {
+	SetUserSkillsFunc  func(string, []models.UserSkillInput) ([]models.UserSkillView, error)
+	GetUserSkillsFunc  func(string, string) ([]models.UserSkillView, error)
+	EndorseSkillFunc   func(string, string, uint) (*models.Endorsement, error)
+	UnendorseSkillFunc func(string, string, uint) error
+}
+
+func (h *SkillDBTestHandler) SetUserSkills(userID string, skills []models.UserSkillInput) ([]models.UserSkillView, error) {
+	return h.SetUserSkillsFunc(userID, skills)
+}
+
+func (h *SkillDBTestHandler) GetUserSkills(userID string, viewerID string) ([]models.UserSkillView, error) {
+	return h.GetUserSkillsFunc(userID, viewerID)
+}
+
+func (h *SkillDBTestHandler) EndorseSkill(endorserID string, username string, skillID uint) (*models.Endorsement, error) {
+	return h.EndorseSkillFunc(endorserID, username, skillID)
+}
+
+func (h *SkillDBTestHandler) UnendorseSkill(endorserID string, username string, skillID uint) error {
+	return h.UnendorseSkillFunc(endorserID, username, skillID)
+}
+
+func (h *SkillDBTestHandler) SetMockGetUserSkillsFunc(skills []models.UserSkillView, err error) {
+	h.GetUserSkillsFunc = func(userID string, viewerID string) ([]models.UserSkillView, error) {
+		return skills, err
+	}
+}
+
+func TestAPIEnv_UpdateUserSkills(t *testing.T) {
+	tooMany := []models.UserSkillInput{}
+	for i := 0; i <= helpers.MaxUserSkills; i++ {
+		tooMany = append(tooMany, models.UserSkillInput{Name: strings.Repeat("a", i+1)})
+	}
+	type args struct {
+		Input   *models.UserSkillsInput
+		DBError error
+	}
+	tests := []struct {
+		name          string
+		args          args
+		expectedSaved []models.UserSkillInput
+		expectedCode  int
+		expectedErr   error
+	}{
+		{
+			"Update skills OK",
+			args{
+				Input: &models.UserSkillsInput{Skills: []models.UserSkillInput{
+					{Name: " golang ", Level: null.IntFrom(4), Years: null.IntFrom(3)},
+					{Name: "Machine   learning"},
+				}},
+			},
+			[]models.UserSkillInput{
+				{Name: "golang", Level: null.IntFrom(4), Years: null.IntFrom(3)},
+				{Name: "Machine learning"},
+			},
+			http.StatusOK,
+			nil,
+		},
+		{
+			"Update skills clear",
+			args{
+				Input: &models.UserSkillsInput{Skills: []models.UserSkillInput{}},
+			},
+			[]models.UserSkillInput{},
+			http.StatusOK,
+			nil,
+		},
+		{
+			"Update skills bad request",
+			args{},
+			nil,
+			http.StatusBadRequest,
+			ErrBadBinding,
+		},
+		{
+			"Update skills too many",
+			args{
+				Input: &models.UserSkillsInput{Skills: tooMany},
+			},
+			nil,
+			http.StatusBadRequest,
+			helpers.ErrTooManySkills,
+		},
+		{
+			"Update skills empty name",
+			args{
+				Input: &models.UserSkillsInput{Skills: []models.UserSkillInput{{Name: "  "}}},
+			},
+			nil,
+			http.StatusBadRequest,
+			helpers.ErrNoSkillName,
+		},
+		{
+			"Update skills name too long",
+			args{
+				Input: &models.UserSkillsInput{Skills: []models.UserSkillInput{{Name: strings.Repeat("a", helpers.MaxSkillNameLength+1)}}},
+			},
+			nil,
+			http.StatusBadRequest,
+			helpers.ErrSkillNameTooLong,
+		},
+		{
+			"Update skills level out of range",
+			args{
+				Input: &models.UserSkillsInput{Skills: []models.UserSkillInput{{Name: "Go", Level: null.IntFrom(helpers.MaxSkillLevel + 1)}}},
+			},
+			nil,
+			http.StatusBadRequest,
+			helpers.ErrInvalidSkillLevel,
+		},
+		{
+			"Update skills negative years",
+			args{
+				Input: &models.UserSkillsInput{Skills: []models.UserSkillInput{{Name: "Go", Years: null.IntFrom(-1)}}},
+			},
+			nil,
+			http.StatusBadRequest,
+			helpers.ErrInvalidSkillYears,
+		},
+		{
+			"Update skills cannot update",
+			args{
+				Input:   &models.UserSkillsInput{Skills: []models.UserSkillInput{{Name: "Go"}}},
+				DBError: ErrTest,
+			},
+			[]models.UserSkillInput{{Name: "Go"}},
+			http.StatusInternalServerError,
+			ErrCannotUpdateSkills,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &SkillDBTestHandler{}
+			a := &APIEnv{
+				SkillDBHandler: dbTestHandler,
+			}
+
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			if tt.args.Input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPut, tt.args.Input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			var savedSkills []models.UserSkillInput
+			dbTestHandler.SetUserSkillsFunc = func(userID string, skills []models.UserSkillInput) ([]models.UserSkillView, error) {
+				savedSkills = skills
+				if tt.args.DBError != nil {
+					return nil, tt.args.DBError
+				}
+				views := []models.UserSkillView{}
+				for i, skill := range skills {
+					views = append(views, models.UserSkillView{ID: uint(i + 1), Name: skill.Name, Level: skill.Level, Years: skill.Years})
+				}
+				return views, nil
+			}
+			a.UpdateUserSkills(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expectedCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			// Names must reach the database handler with whitespace normalised
+			if !reflect.DeepEqual(savedSkills, tt.expectedSaved) {
+				t.Errorf("Saved skills %+v, expected %+v", savedSkills, tt.expectedSaved)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if tt.expectedErr != nil {
+				expected := helpers.ExpectedJSONOutput[string]{JSONType: helpers.ExpectedError, Error: tt.expectedErr}
+				if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, expected); !isEqual {
+					t.Error(errStr)
+				}
+				return
+			}
+			skills, ok := m["data"].([]interface{})
+			if !ok || len(skills) != len(tt.expectedSaved) {
+				t.Errorf("Expected skills %+v, got %v", tt.expectedSaved, m["data"])
+			}
+		})
+	}
+}
+
+func TestAPIEnv_EndorseAndUnendorseSkill(t *testing.T) {
+	type handlerFunc func(*APIEnv, *gin.Context)
+	endorse := func(a *APIEnv, ctx *gin.Context) { a.EndorseSkill(ctx) }
+	unendorse := func(a *APIEnv, ctx *gin.Context) { a.UnendorseSkill(ctx) }
+	tests := []struct {
+		name         string
+		handler      handlerFunc
+		skillID      interface{}
+		dbError      error
+		expected     helpers.ExpectedJSONOutput[string]
+		expectNotify bool
+	}{
+		{"Endorse skill OK", endorse, testSkillID, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: SkillEndorsedMsg}, true},
+		{"Endorse skill invalid ID", endorse, "go", nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrSkillNotFound}, false},
+		{"Endorse skill user not found", endorse, testSkillID, gorm.ErrRecordNotFound, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrUserNotFound}, false},
+		{"Endorse skill not listed", endorse, testSkillID, database.ErrSkillNotListed, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrSkillNotFound}, false},
+		{"Endorse own skill", endorse, testSkillID, database.ErrEndorsingOneself, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrCannotEndorseSelf}, false},
+		{"Endorse skill twice", endorse, testSkillID, database.ErrAlreadyEndorsed, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrAlreadyEndorsed}, false},
+		{"Endorse skill blocked", endorse, testSkillID, database.ErrBlocked, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusForbidden, JSONType: helpers.ExpectedError, Error: ErrBlocked}, false},
+		{"Endorse skill cannot endorse", endorse, testSkillID, ErrTest, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotEndorseSkill}, false},
+		{"Unendorse skill OK", unendorse, testSkillID, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusOK, JSONType: helpers.ExpectedMessage, Message: SkillUnendorsedMsg}, false},
+		{"Unendorse skill invalid ID", unendorse, -1, nil, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrSkillNotFound}, false},
+		{"Unendorse skill not endorsed", unendorse, testSkillID, gorm.ErrRecordNotFound, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusNotFound, JSONType: helpers.ExpectedError, Error: ErrEndorsementNotFound}, false},
+		{"Unendorse own skill", unendorse, testSkillID, database.ErrEndorsingOneself, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusBadRequest, JSONType: helpers.ExpectedError, Error: ErrCannotEndorseSelf}, false},
+		{"Unendorse skill cannot unendorse", unendorse, testSkillID, ErrTest, helpers.ExpectedJSONOutput[string]{
+			StatusCode: http.StatusInternalServerError, JSONType: helpers.ExpectedError, Error: ErrCannotUnendorseSkill}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &SkillDBTestHandler{}
+			notifPoster := &helpers.TestNotificationCreator{}
+			a := &APIEnv{
+				SkillDBHandler:     dbTestHandler,
+				NotificationPoster: notifPoster,
+			}
+
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			helpers.AddParamsToContext(c, helpers.UsernameKey, testUsername)
+			helpers.AddParamsToContext(c, helpers.SkillIDKey, tt.skillID)
+
+			dbTestHandler.EndorseSkillFunc = func(endorserID string, username string, skillID uint) (*models.Endorsement, error) {
+				if tt.dbError != nil {
+					return nil, tt.dbError
+				}
+				return &models.Endorsement{
+					EndorserID: endorserID,
+					Endorser:   models.User{ID: endorserID, UserCredentials: models.UserCredentials{Username: "endorser"}},
+					UserID:     diffUserID,
+					SkillID:    skillID,
+					Skill:      models.Skill{ID: skillID, Name: "Go"},
+				}, nil
+			}
+			dbTestHandler.UnendorseSkillFunc = func(endorserID string, username string, skillID uint) error {
+				return tt.dbError
+			}
+			var notified *models.Notification
+			notifPoster.PostNotificationFromEventFunc = func(ctx *gin.Context, notif *models.Notification) error {
+				notified = notif
+				return nil
+			}
+			tt.handler(a, c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+			if (notified != nil) != tt.expectNotify {
+				t.Errorf("Notification sent = %v, want %v", notified != nil, tt.expectNotify)
+			}
+			if notified != nil && (notified.ReceiverId != diffUserID || notified.SenderId != testUserID) {
+				t.Errorf("Notification %+v sent to the wrong user", notified)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Contains the timelines behind the home feed.
+*/
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"gorm.io/gorm"
+)
+
+// HomeTimeline keeps the posts in each user's home feed: their own posts, posts by the
+// users they follow and posts in the communities they are a member of
+type HomeTimeline interface {
+	// Adds the post to the home feeds that it belongs in
+	AddPost(ctx context.Context, postID uint) error
+	// Removes the post from the home feeds that it was added to
+	RemovePost(ctx context.Context, postID uint) error
+	// Adds the recent posts of a newly followed user to the user's home feed
+	BackfillFollowee(ctx context.Context, userID string, followeeID string) error
+	// Adds the recent posts of a newly joined community to the user's home feed
+	BackfillCommunity(ctx context.Context, userID string, communityID uint) error
+	// Returns the IDs of up to count of the newest posts before the cutoff in the user's
+	// home feed, newest first. If cutoff is null, the posts start from the newest post.
+	GetTimeline(ctx context.Context, userID string, cutoff *helpers.NullableUint, count int) ([]uint, error)
+}
+
+func (a *APIEnv) InitialiseHomeTimeline(timeline HomeTimeline) {
+	a.HomeTimeline = timeline
+}
+
+const (
+	// Most posts kept in each user's timeline; older posts drop out of the home feed
+	timelineLength = 800
+	// Posts by users with at least this many followers, or in communities with at least
+	// this many members, are not fanned out to every follower or member. They are pulled
+	// into home feeds when these are read instead.
+	timelineFanoutLimit = 5000
+)
+
+func NewRedisHomeTimeline(client *redis.Client, db *gorm.DB) *RedisHomeTimeline {
+	return &RedisHomeTimeline{
+		redisDB:   client,
+		DBHandler: &database.TimelineDB{DB: db},
+	}
+}
+
+// RedisHomeTimeline keeps each user's timeline in a Redis sorted set of post IDs, capped
+// at timelineLength posts. Posts are scored by their ID so that timelines are ordered
+// like the newest posts feed and can be paged through with the same cutoff.
+type RedisHomeTimeline struct {
+	redisDB   *redis.Client
+	DBHandler database.TimelineDBHandler
+}
+
+func timelineKey(userID string) string {
+	return "timeline:" + userID
+}
+
+// Posts that no longer exist at all cannot be in any timeline
+func (t *RedisHomeTimeline) AddPost(ctx context.Context, postID uint) error {
+	userIDs, err := t.DBHandler.GetPostAudience(postID, timelineFanoutLimit)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = t.redisDB.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userID := range userIDs {
+			addToTimeline(ctx, pipe, userID, []uint{postID})
+		}
+		return nil
+	})
+	return err
+}
+
+// The post is removed from the timelines that it would be fanned out to now. Any copies
+// left behind, such as in the timelines of users who have since unfollowed its author,
+// are not shown since deleted posts are not retrieved from the database, and age out of
+// the timelines.
+func (t *RedisHomeTimeline) RemovePost(ctx context.Context, postID uint) error {
+	userIDs, err := t.DBHandler.GetPostAudience(postID, timelineFanoutLimit)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	member := strconv.FormatUint(uint64(postID), 10)
+	_, err = t.redisDB.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userID := range userIDs {
+			pipe.ZRem(ctx, timelineKey(userID), member)
+		}
+		return nil
+	})
+	return err
+}
+
+// Removes posts deleted from the database from the given users' timelines
+func (t *RedisHomeTimeline) RemovePosts(ctx context.Context, userIDs []string, postIDs []uint) error {
+	if len(userIDs) == 0 || len(postIDs) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(postIDs))
+	for i, postID := range postIDs {
+		members[i] = strconv.FormatUint(uint64(postID), 10)
+	}
+	_, err := t.redisDB.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, userID := range userIDs {
+			pipe.ZRem(ctx, timelineKey(userID), members...)
+		}
+		return nil
+	})
+	return err
+}
+
+func (t *RedisHomeTimeline) DeleteTimeline(ctx context.Context, userID string) error {
+	return t.redisDB.Del(ctx, timelineKey(userID)).Err()
+}
+
+func (t *RedisHomeTimeline) BackfillFollowee(ctx context.Context, userID string, followeeID string) error {
+	postIDs, err := t.DBHandler.GetRecentPostIDsByUser(followeeID, timelineLength)
+	if err != nil {
+		return err
+	}
+	return t.backfill(ctx, userID, postIDs)
+}
+
+func (t *RedisHomeTimeline) BackfillCommunity(ctx context.Context, userID string, communityID uint) error {
+	postIDs, err := t.DBHandler.GetRecentPostIDsInCommunity(communityID, timelineLength)
+	if err != nil {
+		return err
+	}
+	return t.backfill(ctx, userID, postIDs)
+}
+
+func (t *RedisHomeTimeline) backfill(ctx context.Context, userID string, postIDs []uint) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+	_, err := t.redisDB.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		addToTimeline(ctx, pipe, userID, postIDs)
+		return nil
+	})
+	return err
+}
+
+// Merges the posts fanned out to the user's timeline with those pulled from the
+// database
+func (t *RedisHomeTimeline) GetTimeline(ctx context.Context, userID string, cutoff *helpers.NullableUint, count int) ([]uint, error) {
+	max := "+inf"
+	if !cutoff.IsNull() {
+		cutoffVal, _ := cutoff.GetValue()
+		max = "(" + strconv.FormatUint(uint64(cutoffVal), 10)
+	}
+	members, err := t.redisDB.ZRevRangeByScore(ctx, timelineKey(userID), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   max,
+		Count: int64(count),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	postIDs, err := t.DBHandler.GetPulledPostIDs(userID, cutoff, timelineFanoutLimit, count)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		postID, err := strconv.ParseUint(member, 10, 32)
+		if err != nil {
+			continue
+		}
+		postIDs = append(postIDs, uint(postID))
+	}
+	return newestPostIDs(postIDs, count), nil
+}
+
+// Adds the posts to the user's timeline, dropping the oldest posts beyond timelineLength
+func addToTimeline(ctx context.Context, pipe redis.Pipeliner, userID string, postIDs []uint) {
+	entries := make([]redis.Z, len(postIDs))
+	for i, postID := range postIDs {
+		entries[i] = redis.Z{Score: float64(postID), Member: strconv.FormatUint(uint64(postID), 10)}
+	}
+	pipe.ZAdd(ctx, timelineKey(userID), entries...)
+	pipe.ZRemRangeByRank(ctx, timelineKey(userID), 0, -timelineLength-1)
+}
+
+// Returns up to count of the distinct post IDs, newest first
+func newestPostIDs(postIDs []uint, count int) []uint {
+	sort.Slice(postIDs, func(i, j int) bool { return postIDs[i] > postIDs[j] })
+	output := []uint{}
+	for i, postID := range postIDs {
+		if i > 0 && postID == postIDs[i-1] {
+			continue
+		}
+		output = append(output, postID)
+		if len(output) == count {
+			break
+		}
+	}
+	return output
+}
+
+// Adds the post to home feeds, logging rather than returning any error
+func (a *APIEnv) addToTimelines(ctx context.Context, postID uint) {
+	if err := a.HomeTimeline.AddPost(ctx, postID); err != nil {
+		log.Printf("Unable to add post %d to home feeds: %v", postID, err)
+	}
+}
+
+// Removes the post from home feeds, logging rather than returning any error
+func (a *APIEnv) removeFromTimelines(ctx context.Context, postID uint) {
+	if err := a.HomeTimeline.RemovePost(ctx, postID); err != nil {
+		log.Printf("Unable to remove post %d from home feeds: %v", postID, err)
+	}
+}
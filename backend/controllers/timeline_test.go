@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Posts fanned out to a timeline may also be pulled from the database, and are only
+// returned once
+func TestNewestPostIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		postIDs  []uint
+		count    int
+		expected []uint
+	}{
+		{"Merged newest first", []uint{7, 3, 9, 5}, 10, []uint{9, 7, 5, 3}},
+		{"Duplicates removed", []uint{9, 5, 9, 7, 5}, 10, []uint{9, 7, 5}},
+		{"Truncated to count", []uint{4, 8, 6, 8, 2}, 2, []uint{8, 6}},
+		{"Empty", []uint{}, 10, []uint{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if output := newestPostIDs(tt.postIDs, tt.count); !reflect.DeepEqual(output, tt.expected) {
+				t.Errorf("newestPostIDs() = %v, want %v", output, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Contains controllers for managing personal access tokens.
+*/
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Messages
+const (
+	TokenDeletedMsg = "Token successfully revoked"
+)
+
+// Errors
+var (
+	ErrBadTokenExpiry      = errors.New("token expiry must be in the future")
+	ErrBadTokenName        = errors.New("token name cannot be empty")
+	ErrBadTokenScopes      = errors.New("invalid token scopes")
+	ErrCannotCreateToken   = errors.New("cannot create token")
+	ErrCannotDeleteToken   = errors.New("cannot revoke token")
+	ErrCannotRetrieveToken = errors.New("cannot retrieve tokens")
+	ErrTokenNotFound       = errors.New("token not found")
+)
+
+func (a *APIEnv) InitialiseTokenHandler() {
+	a.TokenDBHandler = &database.TokenDB{
+		DB: a.DB,
+	}
+}
+
+func (a *APIEnv) CreateToken(ctx *gin.Context) {
+	var input models.PersonalAccessTokenInput
+
+	// If unable to bind JSON in request to the token input, return status code
+	// 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	if strings.TrimSpace(input.Name) == "" {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadTokenName)
+		return
+	}
+	if !helpers.ValidateTokenScopes(input.Scopes) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadTokenScopes)
+		return
+	}
+	if input.ExpiresAt.Valid && !input.ExpiresAt.Time.After(time.Now()) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadTokenExpiry)
+		return
+	}
+
+	token, err := helpers.GenerateAccessToken()
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotCreateToken)
+		return
+	}
+
+	userID := helpers.GetUserIDFromContext(ctx)
+	dbToken, err := a.TokenDBHandler.CreateToken(input.PersonalAccessToken(userID, helpers.HashAccessToken(token)))
+	// If token cannot be created, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotCreateToken)
+		return
+	}
+
+	helpers.OutputData(ctx, models.NewPersonalAccessTokenView{
+		PersonalAccessTokenView: *dbToken.PersonalAccessTokenView(),
+		Token:                   token,
+	})
+}
+
+func (a *APIEnv) GetTokens(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	tokens, err := a.TokenDBHandler.GetTokens(userID)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveToken)
+		return
+	}
+
+	tokenViews := []models.PersonalAccessTokenView{}
+	for _, token := range tokens {
+		tokenViews = append(tokenViews, *token.PersonalAccessTokenView())
+	}
+	helpers.OutputData(ctx, models.PersonalAccessTokenArray{
+		Tokens: tokenViews,
+	})
+}
+
+func (a *APIEnv) DeleteToken(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+
+	// Ensure that tokenID is an unsigned integer
+	tokenID, err := helpers.GetTokenIDFromContext(ctx)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrTokenNotFound)
+		return
+	}
+
+	err = a.TokenDBHandler.DeleteToken(tokenID, userID)
+	// If token cannot be found in the database, return status code 404 Not Found
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrTokenNotFound)
+		return
+	}
+	// If user is not the owner of the token, return status code 403 Forbidden
+	if errors.Is(err, helpers.ErrNotOwner) {
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.ErrNotOwner)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotDeleteToken)
+		return
+	}
+	helpers.OutputMessage(ctx, TokenDeletedMsg)
+}
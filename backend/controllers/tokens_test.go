@@ -0,0 +1,520 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/database"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+const (
+	testTokenID      = 1
+	invalidTokenID   = "badtokenid"
+	testTokenName    = "CI updates"
+	testTokenScopes  = models.ScopeRead + " " + models.ScopeWritePosts
+	unknownScopeName = "write:everything"
+)
+
+var (
+	defaultToken = models.PersonalAccessToken{
+		Model: gorm.Model{
+			ID: testTokenID,
+		},
+		UserID: testUserID,
+		Name:   testTokenName,
+		Scopes: testTokenScopes,
+	}
+	defaultTokenInput = models.PersonalAccessTokenInput{
+		Name:   testTokenName,
+		Scopes: []string{models.ScopeRead, models.ScopeWritePosts},
+	}
+)
+
+type TokenDBTestHandler struct {
+	CreateTokenFunc    func(*models.PersonalAccessToken) (*models.PersonalAccessToken, error)
+	DeleteTokenFunc    func(uint, string) error
+	GetTokenByHashFunc func(string) (*models.PersonalAccessToken, error)
+	GetTokensFunc      func(string) ([]models.PersonalAccessToken, error)
+}
+
+func (h *TokenDBTestHandler) CreateToken(token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+	return h.CreateTokenFunc(token)
+}
+
+func (h *TokenDBTestHandler) DeleteToken(tokenID uint, userID string) error {
+	return h.DeleteTokenFunc(tokenID, userID)
+}
+
+func (h *TokenDBTestHandler) GetTokenByHash(tokenHash string) (*models.PersonalAccessToken, error) {
+	return h.GetTokenByHashFunc(tokenHash)
+}
+
+func (h *TokenDBTestHandler) GetTokens(userID string) ([]models.PersonalAccessToken, error) {
+	return h.GetTokensFunc(userID)
+}
+
+func (h *TokenDBTestHandler) SetMockCreateTokenFunc(err error) {
+	h.CreateTokenFunc = func(token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+		return token, err
+	}
+}
+
+func (h *TokenDBTestHandler) SetMockDeleteTokenFunc(err error) {
+	h.DeleteTokenFunc = func(tokenID uint, userID string) error {
+		return err
+	}
+}
+
+func (h *TokenDBTestHandler) SetMockGetTokensFunc(tokens []models.PersonalAccessToken, err error) {
+	h.GetTokensFunc = func(userID string) ([]models.PersonalAccessToken, error) {
+		return tokens, err
+	}
+}
+
+func TestAPIEnv_InitialiseTokenHandler(t *testing.T) {
+	type fields struct {
+		DB *gorm.DB
+	}
+	tests := []struct {
+		name          string
+		fields        fields
+		expectedEmpty bool
+	}{
+		{
+			"Initialise Token DB OK",
+			fields{
+				DB: &gorm.DB{},
+			},
+			false,
+		},
+		{
+			"No DB OK",
+			fields{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &APIEnv{
+				DB: tt.fields.DB,
+			}
+			a.InitialiseTokenHandler()
+			if tokenDB, ok := a.TokenDBHandler.(*database.TokenDB); ok {
+				if tt.expectedEmpty && tokenDB.DB != nil {
+					t.Error("Token DB contains unexpected DB instance")
+				} else if !tt.expectedEmpty && tokenDB.DB != tt.fields.DB {
+					t.Error("TokenDBHandler not initialised correctly")
+				}
+			} else {
+				t.Error("TokenDBHandler is nil!")
+			}
+		})
+	}
+}
+
+func TestAPIEnv_CreateToken(t *testing.T) {
+	type args struct {
+		ContextParams map[string]interface{}
+		TokenInput    *models.PersonalAccessTokenInput
+		TokenDBError  error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.NewPersonalAccessTokenView]
+	}{
+		{
+			"Create Token bad binding",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+			},
+			helpers.ExpectedJSONOutput[models.NewPersonalAccessTokenView]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadBinding,
+			},
+		},
+		{
+			"Create Token empty name",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				TokenInput: &models.PersonalAccessTokenInput{
+					Name:   "   ",
+					Scopes: []string{models.ScopeRead},
+				},
+			},
+			helpers.ExpectedJSONOutput[models.NewPersonalAccessTokenView]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadTokenName,
+			},
+		},
+		{
+			"Create Token no scopes",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				TokenInput: &models.PersonalAccessTokenInput{
+					Name: testTokenName,
+				},
+			},
+			helpers.ExpectedJSONOutput[models.NewPersonalAccessTokenView]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadTokenScopes,
+			},
+		},
+		{
+			"Create Token unknown scope",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				TokenInput: &models.PersonalAccessTokenInput{
+					Name:   testTokenName,
+					Scopes: []string{models.ScopeRead, unknownScopeName},
+				},
+			},
+			helpers.ExpectedJSONOutput[models.NewPersonalAccessTokenView]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadTokenScopes,
+			},
+		},
+		{
+			"Create Token expiry in the past",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				TokenInput: &models.PersonalAccessTokenInput{
+					Name:      testTokenName,
+					Scopes:    []string{models.ScopeRead},
+					ExpiresAt: null.TimeFrom(time.Now().Add(-time.Hour)),
+				},
+			},
+			helpers.ExpectedJSONOutput[models.NewPersonalAccessTokenView]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadTokenExpiry,
+			},
+		},
+		{
+			"Create Token cannot create",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				TokenInput:   &defaultTokenInput,
+				TokenDBError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.NewPersonalAccessTokenView]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotCreateToken,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := TokenDBTestHandler{}
+			a := &APIEnv{
+				TokenDBHandler: &dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+
+			for paramKey, paramVal := range tt.args.ContextParams {
+				helpers.AddParamsToContext(c, paramKey, paramVal)
+			}
+
+			if tt.args.TokenInput != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.args.TokenInput)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			dbTestHandler.SetMockCreateTokenFunc(tt.args.TokenDBError)
+			a.CreateToken(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+// The token returned by CreateToken is random, so the successful case checks that
+// only the hash of the returned token is passed to the database
+func TestAPIEnv_CreateToken_OK(t *testing.T) {
+	dbTestHandler := TokenDBTestHandler{}
+	a := &APIEnv{
+		TokenDBHandler: &dbTestHandler,
+	}
+	c, w := helpers.CreateTestContextAndRecorder()
+	helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+
+	req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, defaultTokenInput)
+	if err != nil {
+		t.Error(err)
+	}
+	c.Request = req
+
+	var storedToken *models.PersonalAccessToken
+	dbTestHandler.CreateTokenFunc = func(token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+		storedToken = token
+		return token, nil
+	}
+	a.CreateToken(c)
+
+	if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(http.StatusOK, w.Code); !isEqual {
+		t.Fatal(errStr)
+	}
+
+	b, _ := io.ReadAll(w.Body)
+	m, err := helpers.ParseJSONString(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := m["data"].(map[string]interface{})
+	token, _ := data["Token"].(string)
+
+	if !strings.HasPrefix(token, "snt_") {
+		t.Errorf("Unexpected token format: %s", token)
+	}
+	if storedToken.TokenHash != helpers.HashAccessToken(token) {
+		t.Error("Stored hash does not match returned token")
+	}
+	if storedToken.UserID != testUserID || storedToken.Scopes != testTokenScopes {
+		t.Errorf("Token stored with wrong owner or scopes: %v", storedToken)
+	}
+}
+
+func TestAPIEnv_GetTokens(t *testing.T) {
+	type args struct {
+		ContextParams map[string]interface{}
+		TokenDBOutput []models.PersonalAccessToken
+		TokenDBError  error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.PersonalAccessTokenArray]
+	}{
+		{
+			"Get Tokens OK",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				TokenDBOutput: []models.PersonalAccessToken{defaultToken},
+			},
+			helpers.ExpectedJSONOutput[models.PersonalAccessTokenArray]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedData,
+				Data: &models.PersonalAccessTokenArray{
+					Tokens: []models.PersonalAccessTokenView{*defaultToken.PersonalAccessTokenView()},
+				},
+			},
+		},
+		{
+			"Get Tokens no tokens OK",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				TokenDBOutput: []models.PersonalAccessToken{},
+			},
+			helpers.ExpectedJSONOutput[models.PersonalAccessTokenArray]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedData,
+				Data: &models.PersonalAccessTokenArray{
+					Tokens: []models.PersonalAccessTokenView{},
+				},
+			},
+		},
+		{
+			"Get Tokens cannot retrieve",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey: testUserID,
+				},
+				TokenDBError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.PersonalAccessTokenArray]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotRetrieveToken,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := TokenDBTestHandler{}
+			a := &APIEnv{
+				TokenDBHandler: &dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+
+			for paramKey, paramVal := range tt.args.ContextParams {
+				helpers.AddParamsToContext(c, paramKey, paramVal)
+			}
+
+			dbTestHandler.SetMockGetTokensFunc(tt.args.TokenDBOutput, tt.args.TokenDBError)
+			a.GetTokens(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_DeleteToken(t *testing.T) {
+	type args struct {
+		ContextParams map[string]interface{}
+		TokenDBError  error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.PersonalAccessTokenView]
+	}{
+		{
+			"Delete Token OK",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:  testUserID,
+					helpers.TokenIDKey: testTokenID,
+				},
+			},
+			helpers.ExpectedJSONOutput[models.PersonalAccessTokenView]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedMessage,
+				Message:    TokenDeletedMsg,
+			},
+		},
+		{
+			"Delete Token invalid token ID",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:  testUserID,
+					helpers.TokenIDKey: invalidTokenID,
+				},
+			},
+			helpers.ExpectedJSONOutput[models.PersonalAccessTokenView]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrTokenNotFound,
+			},
+		},
+		{
+			"Delete Token not found",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:  testUserID,
+					helpers.TokenIDKey: testTokenID,
+				},
+				TokenDBError: gorm.ErrRecordNotFound,
+			},
+			helpers.ExpectedJSONOutput[models.PersonalAccessTokenView]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrTokenNotFound,
+			},
+		},
+		{
+			"Delete Token not owner",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:  testUserID,
+					helpers.TokenIDKey: testTokenID,
+				},
+				TokenDBError: helpers.ErrNotOwner,
+			},
+			helpers.ExpectedJSONOutput[models.PersonalAccessTokenView]{
+				StatusCode: http.StatusForbidden,
+				JSONType:   helpers.ExpectedError,
+				Error:      helpers.ErrNotOwner,
+			},
+		},
+		{
+			"Delete Token cannot delete",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:  testUserID,
+					helpers.TokenIDKey: testTokenID,
+				},
+				TokenDBError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.PersonalAccessTokenView]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotDeleteToken,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := TokenDBTestHandler{}
+			a := &APIEnv{
+				TokenDBHandler: &dbTestHandler,
+			}
+			c, w := helpers.CreateTestContextAndRecorder()
+
+			for paramKey, paramVal := range tt.args.ContextParams {
+				helpers.AddParamsToContext(c, paramKey, paramVal)
+			}
+
+			dbTestHandler.SetMockDeleteTokenFunc(tt.args.TokenDBError)
+			a.DeleteToken(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -16,9 +19,15 @@ import (
 // Errors
 const (
 	SuccessfulAccountCreationMsg = "Account successfully created and logged in"
-	SuccessfulAccountDeleteMsg   = "User successfully deleted"
+	SuccessfulEmailChangeMsg     = "Email successfully changed"
+	SuccessfulPasswordChangeMsg  = "Password successfully changed"
+	VerificationEmailSentMsg     = "Verification email sent, follow the link in the email to confirm your new email"
 )
 
+// The grace period is given in days
+var AccountDeletionScheduledMsg = fmt.Sprintf("Account scheduled for deletion, log in within %d days to cancel",
+	helpers.AccountDeletionGracePeriod/(24*time.Hour))
+
 // Messages
 var (
 	ErrBadEmail                 = errors.New("invalid email")
@@ -26,8 +35,16 @@ var (
 	ErrCannotDeleteUser         = errors.New("cannot delete user")
 	ErrCannotUpdateUser         = errors.New("cannot update user")
 	ErrCreateAccountNoCookie    = errors.New("account successfully created but cookie not set, please login later")
+	ErrEmailAlreadyExists       = errors.New("email is used by another account")
+	ErrEmailUnchanged           = errors.New("new email is the same as the current email")
+	ErrIncorrectPassword        = errors.New("current password is incorrect")
+	ErrInvalidVerificationToken = errors.New("verification link is invalid or has expired")
+	ErrMissingNewPassword       = errors.New("missing new password")
+	ErrMissingUsername          = errors.New("missing username")
+	ErrVerificationEmailFailed  = errors.New("unable to send verification email")
 	ErrMissingSignupCredentials = errors.New("missing username, password, or email")
 	ErrPasswordEncryptFailed    = errors.New("password encryption failed")
+	ErrReauthenticationRequired = errors.New("enter your current password, or log in again through your identity provider")
 	ErrUserNotFound             = errors.New("user not found")
 	ErrUsernameAlreadyExists    = errors.New("username already exists")
 )
@@ -69,8 +86,9 @@ func (a *APIEnv) CreateUser(ctx *gin.Context) {
 	userCredentials.Password = string(hashedPassword)
 
 	user, err := a.UserDBHandler.CreateUser(userCredentials)
-	// If username already exists, return status code 409 Status Conflict
-	if err == gorm.ErrDuplicatedKey {
+	// If username already exists, or is an old username of another user, return status
+	// code 409 Status Conflict
+	if err == gorm.ErrDuplicatedKey || errors.Is(err, database.ErrUsernameTaken) {
 		helpers.OutputError(ctx, http.StatusConflict, ErrUsernameAlreadyExists)
 		return
 	}
@@ -87,10 +105,22 @@ func (a *APIEnv) CreateUser(ctx *gin.Context) {
 	helpers.OutputMessage(ctx, SuccessfulAccountCreationMsg)
 }
 
-// Deletes user - assuming Delete Account is implemented
+// Deletes user's account after a grace period, during which the user and their content
+// are hidden. The user can cancel the deletion by logging in before the grace period ends.
 func (a *APIEnv) DeleteUser(ctx *gin.Context) {
 	userID := helpers.GetUserIDFromContext(ctx)
-	err := a.UserDBHandler.DeleteUser(userID)
+	var input models.AccountDeletionInput
+
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	if _, ok := a.checkCurrentPassword(ctx, userID, input.Password); !ok {
+		return
+	}
+
+	err := a.UserDBHandler.ScheduleUserDeletion(userID, time.Now().Add(helpers.AccountDeletionGracePeriod))
 	// If user cannot be found in the database return status code 404 Status Not Found
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
@@ -109,7 +139,7 @@ func (a *APIEnv) DeleteUser(ctx *gin.Context) {
 		helpers.OutputError(ctx, http.StatusInternalServerError, ErrSessionClearFailed)
 		return
 	}
-	helpers.OutputMessage(ctx, SuccessfulAccountDeleteMsg)
+	helpers.OutputMessage(ctx, AccountDeletionScheduledMsg)
 }
 
 // Returns user's profile as seen by visitor
@@ -118,12 +148,28 @@ func (a *APIEnv) GetProfile(ctx *gin.Context) {
 	viewerID := helpers.GetUserIDFromContext(ctx)
 
 	user, err := a.UserDBHandler.GetUserByUsername(username)
+	// If the username is no longer in use, it may be a username the user had before
+	// changing it
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user, err = a.UserDBHandler.GetUserByAlias(username)
+	}
 	// If cannot find user in database, return status code 404 Not Found
 	if err != nil {
 		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
 		return
 	}
+	// Users whose accounts are scheduled for deletion are hidden
+	if user.IsPendingDeletion() {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return
+	}
 	profile := user.GetUserView(viewerID)
+	profile.Skills, err = a.SkillDBHandler.GetUserSkills(user.ID, viewerID)
+	// If the user's skills cannot be retrieved, return status code 500 Internal Server Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveSkills)
+		return
+	}
 	helpers.OutputData(ctx, profile)
 }
 
@@ -136,6 +182,11 @@ func (a *APIEnv) GetSelfProfile(ctx *gin.Context) {
 		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
 		return
 	}
+	user.Skills, err = a.SkillDBHandler.GetUserSkills(userID, userID)
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotRetrieveSkills)
+		return
+	}
 	helpers.OutputData(ctx, user)
 }
 
@@ -163,3 +214,198 @@ func (a *APIEnv) UpdateUser(ctx *gin.Context) {
 	}
 	helpers.OutputData(ctx, user)
 }
+
+// Changes user's password; the current password is required
+func (a *APIEnv) UpdatePassword(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	var input models.PasswordChangeInput
+
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	if input.NewPassword == "" {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrMissingNewPassword)
+		return
+	}
+	// If new password does not meet requirements, return status code 400 Bad Request
+	if !helpers.ValidatePassword(input.NewPassword) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadPassword)
+		return
+	}
+
+	if _, ok := a.checkCurrentPassword(ctx, userID, input.CurrentPassword); !ok {
+		return
+	}
+
+	hashedPassword, err := helpers.GenerateHashFromPassword(input.NewPassword)
+	// If password hash cannot be generated, return status code 500 Internal Service Error
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrPasswordEncryptFailed)
+		return
+	}
+	err = a.UserDBHandler.UpdatePassword(userID, string(hashedPassword))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateUser)
+		return
+	}
+	helpers.OutputMessage(ctx, SuccessfulPasswordChangeMsg)
+}
+
+// Starts changing user's email. The email only changes once the user follows the
+// link sent to the new email, which proves that the user owns it.
+func (a *APIEnv) UpdateEmail(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	var input models.EmailChangeInput
+
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	input.Email = strings.TrimSpace(input.Email)
+	if !helpers.ValidateEmail(input.Email) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadEmail)
+		return
+	}
+
+	user, ok := a.checkCurrentPassword(ctx, userID, input.CurrentPassword)
+	if !ok {
+		return
+	}
+	if strings.EqualFold(user.Email, input.Email) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrEmailUnchanged)
+		return
+	}
+	// If another account uses the email, return status code 409 Conflict
+	otherUser, err := a.UserDBHandler.GetUserByEmail(input.Email)
+	if err == nil && otherUser.ID != userID {
+		helpers.OutputError(ctx, http.StatusConflict, ErrEmailAlreadyExists)
+		return
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateUser)
+		return
+	}
+
+	token, tokenHash, err := helpers.GenerateLinkToken()
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateUser)
+		return
+	}
+	_, err = a.UserDBHandler.CreateEmailChange(&models.EmailChange{
+		UserID:    userID,
+		Email:     input.Email,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(helpers.EmailVerificationTTL),
+	})
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateUser)
+		return
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nFollow the link below to confirm your new email for SkillNet. "+
+		"The link expires in %v.\n\n%s\n\nIf you did not request this change, you can ignore this email.",
+		user.Username, helpers.EmailVerificationTTL, helpers.EmailVerificationURL(models.ClientAddress, token))
+	if err := a.Mailer.SendMail(input.Email, "Confirm your new email", body); err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrVerificationEmailFailed)
+		return
+	}
+	helpers.OutputMessage(ctx, VerificationEmailSentMsg)
+}
+
+// Confirms an email change with the token from the verification email. The token
+// identifies the user, so no session is required.
+func (a *APIEnv) VerifyEmail(ctx *gin.Context) {
+	var input models.EmailVerificationInput
+
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil || input.Token == "" {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+
+	_, err := a.UserDBHandler.ConfirmEmailChange(helpers.HashAccessToken(input.Token), time.Now())
+	// If the token does not match an unexpired email change, return status code 400 Bad Request
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrInvalidVerificationToken)
+		return
+	}
+	// If another account has started using the email, return status code 409 Conflict
+	if errors.Is(err, database.ErrEmailTaken) {
+		helpers.OutputError(ctx, http.StatusConflict, ErrEmailAlreadyExists)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateUser)
+		return
+	}
+	helpers.OutputMessage(ctx, SuccessfulEmailChangeMsg)
+}
+
+// Changes user's username. Profile URLs with the old username still lead to the user.
+func (a *APIEnv) UpdateUsername(ctx *gin.Context) {
+	userID := helpers.GetUserIDFromContext(ctx)
+	var input models.UsernameChangeInput
+
+	// If request is badly formatted, return status code 400 Bad Request
+	if err := helpers.BindInput(ctx, &input); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrBadBinding)
+		return
+	}
+	if input.Username == "" {
+		helpers.OutputError(ctx, http.StatusBadRequest, ErrMissingUsername)
+		return
+	}
+	if err := models.ValidateUsername(input.Username); err != nil {
+		helpers.OutputError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := a.UserDBHandler.UpdateUsername(userID, input.Username)
+	// If username is used by another user, return status code 409 Conflict
+	if errors.Is(err, database.ErrUsernameTaken) {
+		helpers.OutputError(ctx, http.StatusConflict, ErrUsernameAlreadyExists)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return
+	}
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusInternalServerError, ErrCannotUpdateUser)
+		return
+	}
+	helpers.OutputData(ctx, user)
+}
+
+// Checks the password entered by the user before changing account settings. If the
+// password is incorrect, an error is written to the response and false is returned.
+// Accounts created through an identity provider have no usable password, so the
+// password may be left empty if the user has just logged in through the provider.
+func (a *APIEnv) checkCurrentPassword(ctx *gin.Context, userID string, password string) (*models.User, bool) {
+	user, err := a.UserDBHandler.GetUserByID(userID)
+	// If cannot find user in database, return status code 404 Not Found
+	if err != nil {
+		helpers.OutputError(ctx, http.StatusNotFound, ErrUserNotFound)
+		return nil, false
+	}
+	if password == "" {
+		if helpers.IsRecentProviderLogin(sessions.Default(ctx), time.Now()) {
+			return user, true
+		}
+		helpers.OutputError(ctx, http.StatusUnauthorized, ErrReauthenticationRequired)
+		return nil, false
+	}
+	// If password does not match, return status code 401 Unauthorised
+	if err := helpers.CheckHashEqualsPassword(user.Password, password); err != nil {
+		helpers.OutputError(ctx, http.StatusUnauthorized, ErrIncorrectPassword)
+		return nil, false
+	}
+	return user, true
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/ryanozx/skillnet/database"
 	"github.com/ryanozx/skillnet/helpers"
@@ -34,6 +35,13 @@ var (
 		ShowTitle:   true,
 		ShowAboutMe: false,
 	}
+	pendingDeletionUser = models.User{
+		ID:              testUserID,
+		UserView:        defaultUserView,
+		UserCredentials: defaultCreds,
+		Email:           testEmail,
+		DeleteAfter:     null.TimeFrom(time.Now().Add(time.Hour)),
+	}
 	defaultUserMinimal = models.UserMinimal{
 		Name: null.NewString("Test User", true),
 		URL:  "http://localhost:3000/profile/testuser",
@@ -42,10 +50,18 @@ var (
 
 type UserDBTestHandler struct {
 	CreateUserFunc        func(database.NewUser) (*models.User, error)
-	DeleteUserFunc        func(string) error
+	DeleteUserFunc        func(string) (*models.PurgedAccount, error)
+	ScheduleDeletionFunc  func(string, time.Time) error
+	CancelDeletionFunc    func(string) error
 	GetUserByIDFunc       func(string) (*models.User, error)
 	GetUserByUsernameFunc func(string) (*models.User, error)
+	GetUserByEmailFunc    func(string) (*models.User, error)
 	UpdateUserFunc        func(*models.User, string) (*models.User, error)
+	UpdatePasswordFunc    func(string, string) error
+	UpdateUsernameFunc    func(string, string) (*models.User, error)
+	GetUserByAliasFunc    func(string) (*models.User, error)
+	CreateEmailChangeFunc func(*models.EmailChange) (*models.EmailChange, error)
+	ConfirmEmailFunc      func(string, time.Time) (*models.User, error)
 }
 
 func (h *UserDBTestHandler) CreateUser(newUser database.NewUser) (*models.User, error) {
@@ -53,9 +69,16 @@ func (h *UserDBTestHandler) CreateUser(newUser database.NewUser) (*models.User,
 	return user, err
 }
 
-func (h *UserDBTestHandler) DeleteUser(id string) error {
-	err := h.DeleteUserFunc(id)
-	return err
+func (h *UserDBTestHandler) DeleteUser(id string) (*models.PurgedAccount, error) {
+	return h.DeleteUserFunc(id)
+}
+
+func (h *UserDBTestHandler) ScheduleUserDeletion(id string, deleteAfter time.Time) error {
+	return h.ScheduleDeletionFunc(id, deleteAfter)
+}
+
+func (h *UserDBTestHandler) CancelUserDeletion(id string) error {
+	return h.CancelDeletionFunc(id)
 }
 
 func (h *UserDBTestHandler) GetUserByID(id string) (*models.User, error) {
@@ -68,13 +91,34 @@ func (h *UserDBTestHandler) GetUserByUsername(username string) (*models.User, er
 	return user, err
 }
 
+func (h *UserDBTestHandler) GetUserByEmail(email string) (*models.User, error) {
+	user, err := h.GetUserByEmailFunc(email)
+	return user, err
+}
+
 func (h *UserDBTestHandler) UpdateUser(user *models.User, id string) (*models.User, error) {
 	updatedUser, err := h.UpdateUserFunc(user, id)
 	return updatedUser, err
 }
 
-func (h *UserDBTestHandler) QueryUser(searchTerm string, limit int) ([]models.SearchResult, error) {
-	return nil, nil
+func (h *UserDBTestHandler) UpdatePassword(id string, hashedPassword string) error {
+	return h.UpdatePasswordFunc(id, hashedPassword)
+}
+
+func (h *UserDBTestHandler) UpdateUsername(id string, username string) (*models.User, error) {
+	return h.UpdateUsernameFunc(id, username)
+}
+
+func (h *UserDBTestHandler) GetUserByAlias(username string) (*models.User, error) {
+	return h.GetUserByAliasFunc(username)
+}
+
+func (h *UserDBTestHandler) CreateEmailChange(change *models.EmailChange) (*models.EmailChange, error) {
+	return h.CreateEmailChangeFunc(change)
+}
+
+func (h *UserDBTestHandler) ConfirmEmailChange(tokenHash string, now time.Time) (*models.User, error) {
+	return h.ConfirmEmailFunc(tokenHash, now)
 }
 
 func (h *UserDBTestHandler) SetMockCreateUserFunc(user *models.User, err error) {
@@ -83,8 +127,14 @@ func (h *UserDBTestHandler) SetMockCreateUserFunc(user *models.User, err error)
 	}
 }
 
-func (h *UserDBTestHandler) SetMockDeleteUserFunc(err error) {
-	h.DeleteUserFunc = func(id string) error {
+func (h *UserDBTestHandler) SetMockScheduleUserDeletionFunc(err error) {
+	h.ScheduleDeletionFunc = func(id string, deleteAfter time.Time) error {
+		return err
+	}
+}
+
+func (h *UserDBTestHandler) SetMockCancelUserDeletionFunc(err error) {
+	h.CancelDeletionFunc = func(id string) error {
 		return err
 	}
 }
@@ -101,12 +151,48 @@ func (h *UserDBTestHandler) SetMockGetUserByUsernameFunc(user *models.User, err
 	}
 }
 
+func (h *UserDBTestHandler) SetMockGetUserByEmailFunc(user *models.User, err error) {
+	h.GetUserByEmailFunc = func(email string) (*models.User, error) {
+		return user, err
+	}
+}
+
 func (h *UserDBTestHandler) SetMockUpdateUserFunc(user *models.User, err error) {
 	h.UpdateUserFunc = func(u *models.User, id string) (*models.User, error) {
 		return user, err
 	}
 }
 
+func (h *UserDBTestHandler) SetMockUpdatePasswordFunc(err error) {
+	h.UpdatePasswordFunc = func(id string, hashedPassword string) error {
+		return err
+	}
+}
+
+func (h *UserDBTestHandler) SetMockUpdateUsernameFunc(user *models.User, err error) {
+	h.UpdateUsernameFunc = func(id string, username string) (*models.User, error) {
+		return user, err
+	}
+}
+
+func (h *UserDBTestHandler) SetMockGetUserByAliasFunc(user *models.User, err error) {
+	h.GetUserByAliasFunc = func(username string) (*models.User, error) {
+		return user, err
+	}
+}
+
+func (h *UserDBTestHandler) SetMockCreateEmailChangeFunc(err error) {
+	h.CreateEmailChangeFunc = func(change *models.EmailChange) (*models.EmailChange, error) {
+		return change, err
+	}
+}
+
+func (h *UserDBTestHandler) SetMockConfirmEmailChangeFunc(user *models.User, err error) {
+	h.ConfirmEmailFunc = func(tokenHash string, now time.Time) (*models.User, error) {
+		return user, err
+	}
+}
+
 func TestAPIEnv_InitialiseUserHandler(t *testing.T) {
 	type fields struct {
 		DB *gorm.DB
@@ -205,6 +291,21 @@ func TestAPIEnv_CreateUser(t *testing.T) {
 				Error:      ErrUsernameAlreadyExists,
 			},
 		},
+		{
+			"Create user username is an old username of another user",
+			args{
+				Username:     testUsername,
+				Password:     testPassword,
+				Email:        testEmail,
+				UserDBOutput: &defaultUser,
+				UserDBError:  database.ErrUsernameTaken,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusConflict,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrUsernameAlreadyExists,
+			},
+		},
 		{
 			"Create user DB throws error",
 			args{
@@ -275,8 +376,9 @@ func TestAPIEnv_CreateUser(t *testing.T) {
 }
 
 func TestAPIEnv_DeleteUser(t *testing.T) {
+	user := userWithPassword(t)
 	type args struct {
-		StoreParams map[string]interface{}
+		Input       *models.AccountDeletionInput
 		UserDBError error
 		StoreError  error
 	}
@@ -288,24 +390,40 @@ func TestAPIEnv_DeleteUser(t *testing.T) {
 		{
 			"Delete User OK",
 			args{
-				StoreParams: map[string]interface{}{
-					helpers.UserIDKey: testUserID,
-				},
+				Input:       &models.AccountDeletionInput{Password: testPassword},
 				UserDBError: nil,
 				StoreError:  nil,
 			},
 			helpers.ExpectedJSONOutput[models.User]{
 				StatusCode: http.StatusOK,
 				JSONType:   helpers.ExpectedMessage,
-				Message:    SuccessfulAccountDeleteMsg,
+				Message:    AccountDeletionScheduledMsg,
+			},
+		},
+		{
+			"Delete User bad request",
+			args{},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadBinding,
+			},
+		},
+		{
+			"Delete User wrong password",
+			args{
+				Input: &models.AccountDeletionInput{Password: "wrongPassword123!"},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusUnauthorized,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrIncorrectPassword,
 			},
 		},
 		{
 			"Delete User not found",
 			args{
-				StoreParams: map[string]interface{}{
-					helpers.UserIDKey: testUserID,
-				},
+				Input:       &models.AccountDeletionInput{Password: testPassword},
 				UserDBError: gorm.ErrRecordNotFound,
 			},
 			helpers.ExpectedJSONOutput[models.User]{
@@ -317,9 +435,7 @@ func TestAPIEnv_DeleteUser(t *testing.T) {
 		{
 			"Delete User cannot delete",
 			args{
-				StoreParams: map[string]interface{}{
-					helpers.UserIDKey: testUserID,
-				},
+				Input:       &models.AccountDeletionInput{Password: testPassword},
 				UserDBError: ErrTest,
 			},
 			helpers.ExpectedJSONOutput[models.User]{
@@ -331,9 +447,7 @@ func TestAPIEnv_DeleteUser(t *testing.T) {
 		{
 			"Delete User cannot clear session",
 			args{
-				StoreParams: map[string]interface{}{
-					helpers.UserIDKey: testUserID,
-				},
+				Input:       &models.AccountDeletionInput{Password: testPassword},
 				UserDBError: nil,
 				StoreError:  ErrTest,
 			},
@@ -354,14 +468,23 @@ func TestAPIEnv_DeleteUser(t *testing.T) {
 			c, w := helpers.CreateTestContextAndRecorder()
 			store := helpers.MakeMockStore()
 			helpers.AddStoreToContext(c, store)
-
-			for paramKey, paramVal := range tt.args.StoreParams {
-				store.Set(paramKey, paramVal)
-			}
-
+			store.Set(helpers.UserIDKey, testUserID)
 			store.SetSaveError(tt.args.StoreError)
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			if tt.args.Input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodDelete, tt.args.Input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
 
-			dbTestHandler.SetMockDeleteUserFunc(tt.args.UserDBError)
+			var deleteAfter time.Time
+			dbTestHandler.SetMockGetUserByIDFunc(user, nil)
+			dbTestHandler.ScheduleDeletionFunc = func(id string, after time.Time) error {
+				deleteAfter = after
+				return tt.args.UserDBError
+			}
 			a.DeleteUser(c)
 
 			b, _ := io.ReadAll(w.Body)
@@ -377,6 +500,15 @@ func TestAPIEnv_DeleteUser(t *testing.T) {
 			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
 				t.Error(errStr)
 			}
+			if tt.expected.StatusCode != http.StatusOK {
+				return
+			}
+			if time.Until(deleteAfter) < helpers.AccountDeletionGracePeriod-time.Minute {
+				t.Errorf("Deletion scheduled for %v, before the end of the grace period", deleteAfter)
+			}
+			if store.Get(helpers.UserIDKey) != nil {
+				t.Error("Session was not cleared")
+			}
 		})
 	}
 }
@@ -387,7 +519,17 @@ func TestAPIEnv_GetProfile(t *testing.T) {
 		ContextParams map[string]interface{}
 		UserDBOutput  *models.User
 		UserDBError   error
+		AliasDBOutput *models.User
+		AliasDBError  error
+		SkillsOutput  []models.UserSkillView
+		SkillsError   error
 	}
+	endorsedSkills := []models.UserSkillView{
+		{ID: 1, Name: "Go", Level: null.IntFrom(4), Endorsements: 3, Endorsed: true},
+		{ID: 2, Name: "PostgreSQL", Endorsements: 1},
+	}
+	profileWithSkills := defaultUser.GetUserView(diffUserID)
+	profileWithSkills.Skills = endorsedSkills
 	tests := []struct {
 		name     string
 		args     args
@@ -425,6 +567,85 @@ func TestAPIEnv_GetProfile(t *testing.T) {
 				Data:       defaultUser.GetUserView(diffUserID),
 			},
 		},
+		{
+			"Get Profile with skills OK",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:   diffUserID,
+					helpers.UsernameKey: testUsername,
+				},
+				UserDBOutput: &defaultUser,
+				SkillsOutput: endorsedSkills,
+			},
+			helpers.ExpectedJSONOutput[models.UserView]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedData,
+				Data:       profileWithSkills,
+			},
+		},
+		{
+			"Get Profile cannot retrieve skills",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:   diffUserID,
+					helpers.UsernameKey: testUsername,
+				},
+				UserDBOutput: &defaultUser,
+				SkillsError:  ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.UserView]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotRetrieveSkills,
+			},
+		},
+		{
+			"Get Profile scheduled for deletion",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:   diffUserID,
+					helpers.UsernameKey: testUsername,
+				},
+				UserDBOutput: &pendingDeletionUser,
+			},
+			helpers.ExpectedJSONOutput[models.UserView]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrUserNotFound,
+			},
+		},
+		{
+			"Get Profile by old username OK",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:   diffUserID,
+					helpers.UsernameKey: "oldusername",
+				},
+				UserDBError:   gorm.ErrRecordNotFound,
+				AliasDBOutput: &defaultUser,
+			},
+			helpers.ExpectedJSONOutput[models.UserView]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedData,
+				Data:       defaultUser.GetUserView(diffUserID),
+			},
+		},
+		{
+			"Get Profile username and alias not found",
+			args{
+				ContextParams: map[string]interface{}{
+					helpers.UserIDKey:   testUserID,
+					helpers.UsernameKey: testUsername,
+				},
+				UserDBError:  gorm.ErrRecordNotFound,
+				AliasDBError: gorm.ErrRecordNotFound,
+			},
+			helpers.ExpectedJSONOutput[models.UserView]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrUserNotFound,
+			},
+		},
 		{
 			"Get Profile not found",
 			args{
@@ -445,8 +666,10 @@ func TestAPIEnv_GetProfile(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			dbTestHandler := &UserDBTestHandler{}
+			skillTestHandler := &SkillDBTestHandler{}
 			a := &APIEnv{
-				UserDBHandler: dbTestHandler,
+				UserDBHandler:  dbTestHandler,
+				SkillDBHandler: skillTestHandler,
 			}
 
 			c, w := helpers.CreateTestContextAndRecorder()
@@ -454,7 +677,9 @@ func TestAPIEnv_GetProfile(t *testing.T) {
 				helpers.AddParamsToContext(c, paramKey, paramVal)
 			}
 
+			skillTestHandler.SetMockGetUserSkillsFunc(tt.args.SkillsOutput, tt.args.SkillsError)
 			dbTestHandler.SetMockGetUserByUsernameFunc(tt.args.UserDBOutput, tt.args.UserDBError)
+			dbTestHandler.SetMockGetUserByAliasFunc(tt.args.AliasDBOutput, tt.args.AliasDBError)
 			a.GetProfile(c)
 
 			b, _ := io.ReadAll(w.Body)
@@ -520,8 +745,10 @@ func TestAPIEnv_GetSelfProfile(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			dbTestHandler := &UserDBTestHandler{}
+			skillTestHandler := &SkillDBTestHandler{}
 			a := &APIEnv{
-				UserDBHandler: dbTestHandler,
+				UserDBHandler:  dbTestHandler,
+				SkillDBHandler: skillTestHandler,
 			}
 
 			c, w := helpers.CreateTestContextAndRecorder()
@@ -531,6 +758,7 @@ func TestAPIEnv_GetSelfProfile(t *testing.T) {
 			}
 
 			dbTestHandler.SetMockGetUserByIDFunc(tt.args.UserDBOutput, tt.args.UserDBError)
+			skillTestHandler.SetMockGetUserSkillsFunc(nil, nil)
 			a.GetSelfProfile(c)
 
 			b, _ := io.ReadAll(w.Body)
@@ -664,3 +892,563 @@ func TestAPIEnv_UpdateUser(t *testing.T) {
 		})
 	}
 }
+
+// Returns the default user with testPassword as their password
+func userWithPassword(t *testing.T) *models.User {
+	hashedPassword, err := helpers.GenerateHashFromPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := defaultUser
+	user.Password = string(hashedPassword)
+	return &user
+}
+
+func TestAPIEnv_UpdatePassword(t *testing.T) {
+	user := userWithPassword(t)
+	const newPassword = "newPassword456?"
+	type args struct {
+		Input           *models.PasswordChangeInput
+		LoginMethod     string
+		GetUserDBError  error
+		UpdateUserError error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.User]
+	}{
+		{
+			"Update password OK",
+			args{
+				Input: &models.PasswordChangeInput{CurrentPassword: testPassword, NewPassword: newPassword},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedMessage,
+				Message:    SuccessfulPasswordChangeMsg,
+			},
+		},
+		{
+			"Update password bad request",
+			args{},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadBinding,
+			},
+		},
+		{
+			"Update password missing new password",
+			args{
+				Input: &models.PasswordChangeInput{CurrentPassword: testPassword},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrMissingNewPassword,
+			},
+		},
+		{
+			"Update password after provider login OK",
+			args{
+				Input:       &models.PasswordChangeInput{NewPassword: newPassword},
+				LoginMethod: helpers.LoginMethodProvider,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedMessage,
+				Message:    SuccessfulPasswordChangeMsg,
+			},
+		},
+		{
+			"Update password missing current password",
+			args{
+				Input:       &models.PasswordChangeInput{NewPassword: newPassword},
+				LoginMethod: helpers.LoginMethodPassword,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusUnauthorized,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrReauthenticationRequired,
+			},
+		},
+		{
+			"Update password weak new password",
+			args{
+				Input: &models.PasswordChangeInput{CurrentPassword: testPassword, NewPassword: "password"},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadPassword,
+			},
+		},
+		{
+			"Update password wrong current password",
+			args{
+				Input: &models.PasswordChangeInput{CurrentPassword: "wrongPassword123!", NewPassword: newPassword},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusUnauthorized,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrIncorrectPassword,
+			},
+		},
+		{
+			"Update password user not found",
+			args{
+				Input:          &models.PasswordChangeInput{CurrentPassword: testPassword, NewPassword: newPassword},
+				GetUserDBError: gorm.ErrRecordNotFound,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrUserNotFound,
+			},
+		},
+		{
+			"Update password cannot update",
+			args{
+				Input:           &models.PasswordChangeInput{CurrentPassword: testPassword, NewPassword: newPassword},
+				UpdateUserError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotUpdateUser,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &UserDBTestHandler{}
+			a := &APIEnv{
+				UserDBHandler: dbTestHandler,
+			}
+
+			c, w := helpers.CreateTestContextAndRecorder()
+			store := helpers.MakeMockStore()
+			helpers.AddStoreToContext(c, store)
+			store.Set(helpers.UserIDKey, testUserID)
+			store.Set(helpers.LoginTimeKey, time.Now().Unix())
+			store.Set(helpers.LoginMethodKey, tt.args.LoginMethod)
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			if tt.args.Input != nil {
+				req, err := helpers.GenerateHttpJSONRequest(http.MethodPatch, tt.args.Input)
+				if err != nil {
+					t.Error(err)
+				}
+				c.Request = req
+			}
+
+			var savedHash string
+			dbTestHandler.SetMockGetUserByIDFunc(user, tt.args.GetUserDBError)
+			dbTestHandler.UpdatePasswordFunc = func(id string, hashedPassword string) error {
+				savedHash = hashedPassword
+				return tt.args.UpdateUserError
+			}
+			a.UpdatePassword(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+			if tt.expected.StatusCode == http.StatusOK && helpers.CheckHashEqualsPassword(savedHash, newPassword) != nil {
+				t.Error("New password was not hashed before saving")
+			}
+		})
+	}
+}
+
+func TestAPIEnv_UpdateEmail(t *testing.T) {
+	helpers.SetEnvVars(t)
+	user := userWithPassword(t)
+	const newEmail = "new@def.com"
+	otherUser := models.User{ID: diffUserID, Email: newEmail}
+	type args struct {
+		Input                  *models.EmailChangeInput
+		EmailDBOutput          *models.User
+		EmailDBError           error
+		CreateEmailChangeError error
+		MailError              error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.User]
+	}{
+		{
+			"Update email OK",
+			args{
+				Input:        &models.EmailChangeInput{CurrentPassword: testPassword, Email: newEmail},
+				EmailDBError: gorm.ErrRecordNotFound,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedMessage,
+				Message:    VerificationEmailSentMsg,
+			},
+		},
+		{
+			"Update email bad email",
+			args{
+				Input: &models.EmailChangeInput{CurrentPassword: testPassword, Email: "not-an-email"},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadEmail,
+			},
+		},
+		{
+			"Update email wrong password",
+			args{
+				Input: &models.EmailChangeInput{CurrentPassword: "wrongPassword123!", Email: newEmail},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusUnauthorized,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrIncorrectPassword,
+			},
+		},
+		{
+			"Update email unchanged",
+			args{
+				Input: &models.EmailChangeInput{CurrentPassword: testPassword, Email: testEmail},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrEmailUnchanged,
+			},
+		},
+		{
+			"Update email used by other account",
+			args{
+				Input:         &models.EmailChangeInput{CurrentPassword: testPassword, Email: newEmail},
+				EmailDBOutput: &otherUser,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusConflict,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrEmailAlreadyExists,
+			},
+		},
+		{
+			"Update email cannot save change",
+			args{
+				Input:                  &models.EmailChangeInput{CurrentPassword: testPassword, Email: newEmail},
+				EmailDBError:           gorm.ErrRecordNotFound,
+				CreateEmailChangeError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotUpdateUser,
+			},
+		},
+		{
+			"Update email cannot send email",
+			args{
+				Input:        &models.EmailChangeInput{CurrentPassword: testPassword, Email: newEmail},
+				EmailDBError: gorm.ErrRecordNotFound,
+				MailError:    ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrVerificationEmailFailed,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &UserDBTestHandler{}
+			mailer := &helpers.TestMailer{}
+			a := &APIEnv{
+				UserDBHandler: dbTestHandler,
+				Mailer:        mailer,
+			}
+
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodPatch, tt.args.Input)
+			if err != nil {
+				t.Error(err)
+			}
+			c.Request = req
+
+			var savedChange *models.EmailChange
+			dbTestHandler.SetMockGetUserByIDFunc(user, nil)
+			dbTestHandler.SetMockGetUserByEmailFunc(tt.args.EmailDBOutput, tt.args.EmailDBError)
+			dbTestHandler.CreateEmailChangeFunc = func(change *models.EmailChange) (*models.EmailChange, error) {
+				savedChange = change
+				return change, tt.args.CreateEmailChangeError
+			}
+			mailer.SetMockSendMailFunc(tt.args.MailError)
+			a.UpdateEmail(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+			if tt.expected.StatusCode != http.StatusOK {
+				return
+			}
+			if savedChange == nil || savedChange.Email != newEmail || savedChange.UserID != testUserID || savedChange.TokenHash == "" {
+				t.Errorf("Email change not saved correctly: %v", savedChange)
+			}
+			if len(mailer.Sent) != 1 || mailer.Sent[0] != newEmail {
+				t.Errorf("Verification email sent to %v, want %s", mailer.Sent, newEmail)
+			}
+		})
+	}
+}
+
+func TestAPIEnv_VerifyEmail(t *testing.T) {
+	const token = "verification-token"
+	type args struct {
+		Input          *models.EmailVerificationInput
+		ConfirmDBError error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.User]
+	}{
+		{
+			"Verify email OK",
+			args{
+				Input: &models.EmailVerificationInput{Token: token},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedMessage,
+				Message:    SuccessfulEmailChangeMsg,
+			},
+		},
+		{
+			"Verify email missing token",
+			args{
+				Input: &models.EmailVerificationInput{},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrBadBinding,
+			},
+		},
+		{
+			"Verify email invalid or expired token",
+			args{
+				Input:          &models.EmailVerificationInput{Token: token},
+				ConfirmDBError: gorm.ErrRecordNotFound,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrInvalidVerificationToken,
+			},
+		},
+		{
+			"Verify email taken since request",
+			args{
+				Input:          &models.EmailVerificationInput{Token: token},
+				ConfirmDBError: database.ErrEmailTaken,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusConflict,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrEmailAlreadyExists,
+			},
+		},
+		{
+			"Verify email cannot update",
+			args{
+				Input:          &models.EmailVerificationInput{Token: token},
+				ConfirmDBError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotUpdateUser,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &UserDBTestHandler{}
+			a := &APIEnv{
+				UserDBHandler: dbTestHandler,
+			}
+
+			c, w := helpers.CreateTestContextAndRecorder()
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodPost, tt.args.Input)
+			if err != nil {
+				t.Error(err)
+			}
+			c.Request = req
+
+			var receivedHash string
+			dbTestHandler.ConfirmEmailFunc = func(tokenHash string, now time.Time) (*models.User, error) {
+				receivedHash = tokenHash
+				return &defaultUser, tt.args.ConfirmDBError
+			}
+			a.VerifyEmail(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+			if tt.expected.StatusCode == http.StatusOK && receivedHash != helpers.HashAccessToken(token) {
+				t.Error("Token was not hashed before lookup")
+			}
+		})
+	}
+}
+
+func TestAPIEnv_UpdateUsername(t *testing.T) {
+	helpers.SetEnvVars(t)
+	type args struct {
+		Input        *models.UsernameChangeInput
+		UserDBOutput *models.User
+		UserDBError  error
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected helpers.ExpectedJSONOutput[models.User]
+	}{
+		{
+			"Update username OK",
+			args{
+				Input:        &models.UsernameChangeInput{Username: "newusername"},
+				UserDBOutput: &defaultUser,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusOK,
+				JSONType:   helpers.ExpectedData,
+				Data:       &defaultUser,
+			},
+		},
+		{
+			"Update username missing username",
+			args{
+				Input: &models.UsernameChangeInput{},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrMissingUsername,
+			},
+		},
+		{
+			"Update username contains whitespace",
+			args{
+				Input: &models.UsernameChangeInput{Username: "new username"},
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusBadRequest,
+				JSONType:   helpers.ExpectedError,
+				Error:      models.ErrUsernameWhitespace,
+			},
+		},
+		{
+			"Update username taken",
+			args{
+				Input:       &models.UsernameChangeInput{Username: "newusername"},
+				UserDBError: database.ErrUsernameTaken,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusConflict,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrUsernameAlreadyExists,
+			},
+		},
+		{
+			"Update username user not found",
+			args{
+				Input:       &models.UsernameChangeInput{Username: "newusername"},
+				UserDBError: gorm.ErrRecordNotFound,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusNotFound,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrUserNotFound,
+			},
+		},
+		{
+			"Update username cannot update",
+			args{
+				Input:       &models.UsernameChangeInput{Username: "newusername"},
+				UserDBError: ErrTest,
+			},
+			helpers.ExpectedJSONOutput[models.User]{
+				StatusCode: http.StatusInternalServerError,
+				JSONType:   helpers.ExpectedError,
+				Error:      ErrCannotUpdateUser,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbTestHandler := &UserDBTestHandler{}
+			a := &APIEnv{
+				UserDBHandler: dbTestHandler,
+			}
+
+			c, w := helpers.CreateTestContextAndRecorder()
+			helpers.AddParamsToContext(c, helpers.UserIDKey, testUserID)
+			req, err := helpers.GenerateHttpJSONRequest(http.MethodPatch, tt.args.Input)
+			if err != nil {
+				t.Error(err)
+			}
+			c.Request = req
+
+			dbTestHandler.SetMockUpdateUsernameFunc(tt.args.UserDBOutput, tt.args.UserDBError)
+			a.UpdateUsername(c)
+
+			b, _ := io.ReadAll(w.Body)
+			if errStr, isEqual := helpers.CheckExpectedStatusCodeEqualsActual(tt.expected.StatusCode, w.Code); !isEqual {
+				t.Error(errStr)
+			}
+
+			m, err := helpers.ParseJSONString(b)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if errStr, isEqual := helpers.CheckExpectedJSONEqualsActual(m, tt.expected); !isEqual {
+				t.Error(errStr)
+			}
+		})
+	}
+}
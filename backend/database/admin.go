@@ -0,0 +1,161 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+const (
+	auditLogsToReturn = 50
+	usersToReturn     = 50
+)
+
+var ErrCannotSuspendAdmin = errors.New("admins cannot be suspended")
+
+type AdminDBHandler interface {
+	CreateAuditLog(*models.AuditLog) error
+	GetAuditLogs(*helpers.NullableUint) ([]models.AuditLog, error)
+	GetUsers(search string, after string) ([]models.User, error)
+	SuspendUser(username string, suspension *models.Suspension, actorID string) (*models.User, error)
+	UnsuspendUser(username string, actorID string) (*models.User, error)
+	RevokeUserSessions(username string, now time.Time, actorID string) (*models.User, error)
+	ResetEmailVerification(username string, actorID string) (*models.User, error)
+	CreateImpersonationToken(username string, token *models.PersonalAccessToken, actorID string) (*models.User, error)
+}
+
+// AdminDB implements AdminDBHandler
+type AdminDB struct {
+	DB *gorm.DB
+}
+
+func (db *AdminDB) CreateAuditLog(entry *models.AuditLog) error {
+	return db.DB.Create(entry).Error
+}
+
+func (db *AdminDB) GetAuditLogs(cutoff *helpers.NullableUint) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	query := db.DB
+	if !cutoff.IsNull() {
+		cutoffVal, _ := cutoff.GetValue()
+		query = query.Where("id < ?", cutoffVal)
+	}
+	err := query.Order("id desc").Limit(auditLogsToReturn).Find(&entries).Error
+	return entries, err
+}
+
+// Retrieves users in order of username, starting after the given username. If search is
+// not empty, only users whose username or email contains it are returned. Unlike other
+// queries, users whose accounts are scheduled for deletion are included.
+func (db *AdminDB) GetUsers(search string, after string) ([]models.User, error) {
+	var users []models.User
+	query := db.DB.Where("username > ?", after)
+	if search != "" {
+		pattern := "%" + escapeLikePattern(strings.ToLower(search)) + "%"
+		query = query.Where("lower(username) LIKE ? OR lower(email) LIKE ?", pattern, pattern)
+	}
+	err := query.Order("username").Limit(usersToReturn).Find(&users).Error
+	return users, err
+}
+
+func (db *AdminDB) SuspendUser(username string, suspension *models.Suspension, actorID string) (*models.User, error) {
+	entry := newUserAuditLog(models.AuditActionSuspend, actorID)
+	entry.Detail = suspension.SuspensionReason.String
+	return db.updateUser(username, entry, func(tx *gorm.DB, user *models.User) error {
+		if models.HasRole(user.Role, models.RoleAdmin) {
+			return ErrCannotSuspendAdmin
+		}
+		user.Suspension = *suspension
+		return tx.Model(user).Select("suspended_at", "suspended_until", "suspension_reason").UpdateColumns(user).Error
+	})
+}
+
+func (db *AdminDB) UnsuspendUser(username string, actorID string) (*models.User, error) {
+	entry := newUserAuditLog(models.AuditActionUnsuspend, actorID)
+	return db.updateUser(username, entry, func(tx *gorm.DB, user *models.User) error {
+		user.Suspension = models.Suspension{}
+		return tx.Model(user).Select("suspended_at", "suspended_until", "suspension_reason").UpdateColumns(user).Error
+	})
+}
+
+// Logs the user out of every session created before now
+func (db *AdminDB) RevokeUserSessions(username string, now time.Time, actorID string) (*models.User, error) {
+	entry := newUserAuditLog(models.AuditActionForceLogout, actorID)
+	return db.updateUser(username, entry, func(tx *gorm.DB, user *models.User) error {
+		user.SessionsRevokedAt = null.TimeFrom(now)
+		return tx.Model(user).UpdateColumn("sessions_revoked_at", user.SessionsRevokedAt).Error
+	})
+}
+
+// Marks the user's email as unverified and cancels their pending email change, if any,
+// invalidating the verification link that was sent. The user has to verify their email
+// again by changing it.
+func (db *AdminDB) ResetEmailVerification(username string, actorID string) (*models.User, error) {
+	entry := newUserAuditLog(models.AuditActionResetVerification, actorID)
+	return db.updateUser(username, entry, func(tx *gorm.DB, user *models.User) error {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.EmailChange{}).Error; err != nil {
+			return err
+		}
+		user.EmailVerified = false
+		return tx.Model(user).UpdateColumn("email_verified", false).Error
+	})
+}
+
+// Creates a token that lets the admin access the user's account, belonging to the user
+// so that it appears in the user's list of tokens
+func (db *AdminDB) CreateImpersonationToken(username string, token *models.PersonalAccessToken, actorID string) (*models.User, error) {
+	entry := newUserAuditLog(models.AuditActionImpersonate, actorID)
+	return db.updateUser(username, entry, func(tx *gorm.DB, user *models.User) error {
+		token.UserID = user.ID
+		if err := tx.Create(token).Error; err != nil {
+			return err
+		}
+		entry.Detail = token.Scopes
+		return nil
+	})
+}
+
+// Assigns a role to the user with the given username, recording the change in the
+// audit log. actorID is empty if the role is assigned from the command line.
+func (db *AdminDB) SetUserRole(username string, role string, actorID string) (*models.User, error) {
+	entry := newUserAuditLog(models.AuditActionPromote, actorID)
+	entry.Detail = role
+	return db.updateUser(username, entry, func(tx *gorm.DB, user *models.User) error {
+		user.Role = role
+		return tx.Model(user).UpdateColumn("role", role).Error
+	})
+}
+
+// Applies update to the user with the given username and writes the audit log entry in
+// the same transaction, so that no change to a user goes unrecorded
+func (db *AdminDB) updateUser(username string, entry *models.AuditLog, update func(*gorm.DB, *models.User) error) (*models.User, error) {
+	user := models.User{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, "username = ?", username).Error; err != nil {
+			return err
+		}
+		if err := update(tx, &user); err != nil {
+			return err
+		}
+		entry.TargetID = user.ID
+		entry.OwnerID = user.ID
+		return tx.Create(entry).Error
+	})
+	return &user, err
+}
+
+func newUserAuditLog(action string, actorID string) *models.AuditLog {
+	entry := models.NewAuditLog(action, models.AuditTargetUser, "")
+	entry.ActorID = actorID
+	return entry
+}
+
+// Escapes the wildcard characters of a LIKE pattern so that they are matched literally
+func escapeLikePattern(pattern string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(pattern)
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// Resetting the email verification must mark the email as unverified, so that the user
+// has to prove that they own it again
+func TestAdminDB_ResetEmailVerification(t *testing.T) {
+	db := newDryRunDB(t)
+	// Nothing is retrieved in dry run mode, so the user is filled in as if it was found
+	err := db.Callback().Query().After("gorm:query").Register("test:user", func(tx *gorm.DB) {
+		if user, ok := tx.Statement.Dest.(*models.User); ok {
+			user.ID = "user-id"
+			user.EmailVerified = true
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates []string
+	var updatedVars []interface{}
+	err = db.Callback().Update().After("gorm:update").Register("test:sql", func(tx *gorm.DB) {
+		updates = append(updates, tx.Statement.SQL.String())
+		updatedVars = append(updatedVars, tx.Statement.Vars...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminDB := &AdminDB{DB: db}
+	user, err := adminDB.ResetEmailVerification("user", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) != 1 || !strings.Contains(updates[0], `SET "email_verified"=$1`) {
+		t.Fatalf("Updates %q do not reset the email verification", updates)
+	}
+	if len(updatedVars) == 0 || updatedVars[0] != false {
+		t.Errorf("Email verification set to %v, want false", updatedVars)
+	}
+	if user.EmailVerified {
+		t.Error("Returned user still has a verified email")
+	}
+}
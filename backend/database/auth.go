@@ -4,4 +4,5 @@ import "github.com/ryanozx/skillnet/models"
 
 type AuthDBHandler interface {
 	GetUserByUsername(string) (*models.User, error)
+	CancelUserDeletion(string) error
 }
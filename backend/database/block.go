@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBlocked            = errors.New("user has blocked or been blocked by the owner of the content")
+	ErrRestrictingOneself = errors.New("users cannot block or mute themselves")
+)
+
+type BlockDBHandler interface {
+	BlockUser(userID string, username string) error
+	UnblockUser(userID string, username string) error
+	GetBlocks(userID string) ([]models.Block, error)
+	MuteUser(userID string, username string) error
+	UnmuteUser(userID string, username string) error
+	GetMutes(userID string) ([]models.Mute, error)
+	IsBlocked(userID string, otherID string) (bool, error)
+}
+
+// BlockDB implements BlockDBHandler
+type BlockDB struct {
+	DB *gorm.DB
+}
+
+// Blocks the user with the given username; blocking a user twice has no effect.
+// Returns gorm.ErrRecordNotFound if there is no such user.
+func (db *BlockDB) BlockUser(userID string, username string) error {
+	target, err := db.findRestrictionTarget(userID, username)
+	if err != nil {
+		return err
+	}
+	block := models.Block{UserID: userID, BlockedID: target.ID}
+	return db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error
+}
+
+// Returns gorm.ErrRecordNotFound if the user is not blocked
+func (db *BlockDB) UnblockUser(userID string, username string) error {
+	target, err := db.findRestrictionTarget(userID, username)
+	if err != nil {
+		return err
+	}
+	result := db.DB.Delete(&models.Block{}, "user_id = ? AND blocked_id = ?", userID, target.ID)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Retrieves the users blocked by the user, most recently blocked first
+func (db *BlockDB) GetBlocks(userID string) ([]models.Block, error) {
+	var blocks []models.Block
+	err := db.DB.Joins("Blocked").Where("blocks.user_id = ?", userID).Order("blocks.created_at desc").Find(&blocks).Error
+	return blocks, err
+}
+
+// Mutes the user with the given username; muting a user twice has no effect. Returns
+// gorm.ErrRecordNotFound if there is no such user.
+func (db *BlockDB) MuteUser(userID string, username string) error {
+	target, err := db.findRestrictionTarget(userID, username)
+	if err != nil {
+		return err
+	}
+	mute := models.Mute{UserID: userID, MutedID: target.ID}
+	return db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&mute).Error
+}
+
+// Returns gorm.ErrRecordNotFound if the user is not muted
+func (db *BlockDB) UnmuteUser(userID string, username string) error {
+	target, err := db.findRestrictionTarget(userID, username)
+	if err != nil {
+		return err
+	}
+	result := db.DB.Delete(&models.Mute{}, "user_id = ? AND muted_id = ?", userID, target.ID)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Retrieves the users muted by the user, most recently muted first
+func (db *BlockDB) GetMutes(userID string) ([]models.Mute, error) {
+	var mutes []models.Mute
+	err := db.DB.Joins("Muted").Where("mutes.user_id = ?", userID).Order("mutes.created_at desc").Find(&mutes).Error
+	return mutes, err
+}
+
+// Returns whether either user has blocked the other
+func (db *BlockDB) IsBlocked(userID string, otherID string) (bool, error) {
+	return blockExists(db.DB, userID, otherID)
+}
+
+func (db *BlockDB) findRestrictionTarget(userID string, username string) (*models.User, error) {
+	target := models.User{}
+	if err := db.DB.Where("username = ?", username).First(&target).Error; err != nil {
+		return nil, err
+	}
+	if target.ID == userID {
+		return nil, ErrRestrictingOneself
+	}
+	return &target, nil
+}
+
+// Returns whether userID and the user identified by otherID have blocked each other in
+// either direction. otherID may be a user ID or a subquery selecting one.
+func blockExists(db *gorm.DB, userID string, otherID interface{}) (bool, error) {
+	var count int64
+	err := db.Model(&models.Block{}).
+		Where("(user_id = ? AND blocked_id = (?)) OR (user_id = (?) AND blocked_id = ?)", userID, otherID, otherID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Returns ErrBlocked if the user and the owner of the post have blocked each other
+func checkNotBlockedByPostOwner(db *gorm.DB, userID string, postID uint) error {
+	postOwner := db.Model(&models.Post{}).Select("user_id").Where("id = ?", postID)
+	blocked, err := blockExists(db, userID, postOwner)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// Excludes rows owned by users who have blocked or been blocked by userID. column is
+// the column holding the ID of the row's owner.
+func notBlockedWith(column string, userID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(fmt.Sprintf("%[1]s NOT IN (SELECT blocked_id FROM blocks WHERE user_id = ?) "+
+			"AND %[1]s NOT IN (SELECT user_id FROM blocks WHERE blocked_id = ?)", column), userID, userID)
+	}
+}
+
+// Excludes rows owned by users muted by userID
+func notMutedBy(column string, userID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(fmt.Sprintf("%s NOT IN (SELECT muted_id FROM mutes WHERE user_id = ?)", column), userID)
+	}
+}
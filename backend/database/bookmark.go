@@ -0,0 +1,203 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const bookmarksToReturn = 10
+
+var ErrCollectionNameTaken = errors.New("user already has a collection with this name")
+
+type BookmarkDBHandler interface {
+	CreateBookmark(userID string, postID uint, collectionID *helpers.NullableUint) error
+	DeleteBookmark(userID string, postID uint) error
+	GetBookmarks(userID string, collectionID *helpers.NullableUint, cutoff *helpers.NullableUint) ([]models.Bookmark, error)
+	GetCollections(userID string) ([]models.BookmarkCollection, error)
+	CreateCollection(userID string, input *models.BookmarkCollectionInput) (*models.BookmarkCollection, error)
+	UpdateCollection(collectionID uint, userID string, input *models.BookmarkCollectionInput) (*models.BookmarkCollection, error)
+	DeleteCollection(collectionID uint, userID string) error
+	GetSharedCollection(token string) (*models.BookmarkCollection, error)
+}
+
+// BookmarkDB implements BookmarkDBHandler
+type BookmarkDB struct {
+	DB *gorm.DB
+}
+
+// Returns gorm.ErrRecordNotFound if the user has no such collection
+func findOwnedCollection(db *gorm.DB, collectionID uint, userID string) (*models.BookmarkCollection, error) {
+	collection := models.BookmarkCollection{}
+	err := db.Where("user_id = ?", userID).First(&collection, collectionID).Error
+	return &collection, err
+}
+
+// Bookmarks the post, or moves the bookmark to the collection if the post has already
+// been bookmarked; bookmarks saved without a collection are moved out of theirs.
+// Returns gorm.ErrRecordNotFound if the post cannot be seen by the user or the user has
+// no such collection.
+func (db *BookmarkDB) CreateBookmark(userID string, postID uint, collectionID *helpers.NullableUint) error {
+	bookmark := models.Bookmark{UserID: userID, PostID: postID}
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var visiblePosts int64
+		err := tx.Model(&models.Post{}).Joins("User").Scopes(ownerIsActive, notHeldUnlessOwnedBy("posts", userID),
+			notBlockedWith("posts.user_id", userID)).Where("posts.id = ?", postID).Count(&visiblePosts).Error
+		if err != nil {
+			return err
+		}
+		if visiblePosts == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if !collectionID.IsNull() {
+			collectionIDVal, _ := collectionID.GetValue()
+			if _, err := findOwnedCollection(tx, collectionIDVal, userID); err != nil {
+				return err
+			}
+			bookmark.CollectionID = null.IntFrom(int64(collectionIDVal))
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"collection_id"}),
+		}).Create(&bookmark).Error
+	})
+}
+
+// Returns gorm.ErrRecordNotFound if the user has not bookmarked the post
+func (db *BookmarkDB) DeleteBookmark(userID string, postID uint) error {
+	result := db.DB.Where("user_id = ? AND post_id = ?", userID, postID).Delete(&models.Bookmark{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Retrieves the user's bookmarks before the cutoff, newest first, leaving out those in
+// other collections if collectionID is set
+func (db *BookmarkDB) GetBookmarks(userID string, collectionID *helpers.NullableUint, cutoff *helpers.NullableUint) ([]models.Bookmark, error) {
+	bookmarks := []models.Bookmark{}
+	query := db.DB.Where("user_id = ?", userID)
+	if !collectionID.IsNull() {
+		collectionIDVal, _ := collectionID.GetValue()
+		query = query.Where("collection_id = ?", collectionIDVal)
+	}
+	if !cutoff.IsNull() {
+		cutoffVal, _ := cutoff.GetValue()
+		query = query.Where("id < ?", cutoffVal)
+	}
+	err := query.Order("id desc").Limit(bookmarksToReturn).Find(&bookmarks).Error
+	return bookmarks, err
+}
+
+func (db *BookmarkDB) GetCollections(userID string) ([]models.BookmarkCollection, error) {
+	collections := []models.BookmarkCollection{}
+	err := db.DB.Where("user_id = ?", userID).Order("name").Find(&collections).Error
+	return collections, err
+}
+
+// Returns ErrCollectionNameTaken if the user already has a collection with the name
+func (db *BookmarkDB) CreateCollection(userID string, input *models.BookmarkCollectionInput) (*models.BookmarkCollection, error) {
+	collection := models.BookmarkCollection{UserID: userID, Name: input.Name}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkCollectionNameFree(tx, userID, input.Name, 0); err != nil {
+			return err
+		}
+		if err := setCollectionSharing(&collection, input.Shared); err != nil {
+			return err
+		}
+		return tx.Create(&collection).Error
+	})
+	return &collection, err
+}
+
+// Renames the collection unless the name is empty, and shares or stops sharing it if
+// Shared is given. Collections that are already shared keep their link. Returns gorm.ErrRecordNotFound
+// if the user has no such collection.
+func (db *BookmarkDB) UpdateCollection(collectionID uint, userID string, input *models.BookmarkCollectionInput) (*models.BookmarkCollection, error) {
+	var collection *models.BookmarkCollection
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		collection, err = findOwnedCollection(tx, collectionID, userID)
+		if err != nil {
+			return err
+		}
+		if input.Name != "" && input.Name != collection.Name {
+			if err := checkCollectionNameFree(tx, userID, input.Name, collectionID); err != nil {
+				return err
+			}
+			collection.Name = input.Name
+		}
+		if err := setCollectionSharing(collection, input.Shared); err != nil {
+			return err
+		}
+		return tx.Select("name", "share_token").Save(collection).Error
+	})
+	return collection, err
+}
+
+// Bookmarks in the collection are kept, outside of any collection. Returns
+// gorm.ErrRecordNotFound if the user has no such collection.
+func (db *BookmarkDB) DeleteCollection(collectionID uint, userID string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := findOwnedCollection(tx, collectionID, userID); err != nil {
+			return err
+		}
+		err := tx.Model(&models.Bookmark{}).Where("collection_id = ?", collectionID).Update("collection_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&models.BookmarkCollection{}, collectionID).Error
+	})
+}
+
+// Retrieves a shared collection along with its owner. Returns gorm.ErrRecordNotFound if
+// no collection is shared with the token or its owner is pending deletion.
+func (db *BookmarkDB) GetSharedCollection(token string) (*models.BookmarkCollection, error) {
+	collection := models.BookmarkCollection{}
+	err := db.DB.Joins("User").Scopes(ownerIsActive).
+		First(&collection, "bookmark_collections.share_token = ?", token).Error
+	return &collection, err
+}
+
+// Returns ErrCollectionNameTaken if the user has a collection other than exceptID with
+// the name
+func checkCollectionNameFree(tx *gorm.DB, userID string, name string, exceptID uint) error {
+	var count int64
+	err := tx.Model(&models.BookmarkCollection{}).
+		Where("user_id = ? AND name = ? AND id <> ?", userID, name, exceptID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCollectionNameTaken
+	}
+	return nil
+}
+
+// Gives the collection a share token if it is being shared, or removes its token if not.
+// Sharing is left unchanged if shared is not given.
+func setCollectionSharing(collection *models.BookmarkCollection, shared null.Bool) error {
+	if !shared.Valid {
+		return nil
+	}
+	if !shared.Bool {
+		collection.ShareToken = null.String{}
+		return nil
+	}
+	if collection.IsShared() {
+		return nil
+	}
+	token, err := helpers.GenerateShareToken()
+	if err != nil {
+		return err
+	}
+	collection.ShareToken = null.StringFrom(token)
+	return nil
+}
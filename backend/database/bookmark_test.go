@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestSetCollectionSharing(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          null.String
+		shared         null.Bool
+		expectedShared bool
+		expectNewToken bool
+	}{
+		// Updates that only rename the collection leave out Shared
+		{"Sharing not given keeps link", null.StringFrom("token"), null.Bool{}, true, false},
+		{"Sharing not given stays private", null.String{}, null.Bool{}, false, false},
+		{"Share private collection", null.String{}, null.BoolFrom(true), true, true},
+		{"Share shared collection keeps link", null.StringFrom("token"), null.BoolFrom(true), true, false},
+		{"Stop sharing", null.StringFrom("token"), null.BoolFrom(false), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := &models.BookmarkCollection{ShareToken: tt.token}
+			if err := setCollectionSharing(collection, tt.shared); err != nil {
+				t.Fatal(err)
+			}
+			if collection.IsShared() != tt.expectedShared {
+				t.Errorf("IsShared() = %v, want %v", collection.IsShared(), tt.expectedShared)
+			}
+			if tt.expectedShared && (collection.ShareToken != tt.token) != tt.expectNewToken {
+				t.Errorf("ShareToken = %v, was %v", collection.ShareToken, tt.token)
+			}
+		})
+	}
+}
@@ -12,10 +12,11 @@ const commentsToReturn = 10
 type CommentsDBHandler interface {
 	CreateComment(*models.Comment) (*models.Comment, error)
 	DeleteComment(uint, string) (uint, error)
-	GetComments(uint, *helpers.NullableUint) ([]models.Comment, error)
+	GetComments(postID uint, cutoff *helpers.NullableUint, userID string) ([]models.Comment, error)
 	GetCommentByID(uint) (*models.Comment, error)
 	UpdateComment(*models.Comment, uint, string) (*models.Comment, error)
 	GetValue(uint) (uint64, error)
+	GetValues([]uint) (map[uint]uint64, error)
 }
 
 // CommentDB implements CommentDBHandler
@@ -23,7 +24,12 @@ type CommentDB struct {
 	DB *gorm.DB
 }
 
+// Creates a comment. Returns ErrBlocked if the commenter and the owner of the post have
+// blocked each other.
 func (db *CommentDB) CreateComment(comment *models.Comment) (*models.Comment, error) {
+	if err := checkNotBlockedByPostOwner(db.DB, comment.UserID, comment.PostID); err != nil {
+		return comment, err
+	}
 	result := db.DB.Create(comment)
 	if result.Error != nil {
 		return comment, result.Error
@@ -43,7 +49,7 @@ func (db *CommentDB) DeleteComment(commentID uint, userID string) (uint, error)
 	return comment.PostID, err
 }
 
-func (db *CommentDB) GetComments(postID uint, cutoff *helpers.NullableUint) ([]models.Comment, error) {
+func (db *CommentDB) GetComments(postID uint, cutoff *helpers.NullableUint, userID string) ([]models.Comment, error) {
 	var comments []models.Comment
 
 	query := db.DB.Where("comments.post_id = ?", postID)
@@ -53,13 +59,14 @@ func (db *CommentDB) GetComments(postID uint, cutoff *helpers.NullableUint) ([]m
 		query = query.Where("comments.id < ?", cutoffVal)
 	}
 
-	query = query.Joins("User").Order("comments.id desc").Limit(commentsToReturn).Find(&comments)
+	query = query.Joins("User").Scopes(ownerIsActive, notHeldUnlessOwnedBy("comments", userID),
+		notBlockedWith("comments.user_id", userID)).Order("comments.id desc").Limit(commentsToReturn).Find(&comments)
 	return comments, query.Error
 }
 
 func (db *CommentDB) GetCommentByID(commentID uint) (*models.Comment, error) {
 	comment := models.Comment{}
-	err := db.DB.Joins("Post").Joins("User").First(&comment, "comments.id = ?", commentID).Error
+	err := db.DB.Joins("Post").Joins("User").Scopes(ownerIsActive).First(&comment, "comments.id = ?", commentID).Error
 	return &comment, err
 }
 
@@ -83,3 +90,8 @@ func (db *CommentDB) GetValue(postID uint) (uint64, error) {
 	result := db.DB.Model(&models.Comment{}).Where("post_id = ?", postID).Count(&count)
 	return uint64(count), result.Error
 }
+
+// Counts the comments of each post in one query
+func (db *CommentDB) GetValues(postIDs []uint) (map[uint]uint64, error) {
+	return countPerPost(db.DB.Model(&models.Comment{}), postIDs)
+}
@@ -1,10 +1,6 @@
 package database
 
 import (
-	"fmt"
-	"os"
-	"strings"
-
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
 	"gorm.io/gorm"
@@ -19,7 +15,8 @@ type CommunityDBHandler interface {
 	GetCommunityByName(name string) (*models.Community, error)
 	GetCommunities(*helpers.NullableUint) ([]models.Community, error)
 	UpdateCommunity(*models.Community, string, string) (*models.Community, error)
-	QueryCommunity(string, int) ([]models.SearchResult, error)
+	JoinCommunity(communityName string, userID string) (*models.Community, error)
+	LeaveCommunity(communityName string, userID string) error
 }
 
 type CommunityDB struct {
@@ -44,19 +41,19 @@ func (db *CommunityDB) GetCommunities(cutoff *helpers.NullableUint) ([]models.Co
 		query = query.Where("communities.id < ?", cutoffVal)
 	}
 
-	query = query.Joins("User").Order("communities.id desc").Limit(communitiesToReturn).Find(&communities)
+	query = query.Joins("User").Scopes(ownerIsActive).Order("communities.id desc").Limit(communitiesToReturn).Find(&communities)
 	return communities, query.Error
 }
 
 func (db *CommunityDB) GetCommunityByID(communityID uint) (*models.Community, error) {
 	community := models.Community{}
-	err := db.DB.Joins("User").First(&community, "communities.id = ?", communityID).Error
+	err := db.DB.Joins("User").Scopes(ownerIsActive).First(&community, "communities.id = ?", communityID).Error
 	return &community, err
 }
 
 func (db *CommunityDB) GetCommunityByName(communityName string) (*models.Community, error) {
 	community := models.Community{}
-	err := db.DB.Joins("User").First(&community, "communities.name = ?", communityName).Error
+	err := db.DB.Joins("User").Scopes(ownerIsActive).First(&community, "communities.name = ?", communityName).Error
 	return &community, err
 }
 
@@ -77,22 +74,40 @@ func (db *CommunityDB) UpdateCommunity(community *models.Community, communityNam
 	return resCommunity, err
 }
 
-func (db *CommunityDB) QueryCommunity(searchTerm string, limit int) ([]models.SearchResult, error) {
-
-	results := []models.SearchResult{}
-	lowerCaseSearchTerm := strings.ToLower(searchTerm) + ":*"
-	tableName := "communities" // replace this with your actual table name
-	query := fmt.Sprintf("to_tsquery('english', '%s') @@ to_tsvector('english', lower(name))", lowerCaseSearchTerm)
-	scoreQuery := fmt.Sprintf("ts_rank(to_tsvector('english', lower(name)), to_tsquery('english', '%s')) as score", lowerCaseSearchTerm)
-	urlPrefix := fmt.Sprintf("CONCAT('%s', '/communities/', name) as url", os.Getenv("FRONTEND_BASE_URL"))
-
-	db.DB.Debug().
-		Table(tableName).
-		Select("name, 'community' as result_type, " + scoreQuery + ", " + urlPrefix).
-		Where(query).
-		Limit(limit).
-		Order("score DESC").
-		Scan(&results)
+// Adds the user to the members of the community and returns the community; joining a
+// community twice has no effect
+func (db *CommunityDB) JoinCommunity(communityName string, userID string) (*models.Community, error) {
+	community, err := db.GetCommunityByName(communityName)
+	if err != nil {
+		return nil, err
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		member := models.CommunityMember{UserID: userID, CommunityID: community.ID}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.Community{}).Where("id = ?", community.ID).
+			UpdateColumn("member_count", gorm.Expr("member_count + 1")).Error
+	})
+	return community, err
+}
 
-	return results, nil
+// Returns gorm.ErrRecordNotFound if the user is not a member of the community
+func (db *CommunityDB) LeaveCommunity(communityName string, userID string) error {
+	community, err := db.GetCommunityByName(communityName)
+	if err != nil {
+		return err
+	}
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.CommunityMember{}, "user_id = ? AND community_id = ?", userID, community.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.Community{}).Where("id = ?", community.ID).
+			UpdateColumn("member_count", gorm.Expr("member_count - 1")).Error
+	})
 }
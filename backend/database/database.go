@@ -55,8 +55,14 @@ func establishConnection(env DataSourceNamer) (*gorm.DB, error) {
 // Performs migration automatically based on schemas specified in method body
 func autoMigrate(database *gorm.DB) {
 	log.Println("Running migrations")
-	database.AutoMigrate(&models.Post{}, &models.User{}, &models.Like{}, &models.Comment{}, &models.Community{}, &models.Project{})
+	database.AutoMigrate(&models.Post{}, &models.User{}, &models.Like{}, &models.Comment{}, &models.Community{}, &models.Project{},
+		&models.PersonalAccessToken{}, &models.UserIdentity{}, &models.UsernameAlias{}, &models.EmailChange{}, &models.DataExport{}, &models.AuditLog{},
+		&models.Report{}, &models.Block{}, &models.Mute{}, &models.Skill{}, &models.SkillAlias{}, &models.UserSkill{}, &models.Endorsement{}, &models.ProjectSkill{},
+		&models.ProjectMembership{}, &models.OpenRole{}, &models.OpenRoleSkill{}, &models.RoleApplication{}, &models.RecommendationDismissal{}, &models.BookmarkCollection{}, &models.Bookmark{}, &models.PostPin{},
+		&models.Follow{}, &models.CommunityMember{})
 	// Add more schemas above as necessary
+	migrateSearch(database)
+	seedSkills(database)
 }
 
 // Pass in an empty string for UTC.
@@ -1,11 +1,60 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
+// Connection that can begin transactions but not run statements, for databases that only
+// build statements
+type dryRunConnPool struct{}
+
+var errDryRun = errors.New("statements are not run in dry run mode")
+
+func (p *dryRunConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errDryRun
+}
+
+func (p *dryRunConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, errDryRun
+}
+
+func (p *dryRunConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errDryRun
+}
+
+func (p *dryRunConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+func (p *dryRunConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return p, nil
+}
+
+func (p *dryRunConnPool) Commit() error {
+	return nil
+}
+
+func (p *dryRunConnPool) Rollback() error {
+	return nil
+}
+
+// Opens a database that builds statements without running them
+func newDryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: &dryRunConnPool{}}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
 type TestSuiteEnv struct {
 	suite.Suite
 }
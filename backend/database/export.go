@@ -0,0 +1,99 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Pending exports older than this are assumed to have been interrupted, for instance by
+// a server restart, so that they do not prevent the user from exporting their data
+const staleExportAge = time.Hour
+
+var ErrExportInProgress = errors.New("an export is already in progress")
+
+type ExportDBHandler interface {
+	CreateExport(*models.DataExport) (*models.DataExport, error)
+	GetReadyExport(string, time.Time) (*models.DataExport, error)
+}
+
+// ExportDB implements ExportDBHandler
+type ExportDB struct {
+	DB *gorm.DB
+}
+
+// Creates a pending export, unless the user already has an export in progress
+func (db *ExportDB) CreateExport(export *models.DataExport) (*models.DataExport, error) {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the user serialises concurrent export requests by the same user
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.User{}, "id = ?", export.UserID).Error; err != nil {
+			return err
+		}
+		pending := tx.Model(&models.DataExport{}).Where("user_id = ? AND status = ?", export.UserID, models.ExportPending)
+		if err := pending.Where("created_at < ?", time.Now().Add(-staleExportAge)).Update("status", models.ExportFailed).Error; err != nil {
+			return err
+		}
+		var inProgress int64
+		if err := tx.Model(&models.DataExport{}).Where("user_id = ? AND status = ?", export.UserID, models.ExportPending).Count(&inProgress).Error; err != nil {
+			return err
+		}
+		if inProgress > 0 {
+			return ErrExportInProgress
+		}
+		export.Status = models.ExportPending
+		return tx.Create(export).Error
+	})
+	return export, err
+}
+
+// Retrieves the ready export with the given download token hash, if the download link
+// has not expired
+func (db *ExportDB) GetReadyExport(tokenHash string, now time.Time) (*models.DataExport, error) {
+	export := models.DataExport{}
+	err := db.DB.First(&export, "token_hash = ? AND status = ? AND expires_at > ?", tokenHash, models.ExportReady, now).Error
+	return &export, err
+}
+
+func (db *ExportDB) MarkExportReady(exportID uint, objectName string, tokenHash string, expiresAt time.Time) error {
+	return db.DB.Model(&models.DataExport{}).Where("id = ?", exportID).Updates(map[string]interface{}{
+		"status":      models.ExportReady,
+		"object_name": objectName,
+		"token_hash":  tokenHash,
+		"expires_at":  expiresAt,
+	}).Error
+}
+
+func (db *ExportDB) MarkExportFailed(exportID uint) error {
+	return db.DB.Model(&models.DataExport{}).Where("id = ?", exportID).Update("status", models.ExportFailed).Error
+}
+
+// Retrieves everything stored about the user, including content they have deleted
+// that has not been purged yet
+func (db *ExportDB) GetUserData(userID string) (*models.UserData, error) {
+	user := models.User{}
+	if err := db.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	data := models.UserData{
+		Profile: *user.ProfileExport(),
+	}
+	if err := db.DB.Unscoped().Where("user_id = ?", userID).Order("id").Find(&data.Posts).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Unscoped().Where("user_id = ?", userID).Order("id").Find(&data.Comments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Model(&models.Like{}).Where("user_id = ?", userID).Order("created_at").Find(&data.Likes).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Where("owner_id = ?", userID).Order("id").Find(&data.Projects).Error; err != nil {
+		return nil, err
+	}
+	if err := db.DB.Unscoped().Where("owner_id = ?", userID).Order("id").Find(&data.Communities).Error; err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrFollowingOneself = errors.New("users cannot follow themselves")
+
+type FollowDBHandler interface {
+	FollowUser(userID string, username string) (*models.User, error)
+	UnfollowUser(userID string, username string) error
+	GetFollowing(userID string) ([]models.Follow, error)
+}
+
+// FollowDB implements FollowDBHandler
+type FollowDB struct {
+	DB *gorm.DB
+}
+
+// Follows the user with the given username and returns them; following a user twice has
+// no effect. Returns gorm.ErrRecordNotFound if there is no such user, and ErrBlocked if
+// either user has blocked the other.
+func (db *FollowDB) FollowUser(userID string, username string) (*models.User, error) {
+	target, err := db.findFollowTarget(userID, username)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := blockExists(db.DB, userID, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		follow := models.Follow{FollowerID: userID, FolloweeID: target.ID}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.User{}).Where("id = ?", target.ID).
+			UpdateColumn("follower_count", gorm.Expr("follower_count + 1")).Error
+	})
+	return target, err
+}
+
+// Returns gorm.ErrRecordNotFound if the user is not followed
+func (db *FollowDB) UnfollowUser(userID string, username string) error {
+	target, err := db.findFollowTarget(userID, username)
+	if err != nil {
+		return err
+	}
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Follow{}, "follower_id = ? AND followee_id = ?", userID, target.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.User{}).Where("id = ?", target.ID).
+			UpdateColumn("follower_count", gorm.Expr("follower_count - 1")).Error
+	})
+}
+
+// Retrieves the users followed by the user, most recently followed first
+func (db *FollowDB) GetFollowing(userID string) ([]models.Follow, error) {
+	var follows []models.Follow
+	err := db.DB.Joins("Followee").Where("follows.follower_id = ?", userID).Order("follows.created_at desc").Find(&follows).Error
+	return follows, err
+}
+
+func (db *FollowDB) findFollowTarget(userID string, username string) (*models.User, error) {
+	target := models.User{}
+	if err := db.DB.Where("username = ?", username).First(&target).Error; err != nil {
+		return nil, err
+	}
+	if target.ID == userID {
+		return nil, ErrFollowingOneself
+	}
+	return &target, nil
+}
+
+// Keeps only the posts that belong in the user's home feed: the user's own posts, posts
+// by users they follow and posts in communities they are a member of
+func inHomeFeedOf(userID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(posts.user_id = ? "+
+			"OR posts.user_id IN (SELECT followee_id FROM follows WHERE follower_id = ?) "+
+			"OR posts.community_id IN (SELECT community_id FROM community_members WHERE user_id = ?))", userID, userID, userID)
+	}
+}
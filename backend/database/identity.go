@@ -0,0 +1,29 @@
+package database
+
+import (
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+type IdentityDBHandler interface {
+	CreateIdentity(*models.UserIdentity) (*models.UserIdentity, error)
+	GetIdentity(issuer string, subject string) (*models.UserIdentity, error)
+}
+
+// IdentityDB implements IdentityDBHandler
+type IdentityDB struct {
+	DB *gorm.DB
+}
+
+func (db *IdentityDB) CreateIdentity(identity *models.UserIdentity) (*models.UserIdentity, error) {
+	err := db.DB.Create(identity).Error
+	return identity, err
+}
+
+// Retrieves the identity, along with the user it is linked to, by the provider's
+// issuer and subject
+func (db *IdentityDB) GetIdentity(issuer string, subject string) (*models.UserIdentity, error) {
+	identity := models.UserIdentity{}
+	err := db.DB.Joins("User").First(&identity, "user_identities.issuer = ? AND user_identities.subject = ?", issuer, subject).Error
+	return &identity, err
+}
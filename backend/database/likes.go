@@ -10,18 +10,25 @@ type LikeAPIHandler interface {
 	CreateLike(*models.Like) (*models.Like, error)
 	DeleteLike(string, uint) error
 	GetValue(uint) (uint64, error)
+	GetValues([]uint) (map[uint]uint64, error)
 	GetLikeByID(string) (*models.Like, error)
 }
 
 type DBValueGetter interface {
 	GetValue(uint) (uint64, error)
+	GetValues([]uint) (map[uint]uint64, error)
 }
 
 type LikeDB struct {
 	DB *gorm.DB
 }
 
+// Creates a like. Returns ErrBlocked if the user and the owner of the post have blocked
+// each other.
 func (db *LikeDB) CreateLike(like *models.Like) (*models.Like, error) {
+	if err := checkNotBlockedByPostOwner(db.DB, like.UserID, like.PostID); err != nil {
+		return like, err
+	}
 	result := db.DB.Create(like)
 	if result.Error != nil {
 		return like, result.Error
@@ -29,9 +36,16 @@ func (db *LikeDB) CreateLike(like *models.Like) (*models.Like, error) {
 	return db.GetLikeByID(like.ID)
 }
 
+// Returns gorm.ErrRecordNotFound if the user has not liked the post
 func (db *LikeDB) DeleteLike(userID string, postID uint) error {
-	err := db.DB.Unscoped().Delete(&models.Like{}, "id = ?", helpers.GenerateLikeID(userID, postID)).Error
-	return err
+	result := db.DB.Unscoped().Delete(&models.Like{}, "id = ?", helpers.GenerateLikeID(userID, postID))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (db *LikeDB) GetValue(postID uint) (uint64, error) {
@@ -40,6 +54,35 @@ func (db *LikeDB) GetValue(postID uint) (uint64, error) {
 	return uint64(count), result.Error
 }
 
+// Counts the likes of each post in one query
+func (db *LikeDB) GetValues(postIDs []uint) (map[uint]uint64, error) {
+	return countPerPost(db.DB.Model(&models.Like{}), postIDs)
+}
+
+// Counts the rows of the query per post. Every post in postIDs is in the returned map,
+// including posts with no rows.
+func countPerPost(query *gorm.DB, postIDs []uint) (map[uint]uint64, error) {
+	counts := make(map[uint]uint64, len(postIDs))
+	if len(postIDs) == 0 {
+		return counts, nil
+	}
+	rows := []struct {
+		PostID uint
+		Count  uint64
+	}{}
+	err := query.Select("post_id, COUNT(*) AS count").Where("post_id IN ?", postIDs).Group("post_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, postID := range postIDs {
+		counts[postID] = 0
+	}
+	for _, row := range rows {
+		counts[row.PostID] = row.Count
+	}
+	return counts, nil
+}
+
 func (db *LikeDB) GetLikeByID(likeID string) (*models.Like, error) {
 	like := models.Like{}
 	err := db.DB.Joins("Post").Joins("User").First(&like, "likes.id = ?", likeID).Error
@@ -0,0 +1,20 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Excludes rows belonging to users whose accounts are scheduled for deletion, so that
+// their content is hidden during the grace period. The query must join the User association.
+func ownerIsActive(db *gorm.DB) *gorm.DB {
+	return db.Where("\"User\".delete_after IS NULL")
+}
+
+// Excludes content held for review by the content filter, unless it belongs to userID
+func notHeldUnlessOwnedBy(table string, userID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(fmt.Sprintf("(%[1]s.held_for_review = false OR %[1]s.user_id = ?)", table), userID)
+	}
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTooManyPins        = errors.New("feed already has the most pinned posts allowed")
+	ErrPostNotInCommunity = errors.New("post does not belong to a community")
+	ErrPostNotInProject   = errors.New("post does not belong to a project")
+	ErrPostNotPinned      = errors.New("post is not pinned to the feed")
+)
+
+// Retrieves the posts pinned to the project feed if projectID is set, or else the
+// community feed, that can be shown to the user, most recently pinned first. Expired
+// pins are left out.
+func (db *PostDB) GetPinnedPosts(communityID *helpers.NullableUint, projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
+	posts := []models.Post{}
+	query := db.DB
+	if !projectID.IsNull() {
+		projectIDVal, _ := projectID.GetValue()
+		query = query.Scopes(pinnedTo(models.PinFeedProject)).Where("posts.project_id = ?", projectIDVal)
+	} else if !communityID.IsNull() {
+		communityIDVal, _ := communityID.GetValue()
+		query = query.Scopes(pinnedTo(models.PinFeedCommunity)).Where("posts.community_id = ?", communityIDVal)
+	} else {
+		return posts, nil
+	}
+	err := query.Joins("User").Scopes(ownerIsActive, notHeldUnlessOwnedBy("posts", userID),
+		notBlockedWith("posts.user_id", userID), notMutedBy("posts.user_id", userID)).
+		Preload("Likes").Preload("Bookmarks", "user_id = ?", userID).
+		Order("post_pins.created_at desc").Limit(helpers.MaxPinnedPosts).Find(&posts).Error
+	return posts, err
+}
+
+/*
+Pins the post to the feed of its community or project, or updates the expiry of the pin
+if the post is already pinned there. Only the community owner or site moderators may pin
+posts to a community feed, and only the project owner may pin posts to a project feed;
+otherwise helpers.ErrNotOwner is returned. Returns ErrTooManyPins if the feed already has
+helpers.MaxPinnedPosts other active pins.
+*/
+func (db *PostDB) PinPost(postID uint, feed string, userID string, siteModerator bool, expiresAt null.Time) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		post, err := findPinnablePost(tx, postID, feed, userID, siteModerator)
+		if err != nil {
+			return err
+		}
+		// Pins to the same feed wait for each other, so that they cannot both be counted
+		// under the limit
+		if err := lockFeed(tx, feed, feedID(post, feed)); err != nil {
+			return err
+		}
+		var activePins int64
+		err = tx.Model(&models.PostPin{}).Scopes(activePin).
+			Joins("JOIN posts ON posts.id = post_pins.post_id AND posts.deleted_at IS NULL").
+			Where("post_pins.feed = ? AND post_pins.post_id <> ?", feed, postID).
+			Where(feedColumn(feed)+" = ?", feedID(post, feed)).
+			Count(&activePins).Error
+		if err != nil {
+			return err
+		}
+		if activePins >= helpers.MaxPinnedPosts {
+			return ErrTooManyPins
+		}
+		pin := models.PostPin{
+			PostID:     postID,
+			Feed:       feed,
+			PinnedByID: userID,
+			ExpiresAt:  expiresAt,
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "post_id"}, {Name: "feed"}},
+			DoUpdates: clause.AssignmentColumns([]string{"pinned_by_id", "expires_at"}),
+		}).Create(&pin).Error
+	})
+}
+
+// Follows the same permissions as PinPost. Returns ErrPostNotPinned if the post is not
+// pinned to the feed.
+func (db *PostDB) UnpinPost(postID uint, feed string, userID string, siteModerator bool) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := findPinnablePost(tx, postID, feed, userID, siteModerator); err != nil {
+			return err
+		}
+		result := tx.Where("post_id = ? AND feed = ?", postID, feed).Delete(&models.PostPin{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPostNotPinned
+		}
+		return nil
+	})
+}
+
+// Returns the post if the user may pin it to the feed, helpers.ErrNotOwner if the user
+// may not, or ErrPostNotInCommunity or ErrPostNotInProject if the post does not belong
+// to a feed of that kind
+func findPinnablePost(tx *gorm.DB, postID uint, feed string, userID string, siteModerator bool) (*models.Post, error) {
+	post := models.Post{}
+	if err := tx.First(&post, postID).Error; err != nil {
+		return nil, err
+	}
+	if feed == models.PinFeedProject {
+		if post.ProjectID == 0 {
+			return nil, ErrPostNotInProject
+		}
+		_, err := findOwnedProject(tx, post.ProjectID, userID)
+		return &post, err
+	}
+	if post.CommunityID == 0 {
+		return nil, ErrPostNotInCommunity
+	}
+	if siteModerator {
+		return &post, nil
+	}
+	var ownedCommunities int64
+	err := tx.Model(&models.Community{}).Where("id = ? AND owner_id = ?", post.CommunityID, userID).
+		Count(&ownedCommunities).Error
+	if err != nil {
+		return nil, err
+	}
+	if ownedCommunities == 0 {
+		return nil, helpers.ErrNotOwner
+	}
+	return &post, nil
+}
+
+// Restricts posts to those with an active pin on the feed
+func pinnedTo(feed string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("JOIN post_pins ON post_pins.post_id = posts.id AND post_pins.feed = ?", feed).Scopes(activePin)
+	}
+}
+
+// Leaves out posts with an active pin on the feed, since they are shown above the rest
+// of the feed
+func notPinnedTo(feed string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("posts.id NOT IN (SELECT post_id FROM post_pins WHERE feed = ? AND (expires_at IS NULL OR expires_at > ?))",
+			feed, time.Now())
+	}
+}
+
+func activePin(db *gorm.DB) *gorm.DB {
+	return db.Where("(post_pins.expires_at IS NULL OR post_pins.expires_at > ?)", time.Now())
+}
+
+// Locks the community or project row of the feed until the transaction ends
+func lockFeed(tx *gorm.DB, feed string, id uint) error {
+	var model interface{} = &models.Community{}
+	if feed == models.PinFeedProject {
+		model = &models.Project{}
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(model, id).Error
+}
+
+func feedColumn(feed string) string {
+	if feed == models.PinFeedProject {
+		return "posts.project_id"
+	}
+	return "posts.community_id"
+}
+
+func feedID(post *models.Post, feed string) uint {
+	if feed == models.PinFeedProject {
+		return post.ProjectID
+	}
+	return post.CommunityID
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+// The feed must be locked before its pins are counted, or two pins made at the same time
+// could both be allowed under the limit
+func TestPostDB_PinPost_LocksFeed(t *testing.T) {
+	db := newDryRunDB(t)
+	var queries []string
+	// Nothing is retrieved in dry run mode, so the post is filled in as if it was found
+	err := db.Callback().Query().After("gorm:query").Register("test:post", func(tx *gorm.DB) {
+		queries = append(queries, tx.Statement.SQL.String())
+		if post, ok := tx.Statement.Dest.(*models.Post); ok {
+			post.ID = 1
+			post.CommunityID = 3
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	postDB := &PostDB{DB: db}
+	if err := postDB.PinPost(1, models.PinFeedCommunity, "moderator", true, null.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	lockIndex, countIndex := -1, -1
+	for i, query := range queries {
+		if strings.Contains(query, `FROM "communities"`) && strings.HasSuffix(query, "FOR UPDATE") {
+			lockIndex = i
+		}
+		if strings.Contains(query, `FROM "post_pins"`) {
+			countIndex = i
+		}
+	}
+	if lockIndex == -1 {
+		t.Fatalf("Queries %q do not lock the community", queries)
+	}
+	if countIndex < lockIndex {
+		t.Errorf("Queries %q count the pins before locking the community", queries)
+	}
+}
@@ -1,8 +1,11 @@
 package database
 
 import (
+	"time"
+
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -14,9 +17,20 @@ type PostDBHandler interface {
 	DeletePost(uint, string) error
 	GetPosts(cutoff *helpers.NullableUint, communityID *helpers.NullableUint, projectID *helpers.NullableUint, userID string) ([]models.Post, error)
 	GetPostByID(uint, string) (*models.Post, error)
+	GetPostsByIDs(postIDs []uint, since time.Time, userID string) ([]models.Post, error)
+	GetFeedPostsByIDs(postIDs []uint, since time.Time, communityID *helpers.NullableUint, projectID *helpers.NullableUint, userID string) ([]models.Post, error)
+	GetHomeFeedPostsByIDs(postIDs []uint, userID string) ([]models.Post, error)
+	GetPinnedPosts(communityID *helpers.NullableUint, projectID *helpers.NullableUint, userID string) ([]models.Post, error)
+	PinPost(postID uint, feed string, userID string, siteModerator bool, expiresAt null.Time) error
+	UnpinPost(postID uint, feed string, userID string, siteModerator bool) error
 	UpdatePost(*models.Post, uint, string) (*models.Post, error)
 }
 
+// PostRankingDBHandler is implemented by PostDB
+type PostRankingDBHandler interface {
+	GetPostRankingStats(postID uint) (*models.PostRankingStats, error)
+}
+
 // PostDB implements PostDBHandler
 type PostDB struct {
 	DB *gorm.DB
@@ -42,6 +56,9 @@ func (db *PostDB) DeletePost(postID uint, userID string) error {
 	return err
 }
 
+// Retrieves a page of posts before the cutoff, newest first. Posts pinned to the community
+// or project feed being filtered for are left out, since they are retrieved separately
+// by GetPinnedPosts.
 func (db *PostDB) GetPosts(cutoff *helpers.NullableUint, communityID *helpers.NullableUint,
 	projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
 	var posts []models.Post
@@ -51,11 +68,11 @@ func (db *PostDB) GetPosts(cutoff *helpers.NullableUint, communityID *helpers.Nu
 	if !projectID.IsNull() {
 		// Check if we should filter for project (e.g. project feed)
 		projectIDVal, _ := projectID.GetValue()
-		query = query.Where("posts.project_id = ?", projectIDVal)
+		query = query.Where("posts.project_id = ?", projectIDVal).Scopes(notPinnedTo(models.PinFeedProject))
 	} else if !communityID.IsNull() {
 		// Check if we should filter for community (e.g. community feed)
 		communityIDVal, _ := communityID.GetValue()
-		query = query.Where("posts.community_id = ?", communityIDVal)
+		query = query.Where("posts.community_id = ?", communityIDVal).Scopes(notPinnedTo(models.PinFeedCommunity))
 	}
 
 	if !cutoff.IsNull() {
@@ -63,22 +80,95 @@ func (db *PostDB) GetPosts(cutoff *helpers.NullableUint, communityID *helpers.Nu
 		query = query.Where("posts.id < ?", cutoffVal)
 	}
 
-	query = query.Joins("User").Preload("Likes").
+	query = query.Joins("User").Scopes(ownerIsActive, notHeldUnlessOwnedBy("posts", userID),
+		notBlockedWith("posts.user_id", userID), notMutedBy("posts.user_id", userID)).
+		Preload("Likes").Preload("Bookmarks", "user_id = ?", userID).
 		Joins("LEFT JOIN likes ON (posts.ID = likes.post_id AND likes.user_id = ?)", userID).
 		Order("posts.id desc").Limit(postsToReturn).Find(&posts)
 
 	return posts, query.Error
 }
 
+// Retrieves the posts among postIDs that can be shown to the user, leaving out posts
+// created before since unless it is zero. Posts are returned in no particular order.
+func (db *PostDB) GetPostsByIDs(postIDs []uint, since time.Time, userID string) ([]models.Post, error) {
+	return db.getPostsByIDs(db.DB, postIDs, since, userID)
+}
+
+// Retrieves the posts among postIDs like GetPostsByIDs for a page of a ranked feed.
+// Posts pinned to the community or project feed being filtered for are left out, since
+// they are shown above the first page instead.
+func (db *PostDB) GetFeedPostsByIDs(postIDs []uint, since time.Time, communityID *helpers.NullableUint,
+	projectID *helpers.NullableUint, userID string) ([]models.Post, error) {
+	query := db.DB
+	if !projectID.IsNull() {
+		query = query.Scopes(notPinnedTo(models.PinFeedProject))
+	} else if !communityID.IsNull() {
+		query = query.Scopes(notPinnedTo(models.PinFeedCommunity))
+	}
+	return db.getPostsByIDs(query, postIDs, since, userID)
+}
+
+// Retrieves the posts among postIDs like GetPostsByIDs for a page of the user's home
+// feed. Posts by users that the user no longer follows, or in communities that they have
+// left, are left out until they age out of the user's timeline.
+func (db *PostDB) GetHomeFeedPostsByIDs(postIDs []uint, userID string) ([]models.Post, error) {
+	return db.getPostsByIDs(db.DB.Scopes(inHomeFeedOf(userID)), postIDs, time.Time{}, userID)
+}
+
+func (db *PostDB) getPostsByIDs(query *gorm.DB, postIDs []uint, since time.Time, userID string) ([]models.Post, error) {
+	posts := []models.Post{}
+	if len(postIDs) == 0 {
+		return posts, nil
+	}
+	query = query.Where("posts.id IN ?", postIDs)
+	if !since.IsZero() {
+		query = query.Where("posts.created_at >= ?", since)
+	}
+	err := query.Joins("User").Scopes(ownerIsActive, notHeldUnlessOwnedBy("posts", userID),
+		notBlockedWith("posts.user_id", userID), notMutedBy("posts.user_id", userID)).
+		Preload("Likes").Preload("Bookmarks", "user_id = ?", userID).
+		Joins("LEFT JOIN likes ON (posts.ID = likes.post_id AND likes.user_id = ?)", userID).
+		Find(&posts).Error
+	return posts, err
+}
+
+// Retrieves the like and comment counts of a post along with the feeds that it belongs
+// to. Deleted posts are included so that they can be removed from rankings.
+func (db *PostDB) GetPostRankingStats(postID uint) (*models.PostRankingStats, error) {
+	stats := models.PostRankingStats{}
+	err := db.DB.Unscoped().Model(&models.Post{}).
+		Select("posts.id, posts.created_at, posts.community_id, posts.project_id, "+
+			"posts.deleted_at IS NOT NULL AS deleted, "+
+			"(SELECT COUNT(*) FROM likes WHERE likes.post_id = posts.id) AS like_count, "+
+			"(SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL) AS comment_count").
+		Where("posts.id = ?", postID).
+		Take(&stats).Error
+	return &stats, err
+}
+
+// Returns the IDs of up to limit posts after the given ID, in order of ID, so that
+// every post can be visited in batches
+func (db *PostDB) GetPostIDs(after uint, limit int) ([]uint, error) {
+	var postIDs []uint
+	err := db.DB.Model(&models.Post{}).
+		Where("id > ?", after).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &postIDs).Error
+	return postIDs, err
+}
+
+// Retrieves a post. If userID is empty, held posts and posts by users blocked by or
+// blocking the user are also returned, since the post is not being shown to a user.
 func (db *PostDB) GetPostByID(postID uint, userID string) (*models.Post, error) {
 	post := models.Post{}
-	query := db.DB.Joins("User").First(&post, postID)
-	var err error
-	if userID == "" {
-		err = query.Error
-	} else {
-		err = query.Preload("Likes").Joins("LEFT JOIN likes ON (posts.ID = likes.post_id AND likes.user_id = ?)", userID).Error
+	query := db.DB.Joins("User").Scopes(ownerIsActive)
+	if userID != "" {
+		query = query.Scopes(notHeldUnlessOwnedBy("posts", userID), notBlockedWith("posts.user_id", userID)).
+			Preload("Likes").Preload("Bookmarks", "user_id = ?", userID)
 	}
+	err := query.First(&post, postID).Error
 	return &post, err
 }
 
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// The likes and bookmarks of a post must be preloaded by the query that retrieves it,
+// so that Liked and Bookmarked can be set in its view
+func TestPostDB_GetPostByIDPreloads(t *testing.T) {
+	tests := []struct {
+		name             string
+		userID           string
+		expectedPreloads []string
+	}{
+		{"Shown to user", "user", []string{"Bookmarks", "Likes"}},
+		{"Not shown to user", "", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newDryRunDB(t)
+			preloads := []string{}
+			err := db.Callback().Query().Before("gorm:query").Register("test:preloads", func(tx *gorm.DB) {
+				for name := range tx.Statement.Preloads {
+					preloads = append(preloads, name)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			postDB := &PostDB{DB: db}
+			postDB.GetPostByID(1, tt.userID)
+
+			if len(preloads) != len(tt.expectedPreloads) {
+				t.Fatalf("Preloaded %v, want %v", preloads, tt.expectedPreloads)
+			}
+			for _, expected := range tt.expectedPreloads {
+				found := false
+				for _, preload := range preloads {
+					found = found || preload == expected
+				}
+				if !found {
+					t.Errorf("Preloaded %v, want %v", preloads, tt.expectedPreloads)
+				}
+			}
+		})
+	}
+}
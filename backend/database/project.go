@@ -1,9 +1,7 @@
 package database
 
 import (
-	"fmt"
-	"os"
-	"strings"
+	"sort"
 
 	"github.com/ryanozx/skillnet/helpers"
 	"github.com/ryanozx/skillnet/models"
@@ -13,13 +11,25 @@ import (
 
 const projectsToReturn = 10
 
+// Selects the skills that each project needs: the skills it requires and the skills
+// that its open roles require
+const projectNeededSkills = "SELECT project_id, skill_id FROM project_skills WHERE kind = @required " +
+	"UNION SELECT open_roles.project_id, open_role_skills.skill_id FROM open_role_skills " +
+	"JOIN open_roles ON open_roles.id = open_role_skills.open_role_id WHERE open_roles.status = @open"
+
+var projectNeededSkillsParams = map[string]interface{}{
+	"required": models.ProjectSkillRequired,
+	"open":     models.OpenRoleOpen,
+}
+
 type ProjectDBHandler interface {
 	CreateProject(*models.Project) (*models.Project, error)
 	DeleteProject(uint, string) error
 	GetProjectByID(uint) (*models.Project, error)
-	GetProjects(cutoff *helpers.NullableUint, communityID *helpers.NullableUint, username string) ([]models.Project, error)
+	GetProjects(cutoff *helpers.NullableUint, communityID *helpers.NullableUint, username string, skills []string) ([]models.Project, error)
 	UpdateProject(*models.Project, uint, string) (*models.Project, error)
-	QueryProject(searchTerm string, limit int) ([]models.SearchResult, error)
+	SetProjectSkills(projectID uint, userID string, input *models.ProjectSkillsInput) (*models.Project, error)
+	GetProjectsMatchingSkills(userID string) ([]models.ProjectMatch, error)
 }
 
 type ProjectDB struct {
@@ -46,7 +56,9 @@ func (db *ProjectDB) DeleteProject(projectID uint, userID string) error {
 	return err
 }
 
-func (db *ProjectDB) GetProjects(cutoff *helpers.NullableUint, communityID *helpers.NullableUint, username string) ([]models.Project, error) {
+// Retrieves projects in the community or owned by the user with the given username,
+// tagged with every skill in skills. Skills are matched by name or alias.
+func (db *ProjectDB) GetProjects(cutoff *helpers.NullableUint, communityID *helpers.NullableUint, username string, skills []string) ([]models.Project, error) {
 	var projects []models.Project
 
 	query := db.DB
@@ -56,7 +68,7 @@ func (db *ProjectDB) GetProjects(cutoff *helpers.NullableUint, communityID *help
 		query = query.Where("projects.id < ?", cutoffVal)
 	}
 
-	query = query.Joins("User")
+	query = query.Joins("User").Scopes(ownerIsActive)
 
 	if !communityID.IsNull() {
 		communityIDVal, _ := communityID.GetValue()
@@ -64,6 +76,7 @@ func (db *ProjectDB) GetProjects(cutoff *helpers.NullableUint, communityID *help
 	} else if username != "" {
 		query = query.Where("\"User\".username = ?", username)
 	}
+	query = query.Scopes(hasSkills(skills, "project_skills", "project_id", "projects.id"))
 
 	query = query.Order("projects.id desc").Limit(projectsToReturn).Find(&projects)
 	return projects, query.Error
@@ -71,7 +84,7 @@ func (db *ProjectDB) GetProjects(cutoff *helpers.NullableUint, communityID *help
 
 func (db *ProjectDB) GetProjectByID(projectID uint) (*models.Project, error) {
 	project := models.Project{}
-	err := db.DB.Joins("User").First(&project, "projects.id = ?", projectID).Error
+	err := db.DB.Joins("User").Scopes(ownerIsActive).Preload("Skills.Skill").Preload("Members.User").First(&project, "projects.id = ?", projectID).Error
 	return &project, err
 }
 
@@ -87,25 +100,147 @@ func (db *ProjectDB) UpdateProject(project *models.Project, projectID uint, user
 	result := db.DB.Model(resProject).Clauses(clause.Returning{}).Where("id = ?", projectID).Updates(project)
 	err = result.Error
 	resProject.User = projectGet.User
+	resProject.Skills = projectGet.Skills
+	resProject.Members = projectGet.Members
 	return resProject, err
 }
 
-func (db *ProjectDB) QueryProject(searchTerm string, limit int) ([]models.SearchResult, error) {
+// Replaces the skills that the project is tagged with, creating skills that do not yet
+// exist. Skills that resolve to the same canonical skill are tagged once, as required if
+// they are in input.Required. Returns helpers.ErrNotOwner if the user does not own the
+// project.
+func (db *ProjectDB) SetProjectSkills(projectID uint, userID string, input *models.ProjectSkillsInput) (*models.Project, error) {
+	project, err := db.GetProjectByID(projectID)
+	if err != nil {
+		return project, err
+	}
+	if err := helpers.CheckUserIsOwner(project, userID); err != nil {
+		return project, err
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		tags := []models.ProjectSkill{}
+		tagged := map[uint]bool{}
+		addTags := func(names []string, kind string) error {
+			for _, name := range names {
+				skill, err := resolveSkill(tx, name)
+				if err != nil {
+					return err
+				}
+				if tagged[skill.ID] {
+					continue
+				}
+				tagged[skill.ID] = true
+				tags = append(tags, models.ProjectSkill{ProjectID: projectID, SkillID: skill.ID, Kind: kind})
+			}
+			return nil
+		}
+		if err := addTags(input.Required, models.ProjectSkillRequired); err != nil {
+			return err
+		}
+		if err := addTags(input.Used, models.ProjectSkillUsed); err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", projectID).Delete(&models.ProjectSkill{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		return tx.Omit(clause.Associations).Create(&tags).Error
+	})
+	if err != nil {
+		return project, err
+	}
+	return db.GetProjectByID(projectID)
+}
 
-	results := []models.SearchResult{}
-	lowerCaseSearchTerm := strings.ToLower(searchTerm) + ":*"
-	tableName := "projects" // replace this with your actual table name
-	query := fmt.Sprintf("to_tsquery('english', '%s') @@ to_tsvector('english', lower(name))", lowerCaseSearchTerm)
-	scoreQuery := fmt.Sprintf("ts_rank(to_tsvector('english', lower(name)), to_tsquery('english', '%s')) as score", lowerCaseSearchTerm)
-	urlPrefix := fmt.Sprintf("CONCAT('%s', '/projects/', id) as url", os.Getenv("FRONTEND_BASE_URL"))
+// Retrieves the projects that need the most skills listed on the user's profile, with
+// the skills that they need and their open roles that need them. A project needs the
+// skills that it requires and the skills that its open roles require. Projects owned
+// by the user or that the user is a member of, owned by users pending deletion or by
+// users who have blocked or been blocked by the user are excluded.
+func (db *ProjectDB) GetProjectsMatchingSkills(userID string) ([]models.ProjectMatch, error) {
+	matches := []models.ProjectMatch{}
+	var skillIDs []uint
+	if err := db.DB.Model(&models.UserSkill{}).Where("user_id = ?", userID).Pluck("skill_id", &skillIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(skillIDs) == 0 {
+		return matches, nil
+	}
 
-	db.DB.Debug().
-		Table(tableName).
-		Select("name, 'project' as result_type, " + scoreQuery + ", " + urlPrefix).
-		Where(query).
-		Limit(limit).
-		Order("score DESC").
-		Scan(&results)
+	neededSkills := db.DB.Raw(projectNeededSkills, projectNeededSkillsParams)
+	memberships := db.DB.Model(&models.ProjectMembership{}).Select("project_id").Where("user_id = ?", userID)
+	var projectIDs []uint
+	err := db.DB.
+		Model(&models.Project{}).
+		Joins("JOIN (?) AS needed_skills ON needed_skills.project_id = projects.id", neededSkills).
+		Joins("JOIN users \"User\" ON \"User\".id = projects.owner_id").
+		Where("needed_skills.skill_id IN ? AND projects.owner_id <> ?", skillIDs, userID).
+		Where("projects.id NOT IN (?)", memberships).
+		Scopes(ownerIsActive, notBlockedWith("projects.owner_id", userID)).
+		Group("projects.id").
+		Order("COUNT(*) DESC, projects.id DESC").
+		Limit(projectsToReturn).
+		Pluck("projects.id", &projectIDs).Error
+	if err != nil || len(projectIDs) == 0 {
+		return matches, err
+	}
 
-	return results, nil
+	projects := []models.Project{}
+	err = db.DB.
+		Joins("Community").
+		Preload("Skills.Skill").
+		Preload("OpenRoles", "status = ?", models.OpenRoleOpen).
+		Preload("OpenRoles.Skills.Skill").
+		Find(&projects, "projects.id IN ?", projectIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	projectsByID := map[uint]*models.Project{}
+	for i := range projects {
+		projectsByID[projects[i].ID] = &projects[i]
+	}
+	hasSkill := map[uint]bool{}
+	for _, skillID := range skillIDs {
+		hasSkill[skillID] = true
+	}
+	// Keep the order of projectIDs, most matched skills first
+	for _, projectID := range projectIDs {
+		project, ok := projectsByID[projectID]
+		if !ok {
+			continue
+		}
+		match := models.ProjectMatch{
+			ProjectMinimal: *project.GetProjectMinimal(),
+			MatchedSkills:  []models.Skill{},
+			OpenRoles:      []models.OpenRoleView{},
+		}
+		matched := map[uint]bool{}
+		addMatchedSkills := func(skills []models.Skill) bool {
+			found := false
+			for _, skill := range skills {
+				if !hasSkill[skill.ID] {
+					continue
+				}
+				found = true
+				if !matched[skill.ID] {
+					matched[skill.ID] = true
+					match.MatchedSkills = append(match.MatchedSkills, skill)
+				}
+			}
+			return found
+		}
+		addMatchedSkills(project.SkillsOfKind(models.ProjectSkillRequired))
+		for _, role := range project.OpenRoles {
+			if addMatchedSkills(role.RequiredSkills()) {
+				match.OpenRoles = append(match.OpenRoles, *role.OpenRoleView())
+			}
+		}
+		sort.Slice(match.MatchedSkills, func(i, j int) bool {
+			return match.MatchedSkills[i].Name < match.MatchedSkills[j].Name
+		})
+		matches = append(matches, match)
+	}
+	return matches, nil
 }
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Recent activity counts towards popularity
+const popularityWindow = 30 * 24 * time.Hour
+
+var ErrUnknownRecommendationKind = errors.New("unknown recommendation kind")
+
+type RecommendationDBHandler interface {
+	GetRecommendationCandidates(userID string, kind string, limit int) ([]models.RecommendationCandidate, error)
+	GetActiveUserIDs(after string, limit int) ([]string, error)
+	DismissRecommendation(*models.RecommendationDismissal) error
+	GetUserIDByUsername(username string) (string, error)
+	GetRecommendedUsers(viewerID string, userIDs []string) ([]models.User, error)
+	GetRecommendedCommunities(communityIDs []uint) ([]models.Community, error)
+	GetRecommendedProjects(viewerID string, projectIDs []uint) ([]models.Project, error)
+}
+
+// RecommendationDB implements RecommendationDBHandler
+type RecommendationDB struct {
+	DB *gorm.DB
+}
+
+// Communities that each user takes part in, by owning them or posting in them
+const communityParticipation = "(SELECT user_id, community_id FROM posts WHERE deleted_at IS NULL AND held_for_review = false " +
+	"UNION SELECT owner_id, id FROM communities WHERE deleted_at IS NULL)"
+
+// Other users who liked the same posts as the user, with how many such posts
+const sharedLikers = "(SELECT other.user_id, COUNT(*) AS n FROM likes mine " +
+	"JOIN likes other ON other.post_id = mine.post_id AND other.user_id <> mine.user_id " +
+	"WHERE mine.user_id = @user GROUP BY other.user_id)"
+
+// Excludes candidates owned by column that the user has blocked, muted or been blocked by
+const notBlockedOrMuted = "%[1]s NOT IN (SELECT blocked_id FROM blocks WHERE user_id = @user) " +
+	"AND %[1]s NOT IN (SELECT user_id FROM blocks WHERE blocked_id = @user) " +
+	"AND %[1]s NOT IN (SELECT muted_id FROM mutes WHERE user_id = @user)"
+
+// Each query selects the signals for every candidate of its kind that the user does
+// not already own or take part in
+var recommendationCandidates = map[string]string{
+	models.RecommendationUser: "SELECT users.id AS target_id, " +
+		"COALESCE(skills.n, 0) AS shared_skills, COALESCE(communities.n, 0) AS shared_communities, " +
+		"COALESCE(likers.n, 0) AS shared_likes, COALESCE(popularity.n, 0) AS popularity " +
+		"FROM users " +
+		"LEFT JOIN (SELECT other.user_id, COUNT(*) AS n FROM user_skills mine " +
+		"JOIN user_skills other ON other.skill_id = mine.skill_id WHERE mine.user_id = @user GROUP BY other.user_id) AS skills " +
+		"ON skills.user_id = users.id " +
+		"LEFT JOIN (SELECT other.user_id, COUNT(DISTINCT other.community_id) AS n FROM " + communityParticipation + " AS mine " +
+		"JOIN " + communityParticipation + " AS other ON other.community_id = mine.community_id " +
+		"WHERE mine.user_id = @user GROUP BY other.user_id) AS communities ON communities.user_id = users.id " +
+		"LEFT JOIN " + sharedLikers + " AS likers ON likers.user_id = users.id " +
+		"LEFT JOIN (SELECT posts.user_id, COUNT(*) AS n FROM likes JOIN posts ON posts.id = likes.post_id " +
+		"WHERE posts.deleted_at IS NULL AND likes.created_at >= @since GROUP BY posts.user_id) AS popularity " +
+		"ON popularity.user_id = users.id " +
+		"WHERE users.id <> @user AND users.delete_after IS NULL AND " + fmt.Sprintf(notBlockedOrMuted, "users.id"),
+	models.RecommendationCommunity: "SELECT CAST(communities.id AS text) AS target_id, " +
+		"COALESCE(skills.n, 0) AS shared_skills, COALESCE(comembers.n, 0) AS shared_communities, " +
+		"COALESCE(likers.n, 0) AS shared_likes, COALESCE(popularity.n, 0) AS popularity " +
+		"FROM communities " +
+		// Skills that projects in the community need
+		"LEFT JOIN (SELECT projects.community_id, COUNT(DISTINCT needed.skill_id) AS n FROM projects " +
+		"JOIN (" + projectNeededSkills + ") AS needed ON needed.project_id = projects.id " +
+		"WHERE needed.skill_id IN (SELECT skill_id FROM user_skills WHERE user_id = @user) GROUP BY projects.community_id) AS skills " +
+		"ON skills.community_id = communities.id " +
+		// Users who take part in the community and in a community that the user takes part in
+		"LEFT JOIN (SELECT community_id, COUNT(DISTINCT user_id) AS n FROM " + communityParticipation + " AS participants " +
+		"WHERE user_id IN (SELECT other.user_id FROM " + communityParticipation + " AS mine " +
+		"JOIN " + communityParticipation + " AS other ON other.community_id = mine.community_id " +
+		"WHERE mine.user_id = @user AND other.user_id <> @user) GROUP BY community_id) AS comembers " +
+		"ON comembers.community_id = communities.id " +
+		// Users who post in the community and liked the same posts as the user
+		"LEFT JOIN (SELECT posts.community_id, COUNT(DISTINCT posts.user_id) AS n FROM posts " +
+		"JOIN " + sharedLikers + " AS likers ON likers.user_id = posts.user_id " +
+		"WHERE posts.deleted_at IS NULL GROUP BY posts.community_id) AS likers ON likers.community_id = communities.id " +
+		"LEFT JOIN (SELECT community_id, COUNT(DISTINCT user_id) AS n FROM posts " +
+		"WHERE deleted_at IS NULL AND created_at >= @since GROUP BY community_id) AS popularity " +
+		"ON popularity.community_id = communities.id " +
+		"WHERE communities.deleted_at IS NULL " +
+		"AND communities.id NOT IN (SELECT community_id FROM " + communityParticipation + " AS mine WHERE user_id = @user) AND " +
+		fmt.Sprintf(notBlockedOrMuted, "communities.owner_id"),
+	models.RecommendationProject: "SELECT CAST(projects.id AS text) AS target_id, " +
+		"COALESCE(skills.n, 0) AS shared_skills, " +
+		"CASE WHEN projects.community_id IN (SELECT community_id FROM " + communityParticipation + " AS mine " +
+		"WHERE user_id = @user) THEN 1 ELSE 0 END AS shared_communities, " +
+		"COALESCE(likers.n, 0) AS shared_likes, COALESCE(popularity.n, 0) + COALESCE(members.n, 0) AS popularity " +
+		"FROM projects " +
+		"JOIN users \"User\" ON \"User\".id = projects.owner_id " +
+		"LEFT JOIN (SELECT project_id, COUNT(DISTINCT skill_id) AS n FROM (" + projectNeededSkills + ") AS needed " +
+		"WHERE skill_id IN (SELECT skill_id FROM user_skills WHERE user_id = @user) GROUP BY project_id) AS skills " +
+		"ON skills.project_id = projects.id " +
+		// The owner and members of the project who liked the same posts as the user
+		"LEFT JOIN (SELECT team.project_id, COUNT(*) AS n FROM " +
+		"(SELECT id AS project_id, owner_id AS user_id FROM projects UNION SELECT project_id, user_id FROM project_memberships) AS team " +
+		"JOIN " + sharedLikers + " AS likers ON likers.user_id = team.user_id GROUP BY team.project_id) AS likers " +
+		"ON likers.project_id = projects.id " +
+		"LEFT JOIN (SELECT project_id, COUNT(*) AS n FROM project_memberships GROUP BY project_id) AS members " +
+		"ON members.project_id = projects.id " +
+		"LEFT JOIN (SELECT posts.project_id, COUNT(*) AS n FROM likes JOIN posts ON posts.id = likes.post_id " +
+		"WHERE posts.deleted_at IS NULL AND likes.created_at >= @since GROUP BY posts.project_id) AS popularity " +
+		"ON popularity.project_id = projects.id " +
+		"WHERE projects.owner_id <> @user AND \"User\".delete_after IS NULL " +
+		"AND projects.id NOT IN (SELECT project_id FROM project_memberships WHERE user_id = @user) AND " +
+		fmt.Sprintf(notBlockedOrMuted, "projects.owner_id"),
+}
+
+// Scores candidates by the weighted sum of their signals
+var recommendationScore = fmt.Sprintf("%g * shared_skills + %g * shared_communities + %g * shared_likes + %g * LN(1 + popularity)",
+	models.WeightSharedSkills, models.WeightSharedCommunities, models.WeightSharedLikes, models.WeightPopularity)
+
+// Retrieves the best scoring candidates of the kind for the user, excluding those that
+// the user has dismissed and those with no signals at all
+func (db *RecommendationDB) GetRecommendationCandidates(userID string, kind string, limit int) ([]models.RecommendationCandidate, error) {
+	query, ok := recommendationCandidates[kind]
+	if !ok {
+		return nil, ErrUnknownRecommendationKind
+	}
+	params := map[string]interface{}{
+		"user":     userID,
+		"since":    db.DB.NowFunc().Add(-popularityWindow),
+		"required": models.ProjectSkillRequired,
+		"open":     models.OpenRoleOpen,
+	}
+	dismissed := db.DB.Model(&models.RecommendationDismissal{}).Select("target_id").Where("user_id = ? AND kind = ?", userID, kind)
+
+	candidates := []models.RecommendationCandidate{}
+	err := db.DB.
+		Table("(?) AS candidates", db.DB.Raw(query, params)).
+		Select("candidates.*, "+recommendationScore+" AS score").
+		Where("target_id NOT IN (?)", dismissed).
+		Where(recommendationScore + " > 0").
+		Order("score DESC, target_id").
+		Limit(limit).
+		Scan(&candidates).Error
+	return candidates, err
+}
+
+// Retrieves the IDs of users not pending deletion, in order of ID, starting after the
+// given ID
+func (db *RecommendationDB) GetActiveUserIDs(after string, limit int) ([]string, error) {
+	var userIDs []string
+	err := db.DB.Model(&models.User{}).
+		Where("id > ? AND delete_after IS NULL", after).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &userIDs).Error
+	return userIDs, err
+}
+
+// Dismissing a recommendation more than once has no further effect
+func (db *RecommendationDB) DismissRecommendation(dismissal *models.RecommendationDismissal) error {
+	return db.DB.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(dismissal).Error
+}
+
+// Returns gorm.ErrRecordNotFound if there is no user with the username
+func (db *RecommendationDB) GetUserIDByUsername(username string) (string, error) {
+	user := models.User{}
+	err := db.DB.Select("id").Where("username = ?", username).First(&user).Error
+	return user.ID, err
+}
+
+// Retrieves the users that are still active and have not blocked or been blocked by
+// the viewer since they were recommended. Users are returned in no particular order.
+func (db *RecommendationDB) GetRecommendedUsers(viewerID string, userIDs []string) ([]models.User, error) {
+	users := []models.User{}
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	err := db.DB.
+		Where("users.id IN ? AND users.delete_after IS NULL", userIDs).
+		Scopes(notBlockedWith("users.id", viewerID)).
+		Find(&users).Error
+	return users, err
+}
+
+// Retrieves the communities that still exist. Communities are returned in no particular
+// order.
+func (db *RecommendationDB) GetRecommendedCommunities(communityIDs []uint) ([]models.Community, error) {
+	communities := []models.Community{}
+	if len(communityIDs) == 0 {
+		return communities, nil
+	}
+	err := db.DB.Where("id IN ?", communityIDs).Find(&communities).Error
+	return communities, err
+}
+
+// Retrieves the projects whose owners are still active and have not blocked or been
+// blocked by the viewer since they were recommended. Projects are returned in no
+// particular order.
+func (db *RecommendationDB) GetRecommendedProjects(viewerID string, projectIDs []uint) ([]models.Project, error) {
+	projects := []models.Project{}
+	if len(projectIDs) == 0 {
+		return projects, nil
+	}
+	err := db.DB.
+		Joins("User").
+		Joins("Community").
+		Scopes(ownerIsActive, notBlockedWith("projects.owner_id", viewerID)).
+		Where("projects.id IN ?", projectIDs).
+		Find(&projects).Error
+	return projects, err
+}
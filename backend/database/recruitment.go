@@ -0,0 +1,319 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAlreadyApplied       = errors.New("user has already applied to the role")
+	ErrAlreadyMember        = errors.New("user is already a member of the project")
+	ErrApplicationReviewed  = errors.New("application has already been reviewed")
+	ErrApplyingToOwnProject = errors.New("users cannot apply to roles on their own projects")
+	ErrRoleNotOpen          = errors.New("role is not open to applications")
+)
+
+type RecruitmentDBHandler interface {
+	GetOpenRoles(projectID uint) ([]models.OpenRole, error)
+	CreateOpenRole(projectID uint, userID string, input *models.OpenRoleInput) (*models.OpenRole, error)
+	UpdateOpenRole(projectID uint, roleID uint, userID string, input *models.OpenRoleInput) (*models.OpenRole, error)
+	DeleteOpenRole(projectID uint, roleID uint, userID string) error
+	ApplyToRole(projectID uint, roleID uint, applicantID string, message string) (*models.RoleApplication, error)
+	GetProjectApplications(projectID uint, userID string, status string) ([]models.RoleApplication, error)
+	GetUserApplications(userID string) ([]models.RoleApplication, error)
+	ReviewApplication(projectID uint, applicationID uint, userID string, status string) (*models.RoleApplication, error)
+}
+
+// RecruitmentDB implements RecruitmentDBHandler
+type RecruitmentDB struct {
+	DB *gorm.DB
+}
+
+// Returns gorm.ErrRecordNotFound if there is no such project or its owner is pending
+// deletion
+func findProject(db *gorm.DB, projectID uint) (*models.Project, error) {
+	project := models.Project{}
+	err := db.Joins("User").Scopes(ownerIsActive).First(&project, "projects.id = ?", projectID).Error
+	return &project, err
+}
+
+// Returns helpers.ErrNotOwner if the user does not own the project
+func findOwnedProject(db *gorm.DB, projectID uint, userID string) (*models.Project, error) {
+	project, err := findProject(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if err := helpers.CheckUserIsOwner(project, userID); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func findOpenRole(db *gorm.DB, projectID uint, roleID uint) (*models.OpenRole, error) {
+	role := models.OpenRole{}
+	err := db.Preload("Skills.Skill").Where("project_id = ?", projectID).First(&role, roleID).Error
+	return &role, err
+}
+
+// Replaces the skills that the role requires, creating skills that do not yet exist.
+// Skills that resolve to the same canonical skill are tagged once.
+func setOpenRoleSkills(tx *gorm.DB, roleID uint, names []string) error {
+	tags := []models.OpenRoleSkill{}
+	tagged := map[uint]bool{}
+	for _, name := range names {
+		skill, err := resolveSkill(tx, name)
+		if err != nil {
+			return err
+		}
+		if tagged[skill.ID] {
+			continue
+		}
+		tagged[skill.ID] = true
+		tags = append(tags, models.OpenRoleSkill{OpenRoleID: roleID, SkillID: skill.ID})
+	}
+	if err := tx.Where("open_role_id = ?", roleID).Delete(&models.OpenRoleSkill{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tx.Omit(clause.Associations).Create(&tags).Error
+}
+
+// Retrieves the roles posted on the project, open roles first and then newest first.
+// Returns gorm.ErrRecordNotFound if there is no such project.
+func (db *RecruitmentDB) GetOpenRoles(projectID uint) ([]models.OpenRole, error) {
+	if _, err := findProject(db.DB, projectID); err != nil {
+		return nil, err
+	}
+	roles := []models.OpenRole{}
+	err := db.DB.
+		Preload("Skills.Skill").
+		Where("project_id = ?", projectID).
+		Order(clause.Expr{SQL: "status = ? DESC, id DESC", Vars: []interface{}{models.OpenRoleOpen}}).
+		Find(&roles).Error
+	return roles, err
+}
+
+// Posts an open role on the project. Returns helpers.ErrNotOwner if the user does not
+// own the project.
+func (db *RecruitmentDB) CreateOpenRole(projectID uint, userID string, input *models.OpenRoleInput) (*models.OpenRole, error) {
+	if _, err := findOwnedProject(db.DB, projectID, userID); err != nil {
+		return nil, err
+	}
+	role := models.OpenRole{
+		ProjectID:   projectID,
+		Title:       input.Title,
+		Description: input.Description,
+		Commitment:  input.Commitment,
+		Status:      models.OpenRoleOpen,
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&role).Error; err != nil {
+			return err
+		}
+		return setOpenRoleSkills(tx, role.ID, input.Skills)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return findOpenRole(db.DB, projectID, role.ID)
+}
+
+// Replaces the details and skills of the role, keeping its status if input.Status is
+// empty. Returns helpers.ErrNotOwner if the user does not own the project.
+func (db *RecruitmentDB) UpdateOpenRole(projectID uint, roleID uint, userID string, input *models.OpenRoleInput) (*models.OpenRole, error) {
+	if _, err := findOwnedProject(db.DB, projectID, userID); err != nil {
+		return nil, err
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		role, err := findOpenRole(tx, projectID, roleID)
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{
+			"title":       input.Title,
+			"description": input.Description,
+			"commitment":  input.Commitment,
+		}
+		if input.Status != "" {
+			updates["status"] = input.Status
+		}
+		if err := tx.Model(role).Updates(updates).Error; err != nil {
+			return err
+		}
+		return setOpenRoleSkills(tx, roleID, input.Skills)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return findOpenRole(db.DB, projectID, roleID)
+}
+
+// Deletes the role with its applications. Members accepted into the role stay on the
+// project. Returns helpers.ErrNotOwner if the user does not own the project.
+func (db *RecruitmentDB) DeleteOpenRole(projectID uint, roleID uint, userID string) error {
+	if _, err := findOwnedProject(db.DB, projectID, userID); err != nil {
+		return err
+	}
+	result := db.DB.Where("project_id = ?", projectID).Delete(&models.OpenRole{}, roleID)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Applies to the role on behalf of the applicant. Returns the application with the
+// role, its project and the applicant filled in. Returns ErrRoleNotOpen if the role
+// has been filled or closed, ErrApplyingToOwnProject if the applicant owns the
+// project, ErrAlreadyMember if the applicant is already a member, ErrAlreadyApplied
+// if they have applied to the role before and ErrBlocked if the applicant and the
+// owner have blocked each other.
+func (db *RecruitmentDB) ApplyToRole(projectID uint, roleID uint, applicantID string, message string) (*models.RoleApplication, error) {
+	application := models.RoleApplication{
+		OpenRoleID:  roleID,
+		ApplicantID: applicantID,
+		Message:     message,
+		Status:      models.ApplicationPending,
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		project, err := findProject(tx, projectID)
+		if err != nil {
+			return err
+		}
+		role, err := findOpenRole(tx, projectID, roleID)
+		if err != nil {
+			return err
+		}
+		if !role.IsOpen() {
+			return ErrRoleNotOpen
+		}
+		if project.OwnerID == applicantID {
+			return ErrApplyingToOwnProject
+		}
+		blocked, err := blockExists(tx, applicantID, project.OwnerID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrBlocked
+		}
+		var memberships int64
+		if err := tx.Model(&models.ProjectMembership{}).Where("project_id = ? AND user_id = ?", projectID, applicantID).Count(&memberships).Error; err != nil {
+			return err
+		}
+		if memberships > 0 {
+			return ErrAlreadyMember
+		}
+
+		result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&application)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyApplied
+		}
+		if err := tx.First(&application.Applicant, "id = ?", applicantID).Error; err != nil {
+			return err
+		}
+		role.Project = *project
+		application.OpenRole = *role
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &application, nil
+}
+
+// Retrieves the applications to roles on the project, newest first, optionally only
+// those with the given status. Applications by users pending deletion are hidden.
+// Returns helpers.ErrNotOwner if the user does not own the project.
+func (db *RecruitmentDB) GetProjectApplications(projectID uint, userID string, status string) ([]models.RoleApplication, error) {
+	if _, err := findOwnedProject(db.DB, projectID, userID); err != nil {
+		return nil, err
+	}
+	query := db.DB.
+		Joins("Applicant").
+		Preload("OpenRole.Project").
+		Where("role_applications.open_role_id IN (?)", db.DB.Model(&models.OpenRole{}).Select("id").Where("project_id = ?", projectID)).
+		Where("\"Applicant\".delete_after IS NULL")
+	if status != "" {
+		query = query.Where("role_applications.status = ?", status)
+	}
+	applications := []models.RoleApplication{}
+	err := query.Order("role_applications.id DESC").Find(&applications).Error
+	return applications, err
+}
+
+// Retrieves the applications that the user has made, newest first
+func (db *RecruitmentDB) GetUserApplications(userID string) ([]models.RoleApplication, error) {
+	applications := []models.RoleApplication{}
+	err := db.DB.
+		Joins("Applicant").
+		Preload("OpenRole.Project").
+		Where("role_applications.applicant_id = ?", userID).
+		Order("role_applications.id DESC").
+		Find(&applications).Error
+	return applications, err
+}
+
+// Accepts or rejects a pending application to a role on the project. Accepted
+// applicants become members of the project. Returns the application with the role,
+// its project and the applicant filled in. Returns helpers.ErrNotOwner if the user
+// does not own the project, and ErrApplicationReviewed if the application is no
+// longer pending.
+func (db *RecruitmentDB) ReviewApplication(projectID uint, applicationID uint, userID string, status string) (*models.RoleApplication, error) {
+	application := models.RoleApplication{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		project, err := findOwnedProject(tx, projectID, userID)
+		if err != nil {
+			return err
+		}
+		err = tx.
+			Joins("Applicant").
+			Preload("OpenRole").
+			Where("role_applications.open_role_id IN (?)", tx.Model(&models.OpenRole{}).Select("id").Where("project_id = ?", projectID)).
+			Where("\"Applicant\".delete_after IS NULL").
+			First(&application, "role_applications.id = ?", applicationID).Error
+		if err != nil {
+			return err
+		}
+		if !application.IsPending() {
+			return ErrApplicationReviewed
+		}
+
+		// The status is checked again in the update so that concurrent reviews of the
+		// same application cannot both succeed
+		result := tx.Model(&models.RoleApplication{}).
+			Where("id = ? AND status = ?", applicationID, models.ApplicationPending).
+			Updates(map[string]interface{}{"status": status, "reviewed_at": tx.NowFunc()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrApplicationReviewed
+		}
+		application.Status = status
+		application.ReviewedAt.SetValid(tx.NowFunc())
+		application.OpenRole.Project = *project
+
+		if status != models.ApplicationAccepted {
+			return nil
+		}
+		membership := models.ProjectMembership{
+			UserID:    application.ApplicantID,
+			ProjectID: projectID,
+			Role:      application.OpenRole.Title,
+		}
+		return tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&membership).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &application, nil
+}
@@ -0,0 +1,267 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const reportsToReturn = 20
+
+var (
+	ErrAlreadyReported  = errors.New("target has already been reported by this user")
+	ErrCannotRemove     = errors.New("target cannot be removed")
+	ErrReportResolved   = errors.New("report has already been resolved")
+	ErrUnknownTarget    = errors.New("unknown report target")
+	ErrReportingOneself = errors.New("users cannot report themselves or their own content")
+)
+
+type ReportDBHandler interface {
+	CreateReport(*models.Report) (*models.Report, error)
+	GetReports(status string, moderatorID string, siteModerator bool, cutoff *helpers.NullableUint) ([]models.Report, error)
+	ResolveReport(reportID uint, resolution *models.ReportResolution, siteModerator bool) (*models.ModerationResult, error)
+}
+
+// ReportDB implements ReportDBHandler
+type ReportDB struct {
+	DB *gorm.DB
+}
+
+// reportTarget is the owner of the reported content and the community it belongs to
+type reportTarget struct {
+	UserID      string
+	CommunityID null.Int
+}
+
+// Creates a report after finding the owner and community of the reported content.
+// Returns gorm.ErrRecordNotFound if the content does not exist.
+func (db *ReportDB) CreateReport(report *models.Report) (*models.Report, error) {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		target, err := findReportTarget(tx, report.TargetType, report.TargetID)
+		if err != nil {
+			return err
+		}
+		if target.UserID == report.ReporterID {
+			return ErrReportingOneself
+		}
+		report.TargetUserID = target.UserID
+		report.CommunityID = target.CommunityID
+
+		var openReports int64
+		err = tx.Model(&models.Report{}).Where("reporter_id = ? AND target_type = ? AND target_id = ? AND status = ?",
+			report.ReporterID, report.TargetType, report.TargetID, models.ReportOpen).Count(&openReports).Error
+		if err != nil {
+			return err
+		}
+		if openReports > 0 {
+			return ErrAlreadyReported
+		}
+		return tx.Create(report).Error
+	})
+	return report, err
+}
+
+func findReportTarget(tx *gorm.DB, targetType string, targetID string) (*reportTarget, error) {
+	var query *gorm.DB
+	switch targetType {
+	case models.ReportTargetPost:
+		query = tx.Model(&models.Post{}).Select("user_id, community_id").Where("id = ?", targetID)
+	case models.ReportTargetComment:
+		query = tx.Model(&models.Comment{}).Select("comments.user_id, posts.community_id").
+			Joins("JOIN posts ON posts.id = comments.post_id AND posts.deleted_at IS NULL").Where("comments.id = ?", targetID)
+	case models.ReportTargetProject:
+		query = tx.Model(&models.Project{}).Select("owner_id AS user_id, community_id").Where("id = ?", targetID)
+	case models.ReportTargetCommunity:
+		query = tx.Model(&models.Community{}).Select("owner_id AS user_id, id AS community_id").Where("name = ?", targetID)
+	case models.ReportTargetUser:
+		query = tx.Model(&models.User{}).Select("id AS user_id").Where("username = ?", targetID)
+	default:
+		return nil, ErrUnknownTarget
+	}
+	target := reportTarget{}
+	if err := query.Take(&target).Error; err != nil {
+		return nil, err
+	}
+	// Content outside of any community has a community ID of 0
+	if target.CommunityID.Int64 == 0 {
+		target.CommunityID = null.Int{}
+	}
+	return &target, nil
+}
+
+// Retrieves reports with the given status, or all reports if status is empty. Site
+// moderators see every report, while other users only see reports in communities
+// that they own.
+func (db *ReportDB) GetReports(status string, moderatorID string, siteModerator bool, cutoff *helpers.NullableUint) ([]models.Report, error) {
+	var reports []models.Report
+	query := db.DB
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if !siteModerator {
+		ownedCommunities := db.DB.Model(&models.Community{}).Select("id").Where("owner_id = ?", moderatorID)
+		query = query.Where("community_id IN (?)", ownedCommunities)
+	}
+	if !cutoff.IsNull() {
+		cutoffVal, _ := cutoff.GetValue()
+		query = query.Where("id < ?", cutoffVal)
+	}
+	err := query.Order("id desc").Limit(reportsToReturn).Find(&reports).Error
+	return reports, err
+}
+
+/*
+Resolves a report with the moderator's decision, carrying out the action on the
+reported content. Every other open report on the same content is resolved with the
+same decision. Moderators who are not site moderators may only resolve reports in
+communities they own, and may not suspend users; otherwise helpers.ErrNotOwner is
+returned. The decision is written to the audit log.
+*/
+func (db *ReportDB) ResolveReport(reportID uint, resolution *models.ReportResolution, siteModerator bool) (*models.ModerationResult, error) {
+	result := &models.ModerationResult{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		report := models.Report{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&report, reportID).Error; err != nil {
+			return err
+		}
+		if !report.IsOpen() {
+			return ErrReportResolved
+		}
+		if !siteModerator {
+			if err := checkCommunityModerator(tx, &report, resolution); err != nil {
+				return err
+			}
+		}
+
+		switch resolution.Action {
+		case models.ReportActionRemoveContent:
+			if err := removeReportTarget(tx, &report, result); err != nil {
+				return err
+			}
+		case models.ReportActionSuspendUser:
+			if err := suspendReportedUser(tx, &report, resolution); err != nil {
+				return err
+			}
+		}
+		// Content held by the content filter is shown once a moderator decides to keep it
+		if resolution.Action != models.ReportActionRemoveContent {
+			if err := releaseReportTarget(tx, &report); err != nil {
+				return err
+			}
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("target_type = ? AND target_id = ? AND status = ?",
+			report.TargetType, report.TargetID, models.ReportOpen).Find(&result.Reports).Error
+		if err != nil {
+			return err
+		}
+		ids := make([]uint, len(result.Reports))
+		for i := range result.Reports {
+			ids[i] = result.Reports[i].ID
+			result.Reports[i].Status = resolution.Status()
+			result.Reports[i].Action = null.StringFrom(resolution.Action)
+			result.Reports[i].ModeratorID = resolution.ModeratorID
+			result.Reports[i].ResolutionNote = resolution.Note
+			result.Reports[i].ResolvedAt = null.TimeFrom(resolution.ResolvedAt)
+		}
+		err = tx.Model(&models.Report{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":          resolution.Status(),
+			"action":          resolution.Action,
+			"moderator_id":    resolution.ModeratorID,
+			"resolution_note": resolution.Note,
+			"resolved_at":     resolution.ResolvedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		entry := models.NewAuditLog(models.AuditActionModerate, report.TargetType, report.TargetID)
+		entry.ActorID = resolution.ModeratorID
+		entry.OwnerID = report.TargetUserID
+		entry.Detail = fmt.Sprintf("%s (report %d)", resolution.Action, report.ID)
+		return tx.Create(entry).Error
+	})
+	return result, err
+}
+
+func checkCommunityModerator(tx *gorm.DB, report *models.Report, resolution *models.ReportResolution) error {
+	if !report.CommunityID.Valid || resolution.Action == models.ReportActionSuspendUser {
+		return helpers.ErrNotOwner
+	}
+	var ownedCommunities int64
+	err := tx.Model(&models.Community{}).Where("id = ? AND owner_id = ?", report.CommunityID.Int64, resolution.ModeratorID).
+		Count(&ownedCommunities).Error
+	if err != nil {
+		return err
+	}
+	if ownedCommunities == 0 {
+		return helpers.ErrNotOwner
+	}
+	return nil
+}
+
+// Deletes the reported content; content that has already been deleted is ignored. The
+// removed post, or the post of the removed comment, is set in the result so that its
+// ranking and comment count can be updated.
+func removeReportTarget(tx *gorm.DB, report *models.Report, result *models.ModerationResult) error {
+	switch report.TargetType {
+	case models.ReportTargetPost:
+		post := models.Post{}
+		err := tx.First(&post, "id = ?", report.TargetID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result.RemovedPostID = null.IntFrom(int64(post.ID))
+		return tx.Delete(&post).Error
+	case models.ReportTargetComment:
+		comment := models.Comment{}
+		err := tx.First(&comment, "id = ?", report.TargetID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result.CommentPostID = null.IntFrom(int64(comment.PostID))
+		return tx.Delete(&comment).Error
+	case models.ReportTargetProject:
+		return tx.Delete(&models.Project{}, "id = ?", report.TargetID).Error
+	}
+	// Communities and users are dealt with by suspending their owner instead
+	return ErrCannotRemove
+}
+
+// Stops holding the reported content for review
+func releaseReportTarget(tx *gorm.DB, report *models.Report) error {
+	switch report.TargetType {
+	case models.ReportTargetPost:
+		return tx.Model(&models.Post{}).Where("id = ?", report.TargetID).Update("held_for_review", false).Error
+	case models.ReportTargetComment:
+		return tx.Model(&models.Comment{}).Where("id = ?", report.TargetID).Update("held_for_review", false).Error
+	}
+	return nil
+}
+
+func suspendReportedUser(tx *gorm.DB, report *models.Report, resolution *models.ReportResolution) error {
+	user := models.User{}
+	if err := tx.First(&user, "id = ?", report.TargetUserID).Error; err != nil {
+		return err
+	}
+	if models.HasRole(user.Role, models.RoleAdmin) {
+		return ErrCannotSuspendAdmin
+	}
+	user.Suspension = models.Suspension{
+		SuspendedAt:      null.TimeFrom(resolution.ResolvedAt),
+		SuspendedUntil:   resolution.SuspendUntil,
+		SuspensionReason: null.StringFrom(helpers.ReportSuspensionReason(report.Reason, resolution.Note)),
+	}
+	return tx.Model(&user).Select("suspended_at", "suspended_until", "suspension_reason").UpdateColumns(&user).Error
+}
@@ -0,0 +1,523 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchParams describes a search. Filters left as their zero value are not applied.
+type SearchParams struct {
+	Term string
+	// Types of results to return; every type is returned if empty
+	Types []string
+	// Name of the community that results must belong to. Only projects, posts and
+	// comments belong to a community, so other types are not returned.
+	Community string
+	// Names or aliases of skills that results must all have, regardless of case. Only
+	// users and projects have skills, so other types are not returned.
+	Skills        []string
+	CreatedAfter  null.Time
+	CreatedBefore null.Time
+	// Results up to and including the cursor are skipped
+	Cursor *models.SearchCursor
+	Limit  int
+}
+
+type SearchDBHandler interface {
+	Search(params *SearchParams, userID string) ([]models.SearchResult, error)
+	CountSearchResults(params *SearchParams, userID string) (map[string]int64, error)
+	Suggest(term string, limit int, userID string) ([]models.SearchResult, error)
+}
+
+// SearchDB implements SearchDBHandler
+type SearchDB struct {
+	DB *gorm.DB
+}
+
+// Passed to ts_rank so that scores from different tables can be compared: 1 divides
+// the rank by 1 + the logarithm of the document length, so that long posts do not
+// outrank names simply by repeating a word, and 32 scales the rank to between 0 and 1
+const searchRankNormalisation = 1 | 32
+
+// Passed to ts_headline to build the snippets returned with post and comment search
+// results. Matches are wrapped in <b></b>; the rest of the snippet is not escaped.
+const searchHeadlineOptions = "MaxFragments=2, MaxWords=20, MinWords=5"
+
+// Tables with a search_vector column, mapped to the text column that is indexed
+var searchableTables = map[string]string{
+	"posts":    "content",
+	"comments": "text",
+}
+
+// Columns that names are matched against by trigram similarity, mapped to their table
+var trigramIndexedColumns = map[string]string{
+	"users":       "username",
+	"projects":    "name",
+	"communities": "name",
+}
+
+// Adds a stored tsvector column to each searchable table, with a GIN index and a
+// trigger that keeps the column up to date whenever the indexed text changes, and
+// trigram indexes on names. Every statement is idempotent so that this can run on each
+// startup.
+func migrateSearch(db *gorm.DB) {
+	for table, column := range searchableTables {
+		execMigrations(db, table, []string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector", table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_search_vector ON %[1]s USING GIN (search_vector)", table),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %[1]s_search_vector_update ON %[1]s", table),
+			fmt.Sprintf("CREATE TRIGGER %[1]s_search_vector_update BEFORE INSERT OR UPDATE OF %[2]s ON %[1]s "+
+				"FOR EACH ROW EXECUTE FUNCTION tsvector_update_trigger(search_vector, 'pg_catalog.english', %[2]s)", table, column),
+			// Rows created before the trigger existed
+			fmt.Sprintf("UPDATE %[1]s SET search_vector = to_tsvector('pg_catalog.english', COALESCE(%[2]s, '')) "+
+				"WHERE search_vector IS NULL", table, column),
+		})
+	}
+
+	execMigrations(db, "pg_trgm", []string{"CREATE EXTENSION IF NOT EXISTS pg_trgm"})
+	for table, column := range trigramIndexedColumns {
+		execMigrations(db, table, []string{
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_%[2]s_trgm ON %[1]s USING GIN (%[2]s gin_trgm_ops)", table, column),
+		})
+	}
+}
+
+// Runs statements in order, stopping at the first that fails
+func execMigrations(db *gorm.DB, name string, statements []string) {
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Printf("Failed to migrate search for %s: %v", name, err)
+			return
+		}
+	}
+}
+
+// Returns a page of results of params.Types matching params, visible to userID. Results
+// are ordered by score, with ties broken by type and then ID so that every result has a
+// unique position for cursors to refer to.
+func (db *SearchDB) Search(params *SearchParams, userID string) ([]models.SearchResult, error) {
+	results := []models.SearchResult{}
+	query := db.searchUnion(params, params.Types, userID)
+	if query == nil {
+		return results, nil
+	}
+	if params.Cursor != nil {
+		query = query.Where("(results.score < ? OR (results.score = ? AND "+
+			"(results.result_type COLLATE \"C\", results.result_id COLLATE \"C\") > (?, ?)))",
+			params.Cursor.Score, params.Cursor.Score, params.Cursor.ResultType, params.Cursor.ID)
+	}
+
+	rows := []models.SearchResultRow{}
+	// Snippets are only built for the rows returned, rather than every match
+	err := query.
+		Select("results.result_type, results.result_id, results.name, results.score, results.community_name, "+
+			"results.project_id, results.project_name, ts_headline('english', results.body, search_input.query, ?) AS snippet",
+			searchHeadlineOptions).
+		Scopes(withSearchQuery(params.Term)).
+		Order("results.score DESC, results.result_type COLLATE \"C\", results.result_id COLLATE \"C\"").
+		Limit(params.Limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		results = append(results, *row.ToSearchResult())
+	}
+	return results, nil
+}
+
+// Counts the results of each type matching params, visible to userID. params.Types and
+// params.Cursor are ignored so that clients can show how many results other types have.
+func (db *SearchDB) CountSearchResults(params *SearchParams, userID string) (map[string]int64, error) {
+	counts := map[string]int64{}
+	query := db.searchUnion(params, nil, userID)
+	if query == nil {
+		return counts, nil
+	}
+
+	rows := []struct {
+		ResultType string
+		Count      int64
+	}{}
+	err := query.
+		Select("results.result_type, COUNT(*) AS count").
+		Group("results.result_type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, resultType := range searchableTypes(params, nil) {
+		counts[resultType] = 0
+	}
+	for _, row := range rows {
+		counts[row.ResultType] = row.Count
+	}
+	return counts, nil
+}
+
+// Returns up to limit users, projects and communities visible to userID whose names
+// start with term or contain a word similar to it. Only names are matched, since they
+// have trigram indexes, so that suggestions are fast enough to show while typing. Names
+// starting with term are suggested first.
+func (db *SearchDB) Suggest(term string, limit int, userID string) ([]models.SearchResult, error) {
+	prefix := helpers.EscapeLikePattern(term) + "%"
+	subqueries := []interface{}{}
+	for _, resultType := range suggestedSearchTypes {
+		named := namedSearchTypes[resultType]
+		subqueries = append(subqueries, named.visible(db.DB, userID).
+			Select(fmt.Sprintf("'%[1]s' AS result_type, %[2]s::text AS result_id, %[3]s AS name, "+
+				"word_similarity(?, %[3]s)::float8 AS score, %[3]s ILIKE ? AS is_prefix, %[4]s AS community_name, "+
+				"%[5]d AS type_order", resultType, named.ID, named.Name, orNull(named.CommunityName, "text"),
+				len(subqueries)), term, prefix).
+			Where(fmt.Sprintf("(? <%% %[1]s OR %[1]s ILIKE ?)", named.Name), term, prefix))
+	}
+
+	rows := []models.SearchResultRow{}
+	err := db.union(subqueries).
+		Select("results.result_type, results.result_id, results.name, results.score, results.community_name").
+		Order("results.is_prefix DESC, results.score DESC, results.type_order, length(results.name), results.name").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	results := []models.SearchResult{}
+	for _, row := range rows {
+		results = append(results, *row.ToSearchResult())
+	}
+	return results, nil
+}
+
+// Builds the query for each type of search result. Every query selects the columns
+// built by searchColumns.
+var searchSources = map[string]func(db *gorm.DB, params *SearchParams, userID string) *gorm.DB{
+	models.SearchResultUser:      searchByName(models.SearchResultUser),
+	models.SearchResultProject:   searchByName(models.SearchResultProject),
+	models.SearchResultCommunity: searchByName(models.SearchResultCommunity),
+	models.SearchResultPost:      searchPosts,
+	models.SearchResultComment:   searchComments,
+}
+
+// Types of search results that belong to a community
+var communitySearchTypes = map[string]bool{
+	models.SearchResultProject: true,
+	models.SearchResultPost:    true,
+	models.SearchResultComment: true,
+}
+
+// Types of search results that have skills
+var skillSearchTypes = map[string]bool{
+	models.SearchResultUser:    true,
+	models.SearchResultProject: true,
+}
+
+// Returns the types of results that a search for types with params can return
+func searchableTypes(params *SearchParams, types []string) []string {
+	requested := map[string]bool{}
+	for _, resultType := range types {
+		requested[resultType] = true
+	}
+	output := []string{}
+	for _, resultType := range models.SearchResultTypes {
+		if len(types) > 0 && !requested[resultType] {
+			continue
+		}
+		if params.Community != "" && !communitySearchTypes[resultType] {
+			continue
+		}
+		if len(params.Skills) > 0 && !skillSearchTypes[resultType] {
+			continue
+		}
+		output = append(output, resultType)
+	}
+	return output
+}
+
+// Combines the queries for each type that a search for types with params can return
+// into a single table named results. Returns nil if no type can be returned.
+func (db *SearchDB) searchUnion(params *SearchParams, types []string, userID string) *gorm.DB {
+	subqueries := []interface{}{}
+	for _, resultType := range searchableTypes(params, types) {
+		subqueries = append(subqueries, searchSources[resultType](db.DB, params, userID))
+	}
+	if len(subqueries) == 0 {
+		return nil
+	}
+	return db.union(subqueries)
+}
+
+// Combines subqueries selecting the same columns into a single table named results
+func (db *SearchDB) union(subqueries []interface{}) *gorm.DB {
+	union := strings.Repeat(" UNION ALL (?)", len(subqueries))[len(" UNION ALL "):]
+	return db.DB.Table("("+union+") AS results", subqueries...)
+}
+
+// Columns selected by the query for each type of search result. Columns left empty
+// are selected as NULL.
+type searchColumns struct {
+	ResultType string
+	ID         string
+	Name       string
+	// tsvector that the search term is matched against
+	Vector string
+	// Whether the name is also matched by trigram similarity, which then counts towards
+	// the score
+	MatchName bool
+	// Text that snippets are built from
+	Body          string
+	CommunityName string
+	ProjectID     string
+	ProjectName   string
+}
+
+// Returns column, or NULL of sqlType if column is empty
+func orNull(column string, sqlType string) string {
+	if column == "" {
+		return "NULL::" + sqlType
+	}
+	return column
+}
+
+func (c *searchColumns) String() string {
+	// Similarity is between 0 and 1, like the normalised rank
+	score := fmt.Sprintf("ts_rank(%s, search_input.query, %d)", c.Vector, searchRankNormalisation)
+	if c.MatchName {
+		score = fmt.Sprintf("GREATEST(%s, similarity(%s, search_input.term))", score, c.Name)
+	}
+	return fmt.Sprintf("'%s' AS result_type, %s::text AS result_id, %s AS name, %s::float8 AS score, "+
+		"%s AS body, %s AS community_name, %s AS project_id, %s AS project_name",
+		c.ResultType, c.ID, c.Name, score, orNull(c.Body, "text"),
+		orNull(c.CommunityName, "text"), orNull(c.ProjectID, "bigint"), orNull(c.ProjectName, "text"))
+}
+
+// Types of search results that can be matched by name
+type namedSearchType struct {
+	Table         string
+	ID            string
+	Name          string
+	CommunityName string
+	// tsvector that the search term is matched against; the name if empty
+	Vector string
+	// Text that snippets are built from
+	Body string
+	// Returns the rows of this type that userID can see
+	visible func(db *gorm.DB, userID string) *gorm.DB
+	// Applies the filters in params that rows of this type can be filtered by
+	filter func(params *SearchParams) func(*gorm.DB) *gorm.DB
+}
+
+// Users are matched by their username and display name, then their skills and title,
+// then their about-me. The title and about-me are only matched if the user shows them
+// on their profile.
+const userSearchVector = "setweight(to_tsvector('english', users.username || ' ' || COALESCE(users.name, '')), 'A') || " +
+	"setweight(to_tsvector('english', COALESCE((SELECT string_agg(skills.name, ' ') FROM user_skills " +
+	"JOIN skills ON skills.id = user_skills.skill_id WHERE user_skills.user_id = users.id), '')), 'B') || " +
+	"setweight(to_tsvector('english', CASE WHEN users.show_title THEN COALESCE(users.title, '') ELSE '' END), 'B') || " +
+	"setweight(to_tsvector('english', CASE WHEN users.show_about_me THEN COALESCE(users.about_me, '') ELSE '' END), 'C')"
+
+var namedSearchTypes = map[string]*namedSearchType{
+	models.SearchResultUser: {
+		Table:   "users",
+		ID:      "users.id",
+		Name:    "users.username",
+		Vector:  userSearchVector,
+		Body:    "CASE WHEN users.show_title THEN users.title END",
+		visible: visibleUsers,
+		filter:  userSearchFilters,
+	},
+	models.SearchResultProject: {
+		Table:         "projects",
+		ID:            "projects.id",
+		Name:          "projects.name",
+		CommunityName: "communities.name",
+		visible:       visibleProjects,
+		filter:        projectSearchFilters,
+	},
+	models.SearchResultCommunity: {
+		Table:   "communities",
+		ID:      "communities.id",
+		Name:    "communities.name",
+		visible: visibleCommunities,
+	},
+}
+
+// Types of search results suggested while the user is typing, in the order that they
+// are suggested when equally relevant
+var suggestedSearchTypes = []string{
+	models.SearchResultUser,
+	models.SearchResultProject,
+	models.SearchResultCommunity,
+}
+
+// Builds the query for a type of search result that is matched by name. Names are also
+// matched by trigram similarity, so that they are found despite typos and punctuation
+// that the english configuration splits words on.
+func searchByName(resultType string) func(db *gorm.DB, params *SearchParams, userID string) *gorm.DB {
+	return func(db *gorm.DB, params *SearchParams, userID string) *gorm.DB {
+		named := namedSearchTypes[resultType]
+		columns := searchColumns{
+			ResultType:    resultType,
+			ID:            named.ID,
+			Name:          named.Name,
+			Vector:        named.Vector,
+			MatchName:     true,
+			Body:          named.Body,
+			CommunityName: named.CommunityName,
+		}
+		if columns.Vector == "" {
+			columns.Vector = fmt.Sprintf("to_tsvector('english', %s)", named.Name)
+		}
+		query := named.visible(db, userID).
+			Select(columns.String()).
+			Scopes(withSearchQuery(params.Term), createdBetween(named.Table, params)).
+			Where(fmt.Sprintf("(%s @@ search_input.query OR %s %% search_input.term)", columns.Vector, named.Name))
+		if named.filter != nil {
+			query = query.Scopes(named.filter(params))
+		}
+		return query
+	}
+}
+
+func userSearchFilters(params *SearchParams) func(*gorm.DB) *gorm.DB {
+	return hasSkills(params.Skills, "user_skills", "user_id", "users.id")
+}
+
+func projectSearchFilters(params *SearchParams) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(inCommunity(params), hasSkills(params.Skills, "project_skills", "project_id", "projects.id"))
+	}
+}
+
+func visibleUsers(db *gorm.DB, userID string) *gorm.DB {
+	return db.
+		Table("users").
+		Where("users.delete_after IS NULL").
+		Scopes(notBlockedWith("users.id", userID))
+}
+
+// Excludes projects owned by users who have blocked or been blocked by userID
+func visibleProjects(db *gorm.DB, userID string) *gorm.DB {
+	return db.
+		Table("projects").
+		Joins("JOIN users \"User\" ON \"User\".id = projects.owner_id").
+		Joins("LEFT JOIN communities ON communities.id = projects.community_id AND communities.deleted_at IS NULL").
+		Scopes(ownerIsActive, notBlockedWith("projects.owner_id", userID))
+}
+
+func visibleCommunities(db *gorm.DB, userID string) *gorm.DB {
+	return db.
+		Table("communities").
+		Where("communities.deleted_at IS NULL")
+}
+
+// Excludes posts that userID cannot see in their feed
+func searchPosts(db *gorm.DB, params *SearchParams, userID string) *gorm.DB {
+	columns := searchColumns{
+		ResultType:    models.SearchResultPost,
+		ID:            "posts.id",
+		Name:          "\"User\".username",
+		Vector:        "posts.search_vector",
+		Body:          "posts.content",
+		CommunityName: "communities.name",
+		ProjectID:     "projects.id",
+		ProjectName:   "projects.name",
+	}
+	return db.
+		Table("posts").
+		Select(columns.String()).
+		Joins("JOIN users \"User\" ON \"User\".id = posts.user_id").
+		Scopes(joinSearchParents, matchingSearch(columns.Vector, params.Term), createdBetween("posts", params), inCommunity(params)).
+		Where("posts.deleted_at IS NULL").
+		Scopes(ownerIsActive, notHeldUnlessOwnedBy("posts", userID), notBlockedWith("posts.user_id", userID))
+}
+
+// Excludes comments that userID cannot see, including comments on posts that userID
+// cannot see
+func searchComments(db *gorm.DB, params *SearchParams, userID string) *gorm.DB {
+	columns := searchColumns{
+		ResultType:    models.SearchResultComment,
+		ID:            "comments.id",
+		Name:          "\"User\".username",
+		Vector:        "comments.search_vector",
+		Body:          "comments.text",
+		CommunityName: "communities.name",
+		ProjectID:     "projects.id",
+		ProjectName:   "projects.name",
+	}
+	return db.
+		Table("comments").
+		Select(columns.String()).
+		Joins("JOIN users \"User\" ON \"User\".id = comments.user_id").
+		Joins("JOIN posts ON posts.id = comments.post_id AND posts.deleted_at IS NULL").
+		Joins("JOIN users post_owner ON post_owner.id = posts.user_id AND post_owner.delete_after IS NULL").
+		Scopes(joinSearchParents, matchingSearch(columns.Vector, params.Term), createdBetween("comments", params), inCommunity(params)).
+		Where("comments.deleted_at IS NULL").
+		Scopes(ownerIsActive, notHeldUnlessOwnedBy("comments", userID), notHeldUnlessOwnedBy("posts", userID),
+			notBlockedWith("comments.user_id", userID), notBlockedWith("posts.user_id", userID))
+}
+
+// Builds the tsquery for searchTerm. The term is only ever passed as a bound parameter;
+// the final word is matched as a prefix unless helpers.SplitSearchPrefix finds that it
+// should not be.
+func searchQuery(searchTerm string) clause.Expr {
+	rest, prefix := helpers.SplitSearchPrefix(searchTerm)
+	if prefix == "" {
+		return gorm.Expr("websearch_to_tsquery('english', ?)", rest)
+	}
+	return gorm.Expr("websearch_to_tsquery('english', ?) && to_tsquery('english', ?)", rest, prefix+":*")
+}
+
+// Exposes the tsquery for searchTerm to the rest of the query as search_input.query,
+// and searchTerm itself as search_input.term
+func withSearchQuery(searchTerm string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("CROSS JOIN (SELECT ? AS query, ?::text AS term) AS search_input", searchQuery(searchTerm), searchTerm)
+	}
+}
+
+// Restricts the query to rows whose vector, an SQL expression of type tsvector, matches
+// searchTerm
+func matchingSearch(vector string, searchTerm string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(withSearchQuery(searchTerm)).
+			Where(fmt.Sprintf("%s @@ search_input.query", vector))
+	}
+}
+
+// Restricts the query to rows of table created within the dates in params
+func createdBetween(table string, params *SearchParams) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if params.CreatedAfter.Valid {
+			db = db.Where(fmt.Sprintf("%s.created_at >= ?", table), params.CreatedAfter.Time)
+		}
+		if params.CreatedBefore.Valid {
+			db = db.Where(fmt.Sprintf("%s.created_at < ?", table), params.CreatedBefore.Time)
+		}
+		return db
+	}
+}
+
+// Restricts the query to rows in the community in params. The query must join the
+// communities table.
+func inCommunity(params *SearchParams) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if params.Community == "" {
+			return db
+		}
+		return db.Where("communities.name = ?", params.Community)
+	}
+}
+
+// Joins the community and project that the posts in the query were made in. The
+// query must include the posts table.
+func joinSearchParents(db *gorm.DB) *gorm.DB {
+	return db.Joins("LEFT JOIN communities ON communities.id = posts.community_id AND communities.deleted_at IS NULL").
+		Joins("LEFT JOIN projects ON projects.id = posts.project_id")
+}
@@ -0,0 +1,289 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAlreadyEndorsed  = errors.New("skill has already been endorsed")
+	ErrEndorsingOneself = errors.New("users cannot endorse their own skills")
+	ErrSkillNotListed   = errors.New("user has not listed the skill")
+)
+
+type SkillDBHandler interface {
+	SetUserSkills(userID string, skills []models.UserSkillInput) ([]models.UserSkillView, error)
+	GetUserSkills(userID string, viewerID string) ([]models.UserSkillView, error)
+	EndorseSkill(endorserID string, username string, skillID uint) (*models.Endorsement, error)
+	UnendorseSkill(endorserID string, username string, skillID uint) error
+}
+
+// SkillDB implements SkillDBHandler
+type SkillDB struct {
+	DB *gorm.DB
+}
+
+// Canonical names of common skills, mapped to other names that they are listed under
+var defaultSkillAliases = map[string][]string{
+	"C#":               {"csharp", "c sharp"},
+	"C++":              {"cpp", "cplusplus"},
+	"Go":               {"golang"},
+	"JavaScript":       {"js", "javascript es6", "es6"},
+	"Kubernetes":       {"k8s"},
+	"Machine Learning": {"ml"},
+	"Node.js":          {"node", "nodejs", "node js"},
+	"PostgreSQL":       {"postgres", "psql", "postgre"},
+	"Python":           {"py", "python3"},
+	"React":            {"reactjs", "react.js", "react js"},
+	"TypeScript":       {"ts"},
+}
+
+// Adds the default skills and their aliases. Skills that were created under a name that
+// has since become an alias are merged into the canonical skill. Safe to run on each
+// startup.
+func seedSkills(db *gorm.DB) {
+	for name, aliases := range defaultSkillAliases {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			skill := models.Skill{}
+			if err := tx.Where("lower(name) = lower(?)", name).FirstOrCreate(&skill, models.Skill{Name: name}).Error; err != nil {
+				return err
+			}
+			// Use the canonical capitalisation even if a user created the skill first
+			if err := tx.Model(&skill).Update("name", name).Error; err != nil {
+				return err
+			}
+			for _, alias := range aliases {
+				skillAlias := models.SkillAlias{Alias: alias, SkillID: skill.ID}
+				if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&skillAlias).Error; err != nil {
+					return err
+				}
+				duplicate := models.Skill{}
+				err := tx.Where("lower(name) = ? AND id <> ?", alias, skill.ID).First(&duplicate).Error
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if err := mergeSkill(tx, duplicate.ID, skill.ID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to seed skill %s: %v", name, err)
+		}
+	}
+}
+
+// Moves users' listings, endorsements and project tags of the skill fromID to the skill
+// intoID, then deletes fromID. Users and projects with both keep their listing of intoID.
+func mergeSkill(tx *gorm.DB, fromID uint, intoID uint) error {
+	statements := []string{
+		"UPDATE user_skills SET skill_id = @into WHERE skill_id = @from " +
+			"AND user_id NOT IN (SELECT user_id FROM user_skills WHERE skill_id = @into)",
+		"UPDATE endorsements SET skill_id = @into WHERE skill_id = @from " +
+			"AND (endorser_id, user_id) NOT IN (SELECT endorser_id, user_id FROM endorsements WHERE skill_id = @into)",
+		"UPDATE project_skills SET skill_id = @into WHERE skill_id = @from " +
+			"AND project_id NOT IN (SELECT project_id FROM project_skills WHERE skill_id = @into)",
+		"UPDATE skill_aliases SET skill_id = @into WHERE skill_id = @from",
+		"DELETE FROM skills WHERE id = @from",
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement, map[string]interface{}{"from": fromID, "into": intoID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the skill that name refers to, by alias or by name regardless of case,
+// creating it if there is none
+func resolveSkill(tx *gorm.DB, name string) (*models.Skill, error) {
+	skill := models.Skill{}
+	err := tx.Joins("JOIN skill_aliases ON skill_aliases.skill_id = skills.id").
+		Where("skill_aliases.alias = ?", strings.ToLower(name)).
+		First(&skill).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &skill, err
+	}
+	err = tx.Where("lower(name) = lower(?)", name).FirstOrCreate(&skill, models.Skill{Name: name}).Error
+	return &skill, err
+}
+
+// Restricts the query to rows tagged with every skill in names. Skills are matched by
+// name regardless of case, or by alias. tagTable links rows to skills, with tagColumn
+// referring to the row's ID in idColumn.
+func hasSkills(names []string, tagTable string, tagColumn string, idColumn string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, name := range helpers.NormaliseSkillNames(names) {
+			db = db.Where(fmt.Sprintf("EXISTS (SELECT 1 FROM %[1]s JOIN skills ON skills.id = %[1]s.skill_id "+
+				"WHERE %[1]s.%[2]s = %[3]s AND (lower(skills.name) = @name OR skills.id IN "+
+				"(SELECT skill_id FROM skill_aliases WHERE alias = @name)))", tagTable, tagColumn, idColumn),
+				map[string]interface{}{"name": strings.ToLower(name)})
+		}
+		return db
+	}
+}
+
+// Replaces the skills listed by the user with skills, creating skills that do not yet
+// exist. Skills that resolve to the same canonical skill are listed once, with the level
+// and years given first. Endorsements of skills that the user no longer lists are
+// deleted; endorsements of skills that remain are kept.
+func (db *SkillDB) SetUserSkills(userID string, skills []models.UserSkillInput) ([]models.UserSkillView, error) {
+	var output []models.UserSkillView
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		userSkills := []models.UserSkill{}
+		skillIDs := []uint{}
+		listed := map[uint]bool{}
+		for _, input := range skills {
+			skill, err := resolveSkill(tx, input.Name)
+			if err != nil {
+				return err
+			}
+			if listed[skill.ID] {
+				continue
+			}
+			listed[skill.ID] = true
+			skillIDs = append(skillIDs, skill.ID)
+			userSkills = append(userSkills, models.UserSkill{
+				UserID:  userID,
+				SkillID: skill.ID,
+				Level:   input.Level,
+				Years:   input.Years,
+			})
+		}
+
+		removed := tx.Where("user_id = ?", userID)
+		if len(skillIDs) > 0 {
+			removed = removed.Where("skill_id NOT IN ?", skillIDs)
+		}
+		if err := removed.Session(&gorm.Session{}).Delete(&models.Endorsement{}).Error; err != nil {
+			return err
+		}
+		if err := removed.Session(&gorm.Session{}).Delete(&models.UserSkill{}).Error; err != nil {
+			return err
+		}
+		if len(userSkills) > 0 {
+			err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "skill_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"level", "years"}),
+			}).Create(&userSkills).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var err error
+		output, err = getUserSkills(tx, userID, userID)
+		return err
+	})
+	return output, err
+}
+
+// Retrieves the skills listed by the user, most endorsed first. Endorsements by users
+// pending deletion or who have blocked or been blocked by viewerID are not counted.
+func (db *SkillDB) GetUserSkills(userID string, viewerID string) ([]models.UserSkillView, error) {
+	return getUserSkills(db.DB, userID, viewerID)
+}
+
+func getUserSkills(db *gorm.DB, userID string, viewerID string) ([]models.UserSkillView, error) {
+	endorsements := db.
+		Table("endorsements").
+		Select("endorsements.skill_id, endorsements.endorser_id").
+		Joins("JOIN users endorser ON endorser.id = endorsements.endorser_id AND endorser.delete_after IS NULL").
+		Where("endorsements.user_id = ?", userID).
+		Scopes(notBlockedWith("endorsements.endorser_id", viewerID))
+
+	skills := []models.UserSkillView{}
+	err := db.
+		Table("user_skills").
+		Select("skills.id, skills.name, user_skills.level, user_skills.years, "+
+			"COUNT(visible_endorsements.endorser_id) AS endorsements, "+
+			"COALESCE(BOOL_OR(visible_endorsements.endorser_id = ?), false) AS endorsed", viewerID).
+		Joins("JOIN skills ON skills.id = user_skills.skill_id").
+		Joins("LEFT JOIN (?) AS visible_endorsements ON visible_endorsements.skill_id = user_skills.skill_id", endorsements).
+		Where("user_skills.user_id = ?", userID).
+		Group("skills.id, skills.name, user_skills.level, user_skills.years").
+		Order("endorsements DESC, skills.name").
+		Scan(&skills).Error
+	return skills, err
+}
+
+// Endorses the skill listed by the user with the given username. Returns the endorsement
+// with the endorser, user and skill filled in. Returns gorm.ErrRecordNotFound if there is
+// no such user, ErrSkillNotListed if the user has not listed the skill, and ErrBlocked if
+// the users have blocked each other.
+func (db *SkillDB) EndorseSkill(endorserID string, username string, skillID uint) (*models.Endorsement, error) {
+	user, err := db.findEndorsee(endorserID, username)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := blockExists(db.DB, endorserID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+	userSkill := models.UserSkill{}
+	err = db.DB.Joins("Skill").Where("user_skills.user_id = ? AND user_skills.skill_id = ?", user.ID, skillID).First(&userSkill).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSkillNotListed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	endorsement := models.Endorsement{
+		EndorserID: endorserID,
+		UserID:     user.ID,
+		SkillID:    skillID,
+	}
+	result := db.DB.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&endorsement)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrAlreadyEndorsed
+	}
+	if err := db.DB.First(&endorsement.Endorser, "id = ?", endorserID).Error; err != nil {
+		return nil, err
+	}
+	endorsement.User = *user
+	endorsement.Skill = userSkill.Skill
+	return &endorsement, nil
+}
+
+// Returns gorm.ErrRecordNotFound if there is no such user or the skill is not endorsed
+func (db *SkillDB) UnendorseSkill(endorserID string, username string, skillID uint) error {
+	user, err := db.findEndorsee(endorserID, username)
+	if err != nil {
+		return err
+	}
+	result := db.DB.Delete(&models.Endorsement{}, "endorser_id = ? AND user_id = ? AND skill_id = ?", endorserID, user.ID, skillID)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Users pending deletion cannot be endorsed
+func (db *SkillDB) findEndorsee(endorserID string, username string) (*models.User, error) {
+	user := models.User{}
+	if err := db.DB.Where("username = ? AND delete_after IS NULL", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	if user.ID == endorserID {
+		return nil, ErrEndorsingOneself
+	}
+	return &user, nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+// TimelineDBHandler is implemented by TimelineDB
+type TimelineDBHandler interface {
+	GetPostAudience(postID uint, maxAudience int64) ([]string, error)
+	GetPulledPostIDs(userID string, cutoff *helpers.NullableUint, minAudience int64, limit int) ([]uint, error)
+	GetRecentPostIDsByUser(authorID string, limit int) ([]uint, error)
+	GetRecentPostIDsInCommunity(communityID uint, limit int) ([]uint, error)
+}
+
+// TimelineDB implements TimelineDBHandler
+type TimelineDB struct {
+	DB *gorm.DB
+}
+
+// Retrieves the users whose home feeds the post is fanned out to: its author, the
+// followers of its author and the members of its community. Followers of authors with
+// at least maxAudience followers, and members of communities with at least maxAudience
+// members, are left out since the post is pulled into their feeds instead. Deleted posts
+// are included so that they can be removed from home feeds.
+func (db *TimelineDB) GetPostAudience(postID uint, maxAudience int64) ([]string, error) {
+	post := models.Post{}
+	if err := db.DB.Unscoped().Select("id", "user_id", "community_id").First(&post, postID).Error; err != nil {
+		return nil, err
+	}
+	followers := db.DB.Model(&models.Follow{}).Select("follows.follower_id").
+		Joins("JOIN users ON users.id = follows.followee_id").
+		Where("follows.followee_id = ? AND users.follower_count < ?", post.UserID, maxAudience)
+	members := db.DB.Model(&models.CommunityMember{}).Select("community_members.user_id").
+		Joins("JOIN communities ON communities.id = community_members.community_id").
+		Where("community_members.community_id = ? AND communities.member_count < ?", post.CommunityID, maxAudience)
+	// The author is added separately as they may be neither following nor a member
+	author := db.DB.Model(&models.User{}).Select("users.id").Where("users.id = ?", post.UserID)
+
+	userIDs := []string{}
+	err := db.DB.Raw("? UNION ? UNION ?", followers, members, author).Scan(&userIDs).Error
+	return userIDs, err
+}
+
+// Retrieves every user whose home feed any of the posts may have been fanned out to,
+// whatever the number of followers or members: the authors of the posts, the followers
+// of their authors and the members of their communities
+func getPostsAudience(tx *gorm.DB, postIDs []uint) ([]string, error) {
+	authors := tx.Unscoped().Model(&models.Post{}).Select("posts.user_id").Where("posts.id IN ?", postIDs)
+	communities := tx.Unscoped().Model(&models.Post{}).Select("posts.community_id").Where("posts.id IN ?", postIDs)
+	followers := tx.Model(&models.Follow{}).Select("follows.follower_id").Where("follows.followee_id IN (?)", authors)
+	members := tx.Model(&models.CommunityMember{}).Select("community_members.user_id").
+		Where("community_members.community_id IN (?)", communities)
+
+	userIDs := []string{}
+	err := tx.Raw("? UNION ? UNION ?", followers, members, authors).Scan(&userIDs).Error
+	return userIDs, err
+}
+
+// Retrieves up to limit of the newest posts before the cutoff by users that the user
+// follows who have at least minAudience followers, and in communities that the user is a
+// member of with at least minAudience members. These posts are not fanned out, so they
+// are pulled into the user's home feed when it is read.
+func (db *TimelineDB) GetPulledPostIDs(userID string, cutoff *helpers.NullableUint, minAudience int64, limit int) ([]uint, error) {
+	followees := db.DB.Model(&models.Follow{}).Select("follows.followee_id").
+		Joins("JOIN users ON users.id = follows.followee_id").
+		Where("follows.follower_id = ? AND users.follower_count >= ?", userID, minAudience)
+	communities := db.DB.Model(&models.CommunityMember{}).Select("community_members.community_id").
+		Joins("JOIN communities ON communities.id = community_members.community_id").
+		Where("community_members.user_id = ? AND communities.member_count >= ?", userID, minAudience)
+
+	query := db.DB.Model(&models.Post{}).Where("(posts.user_id IN (?) OR posts.community_id IN (?))", followees, communities)
+	if !cutoff.IsNull() {
+		cutoffVal, _ := cutoff.GetValue()
+		query = query.Where("posts.id < ?", cutoffVal)
+	}
+	postIDs := []uint{}
+	err := query.Order("posts.id desc").Limit(limit).Pluck("posts.id", &postIDs).Error
+	return postIDs, err
+}
+
+// Retrieves up to limit of the newest posts by the user, for adding to the home feed of
+// a new follower
+func (db *TimelineDB) GetRecentPostIDsByUser(authorID string, limit int) ([]uint, error) {
+	postIDs := []uint{}
+	err := db.DB.Model(&models.Post{}).Where("user_id = ?", authorID).
+		Order("id desc").Limit(limit).Pluck("id", &postIDs).Error
+	return postIDs, err
+}
+
+// Retrieves up to limit of the newest posts in the community, for adding to the home
+// feed of a new member
+func (db *TimelineDB) GetRecentPostIDsInCommunity(communityID uint, limit int) ([]uint, error) {
+	postIDs := []uint{}
+	err := db.DB.Model(&models.Post{}).Where("community_id = ?", communityID).
+		Order("id desc").Limit(limit).Pluck("id", &postIDs).Error
+	return postIDs, err
+}
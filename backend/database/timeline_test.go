@@ -0,0 +1,53 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"gorm.io/gorm"
+)
+
+// Only posts that are not fanned out are pulled, so the query must select the followees
+// and communities at or above the fan-out limit
+func TestTimelineDB_GetPulledPostIDsQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		cutoff      *helpers.NullableUint
+		expectedSQL []string
+	}{
+		{"First page", &helpers.NullableUint{}, []string{
+			"users.follower_count >= $2",
+			"communities.member_count >= $4",
+			"ORDER BY posts.id desc LIMIT 20",
+		}},
+		{"After cutoff", helpers.NewNullableUint(7), []string{
+			"users.follower_count >= $2",
+			"communities.member_count >= $4",
+			"posts.id < $5",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newDryRunDB(t)
+			var sql string
+			err := db.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+				sql = tx.Statement.SQL.String()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			timelineDB := &TimelineDB{DB: db}
+			timelineDB.GetPulledPostIDs("user", tt.cutoff, 5000, 20)
+
+			for _, expected := range tt.expectedSQL {
+				if !strings.Contains(sql, expected) {
+					t.Errorf("Query %q does not contain %q", sql, expected)
+				}
+			}
+			if !strings.Contains(sql, `"posts"."deleted_at" IS NULL`) {
+				t.Errorf("Query %q does not leave out deleted posts", sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+type TokenDBHandler interface {
+	CreateToken(*models.PersonalAccessToken) (*models.PersonalAccessToken, error)
+	DeleteToken(uint, string) error
+	GetTokenByHash(string) (*models.PersonalAccessToken, error)
+	GetTokens(string) ([]models.PersonalAccessToken, error)
+}
+
+// TokenDB implements TokenDBHandler
+type TokenDB struct {
+	DB *gorm.DB
+}
+
+func (db *TokenDB) CreateToken(token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+	err := db.DB.Create(token).Error
+	return token, err
+}
+
+func (db *TokenDB) DeleteToken(tokenID uint, userID string) error {
+	token := models.PersonalAccessToken{}
+	if err := db.DB.First(&token, "id = ?", tokenID).Error; err != nil {
+		return err
+	}
+	if err := helpers.CheckUserIsOwner(&token, userID); err != nil {
+		return err
+	}
+	return db.DB.Unscoped().Delete(&token).Error
+}
+
+func (db *TokenDB) GetTokenByHash(tokenHash string) (*models.PersonalAccessToken, error) {
+	token := models.PersonalAccessToken{}
+	// Tokens stop working while their owner's account is scheduled for deletion
+	err := db.DB.Joins("User").Scopes(ownerIsActive).First(&token, "personal_access_tokens.token_hash = ?", tokenHash).Error
+	return &token, err
+}
+
+func (db *TokenDB) GetTokens(userID string) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := db.DB.Where("user_id = ?", userID).Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
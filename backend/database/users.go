@@ -1,9 +1,8 @@
 package database
 
 import (
-	"fmt"
-	"os"
-	"strings"
+	"errors"
+	"time"
 
 	"github.com/ryanozx/skillnet/models"
 	"gorm.io/gorm"
@@ -12,23 +11,46 @@ import (
 
 type UserDBHandler interface {
 	CreateUser(NewUser) (*models.User, error)
-	DeleteUser(string) error
+	DeleteUser(string) (*models.PurgedAccount, error)
+	ScheduleUserDeletion(string, time.Time) error
+	CancelUserDeletion(string) error
 	GetUserByID(string) (*models.User, error)
 	GetUserByUsername(string) (*models.User, error)
+	GetUserByEmail(string) (*models.User, error)
 	UpdateUser(*models.User, string) (*models.User, error)
-	QueryUser(string, int) ([]models.SearchResult, error)
+	UpdatePassword(string, string) error
+	UpdateUsername(string, string) (*models.User, error)
+	GetUserByAlias(string) (*models.User, error)
+	CreateEmailChange(*models.EmailChange) (*models.EmailChange, error)
+	ConfirmEmailChange(string, time.Time) (*models.User, error)
 }
 
+// Errors
+var (
+	ErrEmailTaken    = errors.New("email is used by another account")
+	ErrUsernameTaken = errors.New("username is used by another account")
+)
+
 // UserDB implements both UserDBHandler and AuthAPIHandler
 type UserDB struct {
 	DB *gorm.DB
 }
 
-// Creates a new user from a username and password
+// Creates a new user from a username and password. Returns ErrUsernameTaken if the
+// username was previously used by another user, since their old profile links still
+// redirect to them.
 func (db *UserDB) CreateUser(userCreds NewUser) (*models.User, error) {
 	newUser := userCreds.NewUser()
-	result := db.DB.Model(models.User{}).Create(newUser)
-	err := result.Error
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		aliased, err := isOtherUsersAlias(tx, newUser.Username, "")
+		if err != nil {
+			return err
+		}
+		if aliased {
+			return ErrUsernameTaken
+		}
+		return tx.Model(models.User{}).Create(newUser).Error
+	})
 	return newUser, err
 }
 
@@ -36,14 +58,108 @@ type NewUser interface {
 	NewUser() *models.User
 }
 
-// Deletes user's profile
-func (db *UserDB) DeleteUser(id string) error {
-	user, err := db.GetUserByID(id)
-	if err != nil {
-		return err
+// Permanently deletes a user along with their posts, comments, likes, projects and
+// communities (including other users' posts in them). Returns the posts whose like or
+// comment counts changed, the posts that were deleted and the users whose home feeds they
+// may be in, so that cached counts, post rankings and timelines can be updated.
+func (db *UserDB) DeleteUser(id string) (*models.PurgedAccount, error) {
+	purged := &models.PurgedAccount{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.User{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		var communityIDs, projectIDs []uint
+		if err := tx.Unscoped().Model(&models.Community{}).Where("owner_id = ?", id).Pluck("id", &communityIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Project{}).Where("owner_id = ? OR community_id IN ?", id, communityIDs).Pluck("id", &projectIDs).Error; err != nil {
+			return err
+		}
+		const ownedPosts = "user_id = ? OR project_id IN ? OR community_id IN ?"
+		likedPosts := tx.Model(&models.Like{}).Select("post_id").Where("user_id = ?", id)
+		commentedPosts := tx.Unscoped().Model(&models.Comment{}).Select("post_id").Where("user_id = ?", id)
+		if err := tx.Unscoped().Model(&models.Post{}).Where(ownedPosts, id, projectIDs, communityIDs).
+			Or("id IN (?)", likedPosts).Or("id IN (?)", commentedPosts).Pluck("id", &purged.AffectedPostIDs).Error; err != nil {
+			return err
+		}
+		// The feeds that the deleted posts were ranked in can only be found before they go
+		if err := tx.Unscoped().Model(&models.Post{}).Select("id", "created_at", "community_id", "project_id").
+			Where(ownedPosts, id, projectIDs, communityIDs).Find(&purged.DeletedPosts).Error; err != nil {
+			return err
+		}
+		deletedPostIDs := make([]uint, len(purged.DeletedPosts))
+		for i := range purged.DeletedPosts {
+			purged.DeletedPosts[i].Deleted = true
+			deletedPostIDs[i] = purged.DeletedPosts[i].ID
+		}
+		if len(deletedPostIDs) > 0 {
+			audience, err := getPostsAudience(tx, deletedPostIDs)
+			if err != nil {
+				return err
+			}
+			purged.TimelineUserIDs = audience
+		}
+
+		// Follows and memberships are deleted along with the user, so the follower and
+		// member counts that they added to are taken back first
+		followees := tx.Model(&models.Follow{}).Select("followee_id").Where("follower_id = ?", id)
+		if err := tx.Model(&models.User{}).Where("id IN (?)", followees).
+			UpdateColumn("follower_count", gorm.Expr("follower_count - 1")).Error; err != nil {
+			return err
+		}
+		joinedCommunities := tx.Model(&models.CommunityMember{}).Select("community_id").Where("user_id = ?", id)
+		if err := tx.Model(&models.Community{}).Where("id IN (?)", joinedCommunities).
+			UpdateColumn("member_count", gorm.Expr("member_count - 1")).Error; err != nil {
+			return err
+		}
+
+		// Rows referencing the user or their content are deleted first, since not every
+		// foreign key cascades
+		if err := tx.Where("user_id = ?", id).Delete(&models.Like{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&models.Comment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where(ownedPosts, id, projectIDs, communityIDs).Delete(&models.Post{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? OR project_id IN ?", id, projectIDs).Delete(&models.ProjectMembership{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", projectIDs).Delete(&models.Project{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("id IN ?", communityIDs).Delete(&models.Community{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&models.PersonalAccessToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, "id = ?", id).Error
+	})
+	return purged, err
+}
+
+// Hides the user and their content until deleteAfter, when the account is deleted permanently
+func (db *UserDB) ScheduleUserDeletion(id string, deleteAfter time.Time) error {
+	result := db.DB.Model(&models.User{}).Where("id = ?", id).Update("delete_after", deleteAfter)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
 	}
-	err = db.DB.Delete(&user).Error
-	return err
+	return result.Error
+}
+
+func (db *UserDB) CancelUserDeletion(id string) error {
+	return db.DB.Model(&models.User{}).Where("id = ?", id).Update("delete_after", nil).Error
+}
+
+// Retrieves users whose deletion grace period has ended
+func (db *UserDB) GetUsersDueForDeletion(now time.Time, limit int) ([]models.User, error) {
+	var users []models.User
+	err := db.DB.Where("delete_after <= ?", now).Order("delete_after").Limit(limit).Find(&users).Error
+	return users, err
 }
 
 // Retrieves a User object by ID
@@ -61,6 +177,13 @@ func (db *UserDB) GetUserByUsername(username string) (*models.User, error) {
 	return &user, err
 }
 
+// Retrieves a user by email; emails are compared case-insensitively
+func (db *UserDB) GetUserByEmail(email string) (*models.User, error) {
+	user := models.User{}
+	err := db.DB.First(&user, "lower(email) = lower(?)", email).Error
+	return &user, err
+}
+
 // Updates user's profile.
 func (db *UserDB) UpdateUser(user *models.User, id string) (*models.User, error) {
 	resUser := &models.User{}
@@ -74,26 +197,132 @@ func (db *UserDB) UpdateUser(user *models.User, id string) (*models.User, error)
 	return resUser, result.Error
 }
 
-func (db *UserDB) QueryUser(searchTerm string, limit int) ([]models.SearchResult, error) {
+// Updates user's password; the password should already be hashed
+func (db *UserDB) UpdatePassword(id string, hashedPassword string) error {
+	result := db.DB.Model(&models.User{}).Where("id = ?", id).Update("password", hashedPassword)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Changes user's username. The old username is kept as an alias of the user so that
+// old profile URLs still resolve, and so cannot be taken by another user.
+func (db *UserDB) UpdateUsername(id string, username string) (*models.User, error) {
+	resUser := &models.User{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		user := models.User{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if user.Username == username {
+			*resUser = user
+			return nil
+		}
 
-	results := []models.UserSearchResult{}
-	lowerCaseSearchTerm := strings.ToLower(searchTerm) + ":*"
-	tableName := "users" // replace this with your actual table name
-	query := fmt.Sprintf("to_tsquery('english', '%s') @@ to_tsvector('english', lower(username))", lowerCaseSearchTerm)
-	scoreQuery := fmt.Sprintf("ts_rank(to_tsvector('english', lower(username)), to_tsquery('english', '%s')) as score", lowerCaseSearchTerm)
-	urlPrefix := fmt.Sprintf("CONCAT('%s', '/profile/', username) as url", os.Getenv("FRONTEND_BASE_URL"))
+		var usersWithUsername int64
+		if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&usersWithUsername).Error; err != nil {
+			return err
+		}
+		aliased, err := isOtherUsersAlias(tx, username, id)
+		if err != nil {
+			return err
+		}
+		if usersWithUsername > 0 || aliased {
+			return ErrUsernameTaken
+		}
 
-	db.DB.Debug().
-		Table(tableName).
-		Select("username, 'user' as result_type, " + scoreQuery + ", " + urlPrefix).
-		Where(query).
-		Limit(limit).
-		Order("score DESC").
-		Scan(&results)
+		// The user may be reclaiming one of their old usernames
+		if err := tx.Unscoped().Where("username = ? AND user_id = ?", username, id).Delete(&models.UsernameAlias{}).Error; err != nil {
+			return err
+		}
+		alias := models.UsernameAlias{
+			Username: user.Username,
+			UserID:   id,
+		}
+		if err := tx.Create(&alias).Error; err != nil {
+			return err
+		}
+		// Updating with a User struct rather than a map runs the username checks in
+		// the BeforeUpdate hook
+		err = tx.Model(resUser).Clauses(clause.Returning{}).Where("id = ?", id).Updates(&models.User{
+			UserCredentials: models.UserCredentials{
+				Username: username,
+			},
+		}).Error
+		// Another user may have taken the username since it was checked
+		if isUniqueViolation(tx.Dialector, err) {
+			return ErrUsernameTaken
+		}
+		return err
+	})
+	return resUser, err
+}
+
+// Checks if the error is a unique constraint violation. Errors are not translated by
+// gorm, so the dialector is asked to translate it.
+func isUniqueViolation(dialector gorm.Dialector, err error) bool {
+	if translator, ok := dialector.(gorm.ErrorTranslator); ok && err != nil {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
 
-	convertedResults := []models.SearchResult{}
-	for _, usr := range results {
-		convertedResults = append(convertedResults, *usr.ToSearchResult())
+// Checks if the username was previously used by a user other than the given user; an
+// empty userID matches every user
+func isOtherUsersAlias(tx *gorm.DB, username string, userID string) (bool, error) {
+	query := tx.Model(&models.UsernameAlias{}).Where("username = ?", username)
+	if userID != "" {
+		query = query.Where("user_id <> ?", userID)
 	}
-	return convertedResults, nil
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+// Retrieves the user who previously used the given username
+func (db *UserDB) GetUserByAlias(username string) (*models.User, error) {
+	alias := models.UsernameAlias{}
+	err := db.DB.Joins("User").First(&alias, "username_aliases.username = ?", username).Error
+	return &alias.User, err
+}
+
+// Creates a pending email change, replacing any email change the user has not confirmed
+func (db *UserDB) CreateEmailChange(change *models.EmailChange) (*models.EmailChange, error) {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", change.UserID).Delete(&models.EmailChange{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+	return change, err
+}
+
+// Applies the unexpired email change with the given token hash. Returns
+// gorm.ErrRecordNotFound if there is no such email change.
+func (db *UserDB) ConfirmEmailChange(tokenHash string, now time.Time) (*models.User, error) {
+	resUser := &models.User{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		change := models.EmailChange{}
+		if err := tx.First(&change, "token_hash = ? AND expires_at > ?", tokenHash, now).Error; err != nil {
+			return err
+		}
+		// Another account may have started using the email since the change was requested
+		var usersWithEmail int64
+		if err := tx.Model(&models.User{}).Where("lower(email) = lower(?) AND id <> ?", change.Email, change.UserID).Count(&usersWithEmail).Error; err != nil {
+			return err
+		}
+		if usersWithEmail > 0 {
+			return ErrEmailTaken
+		}
+		if err := tx.Unscoped().Delete(&change).Error; err != nil {
+			return err
+		}
+		// Following the link sent to the email proves that the user owns it
+		return tx.Model(resUser).Clauses(clause.Returning{}).Where("id = ?", change.UserID).Updates(map[string]interface{}{
+			"email":          change.Email,
+			"email_verified": true,
+		}).Error
+	})
+	return resUser, err
 }
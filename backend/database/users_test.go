@@ -0,0 +1,77 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Errors from the database driver are matched by their code
+type testDriverError struct {
+	Code string
+}
+
+func (e *testDriverError) Error() string {
+	return "driver error " + e.Code
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Unique violation", &testDriverError{Code: "23505"}, true},
+		{"Other violation", &testDriverError{Code: "23503"}, false},
+		{"Other error", errors.New("test error"), false},
+		{"No error", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUniqueViolation(postgres.Dialector{}, tt.err); got != tt.want {
+				t.Errorf("isUniqueViolation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Follows and memberships cascade when the user is deleted, so the counts that they
+// added to must be taken back before the user goes
+func TestUserDB_DeleteUser_DecrementsCounts(t *testing.T) {
+	db := newDryRunDB(t)
+	var statements []string
+	record := func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement.SQL.String())
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("test:sql", record); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Delete().After("gorm:delete").Register("test:sql", record); err != nil {
+		t.Fatal(err)
+	}
+	userDB := &UserDB{DB: db}
+	if _, err := userDB.DeleteUser("user"); err != nil {
+		t.Fatal(err)
+	}
+
+	followerCount, memberCount, userDeleted := -1, -1, -1
+	for i, statement := range statements {
+		switch {
+		case strings.Contains(statement, `"follower_count"=follower_count - 1`):
+			followerCount = i
+		case strings.Contains(statement, `"member_count"=member_count - 1`):
+			memberCount = i
+		case strings.HasPrefix(statement, `DELETE FROM "users"`):
+			userDeleted = i
+		}
+	}
+	if followerCount == -1 || memberCount == -1 {
+		t.Fatalf("Statements %q do not decrement the follower and member counts", statements)
+	}
+	if userDeleted < followerCount || userDeleted < memberCount {
+		t.Errorf("Statements %q delete the user before decrementing the counts", statements)
+	}
+}
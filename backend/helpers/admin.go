@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+const (
+	AdminPath          = "/admin"
+	AuditLogPath       = "/audit"
+	AdminUserPath      = "/users"
+	MetricsPath        = "/metrics"
+	AdminUserAfterKey  = "after"
+	AdminUserSearchKey = "q"
+	UserRoleKey        = "userRole"
+	// Impersonation tokens give support staff read-only access to a user's account
+	ImpersonationTokenTTL  = time.Hour
+	ImpersonationTokenName = "Read-only support access"
+)
+
+var ErrAccountSuspended = errors.New("account suspended")
+
+// Retrieves the role of the user making the request. Requests without a role in the
+// context, such as those authenticated by a personal access token, have the
+// permissions of a regular user.
+func GetUserRoleFromContext(ctx ContextGetter) string {
+	val, exists := ctx.Get(UserRoleKey)
+	if !exists {
+		return models.RoleUser
+	}
+	role, ok := val.(string)
+	if !ok {
+		return models.RoleUser
+	}
+	return role
+}
+
+func UserHasRole(ctx ContextGetter, role string) bool {
+	return models.HasRole(GetUserRoleFromContext(ctx), role)
+}
+
+func GenerateAuditLogNextPageURL(backendURL string, newCutoff uint) string {
+	return generateNextPageURL(backendURL, AdminPath+AuditLogPath, newCutoff, nil)
+}
+
+// Users are paged by username, since user IDs are not ordered
+func GenerateAdminUsersNextPageURL(backendURL string, lastUsername string, search string) string {
+	params := url.Values{}
+	params.Set(AdminUserAfterKey, lastUsername)
+	if search != "" {
+		params.Set(AdminUserSearchKey, search)
+	}
+	return fmt.Sprintf("%s/auth%s%s?%s", backendURL, AdminPath, AdminUserPath, params.Encode())
+}
+
+// Returns an error telling the user why, and until when, their account is suspended
+func SuspensionError(suspension *models.Suspension) error {
+	err := ErrAccountSuspended
+	if suspension.SuspendedUntil.Valid {
+		err = fmt.Errorf("%w until %s", err, suspension.SuspendedUntil.Time.UTC().Format(time.RFC1123))
+	}
+	if suspension.SuspensionReason.Valid {
+		err = fmt.Errorf("%w: %s", err, suspension.SuspensionReason.String)
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestSuspensionError(t *testing.T) {
+	until := time.Date(2030, time.January, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name       string
+		suspension models.Suspension
+		want       string
+	}{
+		{
+			"Indefinite suspension without reason",
+			models.Suspension{SuspendedAt: null.TimeFrom(until)},
+			"account suspended",
+		},
+		{
+			"Suspension with end and reason",
+			models.Suspension{
+				SuspendedAt:      null.TimeFrom(until),
+				SuspendedUntil:   null.TimeFrom(until),
+				SuspensionReason: null.StringFrom("Spam"),
+			},
+			"account suspended until Wed, 02 Jan 2030 15:04:05 UTC: Spam",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SuspensionError(&tt.suspension)
+			if err.Error() != tt.want {
+				t.Errorf("SuspensionError() = %q, want %q", err.Error(), tt.want)
+			}
+			if !errors.Is(err, ErrAccountSuspended) {
+				t.Error("SuspensionError() does not wrap ErrAccountSuspended")
+			}
+		})
+	}
+}
+
+func TestUserHasRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     any
+		required string
+		want     bool
+	}{
+		{"No role is a regular user", nil, models.RoleUser, true},
+		{"No role is not a moderator", nil, models.RoleModerator, false},
+		{"Admin is a moderator", models.RoleAdmin, models.RoleModerator, true},
+		{"Moderator is not an admin", models.RoleModerator, models.RoleAdmin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := CreateTestContextAndRecorder()
+			if tt.role != nil {
+				c.Set(UserRoleKey, tt.role)
+			}
+			if got := UserHasRole(c, tt.required); got != tt.want {
+				t.Errorf("UserHasRole() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
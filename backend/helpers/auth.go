@@ -4,6 +4,7 @@ import (
 	"log"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/gin-contrib/sessions"
@@ -14,7 +15,16 @@ import (
 
 const (
 	UserIDKey         = "userID"
+	LoginTimeKey      = "loginTime"
+	LoginMethodKey    = "loginMethod"
 	RouteIfSuccessful = "/posts"
+	// Login methods recorded in the session
+	LoginMethodPassword = "password"
+	LoginMethodProvider = "provider"
+	// A login through an identity provider within this window can be used in place of
+	// the current password, since accounts created through a provider have no usable
+	// password
+	ReauthenticationWindow = 10 * time.Minute
 )
 
 func IsEmptyUserPass(user *models.UserCredentials) bool {
@@ -70,6 +80,20 @@ func IsValidSession(session SessionGetter) bool {
 	return userID != nil
 }
 
+// Retrieves the time that the user logged in to create the session. Sessions created
+// before the login time was recorded are treated as being created at the Unix epoch.
+func GetSessionLoginTime(session SessionGetter) time.Time {
+	loginTime, _ := session.Get(LoginTimeKey).(int64)
+	return time.Unix(loginTime, 0)
+}
+
+// Checks if the session was created by logging in through an identity provider within
+// the reauthentication window
+func IsRecentProviderLogin(session SessionGetter, now time.Time) bool {
+	method, _ := session.Get(LoginMethodKey).(string)
+	return method == LoginMethodProvider && now.Sub(GetSessionLoginTime(session)) <= ReauthenticationWindow
+}
+
 type SessionGetter interface {
 	Get(interface{}) interface{}
 }
@@ -100,8 +124,19 @@ func ExtractSignupUserCredentials(ctx postFormer) *models.SignupUserCredentials
 }
 
 func SaveSession(ctx *gin.Context, user *models.User) error {
+	return saveSession(ctx, user, LoginMethodPassword)
+}
+
+// Saves the session of a user who logged in through an identity provider
+func SaveProviderSession(ctx *gin.Context, user *models.User) error {
+	return saveSession(ctx, user, LoginMethodProvider)
+}
+
+func saveSession(ctx *gin.Context, user *models.User, method string) error {
 	session := sessions.Default(ctx)
 	session.Set(UserIDKey, user.ID)
+	session.Set(LoginTimeKey, time.Now().Unix())
+	session.Set(LoginMethodKey, method)
 	log.Printf("Saving userID: %v", user.ID)
 	if err := session.Save(); err != nil {
 		return err
@@ -3,6 +3,7 @@ package helpers
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ryanozx/skillnet/models"
 )
@@ -193,6 +194,37 @@ func TestIsValidSession(t *testing.T) {
 	}
 }
 
+func TestIsRecentProviderLogin(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		method    interface{}
+		loginTime time.Time
+		want      bool
+	}{
+		{"Recent provider login", LoginMethodProvider, now.Add(-time.Minute), true},
+		{"Old provider login", LoginMethodProvider, now.Add(-ReauthenticationWindow - time.Minute), false},
+		{"Recent password login", LoginMethodPassword, now.Add(-time.Minute), false},
+		{"No login method", nil, now.Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &MockSessionStore{
+				Values: map[interface{}]interface{}{
+					UserIDKey:    testUserID,
+					LoginTimeKey: tt.loginTime.Unix(),
+				},
+			}
+			if tt.method != nil {
+				session.Values[LoginMethodKey] = tt.method
+			}
+			if got := IsRecentProviderLogin(session, now); got != tt.want {
+				t.Errorf("IsRecentProviderLogin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 type mockPostFormer struct {
 	Params map[string](string)
 }
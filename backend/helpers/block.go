@@ -0,0 +1,8 @@
+package helpers
+
+const (
+	BlockPath     = "/block"
+	MutePath      = "/mute"
+	BlockListPath = "/user/blocks"
+	MuteListPath  = "/user/mutes"
+)
@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+const (
+	BookmarkPath            = "/bookmarks"
+	BookmarkCollectionPath  = "/bookmarks/collections"
+	SharedCollectionPath    = "/bookmarks/shared"
+	BookmarkCollectionKey   = "collection"
+	BookmarkCollectionIDKey = "collectionid"
+	ShareTokenKey           = "token"
+	MaxCollectionNameLength = 50
+	shareTokenBytes         = 16
+)
+
+var (
+	ErrCollectionNameTooLong = fmt.Errorf("collection names cannot be longer than %d characters", MaxCollectionNameLength)
+	ErrNoCollectionName      = errors.New("collections must have a name")
+)
+
+// Retrieves the ID of the collection to filter bookmarks by, or to put a bookmark in
+func GetBookmarkCollectionFromQuery(ctx DefaultQueryer) (*NullableUint, error) {
+	return validateUnsignedOrEmptyQuery(ctx, BookmarkCollectionKey)
+}
+
+// Retrieves collectionID from context; the collectionID is inserted into the context by
+// the router when parsing ("/bookmarks/collections/:collectionid")
+func GetBookmarkCollectionIDFromContext(ctx ParamGetter) (uint, error) {
+	return getUnsignedValFromContext(ctx, BookmarkCollectionIDKey)
+}
+
+// Retrieves the share token from context; the token is inserted into the context by
+// the router when parsing ("/bookmarks/shared/:token")
+func GetShareTokenFromContext(ctx ParamGetter) string {
+	return getParamFromContext(ctx, ShareTokenKey)
+}
+
+// Trims the name of the collection in place, and returns an error if the collection
+// cannot be saved. Collections being updated may leave out their name to keep it.
+func ValidateBookmarkCollection(input *models.BookmarkCollectionInput, isUpdate bool) error {
+	input.Name = strings.TrimSpace(input.Name)
+	switch {
+	case input.Name == "" && !isUpdate:
+		return ErrNoCollectionName
+	case len([]rune(input.Name)) > MaxCollectionNameLength:
+		return ErrCollectionNameTooLong
+	}
+	return nil
+}
+
+// Generates the token in the link to a shared collection
+func GenerateShareToken() (string, error) {
+	buf := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func GenerateBookmarkNextPageURL(backendURL string, newCutoff uint, collectionID *NullableUint) string {
+	additionalParams := map[string]interface{}{}
+	if !collectionID.IsNull() {
+		val, _ := collectionID.GetValue()
+		additionalParams[BookmarkCollectionKey] = val
+	}
+	return generateNextPageURL(backendURL, BookmarkPath, newCutoff, additionalParams)
+}
+
+// Shared collections are viewed without logging in, so their pages are not under /auth
+func GenerateSharedCollectionNextPageURL(backendURL string, token string, newCutoff uint) string {
+	return fmt.Sprintf("%s%s/%s?%s=%d", backendURL, SharedCollectionPath, token, CutoffKey, newCutoff)
+}
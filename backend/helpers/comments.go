@@ -1,5 +1,7 @@
 package helpers
 
+import "fmt"
+
 const (
 	CommentPath  = "/comments"
 	CommentIDKey = "commentid"
@@ -16,3 +18,8 @@ func GenerateCommentNextPageURL(backendURL string, postID uint, newCutoff uint)
 		PostIDQueryKey: postID,
 	})
 }
+
+// Key of the cached comment count of the post
+func CommentCountKey(postID uint) string {
+	return fmt.Sprintf("comments:post:%d", postID)
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ryanozx/skillnet/models"
 )
@@ -32,6 +34,28 @@ type GoogleCloudEnv struct {
 	Filepath string
 }
 
+type OIDCEnv struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+type ContentFilterEnv struct {
+	BannedWords     []string
+	MaxLength       int
+	MaxLinks        int
+	NewAccountAge   time.Duration
+	DuplicateWindow time.Duration
+}
+
+type SMTPEnv struct {
+	Username string
+	Password string
+	From     string
+	BaseEnv
+}
+
 func RetrieveRedisEnv() *RedisEnv {
 	sessionKey := os.Getenv("REDIS_SESSION_KEY")
 	host := os.Getenv("REDISHOST")
@@ -62,6 +86,89 @@ func RetrieveGoogleCloudEnv() *GoogleCloudEnv {
 	return &env
 }
 
+// Single sign-on is disabled if OIDC_ISSUER_URL is not set
+func RetrieveOIDCEnv() *OIDCEnv {
+	issuerURL := os.Getenv("OIDC_ISSUER_URL")
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	clientSecret := os.Getenv("OIDC_CLIENT_SECRET")
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	env := OIDCEnv{
+		IssuerURL:    issuerURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+	}
+	return &env
+}
+
+func (env *OIDCEnv) IsEnabled() bool {
+	return env.IssuerURL != ""
+}
+
+// Emails are written to the log instead of being sent if SMTP_HOST is not set
+func RetrieveSMTPEnv() *SMTPEnv {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	username := os.Getenv("SMTP_USERNAME")
+	password := os.Getenv("SMTP_PASSWORD")
+	from := os.Getenv("SMTP_FROM")
+	env := SMTPEnv{
+		Username: username,
+		Password: password,
+		From:     from,
+		BaseEnv: BaseEnv{
+			Host: host,
+			Port: port,
+		},
+	}
+	return &env
+}
+
+func (env *SMTPEnv) IsEnabled() bool {
+	return env.Host != ""
+}
+
+// Content filter settings fall back to defaults if they are not set. Banned words are
+// given as a comma-separated list; no words are banned if it is empty.
+func RetrieveContentFilterEnv() *ContentFilterEnv {
+	var bannedWords []string
+	if words := os.Getenv("CONTENT_BANNED_WORDS"); words != "" {
+		bannedWords = strings.Split(words, ",")
+	}
+	env := ContentFilterEnv{
+		BannedWords:     bannedWords,
+		MaxLength:       getEnvInt("CONTENT_MAX_LENGTH", 5000),
+		MaxLinks:        getEnvInt("CONTENT_NEW_ACCOUNT_MAX_LINKS", 2),
+		NewAccountAge:   getEnvDuration("CONTENT_NEW_ACCOUNT_AGE", 72*time.Hour),
+		DuplicateWindow: getEnvDuration("CONTENT_DUPLICATE_WINDOW", 10*time.Minute),
+	}
+	return &env
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	num, err := strconv.Atoi(val)
+	if err != nil {
+		panic(err)
+	}
+	return num
+}
+
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	duration, err := time.ParseDuration(val)
+	if err != nil {
+		panic(err)
+	}
+	return duration
+}
+
 func RetrieveWebAppEnv() *BaseEnv {
 	addr := os.Getenv("WEBAPP_ADDRESS")
 	port := os.Getenv("WEBAPP_PORT")
@@ -0,0 +1,29 @@
+package helpers
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Exports are stored under "<userID>/" in this bucket
+	DataExportBucket = "skillnet-data-exports"
+	// Download links of exports expire after this long
+	DataExportTTL      = 7 * 24 * time.Hour
+	DataExportTokenKey = "token"
+	DataExportPath     = "/user/export"
+	DataExportFileName = "skillnet-export.zip"
+)
+
+func DataExportObjectName(userID string, exportID uint) string {
+	return fmt.Sprintf("%s/%d.zip", userID, exportID)
+}
+
+// Returns the prefix of the names of all the user's exports
+func DataExportObjectPrefix(userID string) string {
+	return userID + "/"
+}
+
+func DataExportDownloadURL(backendAddress string, token string) string {
+	return fmt.Sprintf("%s%s/download?%s=%s", backendAddress, DataExportPath, DataExportTokenKey, token)
+}
@@ -0,0 +1,12 @@
+package helpers
+
+const (
+	FollowPath        = "/follow"
+	FollowingListPath = "/user/following"
+	MembershipPath    = "/membership"
+	HomeFeedPath      = "/home"
+)
+
+func GenerateHomeFeedNextPageURL(backendURL string, newCutoff uint) string {
+	return generateNextPageURL(backendURL, HomeFeedPath, newCutoff, nil)
+}
@@ -5,3 +5,8 @@ import "fmt"
 func GenerateLikeID(userID string, postID uint) string {
 	return fmt.Sprintf("%s%v", userID, postID)
 }
+
+// Key of the cached like count of the post
+func LikeCountKey(postID uint) string {
+	return fmt.Sprintf("likes:post:%d", postID)
+}
@@ -0,0 +1,51 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"net/mail"
+	"net/smtp"
+)
+
+type Mailer interface {
+	SendMail(to string, subject string, body string) error
+}
+
+// Returns a mailer that sends emails through the SMTP server in the environmental
+// variables, or one that logs emails if no SMTP server is configured
+func NewMailer() Mailer {
+	env := RetrieveSMTPEnv()
+	if !env.IsEnabled() {
+		log.Println("SMTP host not set, emails will be logged instead of sent")
+		return &LogMailer{}
+	}
+	return &SMTPMailer{
+		env: env,
+	}
+}
+
+type SMTPMailer struct {
+	env *SMTPEnv
+}
+
+func (m *SMTPMailer) SendMail(to string, subject string, body string) error {
+	from, err := mail.ParseAddress(m.env.From)
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		from.String(), to, subject, body)
+	var auth smtp.Auth
+	if m.env.Username != "" {
+		auth = smtp.PlainAuth("", m.env.Username, m.env.Password, m.env.Host)
+	}
+	return smtp.SendMail(m.env.Address(), auth, from.Address, []string{to}, []byte(msg))
+}
+
+// LogMailer writes emails to the log; used during development
+type LogMailer struct{}
+
+func (m *LogMailer) SendMail(to string, subject string, body string) error {
+	log.Printf("Email to %s\nSubject: %s\n%s", to, subject, body)
+	return nil
+}
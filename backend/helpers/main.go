@@ -48,6 +48,14 @@ func OutputData(ctx JSONer, obj any) {
 	})
 }
 
+// Adds data to response body along with status code 202 Accepted, for content that
+// has been saved but is not shown to other users yet
+func OutputAcceptedData(ctx JSONer, obj any) {
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"data": obj,
+	})
+}
+
 type JSONer interface {
 	JSON(code int, obj any)
 }
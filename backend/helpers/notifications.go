@@ -1,11 +1,42 @@
 package helpers
 
 import (
+	"context"
+	"encoding/json"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/ryanozx/skillnet/models"
 )
 
+// Returns the Redis key of the sorted set holding the user's pending notifications;
+// the same key is used as the channel that notifications are published to
+func NotificationKey(userID string) string {
+	return "notifications:" + userID
+}
+
+// Adds the notification to the receiver's pending notifications and publishes it to
+// the receiver's channel
+func PublishNotification(ctx context.Context, client *redis.Client, notif *models.Notification) error {
+	// Marshalling the notification to JSON
+	notifJson, err := json.Marshal(notif)
+	if err != nil {
+		return err
+	}
+
+	// Adding the notification to the receiver's sorted set in Redis
+	score := float64(time.Now().Unix())
+	receiverKey := NotificationKey(notif.ReceiverId)
+	err = client.ZAdd(ctx, receiverKey, redis.Z{Score: score, Member: notifJson}).Err()
+	if err != nil {
+		return err
+	}
+
+	// Publish the notification to the receiver's channel
+	formattedMessage := "data: " + string(notifJson) + "\n\n"
+	return client.Publish(ctx, receiverKey, formattedMessage).Err()
+}
+
 func GenerateEventNotification(senderID string, receiverID string, notifText string) *models.Notification {
 	output := models.Notification{
 		SenderId:   senderID,
@@ -25,3 +56,8 @@ func GenerateCommentNotification(commenter *models.User, receiverID string) *mod
 	notifText := commenter.Username + " commented on your post"
 	return GenerateEventNotification(commenter.ID, receiverID, notifText)
 }
+
+func GenerateEndorsementNotification(endorser *models.User, receiverID string, skillName string) *models.Notification {
+	notifText := endorser.Username + " endorsed your " + skillName + " skill"
+	return GenerateEventNotification(endorser.ID, receiverID, notifText)
+}
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	OIDCStartPath    = "/login/oidc/start"
+	OIDCCallbackPath = "/login/oidc/callback"
+	OIDCLinkPath     = "/login/oidc/link"
+	OIDCStateKey     = "oidcState"
+	OIDCNonceKey     = "oidcNonce"
+	OIDCVerifierKey  = "oidcVerifier"
+	// Client page where the user enters their password to link an identity to their account
+	OIDCLinkClientPath = "/login/link"
+	// Identity waiting for the user to confirm the link to their account
+	OIDCLinkUserIDKey  = "oidcLinkUserID"
+	OIDCLinkIssuerKey  = "oidcLinkIssuer"
+	OIDCLinkSubjectKey = "oidcLinkSubject"
+	OIDCLinkEmailKey   = "oidcLinkEmail"
+	OIDCLinkTimeKey    = "oidcLinkTime"
+	// The link must be confirmed within this time of signing in with the provider
+	OIDCLinkTTL = 10 * time.Minute
+)
+
+// Derives a SkillNet username for an account created through single sign-on from the
+// provider's preferred username, falling back to the local part of the email address
+func GenerateOIDCUsername(preferredUsername string, email string) string {
+	username := preferredUsername
+	if username == "" {
+		username, _, _ = strings.Cut(email, "@")
+	}
+	username = regexp.MustCompile(`\s`).ReplaceAllString(username, "")
+	if username == "" {
+		username = "user"
+	}
+	return username
+}
+
+// Appends a random suffix to a username, for use when the username is already taken
+func AddRandomUsernameSuffix(username string) (string, error) {
+	const suffixBytes = 3
+	buf := make([]byte, suffixBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return username + "-" + hex.EncodeToString(buf), nil
+}
+
+// Retrieves a string value from the session, returning an empty string if the
+// value is absent
+func GetSessionString(session SessionGetter, key string) string {
+	val, _ := session.Get(key).(string)
+	return val
+}
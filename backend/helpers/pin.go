@@ -0,0 +1,27 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+const (
+	PinPath    = "/pins"
+	PinFeedKey = "feed"
+	// Most posts that can be pinned to a single community or project feed at once
+	MaxPinnedPosts = 3
+)
+
+var ErrInvalidPinFeed = fmt.Errorf("feed must be one of %s", strings.Join(models.PinFeeds, ", "))
+
+// Retrieves the feed to pin a post to from context; the feed is inserted into the
+// context by the router when parsing ("/posts/:postid/pins/:feed")
+func GetPinFeedFromContext(ctx ParamGetter) (string, error) {
+	feed := getParamFromContext(ctx, PinFeedKey)
+	if !containsString(models.PinFeeds, feed) {
+		return "", ErrInvalidPinFeed
+	}
+	return feed, nil
+}
@@ -1,9 +1,28 @@
 package helpers
 
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
 const (
 	PostPath       = "/posts"
 	PostIDKey      = "postid"
 	PostIDQueryKey = "post"
+	PostSortKey    = "sort"
+	PostWindowKey  = "window"
+	PostCursorKey  = "cursor"
+)
+
+var (
+	ErrInvalidPostCursor = errors.New("invalid cursor")
+	ErrInvalidPostSort   = fmt.Errorf("sort must be one of %s", strings.Join(models.PostSorts, ", "))
+	ErrInvalidPostWindow = fmt.Errorf("window must be one of %s", strings.Join(models.RankingWindows, ", "))
 )
 
 // Retrieves postID from context; the postID is inserted into the context
@@ -16,6 +35,72 @@ func GetPostIDFromQuery(ctx DefaultQueryer) (uint, error) {
 	return getUnsignedValFromQuery(ctx, PostIDQueryKey)
 }
 
+// Retrieves the order of the feed from the query, defaulting to newest first
+func GetPostSortFromQuery(ctx DefaultQueryer) (string, error) {
+	sort := ctx.DefaultQuery(PostSortKey, models.PostSortNew)
+	if !containsString(models.PostSorts, sort) {
+		return "", ErrInvalidPostSort
+	}
+	return sort, nil
+}
+
+// Retrieves the window that top posts are ranked over from the query, defaulting to
+// all time
+func GetPostWindowFromQuery(ctx DefaultQueryer) (string, error) {
+	window := ctx.DefaultQuery(PostWindowKey, models.RankingWindowAll)
+	if !containsString(models.RankingWindows, window) {
+		return "", ErrInvalidPostWindow
+	}
+	return window, nil
+}
+
+// Retrieves the position in a ranked feed to continue from. Returns nil if the query
+// has no cursor, i.e. the first page is requested.
+func GetPostCursorFromQuery(ctx DefaultQueryer) (*models.PostCursor, error) {
+	encoded := ctx.DefaultQuery(PostCursorKey, "")
+	if encoded == "" {
+		return nil, nil
+	}
+	return DecodePostCursor(encoded)
+}
+
+// Cursors are opaque to clients, who only pass them back in the next page URL
+func EncodePostCursor(cursor *models.PostCursor) string {
+	raw := strconv.FormatFloat(cursor.Score, 'g', -1, 64) + ":" + strconv.FormatUint(uint64(cursor.PostID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodePostCursor(encoded string) (*models.PostCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidPostCursor
+	}
+	scoreStr, postIDStr, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, ErrInvalidPostCursor
+	}
+	score, err := strconv.ParseFloat(scoreStr, 64)
+	if err != nil {
+		return nil, ErrInvalidPostCursor
+	}
+	postID, err := strconv.ParseUint(postIDStr, 10, 32)
+	if err != nil {
+		return nil, ErrInvalidPostCursor
+	}
+	return &models.PostCursor{Score: score, PostID: uint(postID)}, nil
+}
+
 func GeneratePostNextPageURL(backendURL string, newCutoff uint, additionalParams map[string]interface{}) string {
 	return generateNextPageURL(backendURL, PostPath, newCutoff, additionalParams)
 }
+
+// Generates the URL of the next page of a hot or top feed, which continues from the cursor
+func GenerateRankedPostNextPageURL(backendURL string, sort string, window string, cursor *models.PostCursor,
+	additionalParams map[string]interface{}) string {
+	nextPageURL := fmt.Sprintf("%s/auth%s?%s=%s&%s=%s&%s=%s", backendURL, PostPath, PostSortKey, sort,
+		PostWindowKey, window, PostCursorKey, EncodePostCursor(cursor))
+	for paramKey, paramVal := range additionalParams {
+		nextPageURL += fmt.Sprintf("&%s=%v", paramKey, paramVal)
+	}
+	return nextPageURL
+}
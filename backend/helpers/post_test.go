@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+func TestDecodePostCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		want    *models.PostCursor
+		wantErr error
+	}{
+		{"Round trip", EncodePostCursor(&models.PostCursor{Score: 37812.30102999566, PostID: 42}),
+			&models.PostCursor{Score: 37812.30102999566, PostID: 42}, nil},
+		{"Zero score", EncodePostCursor(&models.PostCursor{PostID: 7}), &models.PostCursor{PostID: 7}, nil},
+		{"Not base64", "!!", nil, ErrInvalidPostCursor},
+		{"No separator", base64.RawURLEncoding.EncodeToString([]byte("12")), nil, ErrInvalidPostCursor},
+		{"Bad score", base64.RawURLEncoding.EncodeToString([]byte("abc:12")), nil, ErrInvalidPostCursor},
+		{"Bad post ID", base64.RawURLEncoding.EncodeToString([]byte("1.5:-3")), nil, ErrInvalidPostCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePostCursor(tt.encoded)
+			if err != tt.wantErr {
+				t.Errorf("DecodePostCursor() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodePostCursor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package helpers
 
+import "net/url"
+
 const (
-	ProjectPath       = "/projects"
-	ProjectIDQueryKey = "project"
-	ProjectIDKey      = "projectid"
+	ProjectPath         = "/projects"
+	ProjectMatchingPath = "/matching"
+	ProjectIDQueryKey   = "project"
+	ProjectIDKey        = "projectid"
 )
 
 func GetProjectIDFromContext(ctx ParamGetter) (uint, error) {
@@ -14,7 +17,7 @@ func GetProjectIDFromQuery(ctx DefaultQueryer) (*NullableUint, error) {
 	return validateUnsignedOrEmptyQuery(ctx, ProjectIDQueryKey)
 }
 
-func GenerateProjectsNextPageURL(backendURL string, newCutoff uint, communityID *NullableUint, username string) string {
+func GenerateProjectsNextPageURL(backendURL string, newCutoff uint, communityID *NullableUint, username string, skills []string) string {
 	params := make(map[string]interface{})
 	if !communityID.IsNull() {
 		communityIDVal, _ := communityID.GetValue()
@@ -23,5 +26,10 @@ func GenerateProjectsNextPageURL(backendURL string, newCutoff uint, communityID
 	if username != "" {
 		params[UsernameQueryKey] = username
 	}
-	return generateNextPageURL(backendURL, ProjectPath, newCutoff, params)
+	nextPageURL := generateNextPageURL(backendURL, ProjectPath, newCutoff, params)
+	// Skills are repeated, so they cannot be passed as additional parameters
+	for _, skill := range skills {
+		nextPageURL += "&" + SkillQueryKey + "=" + url.QueryEscape(skill)
+	}
+	return nextPageURL
 }
@@ -0,0 +1,22 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+const (
+	RecommendationPath = "/recommendations"
+	DismissalPath      = "/dismissals"
+)
+
+var (
+	ErrInvalidRecommendationKind = fmt.Errorf("kind must be one of %s", strings.Join(models.RecommendationKinds, ", "))
+)
+
+// Checks that kind is one of the kinds of things that can be recommended
+func IsValidRecommendationKind(kind string) bool {
+	return containsString(models.RecommendationKinds, kind)
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+const (
+	OpenRolePath                 = "/roles"
+	ApplicationPath              = "/applications"
+	UserApplicationPath          = "/user/applications"
+	ApplicationReviewPath        = "/review"
+	OpenRoleIDKey                = "roleid"
+	ApplicationIDKey             = "applicationid"
+	ApplicationStatusKey         = "status"
+	MaxOpenRoleTitleLength       = 100
+	MaxOpenRoleDescriptionLength = 2000
+	MaxApplicationMessageLength  = 2000
+)
+
+var (
+	ErrInvalidApplicationStatus = errors.New("applications can only be accepted or rejected")
+	ErrInvalidCommitment        = fmt.Errorf("commitment must be one of %s", strings.Join(models.Commitments, ", "))
+	ErrInvalidOpenRoleStatus    = fmt.Errorf("role status must be one of %s", strings.Join(models.OpenRoleStatuses, ", "))
+	ErrApplicationTooLong       = fmt.Errorf("application messages cannot be longer than %d characters", MaxApplicationMessageLength)
+	ErrNoOpenRoleTitle          = errors.New("roles must have a title")
+	ErrOpenRoleDescTooLong      = fmt.Errorf("role descriptions cannot be longer than %d characters", MaxOpenRoleDescriptionLength)
+	ErrOpenRoleTitleTooLong     = fmt.Errorf("role titles cannot be longer than %d characters", MaxOpenRoleTitleLength)
+)
+
+// Retrieves roleID from context; the roleID is inserted into the context by the router
+// when parsing ("/projects/:projectid/roles/:roleid")
+func GetOpenRoleIDFromContext(ctx ParamGetter) (uint, error) {
+	return getUnsignedValFromContext(ctx, OpenRoleIDKey)
+}
+
+// Retrieves applicationID from context; the applicationID is inserted into the context
+// by the router when parsing ("/projects/:projectid/applications/:applicationid")
+func GetApplicationIDFromContext(ctx ParamGetter) (uint, error) {
+	return getUnsignedValFromContext(ctx, ApplicationIDKey)
+}
+
+// Trims the title and normalises the skills of the role in place, and returns an error
+// if the role cannot be posted
+func ValidateOpenRole(input *models.OpenRoleInput) error {
+	input.Title = strings.TrimSpace(input.Title)
+	input.Skills = NormaliseSkillNames(input.Skills)
+	switch {
+	case input.Title == "":
+		return ErrNoOpenRoleTitle
+	case len([]rune(input.Title)) > MaxOpenRoleTitleLength:
+		return ErrOpenRoleTitleTooLong
+	case len([]rune(input.Description)) > MaxOpenRoleDescriptionLength:
+		return ErrOpenRoleDescTooLong
+	case !containsString(models.Commitments, input.Commitment):
+		return ErrInvalidCommitment
+	case input.Status != "" && !containsString(models.OpenRoleStatuses, input.Status):
+		return ErrInvalidOpenRoleStatus
+	}
+	return ValidateSkillNames(input.Skills)
+}
+
+func IsValidApplicationStatus(status string) bool {
+	return status == models.ApplicationPending || status == models.ApplicationAccepted || status == models.ApplicationRejected
+}
+
+// Applications can only be reviewed by accepting or rejecting them
+func IsValidApplicationReview(status string) bool {
+	return status == models.ApplicationAccepted || status == models.ApplicationRejected
+}
+
+// Tells the project owner that a user has applied to one of the project's roles
+func GenerateApplicationNotification(application *models.RoleApplication) *models.Notification {
+	role := &application.OpenRole
+	notifText := fmt.Sprintf("%s applied to be %s on %s", application.Applicant.Username, role.Title, role.Project.Name)
+	return GenerateEventNotification(application.ApplicantID, role.Project.OwnerID, notifText)
+}
+
+// Tells the applicant whether their application was accepted or rejected
+func GenerateApplicationReviewNotification(application *models.RoleApplication) *models.Notification {
+	role := &application.OpenRole
+	var notifText string
+	if application.Status == models.ApplicationAccepted {
+		notifText = fmt.Sprintf("Your application to be %s on %s was accepted. Welcome to the project!", role.Title, role.Project.Name)
+	} else {
+		notifText = fmt.Sprintf("Your application to be %s on %s was not accepted", role.Title, role.Project.Name)
+	}
+	return GenerateEventNotification(role.Project.OwnerID, application.ApplicantID, notifText)
+}
@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+const (
+	ReportPath      = "/reports"
+	ModerationPath  = "/moderation"
+	ReportIDKey     = "reportid"
+	ReportStatusKey = "status"
+)
+
+func GetReportIDFromContext(ctx ParamGetter) (uint, error) {
+	return getUnsignedValFromContext(ctx, ReportIDKey)
+}
+
+func GenerateReportsNextPageURL(backendURL string, newCutoff uint, status string) string {
+	params := map[string]interface{}{}
+	if status != "" {
+		params[ReportStatusKey] = status
+	}
+	return generateNextPageURL(backendURL, ModerationPath+ReportPath, newCutoff, params)
+}
+
+func IsValidReportTarget(targetType string) bool {
+	return containsString(models.ReportTargets, targetType)
+}
+
+func IsValidReportReason(reason string) bool {
+	return containsString(models.ReportReasons, reason)
+}
+
+func IsValidReportStatus(status string) bool {
+	return status == models.ReportOpen || status == models.ReportActioned || status == models.ReportDismissed
+}
+
+func IsValidReportAction(action string) bool {
+	switch action {
+	case models.ReportActionRemoveContent, models.ReportActionWarnUser, models.ReportActionSuspendUser, models.ReportActionDismiss:
+		return true
+	}
+	return false
+}
+
+// Content other than communities and users is identified by its numeric ID
+func IsValidReportTargetID(targetType string, targetID string) bool {
+	switch targetType {
+	case models.ReportTargetCommunity, models.ReportTargetUser:
+		return strings.TrimSpace(targetID) != ""
+	}
+	_, err := strconv.ParseUint(targetID, 10, 32)
+	return err == nil
+}
+
+// Reason shown to a user who is suspended as the outcome of a report
+func ReportSuspensionReason(reason string, note string) string {
+	output := "reported for " + strings.ReplaceAll(reason, "_", " ")
+	if note != "" {
+		output += " - " + note
+	}
+	return output
+}
+
+// Tells the reporter what a moderator decided to do about their report
+func GenerateReportOutcomeNotification(report *models.Report) *models.Notification {
+	outcome := "took no action"
+	switch report.Action.String {
+	case models.ReportActionRemoveContent:
+		outcome = "removed it"
+	case models.ReportActionWarnUser:
+		outcome = "warned its owner"
+	case models.ReportActionSuspendUser:
+		outcome = "suspended its owner"
+	}
+	notifText := fmt.Sprintf("A moderator reviewed your report of a %s and %s. Thank you for keeping SkillNet safe.", report.TargetType, outcome)
+	return GenerateEventNotification("", report.ReporterID, notifText)
+}
+
+func GenerateWarningNotification(report *models.Report, note string) *models.Notification {
+	notifText := fmt.Sprintf("A moderator has warned you about your %s, which was reported for %s.",
+		report.TargetType, strings.ReplaceAll(report.Reason, "_", " "))
+	if note != "" {
+		notifText += " " + note
+	}
+	return GenerateEventNotification("", report.TargetUserID, notifText)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	SearchPath             = "/search"
+	SuggestPath            = "/suggest"
+	SearchTermKey          = "q"
+	SearchLimitKey         = "limit"
+	SearchTypeKey          = "type"
+	SearchCommunityKey     = "community"
+	SearchCreatedAfterKey  = "created_after"
+	SearchCreatedBeforeKey = "created_before"
+	SearchCursorKey        = "cursor"
+	SearchSkillKey         = "skill"
+	DefaultSearchLimit     = 10
+	MaxSearchLimit         = 50
+	MaxSearchTermLength    = 256
+	DefaultSuggestLimit    = 8
+	// Shorter terms match too many names to be useful
+	MinSuggestTermLength = 2
+)
+
+var (
+	ErrInvalidSearchCursor = errors.New("invalid cursor")
+	ErrInvalidSearchDate   = errors.New("dates must be in the format YYYY-MM-DD or RFC 3339")
+	ErrInvalidSearchType   = errors.New("type must be a comma-separated list of " + strings.Join(models.SearchResultTypes, ", "))
+)
+
+var trailingSearchWord = regexp.MustCompile(`[\pL\pM\pN]+$`)
+
+// Splits a search term into the text to be searched for as entered and a final word
+// to be matched as a prefix, so that results appear while the user is still typing.
+// The prefix only ever contains letters, marks and digits, so it can be given to
+// to_tsquery without any tsquery syntax leaking through; the rest of the term is meant
+// for websearch_to_tsquery, which accepts any input. prefix is empty if the term does
+// not end in a word, or ends in a negated or quoted word.
+func SplitSearchPrefix(searchTerm string) (rest string, prefix string) {
+	loc := trailingSearchWord.FindStringIndex(searchTerm)
+	if loc == nil {
+		return searchTerm, ""
+	}
+	rest, prefix = searchTerm[:loc[0]], searchTerm[loc[0]:]
+	isNegated := strings.HasSuffix(rest, "-")
+	isQuoted := strings.Count(rest, `"`)%2 == 1
+	if isNegated || isQuoted {
+		return searchTerm, ""
+	}
+	return rest, prefix
+}
+
+// Retrieves the comma-separated list of result types to search for from the query;
+// returns an empty list if the types are not specified
+func GetSearchTypesFromQuery(ctx DefaultQueryer) ([]string, error) {
+	types := []string{}
+	query := ctx.DefaultQuery(SearchTypeKey, "")
+	if query == "" {
+		return types, nil
+	}
+	for _, resultType := range strings.Split(query, ",") {
+		if !isSearchResultType(resultType) {
+			return nil, ErrInvalidSearchType
+		}
+		types = append(types, resultType)
+	}
+	return types, nil
+}
+
+func isSearchResultType(resultType string) bool {
+	for _, validType := range models.SearchResultTypes {
+		if resultType == validType {
+			return true
+		}
+	}
+	return false
+}
+
+// Retrieves the date stored under key from the query, as either a date, which is taken
+// to be midnight UTC, or an RFC 3339 timestamp; returns a null time if key is not present
+func GetSearchDateFromQuery(ctx DefaultQueryer, key string) (null.Time, error) {
+	query := ctx.DefaultQuery(key, "")
+	if query == "" {
+		return null.Time{}, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if date, err := time.Parse(layout, query); err == nil {
+			return null.TimeFrom(date), nil
+		}
+	}
+	return null.Time{}, ErrInvalidSearchDate
+}
+
+// Retrieves the cursor marking the end of the previous page of search results from the
+// query; returns nil if the cursor is not present
+func GetSearchCursorFromQuery(ctx DefaultQueryer) (*models.SearchCursor, error) {
+	query := ctx.DefaultQuery(SearchCursorKey, "")
+	if query == "" {
+		return nil, nil
+	}
+	return DecodeSearchCursor(query)
+}
+
+// Cursors are opaque to clients, so that their contents can change without breaking
+// clients
+func EncodeSearchCursor(cursor *models.SearchCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeSearchCursor(encoded string) (*models.SearchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidSearchCursor
+	}
+	var cursor models.SearchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || !isSearchResultType(cursor.ResultType) {
+		return nil, ErrInvalidSearchCursor
+	}
+	return &cursor, nil
+}
+
+var likeSpecialCharacters = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Escapes the characters in s that LIKE patterns treat specially, so that s only
+// matches itself
+func EscapeLikePattern(s string) string {
+	return likeSpecialCharacters.Replace(s)
+}
@@ -0,0 +1,135 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+func TestSplitSearchPrefix(t *testing.T) {
+	safePrefix := regexp.MustCompile(`^[\pL\pM\pN]*$`)
+	tests := []struct {
+		name       string
+		searchTerm string
+		wantRest   string
+		wantPrefix string
+	}{
+		{"Single word", "ryan", "", "ryan"},
+		{"Multiple words", "go web dev", "go web ", "dev"},
+		{"Trailing whitespace", "golang ", "golang ", ""},
+		{"Empty", "", "", ""},
+		{"Non-ASCII word", "café", "", "café"},
+		{"Negated word", "go -java", "go -java", ""},
+		{"Unclosed quote", `"web dev`, `"web dev`, ""},
+		{"Closed quote", `"web dev" go`, `"web dev" `, "go"},
+		{"Single quote", "o'brien", "o'", "brien"},
+		{"Quote injection", "'; DROP TABLE users; --", "'; DROP TABLE users; --", ""},
+		{"Quote injection ending in word", "x'); DELETE FROM users WHERE ('a", "x'); DELETE FROM users WHERE ('", "a"},
+		{"tsquery operators", "a & !b | c:*", "a & !b | c:*", ""},
+		{"tsquery operators ending in word", "(a <-> b) & c", "(a <-> b) & ", "c"},
+		{"Prefix marker", "ryan:*", "ryan:*", ""},
+		{"Backslash", `ryan\`, `ryan\`, ""},
+		{"Null byte", "ryan\x00ozx", "ryan\x00", "ozx"},
+		{"Placeholder", "$1 ?", "$1 ?", ""},
+		{"Only punctuation", `'"()&|!:*<->`, `'"()&|!:*<->`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotRest, gotPrefix := SplitSearchPrefix(tt.searchTerm)
+			if gotRest != tt.wantRest {
+				t.Errorf("SplitSearchPrefix() rest = %q, want %q", gotRest, tt.wantRest)
+			}
+			if gotPrefix != tt.wantPrefix {
+				t.Errorf("SplitSearchPrefix() prefix = %q, want %q", gotPrefix, tt.wantPrefix)
+			}
+			if !safePrefix.MatchString(gotPrefix) {
+				t.Errorf("SplitSearchPrefix() prefix %q contains characters other than letters and digits", gotPrefix)
+			}
+		})
+	}
+}
+
+type testQueryer map[string]string
+
+func (q testQueryer) DefaultQuery(key string, defaultValue string) string {
+	if val, ok := q[key]; ok {
+		return val
+	}
+	return defaultValue
+}
+
+func TestGetSearchTypesFromQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr error
+	}{
+		{"No types", "", []string{}, nil},
+		{"Single type", "user", []string{models.SearchResultUser}, nil},
+		{"Multiple types", "project,post", []string{models.SearchResultProject, models.SearchResultPost}, nil},
+		{"Unknown type", "user,admin", nil, ErrInvalidSearchType},
+		{"Padded type", "user, post", nil, ErrInvalidSearchType},
+		{"Injected type", "user' OR '1'='1", nil, ErrInvalidSearchType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetSearchTypesFromQuery(testQueryer{SearchTypeKey: tt.query})
+			if err != tt.wantErr {
+				t.Errorf("GetSearchTypesFromQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetSearchTypesFromQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchCursor(t *testing.T) {
+	cursor := &models.SearchCursor{Score: 0.1 + 0.2, ResultType: models.SearchResultUser, ID: "'; DROP TABLE users; --"}
+	got, err := DecodeSearchCursor(EncodeSearchCursor(cursor))
+	if err != nil {
+		t.Fatalf("DecodeSearchCursor() error = %v", err)
+	}
+	// Scores are compared for equality when paginating, so they must survive exactly
+	if !reflect.DeepEqual(got, cursor) {
+		t.Errorf("DecodeSearchCursor() = %v, want %v", got, cursor)
+	}
+
+	invalidCursors := []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"s":1,"t":"users","i":"1"}`)),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"s":"1","t":"user","i":"1"}`)),
+	}
+	for _, invalidCursor := range invalidCursors {
+		if _, err := DecodeSearchCursor(invalidCursor); err != ErrInvalidSearchCursor {
+			t.Errorf("DecodeSearchCursor(%q) error = %v, want %v", invalidCursor, err, ErrInvalidSearchCursor)
+		}
+	}
+}
+
+func TestEscapeLikePattern(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"No special characters", "jdoe", "jdoe"},
+		{"Underscore", "jdoe_42", `jdoe\_42`},
+		{"Percent", "100%", `100\%`},
+		{"Backslash", `a\b`, `a\\b`},
+		{"Trailing backslash", `jdoe\`, `jdoe\\`},
+		{"Only wildcards", `%_%`, `\%\_\%`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EscapeLikePattern(tt.s); got != tt.want {
+				t.Errorf("EscapeLikePattern() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+const (
+	UserSkillsPath     = "/user/skills"
+	SkillsPath         = "/skills"
+	EndorsementPath    = "/endorsement"
+	SkillIDKey         = "skillid"
+	SkillQueryKey      = "skill"
+	MaxUserSkills      = 30
+	MaxSkillNameLength = 50
+	MinSkillLevel      = 1
+	MaxSkillLevel      = 5
+	MaxSkillYears      = 80
+)
+
+var (
+	ErrInvalidSkillLevel = fmt.Errorf("skill levels must be between %d and %d", MinSkillLevel, MaxSkillLevel)
+	ErrInvalidSkillYears = fmt.Errorf("years of experience must be between 0 and %d", MaxSkillYears)
+	ErrNoSkillName       = errors.New("skill names cannot be empty")
+	ErrSkillNameTooLong  = fmt.Errorf("skill names cannot be longer than %d characters", MaxSkillNameLength)
+	ErrTooManySkills     = fmt.Errorf("at most %d skills can be listed", MaxUserSkills)
+)
+
+// Retrieves skillID from context; the skillID is inserted into the context by the
+// router when parsing ("/users/:username/skills/:skillid")
+func GetSkillIDFromContext(ctx ParamGetter) (uint, error) {
+	return getUnsignedValFromContext(ctx, SkillIDKey)
+}
+
+// Trims whitespace from name and collapses whitespace within it
+func NormaliseSkillName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Normalises names and removes names that are empty or differ only in case from an
+// earlier name, keeping the order in which names first appear.
+func NormaliseSkillNames(names []string) []string {
+	seen := map[string]bool{}
+	output := []string{}
+	for _, name := range names {
+		name = NormaliseSkillName(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		output = append(output, name)
+	}
+	return output
+}
+
+// Returns an error if names, after normalisation, cannot be listed on a profile
+func ValidateSkillNames(names []string) error {
+	if len(names) > MaxUserSkills {
+		return ErrTooManySkills
+	}
+	for _, name := range names {
+		if name == "" {
+			return ErrNoSkillName
+		}
+		if len([]rune(name)) > MaxSkillNameLength {
+			return ErrSkillNameTooLong
+		}
+	}
+	return nil
+}
+
+// Normalises the name of each skill in place and returns an error if the skills cannot
+// be listed on a profile. Skills that resolve to the same canonical skill are merged
+// when saved, so duplicates are not rejected here.
+func ValidateUserSkills(skills []models.UserSkillInput) error {
+	names := []string{}
+	for i := range skills {
+		skills[i].Name = NormaliseSkillName(skills[i].Name)
+		names = append(names, skills[i].Name)
+		level, years := skills[i].Level, skills[i].Years
+		if level.Valid && (level.Int64 < MinSkillLevel || level.Int64 > MaxSkillLevel) {
+			return ErrInvalidSkillLevel
+		}
+		if years.Valid && (years.Int64 < 0 || years.Int64 > MaxSkillYears) {
+			return ErrInvalidSkillYears
+		}
+	}
+	return ValidateSkillNames(names)
+}
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ryanozx/skillnet/models"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestNormaliseSkillNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"Nil", nil, []string{}},
+		{"Unchanged", []string{"Go", "PostgreSQL"}, []string{"Go", "PostgreSQL"}},
+		{"Whitespace", []string{"  Machine \t learning ", " "}, []string{"Machine learning"}},
+		{"Duplicates keep first", []string{"go", "Go", "GO ", "react"}, []string{"go", "react"}},
+		{"Non-ASCII duplicates", []string{"Ünicode", "ünicode"}, []string{"Ünicode"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormaliseSkillNames(tt.names); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormaliseSkillNames() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUserSkills(t *testing.T) {
+	tests := []struct {
+		name      string
+		skills    []models.UserSkillInput
+		wantNames []string
+		wantErr   error
+	}{
+		{"Valid", []models.UserSkillInput{{Name: " Go ", Level: null.IntFrom(MaxSkillLevel), Years: null.IntFrom(0)}}, []string{"Go"}, nil},
+		{"No level or years", []models.UserSkillInput{{Name: "Go"}}, []string{"Go"}, nil},
+		{"Empty name", []models.UserSkillInput{{Name: "\t"}}, []string{""}, ErrNoSkillName},
+		{"Level too low", []models.UserSkillInput{{Name: "Go", Level: null.IntFrom(MinSkillLevel - 1)}}, []string{"Go"}, ErrInvalidSkillLevel},
+		{"Level too high", []models.UserSkillInput{{Name: "Go", Level: null.IntFrom(MaxSkillLevel + 1)}}, []string{"Go"}, ErrInvalidSkillLevel},
+		{"Negative years", []models.UserSkillInput{{Name: "Go", Years: null.IntFrom(-1)}}, []string{"Go"}, ErrInvalidSkillYears},
+		{"Too many years", []models.UserSkillInput{{Name: "Go", Years: null.IntFrom(MaxSkillYears + 1)}}, []string{"Go"}, ErrInvalidSkillYears},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateUserSkills(tt.skills); err != tt.wantErr {
+				t.Errorf("ValidateUserSkills() error = %v, want %v", err, tt.wantErr)
+			}
+			for i, want := range tt.wantNames {
+				if tt.skills[i].Name != want {
+					t.Errorf("ValidateUserSkills() name = %q, want %q", tt.skills[i].Name, want)
+				}
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	gcs "github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/ryanozx/skillnet/contentfilter"
 	"github.com/ryanozx/skillnet/models"
 )
 
@@ -194,18 +195,37 @@ func CheckExpectedStatusCodeEqualsActual(expected int, actual int) (errorStr str
 }
 
 type TestCache struct {
-	GetCacheValFunc func(context.Context, uint) (uint64, error)
-	SetCacheValFunc func(context.Context, uint) (uint64, error)
+	GetCacheValFunc  func(context.Context, uint) (uint64, error)
+	SetCacheValFunc  func(context.Context, uint) (uint64, error)
+	IncrCacheValFunc func(context.Context, uint, int64) (uint64, error)
 }
 
 func (c *TestCache) GetCacheVal(ctx context.Context, postID uint) (uint64, error) {
 	return c.GetCacheValFunc(ctx, postID)
 }
 
+// Looks up each post with GetCacheValFunc, returning the values found before the
+// first error
+func (c *TestCache) GetCacheVals(ctx context.Context, postIDs []uint) (map[uint]uint64, error) {
+	vals := map[uint]uint64{}
+	for _, postID := range postIDs {
+		val, err := c.GetCacheValFunc(ctx, postID)
+		if err != nil {
+			return vals, err
+		}
+		vals[postID] = val
+	}
+	return vals, nil
+}
+
 func (c *TestCache) SetCacheVal(ctx context.Context, postID uint) (uint64, error) {
 	return c.SetCacheValFunc(ctx, postID)
 }
 
+func (c *TestCache) IncrCacheVal(ctx context.Context, postID uint, delta int64) (uint64, error) {
+	return c.IncrCacheValFunc(ctx, postID, delta)
+}
+
 func (c *TestCache) SetMockGetCacheValFunc(count uint64, err error) {
 	c.GetCacheValFunc = func(ctx context.Context, postID uint) (uint64, error) {
 		return count, err
@@ -218,9 +238,16 @@ func (c *TestCache) SetMockSetCacheValFunc(count uint64, err error) {
 	}
 }
 
+func (c *TestCache) SetMockIncrCacheValFunc(count uint64, err error) {
+	c.IncrCacheValFunc = func(ctx context.Context, postID uint, delta int64) (uint64, error) {
+		return count, err
+	}
+}
+
 func (c *TestCache) ResetFuncs() {
 	c.GetCacheValFunc = nil
 	c.SetCacheValFunc = nil
+	c.IncrCacheValFunc = nil
 }
 
 type TestNotificationCreator struct {
@@ -241,6 +268,25 @@ func (nc *TestNotificationCreator) ResetFuncs() {
 	nc.PostNotificationFromEventFunc = nil
 }
 
+type TestContentFilter struct {
+	CheckFunc func(ctx context.Context, content *contentfilter.Content) *contentfilter.Result
+	Released  []*contentfilter.Content
+}
+
+func (f *TestContentFilter) Check(ctx context.Context, content *contentfilter.Content) *contentfilter.Result {
+	return f.CheckFunc(ctx, content)
+}
+
+func (f *TestContentFilter) Release(ctx context.Context, content *contentfilter.Content) {
+	f.Released = append(f.Released, content)
+}
+
+func (f *TestContentFilter) SetMockCheckFunc(verdict contentfilter.Verdict, reason error) {
+	f.CheckFunc = func(ctx context.Context, content *contentfilter.Content) *contentfilter.Result {
+		return &contentfilter.Result{Verdict: verdict, Reason: reason}
+	}
+}
+
 func SetEnvVars(t *testing.T) {
 	t.Setenv("CLIENT_HOST", "http://localhost")
 	t.Setenv("CLIENT_PORT", "3000")
@@ -249,3 +295,20 @@ func SetEnvVars(t *testing.T) {
 	SetModelClientAddress()
 	SetModelBackendAddress()
 }
+
+// TestMailer records the emails sent instead of sending them
+type TestMailer struct {
+	SendMailFunc func(to string, subject string, body string) error
+	Sent         []string
+}
+
+func (m *TestMailer) SendMail(to string, subject string, body string) error {
+	m.Sent = append(m.Sent, to)
+	return m.SendMailFunc(to, subject, body)
+}
+
+func (m *TestMailer) SetMockSendMailFunc(err error) {
+	m.SendMailFunc = func(to string, subject string, body string) error {
+		return err
+	}
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+const (
+	TokenPath      = "/tokens"
+	TokenIDKey     = "tokenid"
+	TokenScopesKey = "tokenScopes"
+	tokenPrefix    = "snt_"
+	tokenBytes     = 32
+)
+
+// Generates a new personal access token. The prefix makes leaked tokens easy to
+// identify when scanning logs or repositories.
+func GenerateAccessToken() (string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return tokenPrefix + hex.EncodeToString(buf), nil
+}
+
+// Tokens are generated with enough entropy that a fast hash suffices; unlike
+// passwords, they do not need a slow, salted hash to resist brute force.
+func HashAccessToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Extracts the token from an "Authorization: Bearer <token>" header value
+func ExtractBearerToken(header string) (string, bool) {
+	const bearerPrefix = "Bearer "
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix))
+	return token, token != ""
+}
+
+// Checks that at least one scope is requested and that every scope is known
+func ValidateTokenScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func isKnownScope(scope string) bool {
+	for _, known := range models.TokenScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+func GetTokenIDFromContext(ctx ParamGetter) (uint, error) {
+	return getUnsignedValFromContext(ctx, TokenIDKey)
+}
+
+type ContextGetter interface {
+	Get(string) (any, bool)
+}
+
+// Retrieves the scopes of the token used to authenticate the request. The second
+// return value is false if the request was authenticated by a session instead.
+func GetTokenScopesFromContext(ctx ContextGetter) ([]string, bool) {
+	val, exists := ctx.Get(TokenScopesKey)
+	if !exists {
+		return nil, false
+	}
+	scopes, ok := val.([]string)
+	return scopes, ok
+}
@@ -0,0 +1,93 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+func TestExtractBearerToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantToken string
+		wantOK    bool
+	}{
+		{
+			"Bearer token present",
+			"Bearer snt_abc123",
+			"snt_abc123",
+			true,
+		},
+		{
+			"Empty header",
+			"",
+			"",
+			false,
+		},
+		{
+			"Bearer with no token",
+			"Bearer    ",
+			"",
+			false,
+		},
+		{
+			"Basic authorization",
+			"Basic dXNlcjpwYXNz",
+			"",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, ok := ExtractBearerToken(tt.header)
+			if token != tt.wantToken || ok != tt.wantOK {
+				t.Errorf("ExtractBearerToken() = (%v, %v), want (%v, %v)", token, ok, tt.wantToken, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateTokenScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		want   bool
+	}{
+		{
+			"All known scopes",
+			[]string{models.ScopeRead, models.ScopeWritePosts, models.ScopeWriteProjects},
+			true,
+		},
+		{
+			"No scopes",
+			[]string{},
+			false,
+		},
+		{
+			"Unknown scope",
+			[]string{models.ScopeRead, "admin"},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateTokenScopes(tt.scopes); got != tt.want {
+				t.Errorf("ValidateTokenScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashAccessToken(t *testing.T) {
+	token, err := GenerateAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if HashAccessToken(token) != HashAccessToken(token) {
+		t.Error("HashAccessToken() is not deterministic")
+	}
+	if HashAccessToken(token) == token {
+		t.Error("HashAccessToken() returned the token unchanged")
+	}
+}
@@ -29,6 +29,10 @@ func ParseNullableUint(str string) (*NullableUint, error) {
 	return &output, nil
 }
 
+func NewNullableUint(val uint) *NullableUint {
+	return &NullableUint{value: val, hasValue: true}
+}
+
 func (v *NullableUint) IsNull() bool {
 	return !v.hasValue
 }
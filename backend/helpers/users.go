@@ -1,10 +1,20 @@
 package helpers
 
-import "github.com/gin-gonic/gin"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
 
 const (
-	UsernameQueryKey = "username"
-	UsernameKey      = "username"
+	UsernameQueryKey     = "username"
+	UsernameKey          = "username"
+	EmailVerificationTTL = 24 * time.Hour
+	// Accounts are deleted permanently this long after the user deletes them
+	AccountDeletionGracePeriod = 30 * 24 * time.Hour
+	ProfilePictureBucket       = "skillnet-profile-pictures"
 )
 
 func GetUsernameFromQuery(ctx *gin.Context) string {
@@ -18,3 +28,25 @@ func GetUsernameFromContext(ctx ParamGetter) string {
 	username := getParamFromContext(ctx, UsernameKey)
 	return username
 }
+
+// Generates a token for a link sent to the user, such as the link to confirm an email
+// change. The token is sent to the user while only its hash is stored.
+func GenerateLinkToken() (token string, hash string, err error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, HashAccessToken(token), nil
+}
+
+// Returns the link in the verification email, which leads to the client's email
+// verification page
+func EmailVerificationURL(clientAddress string, token string) string {
+	return clientAddress + "/verify-email?token=" + token
+}
+
+// Returns the name of the object storing the user's profile picture
+func ProfilePictureObjectName(userID string) string {
+	return userID + "-pfp.jpeg"
+}
@@ -1,31 +1,180 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
 )
 
+var (
+	ErrInsufficientRole  = errors.New("you do not have permission to access this resource")
+	ErrInsufficientScope = errors.New("token does not have the required scope")
+	ErrInvalidToken      = errors.New("invalid or expired token")
+	ErrPendingDeletion   = errors.New("account is scheduled for deletion, log in again to cancel the deletion")
+	ErrSessionRequired   = errors.New("this action requires a logged in session")
+	ErrSessionRevoked    = errors.New("session has been revoked, please log in again")
+)
+
+type TokenGetter interface {
+	GetTokenByHash(string) (*models.PersonalAccessToken, error)
+}
+
+type UserGetter interface {
+	GetUserByID(string) (*models.User, error)
+}
+
+/*
+Returns middleware that authenticates a request by its personal access token if an
+"Authorization: Bearer <token>" header is present, or by its session otherwise. If
+the user does not have a valid session, the user will be automatically redirected to
+the login gateway. Requests from suspended users, sessions of users whose accounts are
+scheduled for deletion, and sessions revoked by an admin, are rejected. The role of a
+user with a valid session is added to the context; tokens only carry the permissions
+of a regular user.
+*/
+func AuthRequired(tokenDB TokenGetter, userDB UserGetter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token, ok := helpers.ExtractBearerToken(ctx.GetHeader("Authorization")); ok {
+			authenticateToken(ctx, tokenDB, token)
+			return
+		}
+		session := sessions.Default(ctx)
+		if !helpers.IsValidSession(session) {
+			log.Println("UserID in session does not match value in Redis")
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Unable to retrieve session",
+			})
+			ctx.Abort()
+			return
+		}
+		userID := session.Get("userID")
+		user, err := userDB.GetUserByID(fmt.Sprintf("%v", userID))
+		if err != nil {
+			log.Printf("Unable to retrieve user %v: %v", userID, err)
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Unable to retrieve session",
+			})
+			ctx.Abort()
+			return
+		}
+		if user.IsSuspended(time.Now()) {
+			helpers.OutputError(ctx, http.StatusForbidden, helpers.SuspensionError(&user.Suspension))
+			ctx.Abort()
+			return
+		}
+		// The session is cleared so that the user can log in again; logging in cancels
+		// a scheduled deletion
+		if user.IsPendingDeletion() {
+			clearSession(session, userID)
+			helpers.OutputError(ctx, http.StatusUnauthorized, ErrPendingDeletion)
+			ctx.Abort()
+			return
+		}
+		if user.IsSessionRevoked(helpers.GetSessionLoginTime(session)) {
+			clearSession(session, userID)
+			helpers.OutputError(ctx, http.StatusUnauthorized, ErrSessionRevoked)
+			ctx.Abort()
+			return
+		}
+		helpers.AddParamsToContext(ctx, helpers.UserIDKey, userID)
+		ctx.Set(helpers.UserRoleKey, user.Role)
+		ctx.Next()
+	}
+}
+
+func clearSession(session sessions.Session, userID interface{}) {
+	session.Clear()
+	if err := session.Save(); err != nil {
+		log.Printf("Unable to clear session of user %v: %v", userID, err)
+	}
+}
+
+func authenticateToken(ctx *gin.Context, tokenDB TokenGetter, token string) {
+	dbToken, err := tokenDB.GetTokenByHash(helpers.HashAccessToken(token))
+	if err != nil || dbToken.IsExpired(time.Now()) {
+		helpers.OutputError(ctx, http.StatusUnauthorized, ErrInvalidToken)
+		ctx.Abort()
+		return
+	}
+	if dbToken.User.IsSuspended(time.Now()) {
+		helpers.OutputError(ctx, http.StatusForbidden, helpers.SuspensionError(&dbToken.User.Suspension))
+		ctx.Abort()
+		return
+	}
+	helpers.AddParamsToContext(ctx, helpers.UserIDKey, dbToken.UserID)
+	ctx.Set(helpers.TokenScopesKey, dbToken.ScopeList())
+	ctx.Next()
+}
+
 /*
-If user does not have a valid session, the user will be automatically
-redirected to the login gateway
+Restricts requests authenticated by a personal access token to those that the token
+has been granted the scope for. Read-only requests require the read scope, while all
+other requests require writeScope; an empty writeScope only allows tokens to read.
+Requests authenticated by a session are not restricted.
 */
-func AuthRequired(ctx *gin.Context) {
-	session := sessions.Default(ctx)
-	if !helpers.IsValidSession(session) {
-		log.Println("UserID in session does not match value in Redis")
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "Unable to retrieve session",
-		})
+func RequireScope(writeScope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scopes, isTokenAuth := helpers.GetTokenScopesFromContext(ctx)
+		if !isTokenAuth {
+			ctx.Next()
+			return
+		}
+		requiredScope := writeScope
+		if isReadOnlyMethod(ctx.Request.Method) {
+			requiredScope = models.ScopeRead
+		}
+		if requiredScope == "" || !containsScope(scopes, requiredScope) {
+			helpers.OutputError(ctx, http.StatusForbidden, ErrInsufficientScope)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// Rejects requests authenticated by a personal access token, e.g. so that a token
+// cannot be used to mint new tokens
+func RequireSession(ctx *gin.Context) {
+	if _, isTokenAuth := helpers.GetTokenScopesFromContext(ctx); isTokenAuth {
+		helpers.OutputError(ctx, http.StatusForbidden, ErrSessionRequired)
 		ctx.Abort()
+		return
 	}
-	userID := session.Get("userID")
-	helpers.AddParamsToContext(ctx, helpers.UserIDKey, userID)
 	ctx.Next()
 }
 
+// Restricts a route to users with at least the given role. Must be used after
+// AuthRequired, which adds the user's role to the context
+func RequireRole(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !helpers.UserHasRole(ctx, role) {
+			helpers.OutputError(ctx, http.StatusForbidden, ErrInsufficientRole)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 type AuthContext interface {
 }
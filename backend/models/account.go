@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UsernameAlias records a username previously held by a user, so that profile
+// URLs containing the old username continue to resolve after a rename
+type UsernameAlias struct {
+	gorm.Model
+	Username string `gorm:"uniqueIndex; not null"`
+	UserID   string `gorm:"not null"`
+	User     User   `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// EmailChange is a pending change of email address that takes effect once the
+// user confirms it with the token sent to the new address
+type EmailChange struct {
+	gorm.Model
+	UserID    string `gorm:"uniqueIndex; not null"`
+	User      User   `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Email     string `gorm:"not null"`
+	TokenHash string `gorm:"uniqueIndex; not null"`
+	ExpiresAt time.Time
+}
+
+func (change *EmailChange) IsExpired(now time.Time) bool {
+	return now.After(change.ExpiresAt)
+}
+
+// PasswordChangeInput is the request body for changing a user's password
+type PasswordChangeInput struct {
+	CurrentPassword string
+	NewPassword     string
+}
+
+// EmailChangeInput is the request body for changing a user's email; the current
+// password is required so that a hijacked session cannot take over the account
+type EmailChangeInput struct {
+	CurrentPassword string
+	Email           string
+}
+
+// EmailVerificationInput is the request body for confirming an email change
+type EmailVerificationInput struct {
+	Token string
+}
+
+// UsernameChangeInput is the request body for changing a user's username
+type UsernameChangeInput struct {
+	Username string
+}
+
+// AccountDeletionInput is the request body for deleting a user's account
+type AccountDeletionInput struct {
+	Password string
+}
+
+// PurgedAccount describes the data deleted along with an account, so that it can also be
+// cleared from outside the database
+type PurgedAccount struct {
+	// Posts whose like or comment counts changed, including the deleted posts
+	AffectedPostIDs []uint
+	// Posts deleted along with the account, with the feeds that they were ranked in
+	DeletedPosts []PostRankingStats
+	// Users whose home feeds the deleted posts may have been fanned out to
+	TimelineUserIDs []string
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// Suspension prevents a user from logging in or using any existing sessions or tokens.
+// A suspension without an end lasts until the user is unsuspended.
+type Suspension struct {
+	SuspendedAt      null.Time
+	SuspendedUntil   null.Time
+	SuspensionReason null.String
+}
+
+func (s *Suspension) IsSuspended(now time.Time) bool {
+	return s.SuspendedAt.Valid && (!s.SuspendedUntil.Valid || now.Before(s.SuspendedUntil.Time))
+}
+
+// SuspensionInput is the request body for suspending a user; Until may be omitted to
+// suspend the user indefinitely
+type SuspensionInput struct {
+	Reason string
+	Until  null.Time
+}
+
+// AdminUserView contains the details of a user that admins see when managing users
+type AdminUserView struct {
+	ID                string
+	Username          string
+	Email             string
+	Name              null.String
+	Role              string
+	Suspended         bool
+	Suspension        Suspension
+	DeleteAfter       null.Time
+	SessionsRevokedAt null.Time
+}
+
+func (user *User) AdminUserView(now time.Time) *AdminUserView {
+	return &AdminUserView{
+		ID:                user.ID,
+		Username:          user.Username,
+		Email:             user.Email,
+		Name:              user.Name,
+		Role:              user.Role,
+		Suspended:         user.IsSuspended(now),
+		Suspension:        user.Suspension,
+		DeleteAfter:       user.DeleteAfter,
+		SessionsRevokedAt: user.SessionsRevokedAt,
+	}
+}
+
+type AdminUserViewArray struct {
+	Users       []AdminUserView
+	NextPageURL string
+}
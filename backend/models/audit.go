@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionPromote = "promote"
+
+	AuditActionSuspend           = "suspend"
+	AuditActionUnsuspend         = "unsuspend"
+	AuditActionForceLogout       = "force_logout"
+	AuditActionResetVerification = "reset_email_verification"
+	AuditActionImpersonate       = "impersonate"
+	AuditActionModerate          = "moderate"
+)
+
+// Types of resources recorded in the audit log
+const (
+	AuditTargetPost      = "post"
+	AuditTargetComment   = "comment"
+	AuditTargetProject   = "project"
+	AuditTargetCommunity = "community"
+	AuditTargetUser      = "user"
+)
+
+// AuditLog records a privileged action, such as an admin modifying content that they
+// do not own. Entries are not tied to the users involved by foreign keys, so that they
+// are kept after those accounts are deleted.
+type AuditLog struct {
+	gorm.Model
+	ActorID    string `gorm:"index"` // Empty if the action was carried out from the command line
+	Action     string `gorm:"not null"`
+	TargetType string `gorm:"not null"`
+	TargetID   string `gorm:"not null"`
+	OwnerID    string `gorm:"index"`
+	Detail     string
+}
+
+func NewAuditLog(action string, targetType string, targetID interface{}) *AuditLog {
+	return &AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprintf("%v", targetID),
+	}
+}
+
+type AuditLogArray struct {
+	AuditLogs   []AuditLog
+	NextPageURL string
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Block stops two users from seeing or interacting with each other's content. Blocks
+// are one-sided records but apply in both directions.
+type Block struct {
+	UserID    string    `json:"-" gorm:"primaryKey"`
+	User      User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	BlockedID string    `json:"-" gorm:"primaryKey; index"`
+	Blocked   User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time `gorm:"<-:create"`
+}
+
+// Mute hides a user's posts from the feeds of the user who muted them
+type Mute struct {
+	UserID    string    `json:"-" gorm:"primaryKey"`
+	User      User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	MutedID   string    `json:"-" gorm:"primaryKey; index"`
+	Muted     User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time `gorm:"<-:create"`
+}
+
+// ListedUserView is an entry in a user's block or mute list
+type ListedUserView struct {
+	Username    string
+	UserMinimal `json:"User"`
+	Since       time.Time
+}
+
+func (user *User) ListedUserView(since time.Time) *ListedUserView {
+	return &ListedUserView{
+		Username:    user.Username,
+		UserMinimal: *user.GetUserMinimal(),
+		Since:       since,
+	}
+}
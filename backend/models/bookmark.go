@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// Bookmark saves a post for the user to come back to later, optionally in one of the
+// user's collections. A user bookmarks a post at most once.
+type Bookmark struct {
+	ID           uint
+	UserID       string             `json:"-" gorm:"not null; uniqueIndex:idx_bookmarks_user_post"`
+	User         User               `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	PostID       uint               `gorm:"not null; uniqueIndex:idx_bookmarks_user_post; index"`
+	Post         Post               `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	CollectionID null.Int           `gorm:"index"`
+	Collection   BookmarkCollection `json:"-" gorm:"constraint:OnDelete:SET NULL"`
+	CreatedAt    time.Time          `gorm:"<-:create"`
+}
+
+// BookmarkCollection is a named group of a user's bookmarks, such as "Reading list".
+// Collections are private unless shared, in which case anyone with the share link can
+// view them.
+type BookmarkCollection struct {
+	ID     uint
+	UserID string `json:"-" gorm:"not null; uniqueIndex:idx_bookmark_collections_user_name"`
+	User   User   `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Name   string `gorm:"not null; uniqueIndex:idx_bookmark_collections_user_name"`
+	// Set while the collection is shared. The token is kept as is rather than hashed so
+	// that the owner can look up the share link again; it only grants read access to the
+	// collection.
+	ShareToken null.String `json:"-" gorm:"uniqueIndex"`
+	CreatedAt  time.Time   `gorm:"<-:create"`
+}
+
+func (c *BookmarkCollection) IsShared() bool {
+	return c.ShareToken.Valid
+}
+
+// Returns the view of the collection shown to its owner, with the link to share it if
+// it is shared
+func (c *BookmarkCollection) BookmarkCollectionView() *BookmarkCollectionView {
+	view := BookmarkCollectionView{
+		ID:        c.ID,
+		Name:      c.Name,
+		Shared:    c.IsShared(),
+		CreatedAt: c.CreatedAt,
+	}
+	if c.IsShared() {
+		view.ShareURL = null.StringFrom(fmt.Sprintf("%s/bookmarks/shared/%s", ClientAddress, c.ShareToken.String))
+	}
+	return &view
+}
+
+// BookmarkCollectionInput is the request body for creating or updating a collection.
+// When updating a collection, Name is left unchanged if empty and sharing is left
+// unchanged if Shared is not given.
+type BookmarkCollectionInput struct {
+	Name   string
+	Shared null.Bool
+}
+
+// BookmarkCollectionView is a collection as seen by its owner
+type BookmarkCollectionView struct {
+	ID        uint
+	Name      string
+	Shared    bool
+	ShareURL  null.String
+	CreatedAt time.Time
+}
+
+// SharedBookmarkCollectionView is a shared collection as seen by anyone with its link
+type SharedBookmarkCollectionView struct {
+	Name        string
+	Owner       UserMinimal
+	Posts       []PostView
+	NextPageURL string
+}
@@ -32,6 +32,8 @@ type Comment struct {
 	UserID string `json:"-" gorm:"<-:create; not null"`
 	User   User   `json:"-"`
 	Text   string
+	// Held comments are only shown to their owner until a moderator reviews them
+	HeldForReview bool `gorm:"not null; default:false"`
 }
 
 func (comment *Comment) TestFormat() *Comment {
@@ -1,18 +1,23 @@
 package models
 
 import (
+	"fmt"
+
 	"gopkg.in/guregu/null.v3"
 	"gorm.io/gorm"
 )
 
 type Community struct {
 	gorm.Model
-	Name     string `gorm:"<-:create; not null"`
-	OwnerID  string `json:"-" gorm:"<-:create; not null"`
-	User     User   `json:"-" gorm:"foreignKey:OwnerID"`
-	About    null.String
-	Projects []Project `json:"-" gorm:"constraint:OnDelete:CASCADE"`
-	Posts    []Post    `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Name    string `gorm:"<-:create; not null"`
+	OwnerID string `json:"-" gorm:"<-:create; not null"`
+	User    User   `json:"-" gorm:"foreignKey:OwnerID"`
+	About   null.String
+	// Kept with each member who joins so that posts in large communities can be pulled
+	// into home feeds instead of being fanned out
+	MemberCount int64     `json:"-" gorm:"not null; default:0"`
+	Projects    []Project `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Posts       []Post    `json:"-" gorm:"constraint:OnDelete:CASCADE"`
 }
 
 func (c *Community) TestFormat() *Community {
@@ -69,3 +74,8 @@ func (ca *CommunityArray) TestFormat() *CommunityArray {
 	}
 	return output
 }
+
+func GenerateCommunityURL(community *Community) string {
+	url := fmt.Sprintf("%s/communities/%s", ClientAddress, community.Name)
+	return url
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+// Statuses of a data export
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport tracks an archive of a user's data, which is built in the background and
+// can be downloaded with a time-limited link once ready
+type DataExport struct {
+	gorm.Model
+	UserID     string `gorm:"not null; index"`
+	User       User   `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Status     string `gorm:"not null"`
+	ObjectName string `json:"-"`
+	TokenHash  string `json:"-" gorm:"index"`
+	ExpiresAt  null.Time
+}
+
+// ProfileExport contains the profile fields of a user, including those that are never
+// shown to other users
+type ProfileExport struct {
+	Username    string
+	Email       string
+	Name        null.String
+	Title       null.String
+	AboutMe     null.String
+	ShowTitle   bool
+	ShowAboutMe bool
+	ProfilePic  string
+}
+
+func (user *User) ProfileExport() *ProfileExport {
+	output := ProfileExport{
+		Username:    user.Username,
+		Email:       user.Email,
+		Name:        user.Name,
+		Title:       user.Title,
+		AboutMe:     user.AboutMe,
+		ShowTitle:   user.ShowTitle,
+		ShowAboutMe: user.ShowAboutMe,
+		ProfilePic:  user.ProfilePic,
+	}
+	return &output
+}
+
+type LikeExport struct {
+	PostID    uint
+	CreatedAt time.Time
+}
+
+// UserData is everything stored in the database about a user
+type UserData struct {
+	Profile     ProfileExport
+	Posts       []Post
+	Comments    []Comment
+	Likes       []LikeExport
+	Projects    []Project
+	Communities []Community
+}
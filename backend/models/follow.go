@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Follow adds the posts of the followed user to the follower's home feed
+type Follow struct {
+	FollowerID string    `json:"-" gorm:"primaryKey"`
+	Follower   User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	FolloweeID string    `json:"-" gorm:"primaryKey; index"`
+	Followee   User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt  time.Time `gorm:"<-:create"`
+}
+
+// CommunityMember adds the posts in the community to the member's home feed
+type CommunityMember struct {
+	UserID      string    `json:"-" gorm:"primaryKey"`
+	User        User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	CommunityID uint      `json:"-" gorm:"primaryKey; index"`
+	Community   Community `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time `gorm:"<-:create"`
+}
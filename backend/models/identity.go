@@ -0,0 +1,20 @@
+package models
+
+import "gorm.io/gorm"
+
+// UserIdentity links an account at an external identity provider, identified by the
+// provider's issuer URL and the subject it assigns to the account, to a SkillNet user
+type UserIdentity struct {
+	gorm.Model
+	Issuer  string `gorm:"<-:create; not null; uniqueIndex:idx_identity_issuer_subject"`
+	Subject string `gorm:"<-:create; not null; uniqueIndex:idx_identity_issuer_subject"`
+	UserID  string `gorm:"<-:create; not null"`
+	User    User   `gorm:"constraint:OnDelete:CASCADE"`
+	Email   string
+}
+
+// IdentityLinkInput is the request body for linking an identity to an account whose
+// email has not been verified; the account's password proves that the user owns it
+type IdentityLinkInput struct {
+	Password string
+}
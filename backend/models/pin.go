@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// Feeds that a post can be pinned to the top of: the feed of its community, or of its
+// project if it belongs to one
+const (
+	PinFeedCommunity = "community"
+	PinFeedProject   = "project"
+)
+
+var PinFeeds = []string{PinFeedCommunity, PinFeedProject}
+
+// PostPin keeps a post at the top of its community or project feed until it is unpinned
+// or the pin expires. A post is pinned to each feed at most once.
+type PostPin struct {
+	PostID     uint      `gorm:"primaryKey"`
+	Post       Post      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Feed       string    `gorm:"primaryKey"`
+	PinnedByID string    `json:"-" gorm:"not null"`
+	CreatedAt  time.Time `gorm:"<-:create"`
+	ExpiresAt  null.Time
+}
+
+// PinInput is the request body for pinning a post. Pins without an expiry last until
+// the post is unpinned.
+type PinInput struct {
+	ExpiresAt null.Time
+}
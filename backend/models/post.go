@@ -29,15 +29,18 @@ func (pvArray *PostViewArray) TestFormat() *PostViewArray {
 // Post is the database representation of a post object
 type Post struct {
 	gorm.Model
-	UserID      string    `json:"-" gorm:"<-:create; not null"`
-	User        User      `json:"-"`
-	Content     string    `gorm:"not null"`
-	ProjectID   uint      `gorm:"<-:create; not null"`
-	Project     Project   `json:"-"`
-	CommunityID uint      `gorm:"<-:create; not null"`
-	Community   Community `json:"-"`
-	Likes       []Like    `json:"-" gorm:"constraint:OnDelete:CASCADE"`
-	Comments    []Comment `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	UserID  string `json:"-" gorm:"<-:create; not null"`
+	User    User   `json:"-"`
+	Content string `gorm:"not null"`
+	// Held posts are only shown to their owner until a moderator reviews them
+	HeldForReview bool       `gorm:"not null; default:false"`
+	ProjectID     uint       `gorm:"<-:create; not null"`
+	Project       Project    `json:"-"`
+	CommunityID   uint       `gorm:"<-:create; not null"`
+	Community     Community  `json:"-"`
+	Likes         []Like     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Comments      []Comment  `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Bookmarks     []Bookmark `json:"-" gorm:"constraint:OnDelete:CASCADE"`
 }
 
 func (post *Post) TestFormat() *Post {
@@ -56,6 +59,8 @@ type PostView struct {
 	UserMinimal  `json:"User"`
 	IsEditable   bool
 	Liked        bool
+	Bookmarked   bool
+	Pinned       bool
 	LikeCount    uint64
 	CommentCount uint64
 }
@@ -66,6 +71,8 @@ func (pv *PostView) TestFormat() *PostView {
 		UserMinimal:  *pv.UserMinimal.TestFormat(),
 		IsEditable:   pv.IsEditable,
 		Liked:        pv.Liked,
+		Bookmarked:   pv.Bookmarked,
+		Pinned:       pv.Pinned,
 		LikeCount:    pv.LikeCount,
 		CommentCount: pv.CommentCount,
 	}
@@ -96,6 +103,7 @@ func (post *Post) PostView(params *PostViewParams) *PostView {
 		UserMinimal:  *post.User.GetUserMinimal(),
 		IsEditable:   params.UserID == post.UserID,
 		Liked:        len(post.Likes) > 0 && post.Likes[0].UserID == params.UserID,
+		Bookmarked:   len(post.Bookmarks) > 0 && post.Bookmarks[0].UserID == params.UserID,
 		LikeCount:    params.LikeCount,
 		CommentCount: params.CommentCount,
 	}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"fmt"
+	"sort"
+	"time"
 )
 
 type ProjectsArray struct {
@@ -44,9 +46,12 @@ type Project struct {
 	ProjectMinimal `gorm:"embedded"`
 	OwnerID        string              `json:"-" gorm:"<-:create; not null"`
 	User           User                `json:"-" gorm:"foreignKey:OwnerID"`
-	Members        []ProjectMembership `json:"-"`
+	Members        []ProjectMembership `json:"-" gorm:"constraint:OnDelete:CASCADE"`
 	PublicCanPost  bool
-	Posts          []Post `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt      time.Time      `json:"-" gorm:"not null; default:CURRENT_TIMESTAMP"`
+	Posts          []Post         `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Skills         []ProjectSkill `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	OpenRoles      []OpenRole     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
 }
 
 func (p *Project) TestFormat() *Project {
@@ -60,9 +65,19 @@ func (p *Project) TestFormat() *Project {
 
 type ProjectView struct {
 	ProjectMinimal
-	Owner         UserMinimal
-	PublicCanPost bool
-	IsOwner       bool
+	Owner          UserMinimal
+	PublicCanPost  bool
+	IsOwner        bool
+	IsMember       bool
+	RequiredSkills []Skill
+	UsedSkills     []Skill
+	Members        []ProjectMemberView
+}
+
+// ProjectMemberView is a collaborator on a project with the role they joined in
+type ProjectMemberView struct {
+	UserMinimal
+	Role string
 }
 
 func (p *Project) ProjectView(userID string) *ProjectView {
@@ -71,10 +86,41 @@ func (p *Project) ProjectView(userID string) *ProjectView {
 		Owner:          *p.User.GetUserMinimal(),
 		PublicCanPost:  p.PublicCanPost,
 		IsOwner:        userID == p.OwnerID,
+		RequiredSkills: p.SkillsOfKind(ProjectSkillRequired),
+		UsedSkills:     p.SkillsOfKind(ProjectSkillUsed),
+		Members:        []ProjectMemberView{},
+	}
+	for _, member := range p.Members {
+		// Members pending deletion are hidden
+		if member.User.IsPendingDeletion() {
+			continue
+		}
+		if member.UserID == userID {
+			output.IsMember = true
+		}
+		output.Members = append(output.Members, ProjectMemberView{
+			UserMinimal: *member.User.GetUserMinimal(),
+			Role:        member.Role,
+		})
 	}
 	return &output
 }
 
+// Returns the skills of kind that the project is tagged with, sorted by name. Skills
+// must be loaded with the project.
+func (p *Project) SkillsOfKind(kind string) []Skill {
+	skills := []Skill{}
+	for _, tag := range p.Skills {
+		if tag.Kind == kind {
+			skills = append(skills, tag.Skill)
+		}
+	}
+	sort.Slice(skills, func(i, j int) bool {
+		return skills[i].Name < skills[j].Name
+	})
+	return skills
+}
+
 func (p *Project) GetProjectMinimal() *ProjectMinimal {
 	p.URL = GenerateProjectURL(p)
 	p.CommunityName = p.Community.Name
@@ -85,11 +131,47 @@ func (p *Project) GetUserID() string {
 	return p.OwnerID
 }
 
+// Kinds of skills that projects can be tagged with
+const (
+	// Skills that the project needs from contributors
+	ProjectSkillRequired = "required"
+	// Skills that the project is built with
+	ProjectSkillUsed = "used"
+)
+
+// ProjectSkill tags a project with a skill. A skill is tagged at most once per project.
+type ProjectSkill struct {
+	ProjectID uint    `gorm:"primaryKey"`
+	Project   Project `gorm:"constraint:OnDelete:CASCADE"`
+	SkillID   uint    `gorm:"primaryKey; index"`
+	Skill     Skill   `gorm:"constraint:OnDelete:CASCADE"`
+	Kind      string  `gorm:"not null"`
+}
+
+// ProjectSkillsInput replaces the skills that a project is tagged with. Skills in
+// both lists are tagged as required.
+type ProjectSkillsInput struct {
+	Required []string
+	Used     []string
+}
+
+// ProjectMatch is a project that requires skills listed on the viewer's profile, with
+// its open roles that require them
+type ProjectMatch struct {
+	ProjectMinimal
+	MatchedSkills []Skill
+	OpenRoles     []OpenRoleView
+}
+
+// ProjectMembership records that a user collaborates on a project, having been
+// accepted into one of its open roles
 type ProjectMembership struct {
-	UserID    string
-	User      User
-	ProjectID uint
-	Project   Project
+	UserID    string    `gorm:"primaryKey"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE"`
+	ProjectID uint      `gorm:"primaryKey; index"`
+	Project   Project   `gorm:"constraint:OnDelete:CASCADE"`
+	Role      string    // Title of the open role that the user was accepted into
+	CreatedAt time.Time `gorm:"<-:create"`
 }
 
 func GenerateProjectURL(project *Project) string {
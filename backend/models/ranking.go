@@ -0,0 +1,72 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Orders in which a feed of posts can be sorted
+const (
+	PostSortNew = "new"
+	PostSortHot = "hot"
+	PostSortTop = "top"
+)
+
+var PostSorts = []string{PostSortNew, PostSortHot, PostSortTop}
+
+// Periods that top posts are ranked over, by when the posts were created
+const (
+	RankingWindowDay  = "day"
+	RankingWindowWeek = "week"
+	RankingWindowAll  = "all"
+)
+
+var RankingWindows = []string{RankingWindowDay, RankingWindowWeek, RankingWindowAll}
+
+// Durations of the ranking windows; posts of any age are ranked in the all-time window
+var RankingWindowDurations = map[string]time.Duration{
+	RankingWindowDay:  24 * time.Hour,
+	RankingWindowWeek: 7 * 24 * time.Hour,
+}
+
+// A post's hot score grows by one for every tenfold increase in its points, and newer
+// posts start higher by one for every hotDecay that passes, so that an older post needs
+// ten times the points of a newer one to rank alongside it
+const hotDecay = 45000 * time.Second
+
+// PostRankingStats holds what a post is ranked by, and the feeds it is ranked in
+type PostRankingStats struct {
+	ID           uint
+	CreatedAt    time.Time
+	CommunityID  uint
+	ProjectID    uint
+	LikeCount    int64
+	CommentCount int64
+	Deleted      bool
+}
+
+// Points are the likes and comments that the post has received
+func (s *PostRankingStats) Points() float64 {
+	return float64(s.LikeCount + s.CommentCount)
+}
+
+func (s *PostRankingStats) HotScore() float64 {
+	return math.Log10(math.Max(s.Points(), 1)) + float64(s.CreatedAt.Unix())/hotDecay.Seconds()
+}
+
+func (s *PostRankingStats) TopScore() float64 {
+	return s.Points()
+}
+
+// Returns whether the post was created within the window at the given time
+func (s *PostRankingStats) IsWithinWindow(window string, now time.Time) bool {
+	duration, ok := RankingWindowDurations[window]
+	return !ok || !s.CreatedAt.Before(now.Add(-duration))
+}
+
+// PostCursor is the position of a post in a ranked feed. Posts with the same score are
+// ordered by descending ID.
+type PostCursor struct {
+	Score  float64
+	PostID uint
+}
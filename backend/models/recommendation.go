@@ -0,0 +1,110 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Kinds of things that can be recommended to a user. Communities and projects are
+// identified by their ID. Users are stored by their ID, but shown to and dismissed by
+// clients by their username, since user IDs are never revealed.
+const (
+	RecommendationUser      = "user"
+	RecommendationCommunity = "community"
+	RecommendationProject   = "project"
+)
+
+var RecommendationKinds = []string{RecommendationUser, RecommendationCommunity, RecommendationProject}
+
+// Signals that a recommendation is based on; the strongest signal is given as the
+// reason for the recommendation
+const (
+	ReasonSharedSkills      = "shared_skills"
+	ReasonSharedCommunities = "shared_communities"
+	ReasonSharedLikes       = "shared_likes"
+	ReasonPopular           = "popular"
+)
+
+// Weights of each signal in the score of a recommendation. Popularity is scaled
+// logarithmically so that popular candidates do not drown out personal signals.
+const (
+	WeightSharedSkills      = 3.0
+	WeightSharedCommunities = 2.0
+	WeightSharedLikes       = 1.0
+	WeightPopularity        = 0.5
+)
+
+// RecommendationCandidate is something that may be recommended to a user, with the
+// strength of each signal for it
+type RecommendationCandidate struct {
+	TargetID          string
+	SharedSkills      int64
+	SharedCommunities int64
+	SharedLikes       int64
+	Popularity        int64
+	Score             float64
+}
+
+// Returns the signal that contributes the most to the candidate's score
+func (c *RecommendationCandidate) Reason() string {
+	reason := ReasonPopular
+	strongest := WeightPopularity * math.Log1p(float64(c.Popularity))
+	signals := []struct {
+		reason string
+		weight float64
+	}{
+		{ReasonSharedSkills, WeightSharedSkills * float64(c.SharedSkills)},
+		{ReasonSharedCommunities, WeightSharedCommunities * float64(c.SharedCommunities)},
+		{ReasonSharedLikes, WeightSharedLikes * float64(c.SharedLikes)},
+	}
+	for _, signal := range signals {
+		if signal.weight > strongest {
+			reason, strongest = signal.reason, signal.weight
+		}
+	}
+	return reason
+}
+
+// Recommendation is a scored suggestion stored for a user until recommendations are
+// next computed
+type Recommendation struct {
+	Kind     string
+	TargetID string
+	Score    float64
+	Reason   string
+}
+
+// RecommendationDismissal records that a user does not want to be recommended
+// something again
+type RecommendationDismissal struct {
+	UserID    string    `gorm:"primaryKey"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE"`
+	Kind      string    `gorm:"primaryKey"`
+	TargetID  string    `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"<-:create"`
+}
+
+// RecommendationDismissalInput is the request body for dismissing a recommendation.
+// TargetID is the ID given in the recommendation.
+type RecommendationDismissalInput struct {
+	Kind     string
+	TargetID string
+}
+
+// RecommendationView is a recommendation as shown to the user. Only the field for the
+// kind of the recommendation is set.
+type RecommendationView struct {
+	Kind      string
+	TargetID  string
+	Reason    string
+	User      *UserMinimal    `json:",omitempty"`
+	Community *Community      `json:",omitempty"`
+	Project   *ProjectMinimal `json:",omitempty"`
+}
+
+// RecommendationsView holds the recommendations of each kind, best first
+type RecommendationsView struct {
+	Users       []RecommendationView
+	Communities []RecommendationView
+	Projects    []RecommendationView
+}
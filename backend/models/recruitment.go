@@ -0,0 +1,167 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// How much time an open role expects from the collaborator
+const (
+	CommitmentCasual   = "casual"
+	CommitmentPartTime = "part_time"
+	CommitmentFullTime = "full_time"
+)
+
+var Commitments = []string{CommitmentCasual, CommitmentPartTime, CommitmentFullTime}
+
+// States of an open role. Only open roles accept applications.
+const (
+	OpenRoleOpen   = "open"
+	OpenRoleFilled = "filled"
+	OpenRoleClosed = "closed"
+)
+
+var OpenRoleStatuses = []string{OpenRoleOpen, OpenRoleFilled, OpenRoleClosed}
+
+// States of an application to an open role
+const (
+	ApplicationPending  = "pending"
+	ApplicationAccepted = "accepted"
+	ApplicationRejected = "rejected"
+)
+
+// OpenRole is a position on a project that the owner is looking for collaborators to
+// fill, such as "Backend developer"
+type OpenRole struct {
+	ID          uint
+	ProjectID   uint    `gorm:"index; not null; <-:create"`
+	Project     Project `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Title       string  `gorm:"not null"`
+	Description string
+	Commitment  string          `gorm:"not null"`
+	Status      string          `gorm:"not null; default:open"`
+	Skills      []OpenRoleSkill `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time       `gorm:"<-:create"`
+	UpdatedAt   time.Time
+}
+
+func (r *OpenRole) IsOpen() bool {
+	return r.Status == OpenRoleOpen
+}
+
+// Returns the skills that the role requires, sorted by name. Skills must be loaded
+// with the role.
+func (r *OpenRole) RequiredSkills() []Skill {
+	skills := []Skill{}
+	for _, tag := range r.Skills {
+		skills = append(skills, tag.Skill)
+	}
+	sort.Slice(skills, func(i, j int) bool {
+		return skills[i].Name < skills[j].Name
+	})
+	return skills
+}
+
+func (r *OpenRole) OpenRoleView() *OpenRoleView {
+	return &OpenRoleView{
+		ID:             r.ID,
+		ProjectID:      r.ProjectID,
+		Title:          r.Title,
+		Description:    r.Description,
+		Commitment:     r.Commitment,
+		Status:         r.Status,
+		RequiredSkills: r.RequiredSkills(),
+		CreatedAt:      r.CreatedAt,
+	}
+}
+
+// OpenRoleSkill tags an open role with a skill that it requires
+type OpenRoleSkill struct {
+	OpenRoleID uint     `gorm:"primaryKey"`
+	OpenRole   OpenRole `gorm:"constraint:OnDelete:CASCADE"`
+	SkillID    uint     `gorm:"primaryKey; index"`
+	Skill      Skill    `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// OpenRoleInput is the request body for posting or updating an open role. Skills
+// replaces the skills that the role requires. Status is ignored when posting a role,
+// which is always posted open.
+type OpenRoleInput struct {
+	Title       string
+	Description string
+	Commitment  string
+	Status      string
+	Skills      []string
+}
+
+// OpenRoleView is an open role as shown on its project
+type OpenRoleView struct {
+	ID             uint
+	ProjectID      uint
+	Title          string
+	Description    string
+	Commitment     string
+	Status         string
+	RequiredSkills []Skill
+	CreatedAt      time.Time
+}
+
+// RoleApplication is a user's request to fill an open role. A user applies to a role
+// at most once.
+type RoleApplication struct {
+	ID          uint
+	OpenRoleID  uint     `gorm:"not null; uniqueIndex:idx_role_applications_role_applicant"`
+	OpenRole    OpenRole `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	ApplicantID string   `gorm:"not null; uniqueIndex:idx_role_applications_role_applicant; index"`
+	Applicant   User     `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Message     string
+	Status      string    `gorm:"not null; default:pending"`
+	CreatedAt   time.Time `gorm:"<-:create"`
+	ReviewedAt  null.Time
+}
+
+func (app *RoleApplication) IsPending() bool {
+	return app.Status == ApplicationPending
+}
+
+func (app *RoleApplication) RoleApplicationView() *RoleApplicationView {
+	return &RoleApplicationView{
+		ID:          app.ID,
+		OpenRoleID:  app.OpenRoleID,
+		RoleTitle:   app.OpenRole.Title,
+		ProjectID:   app.OpenRole.ProjectID,
+		ProjectName: app.OpenRole.Project.Name,
+		Applicant:   *app.Applicant.GetUserMinimal(),
+		Message:     app.Message,
+		Status:      app.Status,
+		CreatedAt:   app.CreatedAt,
+		ReviewedAt:  app.ReviewedAt,
+	}
+}
+
+// RoleApplicationInput is the request body for applying to an open role
+type RoleApplicationInput struct {
+	Message string
+}
+
+// ApplicationReviewInput is the request body for accepting or rejecting an
+// application, with Status set to accepted or rejected
+type ApplicationReviewInput struct {
+	Status string
+}
+
+// RoleApplicationView is an application as seen by the project owner and the applicant
+type RoleApplicationView struct {
+	ID          uint
+	OpenRoleID  uint
+	RoleTitle   string
+	ProjectID   uint
+	ProjectName string
+	Applicant   UserMinimal
+	Message     string
+	Status      string
+	CreatedAt   time.Time
+	ReviewedAt  null.Time
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+// Types of content that can be reported. Posts, comments and projects are identified
+// by their ID, communities by their name and users by their username.
+const (
+	ReportTargetPost      = AuditTargetPost
+	ReportTargetComment   = AuditTargetComment
+	ReportTargetProject   = AuditTargetProject
+	ReportTargetCommunity = AuditTargetCommunity
+	ReportTargetUser      = AuditTargetUser
+)
+
+var ReportTargets = []string{ReportTargetPost, ReportTargetComment, ReportTargetProject, ReportTargetCommunity, ReportTargetUser}
+
+// Reasons that content can be reported for
+const (
+	ReportReasonSpam          = "spam"
+	ReportReasonHarassment    = "harassment"
+	ReportReasonHateSpeech    = "hate_speech"
+	ReportReasonInappropriate = "inappropriate"
+	ReportReasonOther         = "other"
+)
+
+// Content held by the content filter is reported with this reason so that it appears
+// in the moderation queue. Users cannot report content with this reason.
+const (
+	ReportReasonFiltered    = "filtered"
+	ContentFilterReporterID = "contentfilter"
+)
+
+var ReportReasons = []string{ReportReasonSpam, ReportReasonHarassment, ReportReasonHateSpeech, ReportReasonInappropriate, ReportReasonOther}
+
+// States of a report in the moderation queue
+const (
+	ReportOpen      = "open"
+	ReportActioned  = "actioned"
+	ReportDismissed = "dismissed"
+)
+
+// Actions a moderator can take on a report
+const (
+	ReportActionRemoveContent = "remove_content"
+	ReportActionWarnUser      = "warn_user"
+	ReportActionSuspendUser   = "suspend_user"
+	ReportActionDismiss       = "dismiss"
+)
+
+// Report is a user's complaint about a piece of content or another user. As with the
+// audit log, reports are not tied to users by foreign keys so that they are kept after
+// the accounts involved are deleted.
+type Report struct {
+	gorm.Model
+	ReporterID   string `json:"-" gorm:"index; not null"`
+	TargetType   string `gorm:"not null"`
+	TargetID     string `gorm:"not null"`
+	TargetUserID string `json:"-" gorm:"index; not null"` // Owner of the reported content, or the reported user
+	// Community that the reported content belongs to; reports in a community can be
+	// moderated by the community's owner
+	CommunityID    null.Int `gorm:"index"`
+	Reason         string   `gorm:"not null"`
+	Note           string
+	Status         string `gorm:"index; not null; default:open"`
+	Action         null.String
+	ModeratorID    string `json:"-"`
+	ResolutionNote string
+	ResolvedAt     null.Time
+}
+
+func (r *Report) IsOpen() bool {
+	return r.Status == ReportOpen
+}
+
+// ReportInput is the request body for reporting content
+type ReportInput struct {
+	TargetType string
+	TargetID   string
+	Reason     string
+	Note       string
+}
+
+func (input *ReportInput) Report(reporterID string) *Report {
+	return &Report{
+		ReporterID: reporterID,
+		TargetType: input.TargetType,
+		TargetID:   input.TargetID,
+		Reason:     input.Reason,
+		Note:       input.Note,
+		Status:     ReportOpen,
+	}
+}
+
+// ReportResolutionInput is the request body for resolving a report. SuspendUntil is
+// only used when suspending the user, and may be omitted to suspend them indefinitely.
+type ReportResolutionInput struct {
+	Action       string
+	Note         string
+	SuspendUntil null.Time
+}
+
+// ReportResolution is the outcome of a report decided by a moderator
+type ReportResolution struct {
+	Action       string
+	Note         string
+	ModeratorID  string
+	SuspendUntil null.Time
+	ResolvedAt   time.Time
+}
+
+// Status of a report once it has been resolved with the action
+func (res *ReportResolution) Status() string {
+	if res.Action == ReportActionDismiss {
+		return ReportDismissed
+	}
+	return ReportActioned
+}
+
+type ReportArray struct {
+	Reports     []Report
+	NextPageURL string
+}
+
+// ModerationResult is the outcome of resolving a report
+type ModerationResult struct {
+	Reports []Report // Every open report on the target, all resolved by the same decision
+	// Post that was removed, if a post was removed
+	RemovedPostID null.Int
+	// Post whose comment count changed, if a comment was removed
+	CommentPostID null.Int
+}
@@ -0,0 +1,26 @@
+package models
+
+// Roles that can be assigned to a user. Each role has every permission of the roles
+// ranked below it.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// Checks if role grants the permissions of the required role. Unknown roles are
+// treated as regular users.
+func HasRole(role string, required string) bool {
+	return roleRanks[role] >= roleRanks[required]
+}
@@ -1,25 +1,130 @@
 package models
 
-type UserSearchResult struct {
-	Username   string  `json:"name"`
-	ResultType string  `json:"result_type"`
-	Score      float64 `json:"score"` // ts_rank returns a float8
-	URL        string  `json:"url"`
+import (
+	"strconv"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// Types of search results
+const (
+	SearchResultUser      = "user"
+	SearchResultProject   = "project"
+	SearchResultCommunity = "community"
+	SearchResultPost      = "post"
+	SearchResultComment   = "comment"
+)
+
+// SearchResultTypes contains every type of search result
+var SearchResultTypes = []string{
+	SearchResultUser,
+	SearchResultProject,
+	SearchResultCommunity,
+	SearchResultPost,
+	SearchResultComment,
 }
 
 type SearchResult struct {
+	ID         string  `json:"-"` // Only used to build cursors
 	Name       string  `json:"name"`
 	ResultType string  `json:"result_type"`
-	Score      float64 `json:"score"` // ts_rank returns a float8
+	Score      float64 `json:"score"` // Normalised to between 0 and 1
 	URL        string  `json:"url"`
+	// Set for posts, comments and users who show their title; Snippet contains the
+	// matched text highlighted by ts_headline
+	Snippet   string              `json:"snippet,omitempty"`
+	Community *SearchResultParent `json:"community,omitempty"`
+	Project   *SearchResultParent `json:"project,omitempty"`
+}
+
+// Returns a cursor for the page of results following this result
+func (result *SearchResult) Cursor() *SearchCursor {
+	output := SearchCursor{
+		Score:      result.Score,
+		ResultType: result.ResultType,
+		ID:         result.ID,
+	}
+	return &output
+}
+
+// SearchResultsPage is a struct for supporting search result pagination. Facets
+// contains the number of results of each type, ignoring the type filter, and is only
+// returned with the first page.
+type SearchResultsPage struct {
+	Results    []SearchResult   `json:"results"`
+	Facets     map[string]int64 `json:"facets,omitempty"`
+	NextCursor string           `json:"next_cursor,omitempty"`
 }
 
-func (usr *UserSearchResult) ToSearchResult() *SearchResult {
+// SearchCursor identifies the last result of a page of search results, since results
+// are ordered by score, then type, then ID
+type SearchCursor struct {
+	Score      float64 `json:"s"`
+	ResultType string  `json:"t"`
+	ID         string  `json:"i"`
+}
+
+// SearchResultParent is the community or project that a result belongs to
+type SearchResultParent struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// SearchResultRow is the database representation of a search result of any type
+type SearchResultRow struct {
+	ResultType    string
+	ResultID      string
+	Name          string
+	Score         float64
+	Snippet       null.String
+	CommunityName null.String
+	ProjectID     null.Int
+	ProjectName   null.String
+}
+
+func (row *SearchResultRow) ToSearchResult() *SearchResult {
 	output := SearchResult{
-		Name:       usr.Username,
-		ResultType: usr.ResultType,
-		Score:      usr.Score,
-		URL:        usr.URL,
+		ID:         row.ResultID,
+		Name:       row.Name,
+		ResultType: row.ResultType,
+		Score:      row.Score,
+		Snippet:    row.Snippet.String,
+	}
+	if row.CommunityName.Valid {
+		output.Community = &SearchResultParent{
+			Name: row.CommunityName.String,
+			URL:  GenerateCommunityURL(&Community{Name: row.CommunityName.String}),
+		}
+	}
+	if row.ProjectID.Valid {
+		project := Project{}
+		project.ID = uint(row.ProjectID.Int64)
+		output.Project = &SearchResultParent{
+			Name: row.ProjectName.String,
+			URL:  GenerateProjectURL(&project),
+		}
+	}
+
+	switch row.ResultType {
+	case SearchResultUser:
+		user := User{}
+		user.Username = row.Name
+		output.URL = GenerateProfileURL(&user)
+	case SearchResultProject:
+		projectID, _ := strconv.ParseUint(row.ResultID, 10, 64)
+		project := Project{}
+		project.ID = uint(projectID)
+		output.URL = GenerateProjectURL(&project)
+	case SearchResultCommunity:
+		output.URL = GenerateCommunityURL(&Community{Name: row.Name})
+	default:
+		// Posts and comments have no page of their own, so link to the narrowest feed
+		// containing them
+		if output.Project != nil {
+			output.URL = output.Project.URL
+		} else if output.Community != nil {
+			output.URL = output.Community.URL
+		}
 	}
 	return &output
 }
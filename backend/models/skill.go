@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// Skill is a canonical skill that users can list on their profile. Names are unique
+// regardless of case.
+type Skill struct {
+	ID   uint
+	Name string `gorm:"not null; uniqueIndex:idx_skills_name,expression:lower(name)"`
+}
+
+// SkillAlias is another name for a skill, such as "golang" for Go. Skills listed under
+// an alias are listed under the skill instead. Aliases are stored in lower case.
+type SkillAlias struct {
+	Alias   string `gorm:"primaryKey"`
+	SkillID uint   `gorm:"not null; index"`
+	Skill   Skill  `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// UserSkill records that a user has listed a skill on their profile, with how
+// proficient they rate themselves at it and how many years they have used it for
+type UserSkill struct {
+	UserID    string `gorm:"primaryKey"`
+	User      User   `gorm:"constraint:OnDelete:CASCADE"`
+	SkillID   uint   `gorm:"primaryKey; index"`
+	Skill     Skill  `gorm:"constraint:OnDelete:CASCADE"`
+	Level     null.Int
+	Years     null.Int
+	CreatedAt time.Time `gorm:"<-:create"`
+}
+
+// Endorsement records that a user vouches for a skill listed on another user's profile
+type Endorsement struct {
+	EndorserID string    `gorm:"primaryKey"`
+	Endorser   User      `gorm:"constraint:OnDelete:CASCADE"`
+	UserID     string    `gorm:"primaryKey; index:idx_endorsements_user_skill"`
+	User       User      `gorm:"constraint:OnDelete:CASCADE"`
+	SkillID    uint      `gorm:"primaryKey; index:idx_endorsements_user_skill"`
+	Skill      Skill     `gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt  time.Time `gorm:"<-:create"`
+}
+
+// UserSkillInput is a skill to list on a user's profile
+type UserSkillInput struct {
+	Name  string
+	Level null.Int
+	Years null.Int
+}
+
+// UserSkillsInput replaces the skills listed on a user's profile
+type UserSkillsInput struct {
+	Skills []UserSkillInput
+}
+
+// UserSkillView is a skill as shown on a user's profile
+type UserSkillView struct {
+	ID           uint
+	Name         string
+	Level        null.Int
+	Years        null.Int
+	Endorsements int64
+	// Whether the user viewing the profile has endorsed the skill
+	Endorsed bool
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+// Scopes that can be granted to a personal access token
+const (
+	ScopeRead          = "read"
+	ScopeWritePosts    = "write:posts"
+	ScopeWriteProjects = "write:projects"
+)
+
+var TokenScopes = []string{ScopeRead, ScopeWritePosts, ScopeWriteProjects}
+
+// PersonalAccessToken is the database representation of a personal access token.
+// Only the hash of the token is stored; the token itself is shown to the user once
+// upon creation.
+type PersonalAccessToken struct {
+	gorm.Model
+	UserID    string `json:"-" gorm:"<-:create; not null"`
+	User      User   `json:"-"`
+	Name      string `gorm:"not null"`
+	TokenHash string `json:"-" gorm:"<-:create; uniqueIndex; not null"`
+	Scopes    string `json:"-" gorm:"not null"` // space-separated list of scopes
+	ExpiresAt null.Time
+}
+
+func (t *PersonalAccessToken) GetUserID() string {
+	return t.UserID
+}
+
+func (t *PersonalAccessToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *PersonalAccessToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt.Valid && !now.Before(t.ExpiresAt.Time)
+}
+
+// PersonalAccessTokenInput is the information the client supplies when creating a token
+type PersonalAccessTokenInput struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt null.Time
+}
+
+func (input *PersonalAccessTokenInput) PersonalAccessToken(userID string, tokenHash string) *PersonalAccessToken {
+	output := PersonalAccessToken{
+		UserID:    userID,
+		Name:      input.Name,
+		TokenHash: tokenHash,
+		Scopes:    strings.Join(input.Scopes, " "),
+		ExpiresAt: input.ExpiresAt,
+	}
+	return &output
+}
+
+// PersonalAccessTokenView represents the information the client receives about a token
+type PersonalAccessTokenView struct {
+	ID        uint
+	CreatedAt time.Time
+	Name      string
+	Scopes    []string
+	ExpiresAt null.Time
+}
+
+func (tv *PersonalAccessTokenView) TestFormat() *PersonalAccessTokenView {
+	return tv
+}
+
+func (t *PersonalAccessToken) PersonalAccessTokenView() *PersonalAccessTokenView {
+	output := PersonalAccessTokenView{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt,
+		Name:      t.Name,
+		Scopes:    t.ScopeList(),
+		ExpiresAt: t.ExpiresAt,
+	}
+	return &output
+}
+
+// NewPersonalAccessTokenView is returned only once, when the token is created, as it
+// is the only time the token itself is revealed to the client
+type NewPersonalAccessTokenView struct {
+	PersonalAccessTokenView
+	Token string
+}
+
+func (ntv *NewPersonalAccessTokenView) TestFormat() *NewPersonalAccessTokenView {
+	return ntv
+}
+
+type PersonalAccessTokenArray struct {
+	Tokens []PersonalAccessTokenView
+}
+
+func (ta *PersonalAccessTokenArray) TestFormat() *PersonalAccessTokenArray {
+	return ta
+}
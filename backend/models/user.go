@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"time"
 
 	"gopkg.in/guregu/null.v3"
 	"gorm.io/gorm"
@@ -57,6 +58,8 @@ type UserView struct {
 	AboutMe     null.String
 	ShowTitle   bool
 	ShowAboutMe bool
+	// Sorted by number of endorsements, most endorsed first
+	Skills []UserSkillView `gorm:"-:all" json:",omitempty"`
 }
 
 func (uv *UserView) TestFormat() *UserView {
@@ -66,6 +69,7 @@ func (uv *UserView) TestFormat() *UserView {
 		AboutMe:     uv.AboutMe,
 		ShowTitle:   uv.ShowTitle,
 		ShowAboutMe: uv.ShowAboutMe,
+		Skills:      uv.Skills,
 	}
 	return &output
 }
@@ -76,9 +80,19 @@ type User struct {
 	UserView        `gorm:"embedded"`
 	UserCredentials `gorm:"embedded"`
 	Email           string    `json:"-" gorm:"not null"`
-	Likes           []Like    `json:"-" gorm:"constraint:OnDelete:CASCADE"`
-	Comments        []Comment `json:"-" gorm:"constraint:OnDelete:CASCADE"`
-	Projects        []Project `json:"-" gorm:"constraint:OnDelete:CASCADE;foreignKey:OwnerID"`
+	EmailVerified   bool      `json:"-" gorm:"not null; default:false"` // Set once the user has proven that they own the email
+	Role            string    `json:"-" gorm:"not null; default:user"`
+	CreatedAt       time.Time `json:"-" gorm:"not null; default:CURRENT_TIMESTAMP"`
+	DeleteAfter     null.Time `json:"-" gorm:"index"` // Set while the account is scheduled for deletion
+	Suspension      `json:"-" gorm:"embedded"`
+	// Sessions created before this time are rejected, logging the user out everywhere
+	SessionsRevokedAt null.Time `json:"-"`
+	// Kept with each follow so that posts by users with many followers can be pulled into
+	// home feeds instead of being fanned out
+	FollowerCount int64     `json:"-" gorm:"not null; default:0"`
+	Likes         []Like    `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Comments      []Comment `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Projects      []Project `json:"-" gorm:"constraint:OnDelete:CASCADE;foreignKey:OwnerID"`
 }
 
 func (user *User) TestFormat() *User {
@@ -97,6 +111,18 @@ func (userCreds *SignupUserCredentials) NewUser() *User {
 	return &user
 }
 
+// VerifiedSignupUserCredentials are used to create accounts whose email has already been
+// verified, such as accounts created through an identity provider
+type VerifiedSignupUserCredentials struct {
+	SignupUserCredentials
+}
+
+func (userCreds *VerifiedSignupUserCredentials) NewUser() *User {
+	user := userCreds.SignupUserCredentials.NewUser()
+	user.EmailVerified = true
+	return user
+}
+
 func (user *User) BeforeCreate(tx *gorm.DB) error {
 	user.ID = uuid.NewString()
 	if err := user.whiteSpaceCheck(tx); err != nil {
@@ -134,6 +160,15 @@ func (user *User) GetUserView(viewerID string) *UserView {
 	return &output
 }
 
+func (user *User) IsPendingDeletion() bool {
+	return user.DeleteAfter.Valid
+}
+
+// Checks if a session created at loginTime has since been revoked
+func (user *User) IsSessionRevoked(loginTime time.Time) bool {
+	return user.SessionsRevokedAt.Valid && loginTime.Before(user.SessionsRevokedAt.Time)
+}
+
 func (user *User) GetUserMinimal() *UserMinimal {
 	user.URL = GenerateProfileURL(user)
 	return &user.UserMinimal
@@ -144,13 +179,22 @@ func GenerateProfileURL(user *User) string {
 	return url
 }
 
+var ErrUsernameWhitespace = errors.New("username cannot contain whitespace")
+
 func (user *User) whiteSpaceCheck(tx *gorm.DB) error {
 	if tx.Statement.Changed("Username") {
 		username := tx.Statement.Dest.(*User).Username
-		isWhiteSpacePresent := regexp.MustCompile(`\s`).MatchString(username)
-		if isWhiteSpacePresent {
-			return errors.New("username cannot contain whitespace")
-		}
+		return ValidateUsername(username)
+	}
+	return nil
+}
+
+// Checks that a username can be used; also used by handlers so that invalid
+// usernames are rejected before reaching the database
+func ValidateUsername(username string) error {
+	isWhiteSpacePresent := regexp.MustCompile(`\s`).MatchString(username)
+	if isWhiteSpacePresent {
+		return ErrUsernameWhitespace
 	}
 	return nil
 }
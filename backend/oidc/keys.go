@@ -0,0 +1,94 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+var ErrUnknownSigningKey = errors.New("ID token is not signed with any of the provider's keys")
+
+// Minimum time between fetches of the provider's signing keys. Tokens that cannot be
+// verified with the cached keys are rejected without refetching the keys until this
+// has passed, so that forged tokens cannot be used to flood the provider with requests.
+const keyRefetchInterval = time.Minute
+
+// keySet caches the provider's signing keys. The keys are refetched when a token cannot
+// be verified with them, in case the provider has rotated its keys, but at most once
+// every keyRefetchInterval.
+type keySet struct {
+	jwksURI    string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mutex     sync.Mutex
+	keys      *gooidc.StaticKeySet
+	fetchedAt time.Time
+}
+
+func newKeySet(jwksURI string, httpClient *http.Client) *keySet {
+	return &keySet{
+		jwksURI:    jwksURI,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+}
+
+// Implements gooidc.KeySet
+func (s *keySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.keys != nil {
+		if payload, err := s.keys.VerifySignature(ctx, jwt); err == nil {
+			return payload, nil
+		}
+	}
+	if !s.fetchedAt.IsZero() && s.now().Sub(s.fetchedAt) < keyRefetchInterval {
+		return nil, ErrUnknownSigningKey
+	}
+	// Failed fetches count towards the limit as well
+	s.fetchedAt = s.now()
+	keys, err := s.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.keys = keys
+	return s.keys.VerifySignature(ctx, jwt)
+}
+
+func (s *keySet) fetchKeys(ctx context.Context) (*gooidc.StaticKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, s.jwksURI)
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+	keys := gooidc.StaticKeySet{}
+	for _, jwk := range jwks.Keys {
+		if !jwk.Valid() || !jwk.IsPublic() || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		keys.PublicKeys = append(keys.PublicKeys, crypto.PublicKey(jwk.Key))
+	}
+	return &keys, nil
+}
@@ -0,0 +1,216 @@
+/*
+Contains an in-process OpenID Connect provider for testing single sign-on.
+*/
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+const (
+	stubKeyID = "stub-key"
+	// Redirect URL registered for the stub's client
+	RedirectURL = "http://localhost/login/oidc/callback"
+)
+
+// StubProvider is an in-process OpenID Connect provider for use in tests. It serves a
+// discovery document, its signing keys and a token endpoint that enforces PKCE.
+type StubProvider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	key          *rsa.PrivateKey
+
+	mutex      sync.Mutex
+	codes      map[string]stubCode
+	keyFetches int
+}
+
+type stubCode struct {
+	codeChallenge string
+	claims        map[string]any
+}
+
+func NewStubProvider(clientID string, clientSecret string) *StubProvider {
+	stub := &StubProvider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          generateKey(),
+		codes:        make(map[string]stubCode),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", stub.serveDiscovery)
+	mux.HandleFunc("/jwks", stub.serveKeys)
+	mux.HandleFunc("/token", stub.serveToken)
+	stub.Server = httptest.NewServer(mux)
+	return stub
+}
+
+func (s *StubProvider) URL() string {
+	return s.Server.URL
+}
+
+func (s *StubProvider) Close() {
+	s.Server.Close()
+}
+
+// Returns the number of times the stub's signing keys have been fetched
+func (s *StubProvider) KeyFetches() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.keyFetches
+}
+
+// Registers an authorization code that can be exchanged once with the code verifier
+// matching codeChallenge. The claims are added to (or override) the default claims of
+// the issued ID token.
+func (s *StubProvider) AddCode(code string, codeChallenge string, claims map[string]any) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.codes[code] = stubCode{
+		codeChallenge: codeChallenge,
+		claims:        claims,
+	}
+}
+
+// Returns the claims of an ID token issued by the stub to its client
+func (s *StubProvider) DefaultClaims() map[string]any {
+	return map[string]any{
+		"iss": s.URL(),
+		"aud": s.ClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// Signs the claims as an RS256 JWT with the stub's key
+func (s *StubProvider) SignIDToken(claims map[string]any) string {
+	s.mutex.Lock()
+	key := s.key
+	s.mutex.Unlock()
+	return signJWT(key, stubKeyID, claims)
+}
+
+// Signs the claims with a freshly generated key that the stub does not publish
+func (s *StubProvider) SignIDTokenWithUnknownKey(claims map[string]any) string {
+	return signJWT(generateKey(), "unknown-key", claims)
+}
+
+// Replaces the stub's signing key, as a provider does when it rotates its keys
+func (s *StubProvider) RotateKey() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.key = generateKey()
+}
+
+func generateKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func signJWT(key *rsa.PrivateKey, keyID string, claims map[string]any) string {
+	signingKey := jose.SigningKey{
+		Algorithm: jose.RS256,
+		Key:       jose.JSONWebKey{Key: key, KeyID: keyID},
+	}
+	signer, err := jose.NewSigner(signingKey, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		panic(err)
+	}
+	payload, _ := json.Marshal(claims)
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		panic(err)
+	}
+	token, err := signed.CompactSerialize()
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
+
+func (s *StubProvider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.URL(),
+		"authorization_endpoint":                s.URL() + "/authorize",
+		"token_endpoint":                        s.URL() + "/token",
+		"jwks_uri":                              s.URL() + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (s *StubProvider) serveKeys(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.keyFetches++
+	key := s.key
+	s.mutex.Unlock()
+
+	writeJSON(w, http.StatusOK, jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{
+				Key:       &key.PublicKey,
+				KeyID:     stubKeyID,
+				Algorithm: string(jose.RS256),
+				Use:       "sig",
+			},
+		},
+	})
+}
+
+func (s *StubProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mutex.Lock()
+	code, found := s.codes[r.PostFormValue("code")]
+	delete(s.codes, r.PostFormValue("code"))
+	s.mutex.Unlock()
+
+	if !found || r.PostFormValue("grant_type") != "authorization_code" ||
+		codeChallenge(r.PostFormValue("code_verifier")) != code.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := s.DefaultClaims()
+	for key, val := range code.claims {
+		claims[key] = val
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "stub-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     s.SignIDToken(claims),
+	})
+}
+
+// Derives the S256 PKCE code challenge from a code verifier
+func codeChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, obj any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(obj)
+}
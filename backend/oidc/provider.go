@@ -0,0 +1,188 @@
+/*
+Contains the OpenID Connect relying party used for single sign-on. It supports the
+authorization code flow with PKCE against any provider that publishes a discovery
+document and signs ID tokens with RS256. ID tokens are verified with go-oidc.
+*/
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Errors
+var (
+	ErrBadNonce        = errors.New("ID token nonce does not match")
+	ErrDiscoveryFailed = errors.New("unable to retrieve provider configuration")
+	ErrInvalidIDToken  = errors.New("ID token is invalid")
+	ErrMissingIDToken  = errors.New("token response does not contain an ID token")
+)
+
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// Claims are the verified claims of an ID token that SkillNet makes use of
+type Claims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// idTokenClaims are the claims of an ID token that are not checked by go-oidc
+type idTokenClaims struct {
+	Email             string  `json:"email"`
+	EmailVerified     boolish `json:"email_verified"`
+	Name              string  `json:"name"`
+	PreferredUsername string  `json:"preferred_username"`
+}
+
+func (c *idTokenClaims) Claims(idToken *gooidc.IDToken) *Claims {
+	output := Claims{
+		Issuer:            idToken.Issuer,
+		Subject:           idToken.Subject,
+		Email:             c.Email,
+		EmailVerified:     bool(c.EmailVerified),
+		Name:              c.Name,
+		PreferredUsername: c.PreferredUsername,
+	}
+	return &output
+}
+
+// Provider is an OpenID Connect provider discovered from its issuer URL
+type Provider struct {
+	issuer     string
+	oauth      oauth2.Config
+	keys       *keySet
+	verifier   *gooidc.IDTokenVerifier
+	httpClient *http.Client
+}
+
+// Retrieves the provider's discovery document and returns a Provider configured for
+// the given client
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	discovered, err := gooidc.NewProvider(gooidc.ClientContext(ctx, httpClient), config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscoveryFailed, err)
+	}
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := discovered.Claims(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscoveryFailed, err)
+	}
+
+	keys := newKeySet(doc.JWKSURI, httpClient)
+	provider := Provider{
+		issuer: config.IssuerURL,
+		oauth: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Scopes:       []string{gooidc.ScopeOpenID, "email", "profile"},
+			Endpoint:     discovered.Endpoint(),
+		},
+		keys: keys,
+		// Only RS256 is accepted since no supported algorithms are configured
+		verifier:   gooidc.NewVerifier(config.IssuerURL, keys, &gooidc.Config{ClientID: config.ClientID}),
+		httpClient: httpClient,
+	}
+	return &provider, nil
+}
+
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+// Returns the URL of the provider's login page. The state and nonce are echoed back by
+// the provider so that the callback can be tied to the request that started the flow.
+func (p *Provider) AuthCodeURL(state string, nonce string, codeVerifier string) string {
+	return p.oauth.AuthCodeURL(state,
+		gooidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", CodeChallenge(codeVerifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+}
+
+// Exchanges the authorization code for tokens and returns the claims of the verified
+// ID token
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*Claims, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	token, err := p.oauth.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+	return p.verifyIDToken(ctx, rawIDToken, nonce)
+}
+
+// Verifies the signature, issuer, audience and expiry of a raw ID token, and that it
+// was issued for the login with the given nonce
+func (p *Provider) verifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*Claims, error) {
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrBadNonce
+	}
+	var claims idTokenClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	return claims.Claims(idToken), nil
+}
+
+// Generates a random URL-safe string suitable for use as a state, nonce or PKCE code
+// verifier
+func GenerateRandomString() (string, error) {
+	const numBytes = 32
+	buf := make([]byte, numBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Derives the S256 PKCE code challenge from a code verifier
+func CodeChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// Some providers send email_verified as the string "true" instead of a boolean
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	var val bool
+	if err := json.Unmarshal(data, &val); err == nil {
+		*b = boolish(val)
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*b = boolish(str == "true")
+	return nil
+}
@@ -0,0 +1,251 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/oidc/oidctest"
+)
+
+const (
+	testClientID     = "skillnet"
+	testClientSecret = "secret"
+	testNonce        = "test-nonce"
+	testSubject      = "provider-user-1"
+)
+
+func stubConfig(stub *oidctest.StubProvider) Config {
+	return Config{
+		IssuerURL:    stub.URL(),
+		ClientID:     stub.ClientID,
+		ClientSecret: stub.ClientSecret,
+		RedirectURL:  oidctest.RedirectURL,
+	}
+}
+
+func setupStub(t *testing.T) (*oidctest.StubProvider, *Provider) {
+	stub := oidctest.NewStubProvider(testClientID, testClientSecret)
+	t.Cleanup(stub.Close)
+	provider, err := NewProvider(context.Background(), stubConfig(stub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stub, provider
+}
+
+func TestNewProvider_DiscoveryFailed(t *testing.T) {
+	stub := oidctest.NewStubProvider(testClientID, testClientSecret)
+	config := stubConfig(stub)
+	stub.Close()
+
+	if _, err := NewProvider(context.Background(), config); !errors.Is(err, ErrDiscoveryFailed) {
+		t.Errorf("NewProvider() error = %v, want %v", err, ErrDiscoveryFailed)
+	}
+}
+
+func TestProvider_AuthCodeURL(t *testing.T) {
+	_, provider := setupStub(t)
+	const state = "test-state"
+	const codeVerifier = "test-verifier"
+
+	authURL, err := url.Parse(provider.AuthCodeURL(state, testNonce, codeVerifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	expected := map[string]string{
+		"state":                 state,
+		"nonce":                 testNonce,
+		"client_id":             testClientID,
+		"response_type":         "code",
+		"code_challenge":        CodeChallenge(codeVerifier),
+		"code_challenge_method": "S256",
+	}
+	for key, val := range expected {
+		if query.Get(key) != val {
+			t.Errorf("AuthCodeURL() %s = %s, want %s", key, query.Get(key), val)
+		}
+	}
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	const code = "test-code"
+	const codeVerifier = "test-verifier"
+	tests := []struct {
+		name         string
+		claims       map[string]any
+		codeVerifier string
+		nonce        string
+		wantErr      bool
+	}{
+		{
+			"Exchange OK",
+			map[string]any{"sub": testSubject, "nonce": testNonce, "email": "abc@def.com", "email_verified": true},
+			codeVerifier,
+			testNonce,
+			false,
+		},
+		{
+			"Exchange wrong code verifier",
+			map[string]any{"sub": testSubject, "nonce": testNonce},
+			"wrong-verifier",
+			testNonce,
+			true,
+		},
+		{
+			"Exchange wrong nonce",
+			map[string]any{"sub": testSubject, "nonce": "replayed-nonce"},
+			codeVerifier,
+			testNonce,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, provider := setupStub(t)
+			stub.AddCode(code, CodeChallenge(codeVerifier), tt.claims)
+
+			claims, err := provider.Exchange(context.Background(), code, tt.codeVerifier, tt.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.Subject != testSubject || !claims.EmailVerified || claims.Issuer != stub.URL()) {
+				t.Errorf("Exchange() returned unexpected claims %v", claims)
+			}
+		})
+	}
+}
+
+func TestProvider_verifyIDToken(t *testing.T) {
+	stub, provider := setupStub(t)
+	withClaims := func(overrides map[string]any) map[string]any {
+		claims := stub.DefaultClaims()
+		claims["sub"] = testSubject
+		claims["nonce"] = testNonce
+		for key, val := range overrides {
+			claims[key] = val
+		}
+		return claims
+	}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			"Valid token",
+			stub.SignIDToken(withClaims(nil)),
+			nil,
+		},
+		{
+			"Audience array containing client",
+			stub.SignIDToken(withClaims(map[string]any{"aud": []string{"other", testClientID}})),
+			nil,
+		},
+		{
+			"Email verified as string",
+			stub.SignIDToken(withClaims(map[string]any{"email_verified": "true"})),
+			nil,
+		},
+		{
+			"Wrong audience",
+			stub.SignIDToken(withClaims(map[string]any{"aud": "another-client"})),
+			ErrInvalidIDToken,
+		},
+		{
+			"Wrong issuer",
+			stub.SignIDToken(withClaims(map[string]any{"iss": "https://evil.example.com"})),
+			ErrInvalidIDToken,
+		},
+		{
+			"Expired",
+			stub.SignIDToken(withClaims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+			ErrInvalidIDToken,
+		},
+		{
+			"Wrong nonce",
+			stub.SignIDToken(withClaims(map[string]any{"nonce": "other"})),
+			ErrBadNonce,
+		},
+		{
+			"Unknown signing key",
+			stub.SignIDTokenWithUnknownKey(withClaims(nil)),
+			ErrInvalidIDToken,
+		},
+		{
+			"Unsigned token",
+			"eyJhbGciOiJub25lIn0.e30.",
+			ErrInvalidIDToken,
+		},
+		{
+			"Malformed token",
+			"not-a-jwt",
+			ErrInvalidIDToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.verifyIDToken(context.Background(), tt.token, testNonce)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("verifyIDToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_verifyIDToken_TamperedPayload(t *testing.T) {
+	stub, provider := setupStub(t)
+	claims := stub.DefaultClaims()
+	claims["nonce"] = testNonce
+	token := stub.SignIDToken(claims)
+	other := stub.SignIDToken(map[string]any{"sub": "someone-else"})
+
+	// Combine the header and signature of one token with the payload of another
+	tokenParts, otherParts := strings.Split(token, "."), strings.Split(other, ".")
+	tampered := tokenParts[0] + "." + otherParts[1] + "." + tokenParts[2]
+	if _, err := provider.verifyIDToken(context.Background(), tampered, testNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("verifyIDToken() error = %v, want %v", err, ErrInvalidIDToken)
+	}
+}
+
+// Tokens that cannot be verified with the cached keys only cause the keys to be
+// refetched once every keyRefetchInterval
+func TestProvider_verifyIDToken_KeyRefetch(t *testing.T) {
+	stub, provider := setupStub(t)
+	now := time.Now()
+	provider.keys.now = func() time.Time {
+		return now
+	}
+	claims := stub.DefaultClaims()
+	claims["nonce"] = testNonce
+	verify := func(token string) error {
+		_, err := provider.verifyIDToken(context.Background(), token, testNonce)
+		return err
+	}
+
+	if err := verify(stub.SignIDToken(claims)); err != nil {
+		t.Fatalf("verifyIDToken() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := verify(stub.SignIDTokenWithUnknownKey(claims)); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("verifyIDToken() error = %v, want %v", err, ErrInvalidIDToken)
+		}
+	}
+	if stub.KeyFetches() != 1 {
+		t.Errorf("Keys fetched %d times, want 1", stub.KeyFetches())
+	}
+
+	// Once the interval has passed, rotated keys are picked up
+	stub.RotateKey()
+	now = now.Add(keyRefetchInterval)
+	if err := verify(stub.SignIDToken(claims)); err != nil {
+		t.Errorf("verifyIDToken() error = %v", err)
+	}
+	if stub.KeyFetches() != 2 {
+		t.Errorf("Keys fetched %d times, want 2", stub.KeyFetches())
+	}
+}
@@ -0,0 +1,152 @@
+package workers
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Number of posts whose counts are compared at a time
+const reconcileBatchSize = 500
+
+// Drift found by the counter reconcilers, by counter name. Drifted posts are posts whose
+// cached count differed from the database, and drift is the total difference.
+var (
+	reconciledPostsMetric = expvar.NewMap("counter_reconciled_posts")
+	driftedPostsMetric    = expvar.NewMap("counter_drifted_posts")
+	driftMetric           = expvar.NewMap("counter_drift")
+)
+
+// CounterDBHandler is implemented by database.LikeDB and database.CommentDB
+type CounterDBHandler interface {
+	GetValues(postIDs []uint) (map[uint]uint64, error)
+}
+
+// CounterCache is implemented by redis.Client
+type CounterCache interface {
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+}
+
+// CounterReconciler corrects cached post counts that have drifted from the database,
+// such as when the cache could not be updated after a like was written. Drifted counts
+// are cleared rather than overwritten, so that a change made while they are compared
+// is not lost; they are recounted the next time they are read or changed.
+type CounterReconciler struct {
+	// Name identifies the counter in logs and metrics
+	Name     string
+	Posts    PostIDLister
+	DB       CounterDBHandler
+	Cache    CounterCache
+	Key      func(postID uint) string
+	Interval time.Duration
+}
+
+// ReconcileResult is what a reconciliation run found
+type ReconcileResult struct {
+	Posts        int
+	DriftedPosts int
+	Drift        uint64
+}
+
+// Reconciles the counts every interval until the context is cancelled
+func (r *CounterReconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		result, err := r.ReconcileAll(ctx)
+		if err != nil {
+			log.Printf("Reconciling %s counts failed after %d posts: %v", r.Name, result.Posts, err)
+		} else {
+			log.Printf("Reconciled %s counts of %d posts; %d had drifted by %d in total", r.Name, result.Posts, result.DriftedPosts, result.Drift)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compares the cached count of every post with the database, clearing the counts that
+// differ. Counts that are not cached are skipped.
+func (r *CounterReconciler) ReconcileAll(ctx context.Context) (ReconcileResult, error) {
+	result := ReconcileResult{}
+	after := uint(0)
+	for {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		postIDs, err := r.Posts.GetPostIDs(after, reconcileBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(postIDs) > 0 {
+			if err := r.reconcile(ctx, postIDs, &result); err != nil {
+				return result, err
+			}
+		}
+		if len(postIDs) < reconcileBatchSize {
+			return result, nil
+		}
+		after = postIDs[len(postIDs)-1]
+	}
+}
+
+func (r *CounterReconciler) reconcile(ctx context.Context, postIDs []uint, result *ReconcileResult) error {
+	keys := make([]string, len(postIDs))
+	for i, postID := range postIDs {
+		keys[i] = r.Key(postID)
+	}
+	// The cache is read before the database, so that a change made in between is seen
+	// as drift and cleared rather than missed
+	cached, err := r.Cache.MGet(ctx, keys...).Result()
+	if err != nil {
+		return err
+	}
+	counts, err := r.DB.GetValues(postIDs)
+	if err != nil {
+		return err
+	}
+
+	drifted := []string{}
+	drift := uint64(0)
+	for i, postID := range postIDs {
+		valStr, ok := cached[i].(string)
+		if !ok {
+			continue
+		}
+		val, err := strconv.ParseUint(valStr, 10, 64)
+		if err == nil && val == counts[postID] {
+			continue
+		}
+		drifted = append(drifted, keys[i])
+		if err == nil {
+			drift += absDiff(val, counts[postID])
+		}
+	}
+	if len(drifted) > 0 {
+		if err := r.Cache.Del(ctx, drifted...).Err(); err != nil {
+			return err
+		}
+	}
+
+	result.Posts += len(postIDs)
+	result.DriftedPosts += len(drifted)
+	result.Drift += drift
+	reconciledPostsMetric.Add(r.Name, int64(len(postIDs)))
+	driftedPostsMetric.Add(r.Name, int64(len(drifted)))
+	driftMetric.Add(r.Name, int64(drift))
+	return nil
+}
+
+func absDiff(a uint64, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
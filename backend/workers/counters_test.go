@@ -0,0 +1,164 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type counterTestDB struct {
+	counts map[uint]uint64
+	err    error
+}
+
+func (db *counterTestDB) GetValues(postIDs []uint) (map[uint]uint64, error) {
+	output := map[uint]uint64{}
+	for _, postID := range postIDs {
+		output[postID] = db.counts[postID]
+	}
+	return output, db.err
+}
+
+type counterTestCache struct {
+	testKeyDeleter
+	vals map[string]string
+	err  error
+}
+
+func (c *counterTestCache) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	vals := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if val, ok := c.vals[key]; ok {
+			vals[i] = val
+		}
+	}
+	return redis.NewSliceResult(vals, c.err)
+}
+
+func testCountKey(postID uint) string {
+	return fmt.Sprintf("test:post:%d", postID)
+}
+
+func TestCounterReconciler_ReconcileAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		posts       *testPosts
+		db          *counterTestDB
+		cache       *counterTestCache
+		want        ReconcileResult
+		wantErr     bool
+		wantCleared []string
+	}{
+		{
+			"Reconcile OK",
+			&testPosts{postIDs: []uint{1, 2, 3, 4, 5}},
+			&counterTestDB{counts: map[uint]uint64{1: 3, 2: 5, 3: 1, 4: 2}},
+			&counterTestCache{vals: map[string]string{
+				"test:post:1": "3",
+				"test:post:2": "2",
+				"test:post:3": "4",
+				"test:post:5": "invalid",
+			}},
+			ReconcileResult{Posts: 5, DriftedPosts: 3, Drift: 6},
+			false,
+			[]string{"test:post:2", "test:post:3", "test:post:5"},
+		},
+		{
+			"Reconcile no drift",
+			&testPosts{postIDs: []uint{1, 2}},
+			&counterTestDB{counts: map[uint]uint64{1: 3}},
+			&counterTestCache{vals: map[string]string{"test:post:1": "3"}},
+			ReconcileResult{Posts: 2},
+			false,
+			nil,
+		},
+		{
+			"Reconcile no posts",
+			&testPosts{},
+			&counterTestDB{},
+			&counterTestCache{},
+			ReconcileResult{},
+			false,
+			nil,
+		},
+		{
+			"Reconcile cannot retrieve posts",
+			&testPosts{err: errTest},
+			&counterTestDB{},
+			&counterTestCache{},
+			ReconcileResult{},
+			true,
+			nil,
+		},
+		{
+			"Reconcile cannot read cache",
+			&testPosts{postIDs: []uint{1}},
+			&counterTestDB{},
+			&counterTestCache{err: errTest},
+			ReconcileResult{},
+			true,
+			nil,
+		},
+		{
+			"Reconcile cannot count in database",
+			&testPosts{postIDs: []uint{1}},
+			&counterTestDB{err: errTest},
+			&counterTestCache{vals: map[string]string{"test:post:1": "3"}},
+			ReconcileResult{},
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &CounterReconciler{
+				Name:  "test",
+				Posts: tt.posts,
+				DB:    tt.db,
+				Cache: tt.cache,
+				Key:   testCountKey,
+			}
+			got, err := r.ReconcileAll(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReconcileAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReconcileAll() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.cache.deleted, tt.wantCleared) {
+				t.Errorf("Cleared counts %v, want %v", tt.cache.deleted, tt.wantCleared)
+			}
+		})
+	}
+}
+
+func TestCounterReconciler_ReconcileAllInBatches(t *testing.T) {
+	postIDs := make([]uint, reconcileBatchSize+1)
+	vals := map[string]string{}
+	for i := range postIDs {
+		postIDs[i] = uint(i + 1)
+		vals[testCountKey(postIDs[i])] = "1"
+	}
+	cache := &counterTestCache{vals: vals}
+	r := &CounterReconciler{
+		Name:  "test",
+		Posts: &testPosts{postIDs: postIDs},
+		DB:    &counterTestDB{counts: map[uint]uint64{reconcileBatchSize + 1: 1}},
+		Cache: cache,
+		Key:   testCountKey,
+	}
+	got, err := r.ReconcileAll(context.Background())
+	if err != nil {
+		t.Fatalf("ReconcileAll() error = %v", err)
+	}
+	want := ReconcileResult{Posts: reconcileBatchSize + 1, DriftedPosts: reconcileBatchSize, Drift: reconcileBatchSize}
+	if got != want {
+		t.Errorf("ReconcileAll() = %+v, want %+v", got, want)
+	}
+	if len(cache.deleted) != reconcileBatchSize {
+		t.Errorf("Cleared %d counts, want %d", len(cache.deleted), reconcileBatchSize)
+	}
+}
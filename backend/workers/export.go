@@ -0,0 +1,171 @@
+package workers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+)
+
+// ExportDBHandler is implemented by database.ExportDB
+type ExportDBHandler interface {
+	GetUserData(string) (*models.UserData, error)
+	MarkExportReady(exportID uint, objectName string, tokenHash string, expiresAt time.Time) error
+	MarkExportFailed(exportID uint) error
+}
+
+// ObjectStore is implemented by GoogleCloudStorage
+type ObjectStore interface {
+	NewObjectWriter(ctx context.Context, bucket string, name string) io.WriteCloser
+	NewObjectReader(ctx context.Context, bucket string, name string) (io.ReadCloser, error)
+}
+
+type NotificationStore interface {
+	GetPendingNotifications(ctx context.Context, userID string) ([]string, error)
+	PostNotification(ctx context.Context, notif *models.Notification) error
+}
+
+// DataExporter builds archives of everything SkillNet holds about a user
+type DataExporter struct {
+	DB            ExportDBHandler
+	Store         ObjectStore
+	Notifications NotificationStore
+}
+
+// Builds the export in the background; the user is notified once it is ready
+func (e *DataExporter) StartExport(export *models.DataExport) {
+	go func() {
+		ctx := context.Background()
+		if err := e.buildExport(ctx, export, time.Now()); err != nil {
+			log.Printf("Data export %d failed: %v", export.ID, err)
+			if err := e.DB.MarkExportFailed(export.ID); err != nil {
+				log.Printf("Unable to mark data export %d as failed: %v", export.ID, err)
+			}
+			notif := helpers.GenerateEventNotification("", export.UserID, "Your data export failed, please try again")
+			if err := e.Notifications.PostNotification(ctx, notif); err != nil {
+				log.Printf("Unable to notify user of failed data export %d: %v", export.ID, err)
+			}
+		}
+	}()
+}
+
+// Opens a ready export for downloading
+func (e *DataExporter) OpenExport(ctx context.Context, export *models.DataExport) (io.ReadCloser, error) {
+	return e.Store.NewObjectReader(ctx, helpers.DataExportBucket, export.ObjectName)
+}
+
+func (e *DataExporter) buildExport(ctx context.Context, export *models.DataExport, now time.Time) error {
+	data, err := e.DB.GetUserData(export.UserID)
+	if err != nil {
+		return err
+	}
+	notifications, err := e.Notifications.GetPendingNotifications(ctx, export.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Cancelling the context before closing the writer discards a partially written object
+	writeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	objectName := helpers.DataExportObjectName(export.UserID, export.ID)
+	writer := e.Store.NewObjectWriter(writeCtx, helpers.DataExportBucket, objectName)
+	if err := e.writeArchive(ctx, writer, export.UserID, data, notifications); err != nil {
+		cancel()
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	token, tokenHash, err := helpers.GenerateLinkToken()
+	if err != nil {
+		return err
+	}
+	if err := e.DB.MarkExportReady(export.ID, objectName, tokenHash, now.Add(helpers.DataExportTTL)); err != nil {
+		return err
+	}
+	notifText := fmt.Sprintf("Your data export is ready, download it within %d days at %s",
+		int(helpers.DataExportTTL.Hours()/24), helpers.DataExportDownloadURL(models.BackendAddress, token))
+	notif := helpers.GenerateEventNotification("", export.UserID, notifText)
+	return e.Notifications.PostNotification(ctx, notif)
+}
+
+// Writes the user's data as JSON files, along with the original files they uploaded,
+// into a ZIP archive
+func (e *DataExporter) writeArchive(ctx context.Context, w io.Writer, userID string, data *models.UserData, notifications []string) error {
+	archive := zip.NewWriter(w)
+	notificationsJSON := make([]json.RawMessage, len(notifications))
+	for i, notif := range notifications {
+		notificationsJSON[i] = json.RawMessage(notif)
+	}
+	files := []struct {
+		name string
+		obj  any
+	}{
+		{"profile.json", data.Profile},
+		{"posts.json", data.Posts},
+		{"comments.json", data.Comments},
+		{"likes.json", data.Likes},
+		{"projects.json", data.Projects},
+		{"communities.json", data.Communities},
+		{"notifications.json", notificationsJSON},
+	}
+	for _, file := range files {
+		if err := writeJSONFile(archive, file.name, file.obj); err != nil {
+			return err
+		}
+	}
+
+	picture, err := e.Store.NewObjectReader(ctx, helpers.ProfilePictureBucket, helpers.ProfilePictureObjectName(userID))
+	if err == nil {
+		defer picture.Close()
+		if err := writeFile(archive, "media/profile-picture.jpeg", picture); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, ErrObjectNotExist) {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeJSONFile(archive *zip.Writer, name string, obj any) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(obj)
+}
+
+func writeFile(archive *zip.Writer, name string, r io.Reader) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, r)
+	return err
+}
+
+// RedisNotifications reads and posts notifications stored in Redis
+type RedisNotifications struct {
+	Client *redis.Client
+}
+
+// Returns the notifications the user has not received yet, without removing them
+func (r *RedisNotifications) GetPendingNotifications(ctx context.Context, userID string) ([]string, error) {
+	return r.Client.ZRange(ctx, helpers.NotificationKey(userID), 0, -1).Result()
+}
+
+func (r *RedisNotifications) PostNotification(ctx context.Context, notif *models.Notification) error {
+	return helpers.PublishNotification(ctx, r.Client, notif)
+}
@@ -0,0 +1,184 @@
+package workers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+	"gorm.io/gorm"
+)
+
+type exportTestDB struct {
+	data          *models.UserData
+	dataErr       error
+	readyObject   string
+	readyToken    string
+	readyExpiry   time.Time
+	markedFailure bool
+}
+
+func (db *exportTestDB) GetUserData(userID string) (*models.UserData, error) {
+	return db.data, db.dataErr
+}
+
+func (db *exportTestDB) MarkExportReady(exportID uint, objectName string, tokenHash string, expiresAt time.Time) error {
+	db.readyObject, db.readyToken, db.readyExpiry = objectName, tokenHash, expiresAt
+	return nil
+}
+
+func (db *exportTestDB) MarkExportFailed(exportID uint) error {
+	db.markedFailure = true
+	return nil
+}
+
+type testObjectWriter struct {
+	bytes.Buffer
+	store *testObjectStore
+	key   string
+}
+
+func (w *testObjectWriter) Close() error {
+	w.store.objects[w.key] = w.Bytes()
+	return nil
+}
+
+type testObjectStore struct {
+	objects map[string][]byte
+}
+
+func (s *testObjectStore) NewObjectWriter(ctx context.Context, bucket string, name string) io.WriteCloser {
+	return &testObjectWriter{store: s, key: bucket + "/" + name}
+}
+
+func (s *testObjectStore) NewObjectReader(ctx context.Context, bucket string, name string) (io.ReadCloser, error) {
+	obj, ok := s.objects[bucket+"/"+name]
+	if !ok {
+		return nil, ErrObjectNotExist
+	}
+	return io.NopCloser(bytes.NewReader(obj)), nil
+}
+
+type testNotifications struct {
+	pending []string
+	posted  []*models.Notification
+}
+
+func (n *testNotifications) GetPendingNotifications(ctx context.Context, userID string) ([]string, error) {
+	return n.pending, nil
+}
+
+func (n *testNotifications) PostNotification(ctx context.Context, notif *models.Notification) error {
+	n.posted = append(n.posted, notif)
+	return nil
+}
+
+func TestDataExporter_buildExport(t *testing.T) {
+	tests := []struct {
+		name        string
+		withPicture bool
+		dataErr     error
+		wantErr     bool
+		wantFiles   []string
+	}{
+		{
+			"Export OK",
+			true,
+			nil,
+			false,
+			[]string{"comments.json", "communities.json", "likes.json", "media/profile-picture.jpeg",
+				"notifications.json", "posts.json", "profile.json", "projects.json"},
+		},
+		{
+			"Export without profile picture OK",
+			false,
+			nil,
+			false,
+			[]string{"comments.json", "communities.json", "likes.json", "notifications.json",
+				"posts.json", "profile.json", "projects.json"},
+		},
+		{
+			"Export cannot get user data",
+			false,
+			errTest,
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &exportTestDB{
+				data: &models.UserData{
+					Profile: models.ProfileExport{Username: "user1"},
+					Posts:   []models.Post{{Content: "Hello world"}},
+				},
+				dataErr: tt.dataErr,
+			}
+			store := &testObjectStore{objects: make(map[string][]byte)}
+			if tt.withPicture {
+				store.objects[helpers.ProfilePictureBucket+"/"+helpers.ProfilePictureObjectName("user1")] = []byte("jpeg")
+			}
+			notifs := &testNotifications{pending: []string{`{"Content":"Welcome"}`}}
+			e := &DataExporter{
+				DB:            db,
+				Store:         store,
+				Notifications: notifs,
+			}
+			export := &models.DataExport{Model: gorm.Model{ID: 7}, UserID: "user1"}
+			now := time.Now()
+
+			err := e.buildExport(context.Background(), export, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildExport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(notifs.posted) != 0 || db.readyObject != "" {
+					t.Error("Failed export should not be marked ready")
+				}
+				return
+			}
+
+			objectName := helpers.DataExportObjectName("user1", 7)
+			if db.readyObject != objectName || !db.readyExpiry.Equal(now.Add(helpers.DataExportTTL)) {
+				t.Errorf("Export marked ready with object %s expiring %v", db.readyObject, db.readyExpiry)
+			}
+			archive, ok := store.objects[helpers.DataExportBucket+"/"+objectName]
+			if !ok {
+				t.Fatal("Archive was not written")
+			}
+			reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var files []string
+			for _, file := range reader.File {
+				files = append(files, file.Name)
+			}
+			sort.Strings(files)
+			if strings.Join(files, ",") != strings.Join(tt.wantFiles, ",") {
+				t.Errorf("Archive contains %v, want %v", files, tt.wantFiles)
+			}
+
+			// The link in the notification must carry the token whose hash was stored
+			if len(notifs.posted) != 1 {
+				t.Fatalf("Expected 1 notification, got %d", len(notifs.posted))
+			}
+			content := notifs.posted[0].Content
+			prefix := helpers.DataExportDownloadURL(models.BackendAddress, "")
+			idx := strings.Index(content, prefix)
+			if idx < 0 {
+				t.Fatalf("Notification does not contain download link: %s", content)
+			}
+			token := strings.Fields(content[idx+len(prefix):])[0]
+			if helpers.HashAccessToken(token) != db.readyToken {
+				t.Error("Download link token does not match stored hash")
+			}
+		})
+	}
+}
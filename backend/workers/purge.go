@@ -0,0 +1,166 @@
+/*
+Contains background workers that run alongside the server.
+*/
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/helpers"
+	"github.com/ryanozx/skillnet/models"
+)
+
+// Number of accounts purged per run, so that a backlog does not hold up the worker
+const purgeBatchSize = 50
+
+// AccountPurgeDBHandler is implemented by database.UserDB
+type AccountPurgeDBHandler interface {
+	GetUsersDueForDeletion(time.Time, int) ([]models.User, error)
+	DeleteUser(string) (*models.PurgedAccount, error)
+}
+
+// KeyDeleter is implemented by redis.Client
+type KeyDeleter interface {
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+}
+
+// PurgedPostRanker is implemented by controllers.RedisPostRanker
+type PurgedPostRanker interface {
+	PostRanker
+	RemovePost(ctx context.Context, stats *models.PostRankingStats) error
+}
+
+// PurgedTimelines is implemented by controllers.RedisHomeTimeline
+type PurgedTimelines interface {
+	RemovePosts(ctx context.Context, userIDs []string, postIDs []uint) error
+	DeleteTimeline(ctx context.Context, userID string) error
+}
+
+// UserFileDeleter deletes the files stored for a user, such as uploaded pictures and
+// data exports
+type UserFileDeleter interface {
+	DeleteUserFiles(ctx context.Context, userID string) error
+}
+
+// AccountPurger permanently deletes accounts whose deletion grace period has ended,
+// along with the data kept outside the database for them
+type AccountPurger struct {
+	DB            AccountPurgeDBHandler
+	LikesCache    KeyDeleter
+	CommentsCache KeyDeleter
+	Notifications KeyDeleter
+	Ranker        PurgedPostRanker
+	Timelines     PurgedTimelines
+	Files         UserFileDeleter
+	Interval      time.Duration
+}
+
+// Purges due accounts every interval until the context is cancelled
+func (p *AccountPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		if purged, err := p.PurgeDueAccounts(ctx, time.Now()); err != nil {
+			log.Printf("Account purge failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d accounts", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purges accounts due for deletion at the given time. Returns the number of accounts
+// purged; accounts that could not be purged are retried on the next run.
+func (p *AccountPurger) PurgeDueAccounts(ctx context.Context, now time.Time) (int, error) {
+	users, err := p.DB.GetUsersDueForDeletion(now, purgeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, user := range users {
+		if err := p.purgeAccount(ctx, user.ID); err != nil {
+			log.Printf("Unable to purge account %s: %v", user.ID, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (p *AccountPurger) purgeAccount(ctx context.Context, userID string) error {
+	// Files are deleted before the user so that a failure here is retried on the next
+	// run, instead of leaving files behind for a user who no longer exists
+	if err := p.Files.DeleteUserFiles(ctx, userID); err != nil {
+		return err
+	}
+	purged, err := p.DB.DeleteUser(userID)
+	if err != nil {
+		return err
+	}
+
+	// The counts are recomputed from the database the next time they are read
+	if postIDs := purged.AffectedPostIDs; len(postIDs) > 0 {
+		likeKeys := make([]string, len(postIDs))
+		commentKeys := make([]string, len(postIDs))
+		for i, postID := range postIDs {
+			likeKeys[i] = helpers.LikeCountKey(postID)
+			commentKeys[i] = helpers.CommentCountKey(postID)
+		}
+		if err := p.LikesCache.Del(ctx, likeKeys...).Err(); err != nil {
+			log.Printf("Unable to clear cached like counts of account %s: %v", userID, err)
+		}
+		if err := p.CommentsCache.Del(ctx, commentKeys...).Err(); err != nil {
+			log.Printf("Unable to clear cached comment counts of account %s: %v", userID, err)
+		}
+	}
+	p.rerankPosts(ctx, purged)
+	p.clearTimelines(ctx, userID, purged)
+	if err := p.Notifications.Del(ctx, helpers.NotificationKey(userID)).Err(); err != nil {
+		log.Printf("Unable to clear notifications of account %s: %v", userID, err)
+	}
+	return nil
+}
+
+// Takes the deleted posts out of the hot and top feeds, and ranks the other posts again
+// without the account's likes and comments. Posts that cannot be ranked are corrected the
+// next time they are ranked.
+func (p *AccountPurger) rerankPosts(ctx context.Context, purged *models.PurgedAccount) {
+	deleted := map[uint]bool{}
+	for i := range purged.DeletedPosts {
+		post := &purged.DeletedPosts[i]
+		deleted[post.ID] = true
+		if err := p.Ranker.RemovePost(ctx, post); err != nil {
+			log.Printf("Unable to remove post %d from rankings: %v", post.ID, err)
+		}
+	}
+	for _, postID := range purged.AffectedPostIDs {
+		if deleted[postID] {
+			continue
+		}
+		if err := p.Ranker.RankPost(ctx, postID); err != nil {
+			log.Printf("Unable to rank post %d: %v", postID, err)
+		}
+	}
+}
+
+// Deletes the account's home feed and removes the deleted posts from the home feeds of
+// the users they were fanned out to
+func (p *AccountPurger) clearTimelines(ctx context.Context, userID string, purged *models.PurgedAccount) {
+	if err := p.Timelines.DeleteTimeline(ctx, userID); err != nil {
+		log.Printf("Unable to clear home feed of account %s: %v", userID, err)
+	}
+	postIDs := make([]uint, len(purged.DeletedPosts))
+	for i, post := range purged.DeletedPosts {
+		postIDs[i] = post.ID
+	}
+	if err := p.Timelines.RemovePosts(ctx, purged.TimelineUserIDs, postIDs); err != nil {
+		log.Printf("Unable to remove posts of account %s from home feeds: %v", userID, err)
+	}
+}
@@ -0,0 +1,248 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/models"
+)
+
+var errTest = errors.New("test error")
+
+type purgeTestDB struct {
+	dueUsers     []models.User
+	dueErr       error
+	purged       models.PurgedAccount
+	deleteErrs   map[string]error
+	deletedUsers []string
+}
+
+func (db *purgeTestDB) GetUsersDueForDeletion(now time.Time, limit int) ([]models.User, error) {
+	return db.dueUsers, db.dueErr
+}
+
+func (db *purgeTestDB) DeleteUser(id string) (*models.PurgedAccount, error) {
+	if err := db.deleteErrs[id]; err != nil {
+		return nil, err
+	}
+	db.deletedUsers = append(db.deletedUsers, id)
+	return &db.purged, nil
+}
+
+type purgeTestRanker struct {
+	testRanker
+	removed []uint
+}
+
+func (r *purgeTestRanker) RemovePost(ctx context.Context, stats *models.PostRankingStats) error {
+	r.removed = append(r.removed, stats.ID)
+	return nil
+}
+
+type purgeTestTimelines struct {
+	deleted []string
+	removed map[string][]uint
+}
+
+func (t *purgeTestTimelines) RemovePosts(ctx context.Context, userIDs []string, postIDs []uint) error {
+	for _, userID := range userIDs {
+		if t.removed == nil {
+			t.removed = map[string][]uint{}
+		}
+		t.removed[userID] = append(t.removed[userID], postIDs...)
+	}
+	return nil
+}
+
+func (t *purgeTestTimelines) DeleteTimeline(ctx context.Context, userID string) error {
+	t.deleted = append(t.deleted, userID)
+	return nil
+}
+
+type testKeyDeleter struct {
+	deleted []string
+}
+
+func (d *testKeyDeleter) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	d.deleted = append(d.deleted, keys...)
+	cmd := redis.NewIntCmd(ctx)
+	cmd.SetVal(int64(len(keys)))
+	return cmd
+}
+
+type testFiles struct {
+	errs    map[string]error
+	deleted []string
+}
+
+func (p *testFiles) DeleteUserFiles(ctx context.Context, userID string) error {
+	if err := p.errs[userID]; err != nil {
+		return err
+	}
+	p.deleted = append(p.deleted, userID)
+	return nil
+}
+
+func TestAccountPurger_PurgeDueAccounts(t *testing.T) {
+	tests := []struct {
+		name                string
+		db                  *purgeTestDB
+		files               *testFiles
+		wantPurged          int
+		wantErr             bool
+		wantDeletedUsers    []string
+		wantDeletedLikes    []string
+		wantDeletedComments []string
+		wantDeletedNotifs   []string
+		wantDeletedFiles    []string
+		wantRemovedPosts    []uint
+		wantRankedPosts     []uint
+		wantRemovedFeeds    map[string][]uint
+	}{
+		{
+			"Purge OK",
+			&purgeTestDB{
+				dueUsers: []models.User{{ID: "user1"}},
+				purged: models.PurgedAccount{
+					AffectedPostIDs: []uint{1, 2},
+					DeletedPosts:    []models.PostRankingStats{{ID: 2}},
+					TimelineUserIDs: []string{"user1", "follower"},
+				},
+			},
+			&testFiles{},
+			1,
+			false,
+			[]string{"user1"},
+			[]string{"likes:post:1", "likes:post:2"},
+			[]string{"comments:post:1", "comments:post:2"},
+			[]string{"notifications:user1"},
+			[]string{"user1"},
+			[]uint{2},
+			[]uint{1},
+			map[string][]uint{"user1": {2}, "follower": {2}},
+		},
+		{
+			"Purge no due accounts",
+			&purgeTestDB{},
+			&testFiles{},
+			0,
+			false,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"Purge cannot retrieve due accounts",
+			&purgeTestDB{
+				dueErr: errTest,
+			},
+			&testFiles{},
+			0,
+			true,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"Purge keeps account if files cannot be deleted",
+			&purgeTestDB{
+				dueUsers: []models.User{{ID: "user1"}, {ID: "user2"}},
+			},
+			&testFiles{
+				errs: map[string]error{"user1": errTest},
+			},
+			1,
+			false,
+			[]string{"user2"},
+			nil,
+			nil,
+			[]string{"notifications:user2"},
+			[]string{"user2"},
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"Purge continues after database error",
+			&purgeTestDB{
+				dueUsers:   []models.User{{ID: "user1"}, {ID: "user2"}},
+				deleteErrs: map[string]error{"user1": errTest},
+			},
+			&testFiles{},
+			1,
+			false,
+			[]string{"user2"},
+			nil,
+			nil,
+			[]string{"notifications:user2"},
+			[]string{"user1", "user2"},
+			nil,
+			nil,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			likes, comments, notifs := &testKeyDeleter{}, &testKeyDeleter{}, &testKeyDeleter{}
+			ranker := &purgeTestRanker{}
+			timelines := &purgeTestTimelines{}
+			p := &AccountPurger{
+				DB:            tt.db,
+				LikesCache:    likes,
+				CommentsCache: comments,
+				Notifications: notifs,
+				Ranker:        ranker,
+				Timelines:     timelines,
+				Files:         tt.files,
+			}
+			purged, err := p.PurgeDueAccounts(context.Background(), time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PurgeDueAccounts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if purged != tt.wantPurged {
+				t.Errorf("PurgeDueAccounts() = %d, want %d", purged, tt.wantPurged)
+			}
+			if !reflect.DeepEqual(tt.db.deletedUsers, tt.wantDeletedUsers) {
+				t.Errorf("Deleted users %v, want %v", tt.db.deletedUsers, tt.wantDeletedUsers)
+			}
+			if !reflect.DeepEqual(likes.deleted, tt.wantDeletedLikes) {
+				t.Errorf("Cleared like counts %v, want %v", likes.deleted, tt.wantDeletedLikes)
+			}
+			if !reflect.DeepEqual(comments.deleted, tt.wantDeletedComments) {
+				t.Errorf("Cleared comment counts %v, want %v", comments.deleted, tt.wantDeletedComments)
+			}
+			if !reflect.DeepEqual(notifs.deleted, tt.wantDeletedNotifs) {
+				t.Errorf("Cleared notifications %v, want %v", notifs.deleted, tt.wantDeletedNotifs)
+			}
+			if !reflect.DeepEqual(tt.files.deleted, tt.wantDeletedFiles) {
+				t.Errorf("Deleted files %v, want %v", tt.files.deleted, tt.wantDeletedFiles)
+			}
+			if !reflect.DeepEqual(ranker.removed, tt.wantRemovedPosts) {
+				t.Errorf("Removed posts %v from rankings, want %v", ranker.removed, tt.wantRemovedPosts)
+			}
+			if !reflect.DeepEqual(ranker.ranked, tt.wantRankedPosts) {
+				t.Errorf("Ranked posts %v, want %v", ranker.ranked, tt.wantRankedPosts)
+			}
+			if !reflect.DeepEqual(timelines.deleted, tt.wantDeletedUsers) {
+				t.Errorf("Deleted home feeds %v, want %v", timelines.deleted, tt.wantDeletedUsers)
+			}
+			if !reflect.DeepEqual(timelines.removed, tt.wantRemovedFeeds) {
+				t.Errorf("Removed posts %v from home feeds, want %v", timelines.removed, tt.wantRemovedFeeds)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Number of posts ranked at a time while backfilling
+const backfillBatchSize = 500
+
+// Set once every post has been ranked. It is kept with the rankings, so that they are
+// backfilled again if they are lost, such as when Redis is flushed.
+const rankingBackfilledKey = "posts:ranking:backfilled"
+
+// PostIDLister is implemented by database.PostDB
+type PostIDLister interface {
+	GetPostIDs(after uint, limit int) ([]uint, error)
+}
+
+// PostRanker is implemented by controllers.RedisPostRanker
+type PostRanker interface {
+	RankPost(ctx context.Context, postID uint) error
+}
+
+// BackfillMarker is implemented by redis.Client
+type BackfillMarker interface {
+	Exists(ctx context.Context, keys ...string) *redis.IntCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+}
+
+// RankingBackfiller ranks every existing post for the hot and top feeds. Posts are
+// otherwise only ranked when they are created, edited, liked or commented on, so posts
+// from before the rankings were kept would be missing from the feeds.
+type RankingBackfiller struct {
+	Posts  PostIDLister
+	Ranker PostRanker
+	Marker BackfillMarker
+}
+
+// Backfills the rankings once, if they have not been backfilled already
+func (b *RankingBackfiller) Run(ctx context.Context) {
+	ranked, err := b.Backfill(ctx)
+	if err != nil {
+		log.Printf("Backfilling post rankings failed after %d posts: %v", ranked, err)
+	} else if ranked > 0 {
+		log.Printf("Backfilled post rankings of %d posts", ranked)
+	}
+}
+
+// Ranks every post in batches, returning the number of posts ranked. Nothing is ranked
+// if the rankings have already been backfilled. Posts that cannot be ranked are skipped,
+// and the rankings are backfilled again the next time this runs.
+func (b *RankingBackfiller) Backfill(ctx context.Context) (int, error) {
+	done, err := b.Marker.Exists(ctx, rankingBackfilledKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if done > 0 {
+		return 0, nil
+	}
+
+	ranked, failed := 0, 0
+	after := uint(0)
+	for {
+		if ctx.Err() != nil {
+			return ranked, ctx.Err()
+		}
+		postIDs, err := b.Posts.GetPostIDs(after, backfillBatchSize)
+		if err != nil {
+			return ranked, err
+		}
+		for _, postID := range postIDs {
+			if err := b.Ranker.RankPost(ctx, postID); err != nil {
+				log.Printf("Unable to rank post %d: %v", postID, err)
+				failed++
+				continue
+			}
+			ranked++
+		}
+		if len(postIDs) < backfillBatchSize {
+			break
+		}
+		after = postIDs[len(postIDs)-1]
+	}
+	if failed > 0 {
+		return ranked, fmt.Errorf("unable to rank %d posts", failed)
+	}
+	return ranked, b.Marker.Set(ctx, rankingBackfilledKey, time.Now().Unix(), 0).Err()
+}
@@ -0,0 +1,102 @@
+package workers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type testPosts struct {
+	postIDs []uint
+	err     error
+}
+
+func (p *testPosts) GetPostIDs(after uint, limit int) ([]uint, error) {
+	output := []uint{}
+	for _, postID := range p.postIDs {
+		if postID > after && len(output) < limit {
+			output = append(output, postID)
+		}
+	}
+	return output, p.err
+}
+
+type testRanker struct {
+	errs   map[uint]error
+	ranked []uint
+}
+
+func (r *testRanker) RankPost(ctx context.Context, postID uint) error {
+	if err := r.errs[postID]; err != nil {
+		return err
+	}
+	r.ranked = append(r.ranked, postID)
+	return nil
+}
+
+type testBackfillMarker struct {
+	marked bool
+}
+
+func (m *testBackfillMarker) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	cmd := redis.NewIntCmd(ctx)
+	if m.marked {
+		cmd.SetVal(1)
+	}
+	return cmd
+}
+
+func (m *testBackfillMarker) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	m.marked = true
+	return redis.NewStatusResult("OK", nil)
+}
+
+func TestRankingBackfiller_Backfill(t *testing.T) {
+	manyPostIDs := []uint{}
+	for postID := uint(1); postID <= backfillBatchSize+2; postID++ {
+		manyPostIDs = append(manyPostIDs, postID)
+	}
+	tests := []struct {
+		name       string
+		postIDs    []uint
+		rankErrs   map[uint]error
+		marked     bool
+		wantRanked []uint
+		wantErr    bool
+		wantMarked bool
+	}{
+		{"Backfill OK", []uint{1, 2, 3}, nil, false, []uint{1, 2, 3}, false, true},
+		{"Backfill across batches OK", manyPostIDs, nil, false, manyPostIDs, false, true},
+		// Rankings are only backfilled once
+		{"Backfill already done", []uint{1, 2, 3}, nil, true, nil, false, true},
+		// Posts that cannot be ranked are retried in the next backfill
+		{"Backfill cannot rank post", []uint{1, 2, 3}, map[uint]error{2: errTest}, false, []uint{1, 3}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranker := &testRanker{errs: tt.rankErrs}
+			marker := &testBackfillMarker{marked: tt.marked}
+			b := &RankingBackfiller{
+				Posts:  &testPosts{postIDs: tt.postIDs},
+				Ranker: ranker,
+				Marker: marker,
+			}
+			ranked, err := b.Backfill(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Backfill() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ranked != len(tt.wantRanked) {
+				t.Errorf("Backfill() = %d, want %d", ranked, len(tt.wantRanked))
+			}
+			if !reflect.DeepEqual(ranker.ranked, tt.wantRanked) {
+				t.Errorf("Ranked posts %v, want %v", ranker.ranked, tt.wantRanked)
+			}
+			if marker.marked != tt.wantMarked {
+				t.Errorf("Marked as backfilled = %v, want %v", marker.marked, tt.wantMarked)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ryanozx/skillnet/models"
+)
+
+const (
+	// Number of recommendations of each kind stored per user, so that some remain after
+	// the user dismisses a few
+	recommendationsToStore = 20
+	// Number of recommendations of each kind returned to the user
+	recommendationsToReturn = 10
+	// Number of users whose recommendations are recomputed at a time
+	recommendationBatchSize = 100
+)
+
+// RecommendationDBHandler is implemented by database.RecommendationDB
+type RecommendationDBHandler interface {
+	GetRecommendationCandidates(userID string, kind string, limit int) ([]models.RecommendationCandidate, error)
+	GetActiveUserIDs(after string, limit int) ([]string, error)
+	DismissRecommendation(*models.RecommendationDismissal) error
+}
+
+// RecommendationStore is implemented by RedisRecommendations
+type RecommendationStore interface {
+	// Replaces the user's recommendations; they expire after ttl
+	SaveRecommendations(ctx context.Context, userID string, recommendations []models.Recommendation, ttl time.Duration) error
+	// Returns up to limit recommendations of each kind, best first, and whether the
+	// user has recommendations stored at all
+	GetRecommendations(ctx context.Context, userID string, limit int) ([]models.Recommendation, bool, error)
+	RemoveRecommendation(ctx context.Context, userID string, kind string, targetID string) error
+}
+
+// Recommender suggests users, communities and projects to each user, based on the
+// skills they share, the communities they take part in, the posts they like and how
+// popular each suggestion is. Recommendations are recomputed every interval, and for
+// users without any when they ask for them.
+type Recommender struct {
+	DB       RecommendationDBHandler
+	Store    RecommendationStore
+	Interval time.Duration
+}
+
+// Recomputes every user's recommendations every interval until the context is cancelled
+func (r *Recommender) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		if recomputed, err := r.RecomputeAll(ctx); err != nil {
+			log.Printf("Recomputing recommendations failed after %d users: %v", recomputed, err)
+		} else {
+			log.Printf("Recomputed recommendations of %d users", recomputed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recomputes the recommendations of every user not pending deletion. Returns the number
+// of users whose recommendations were recomputed; users whose recommendations could not
+// be recomputed keep their previous ones until they expire.
+func (r *Recommender) RecomputeAll(ctx context.Context) (int, error) {
+	recomputed := 0
+	after := ""
+	for {
+		userIDs, err := r.DB.GetActiveUserIDs(after, recommendationBatchSize)
+		if err != nil {
+			return recomputed, err
+		}
+		for _, userID := range userIDs {
+			if ctx.Err() != nil {
+				return recomputed, ctx.Err()
+			}
+			if _, err := r.Recompute(ctx, userID); err != nil {
+				log.Printf("Unable to recompute recommendations of user %s: %v", userID, err)
+				continue
+			}
+			recomputed++
+		}
+		if len(userIDs) < recommendationBatchSize {
+			return recomputed, nil
+		}
+		after = userIDs[len(userIDs)-1]
+	}
+}
+
+// Computes and stores the user's recommendations, returning them best first within
+// each kind
+func (r *Recommender) Recompute(ctx context.Context, userID string) ([]models.Recommendation, error) {
+	recommendations := []models.Recommendation{}
+	for _, kind := range models.RecommendationKinds {
+		candidates, err := r.DB.GetRecommendationCandidates(userID, kind, recommendationsToStore)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range candidates {
+			recommendations = append(recommendations, models.Recommendation{
+				Kind:     kind,
+				TargetID: candidate.TargetID,
+				Score:    candidate.Score,
+				Reason:   candidate.Reason(),
+			})
+		}
+	}
+	// Recommendations outlive one interval so that they do not expire just before being
+	// recomputed
+	if err := r.Store.SaveRecommendations(ctx, userID, recommendations, 2*r.Interval); err != nil {
+		return nil, err
+	}
+	return recommendations, nil
+}
+
+// Returns the user's recommendations, best first within each kind. Recommendations are
+// computed on the spot for users who have none stored, such as new users.
+func (r *Recommender) GetRecommendations(ctx context.Context, userID string) ([]models.Recommendation, error) {
+	recommendations, found, err := r.Store.GetRecommendations(ctx, userID, recommendationsToReturn)
+	if err != nil || found {
+		return recommendations, err
+	}
+	recommendations, err = r.Recompute(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	output := []models.Recommendation{}
+	perKind := map[string]int{}
+	for _, recommendation := range recommendations {
+		if perKind[recommendation.Kind] < recommendationsToReturn {
+			perKind[recommendation.Kind]++
+			output = append(output, recommendation)
+		}
+	}
+	return output, nil
+}
+
+// Records that the user does not want the recommendation, so that it is left out when
+// recommendations are next computed, and removes it from the stored recommendations
+func (r *Recommender) Dismiss(ctx context.Context, dismissal *models.RecommendationDismissal) error {
+	if err := r.DB.DismissRecommendation(dismissal); err != nil {
+		return err
+	}
+	return r.Store.RemoveRecommendation(ctx, dismissal.UserID, dismissal.Kind, dismissal.TargetID)
+}
+
+// RedisRecommendations stores recommendations in Redis. Each kind of recommendation is
+// a sorted set of target IDs by score, with a hash of the reason for each target. A
+// separate key records when the user's recommendations were computed, so that users
+// with no recommendations of any kind are not recomputed on every request.
+type RedisRecommendations struct {
+	Client *redis.Client
+}
+
+func recommendationsKey(userID string, kind string) string {
+	return "recommendations:" + userID + ":" + kind
+}
+
+func recommendationReasonsKey(userID string, kind string) string {
+	return recommendationsKey(userID, kind) + ":reasons"
+}
+
+func recommendationsComputedKey(userID string) string {
+	return "recommendations:" + userID
+}
+
+func (r *RedisRecommendations) SaveRecommendations(ctx context.Context, userID string, recommendations []models.Recommendation, ttl time.Duration) error {
+	_, err := r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, kind := range models.RecommendationKinds {
+			pipe.Del(ctx, recommendationsKey(userID, kind), recommendationReasonsKey(userID, kind))
+		}
+		for _, recommendation := range recommendations {
+			pipe.ZAdd(ctx, recommendationsKey(userID, recommendation.Kind), redis.Z{Score: recommendation.Score, Member: recommendation.TargetID})
+			pipe.HSet(ctx, recommendationReasonsKey(userID, recommendation.Kind), recommendation.TargetID, recommendation.Reason)
+		}
+		for _, kind := range models.RecommendationKinds {
+			pipe.Expire(ctx, recommendationsKey(userID, kind), ttl)
+			pipe.Expire(ctx, recommendationReasonsKey(userID, kind), ttl)
+		}
+		pipe.Set(ctx, recommendationsComputedKey(userID), time.Now().Unix(), ttl)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisRecommendations) GetRecommendations(ctx context.Context, userID string, limit int) ([]models.Recommendation, bool, error) {
+	computed, err := r.Client.Exists(ctx, recommendationsComputedKey(userID)).Result()
+	if err != nil || computed == 0 {
+		return nil, false, err
+	}
+	scores := map[string]*redis.ZSliceCmd{}
+	reasons := map[string]*redis.MapStringStringCmd{}
+	_, err = r.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, kind := range models.RecommendationKinds {
+			scores[kind] = pipe.ZRevRangeWithScores(ctx, recommendationsKey(userID, kind), 0, int64(limit-1))
+			reasons[kind] = pipe.HGetAll(ctx, recommendationReasonsKey(userID, kind))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	recommendations := []models.Recommendation{}
+	for _, kind := range models.RecommendationKinds {
+		for _, z := range scores[kind].Val() {
+			targetID, _ := z.Member.(string)
+			recommendations = append(recommendations, models.Recommendation{
+				Kind:     kind,
+				TargetID: targetID,
+				Score:    z.Score,
+				Reason:   reasons[kind].Val()[targetID],
+			})
+		}
+	}
+	return recommendations, true, nil
+}
+
+func (r *RedisRecommendations) RemoveRecommendation(ctx context.Context, userID string, kind string, targetID string) error {
+	_, err := r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, recommendationsKey(userID, kind), targetID)
+		pipe.HDel(ctx, recommendationReasonsKey(userID, kind), targetID)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,189 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ryanozx/skillnet/models"
+)
+
+type recommendationTestDB struct {
+	userIDs       []string
+	candidates    map[string][]models.RecommendationCandidate
+	candidatesErr error
+	dismissed     []*models.RecommendationDismissal
+	recomputedFor []string
+}
+
+func (db *recommendationTestDB) GetRecommendationCandidates(userID string, kind string, limit int) ([]models.RecommendationCandidate, error) {
+	if kind == models.RecommendationUser {
+		db.recomputedFor = append(db.recomputedFor, userID)
+	}
+	candidates := db.candidates[kind]
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, db.candidatesErr
+}
+
+func (db *recommendationTestDB) GetActiveUserIDs(after string, limit int) ([]string, error) {
+	userIDs := []string{}
+	for _, userID := range db.userIDs {
+		if userID > after && len(userIDs) < limit {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
+func (db *recommendationTestDB) DismissRecommendation(dismissal *models.RecommendationDismissal) error {
+	db.dismissed = append(db.dismissed, dismissal)
+	return nil
+}
+
+type testRecommendationStore struct {
+	recommendations map[string][]models.Recommendation
+	ttl             time.Duration
+}
+
+func (s *testRecommendationStore) SaveRecommendations(ctx context.Context, userID string, recommendations []models.Recommendation, ttl time.Duration) error {
+	s.recommendations[userID] = recommendations
+	s.ttl = ttl
+	return nil
+}
+
+func (s *testRecommendationStore) GetRecommendations(ctx context.Context, userID string, limit int) ([]models.Recommendation, bool, error) {
+	recommendations, found := s.recommendations[userID]
+	return recommendations, found, nil
+}
+
+func (s *testRecommendationStore) RemoveRecommendation(ctx context.Context, userID string, kind string, targetID string) error {
+	kept := []models.Recommendation{}
+	for _, recommendation := range s.recommendations[userID] {
+		if recommendation.Kind != kind || recommendation.TargetID != targetID {
+			kept = append(kept, recommendation)
+		}
+	}
+	s.recommendations[userID] = kept
+	return nil
+}
+
+func testCandidates(n int) []models.RecommendationCandidate {
+	candidates := []models.RecommendationCandidate{}
+	for i := 0; i < n; i++ {
+		candidates = append(candidates, models.RecommendationCandidate{
+			TargetID:     fmt.Sprint(i),
+			SharedSkills: int64(n - i),
+			Score:        float64(n - i),
+		})
+	}
+	return candidates
+}
+
+func TestRecommender_GetRecommendations(t *testing.T) {
+	stored := []models.Recommendation{{Kind: models.RecommendationProject, TargetID: "1", Reason: models.ReasonPopular}}
+	tests := []struct {
+		name           string
+		stored         []models.Recommendation
+		candidatesErr  error
+		wantRecomputed bool
+		wantPerKind    map[string]int
+		wantErr        bool
+	}{
+		{"Stored recommendations OK", stored, nil, false, map[string]int{models.RecommendationProject: 1}, false},
+		{"New user recommendations computed", nil, nil, true, map[string]int{
+			models.RecommendationUser:      recommendationsToReturn,
+			models.RecommendationCommunity: 3,
+		}, false},
+		{"New user recommendations cannot be computed", nil, errTest, true, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &recommendationTestDB{
+				candidates: map[string][]models.RecommendationCandidate{
+					models.RecommendationUser:      testCandidates(recommendationsToStore + 5),
+					models.RecommendationCommunity: testCandidates(3),
+				},
+				candidatesErr: tt.candidatesErr,
+			}
+			store := &testRecommendationStore{recommendations: map[string][]models.Recommendation{}}
+			if tt.stored != nil {
+				store.recommendations["user1"] = tt.stored
+			}
+			r := &Recommender{DB: db, Store: store, Interval: time.Hour}
+
+			recommendations, err := r.GetRecommendations(context.Background(), "user1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetRecommendations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if recomputed := len(db.recomputedFor) > 0; recomputed != tt.wantRecomputed {
+				t.Errorf("Recomputed = %v, want %v", recomputed, tt.wantRecomputed)
+			}
+			if tt.wantErr {
+				return
+			}
+			perKind := map[string]int{}
+			for _, recommendation := range recommendations {
+				perKind[recommendation.Kind]++
+			}
+			if !reflect.DeepEqual(perKind, tt.wantPerKind) {
+				t.Errorf("Recommendations per kind = %v, want %v", perKind, tt.wantPerKind)
+			}
+			if tt.wantRecomputed {
+				if saved := len(store.recommendations["user1"]); saved != recommendationsToStore+3 {
+					t.Errorf("Stored %d recommendations, want %d", saved, recommendationsToStore+3)
+				}
+				if store.ttl != 2*time.Hour {
+					t.Errorf("Recommendations stored for %v, want %v", store.ttl, 2*time.Hour)
+				}
+				if recommendations[0].TargetID != "0" || recommendations[0].Reason != models.ReasonSharedSkills {
+					t.Errorf("Unexpected best recommendation %+v", recommendations[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRecommender_RecomputeAll(t *testing.T) {
+	userIDs := []string{}
+	for i := 0; i < recommendationBatchSize+5; i++ {
+		userIDs = append(userIDs, fmt.Sprintf("user%03d", i))
+	}
+	db := &recommendationTestDB{userIDs: userIDs}
+	store := &testRecommendationStore{recommendations: map[string][]models.Recommendation{}}
+	r := &Recommender{DB: db, Store: store, Interval: time.Hour}
+
+	recomputed, err := r.RecomputeAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recomputed != len(userIDs) || !reflect.DeepEqual(db.recomputedFor, userIDs) {
+		t.Errorf("Recomputed %d users, want %d", recomputed, len(userIDs))
+	}
+}
+
+func TestRecommender_Dismiss(t *testing.T) {
+	db := &recommendationTestDB{}
+	store := &testRecommendationStore{recommendations: map[string][]models.Recommendation{
+		"user1": {
+			{Kind: models.RecommendationProject, TargetID: "1"},
+			{Kind: models.RecommendationCommunity, TargetID: "1"},
+		},
+	}}
+	r := &Recommender{DB: db, Store: store, Interval: time.Hour}
+	dismissal := &models.RecommendationDismissal{UserID: "user1", Kind: models.RecommendationProject, TargetID: "1"}
+
+	if err := r.Dismiss(context.Background(), dismissal); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.dismissed) != 1 || db.dismissed[0] != dismissal {
+		t.Error("Dismissal was not stored")
+	}
+	expected := []models.Recommendation{{Kind: models.RecommendationCommunity, TargetID: "1"}}
+	if !reflect.DeepEqual(store.recommendations["user1"], expected) {
+		t.Errorf("Stored recommendations %v, want %v", store.recommendations["user1"], expected)
+	}
+}
@@ -0,0 +1,55 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"github.com/ryanozx/skillnet/helpers"
+	"google.golang.org/api/iterator"
+)
+
+var ErrObjectNotExist = errors.New("object does not exist")
+
+// GoogleCloudStorage stores files in Google Cloud Storage
+type GoogleCloudStorage struct {
+	Client *storage.Client
+}
+
+func (g *GoogleCloudStorage) NewObjectWriter(ctx context.Context, bucket string, name string) io.WriteCloser {
+	return g.Client.Bucket(bucket).Object(name).NewWriter(ctx)
+}
+
+// Returns ErrObjectNotExist if there is no such object
+func (g *GoogleCloudStorage) NewObjectReader(ctx context.Context, bucket string, name string) (io.ReadCloser, error) {
+	reader, err := g.Client.Bucket(bucket).Object(name).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrObjectNotExist
+	}
+	return reader, err
+}
+
+// Deletes the user's profile picture and data exports
+func (g *GoogleCloudStorage) DeleteUserFiles(ctx context.Context, userID string) error {
+	err := g.Client.Bucket(helpers.ProfilePictureBucket).Object(helpers.ProfilePictureObjectName(userID)).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return err
+	}
+
+	exports := g.Client.Bucket(helpers.DataExportBucket)
+	objects := exports.Objects(ctx, &storage.Query{Prefix: helpers.DataExportObjectPrefix(userID)})
+	for {
+		attrs, err := objects.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = exports.Object(attrs.Name).Delete(ctx)
+		if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			return err
+		}
+	}
+}
@@ -73,7 +73,7 @@ function Searchbar() {
 
         debounceTimeout = setTimeout(() => {
             axiosInstance
-            .get(process.env.BACKEND_BASE_URL + '/auth/search', { params: { q: value }, withCredentials: true })
+            .get(process.env.BACKEND_BASE_URL + '/auth/search/suggest', { params: { q: value }, withCredentials: true })
             .then((response) => {
                 setDropdownItems(response.data.data);
                 setShowDropdown(value !== '' && dropdownRef.current === document.activeElement);